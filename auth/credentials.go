@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// MinPasswordLength is the length passwords must have at least.
+	MinPasswordLength = 8
+
+	// LoginCodeTTL is how long a login code can be used.
+	LoginCodeTTL = 10 * time.Minute
+
+	// LoginCodeResendAfter is how long a worker waits for another login code.
+	LoginCodeResendAfter = time.Minute
+
+	codeDigits = 6
+)
+
+// HashPassword hashes a password for storage.
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword tells whether a password matches a hash from HashPassword.
+func CheckPassword(hash, password string) bool {
+	return hash != "" && bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// NewLoginCode returns a random numeric login code.
+func NewLoginCode() (string, error) {
+	max := big.NewInt(1)
+	for i := 0; i < codeDigits; i++ {
+		max.Mul(max, big.NewInt(10))
+	}
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", codeDigits, n), nil
+}
+
+// HashLoginCode hashes a login code for storage. Codes live only minutes and
+// allow a few guesses, so a plain hash is enough.
+func HashLoginCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,79 @@
+package auth
+
+// Access roles grant permissions. They are separate from a worker's role,
+// which only describes the machines they drive.
+const (
+	RoleOwner      = "owner"
+	RoleAgronomist = "agronomist"
+	RoleForeman    = "foreman"
+	RoleOperator   = "operator"
+)
+
+// Roles lists the access roles from the most to the least privileged.
+var Roles = []string{RoleOwner, RoleAgronomist, RoleForeman, RoleOperator}
+
+// Permission is something only some access roles may do. Reading fields,
+// schedules and operations is open to every worker.
+type Permission int
+
+const (
+	// ManageWorkers covers creating, updating and deleting workers, inviting
+	// workers of other organizations and granting access roles.
+	ManageWorkers Permission = iota
+
+	// ManageFields covers fields, their boundaries, splits, merges and
+	// imports, and regions.
+	ManageFields
+
+	// ManageSchedules covers creating, updating and deleting schedules.
+	ManageSchedules
+
+	// ManageOperations covers creating, editing, assigning, cancelling and
+	// deleting operations and confirming or dismissing detected ones.
+	ManageOperations
+
+	// WorkAnyOperation covers starting, pausing, completing and rejecting
+	// operations and recording their coverage and tracks on behalf of
+	// others. Every worker may do so for their own operations.
+	WorkAnyOperation
+
+	// ViewReports covers the daily, monthly, yearly and range reports with
+	// the hours worked by everyone, and their exports. They hold no costs;
+	// cost figures need a permission of their own, kept to owners.
+	ViewReports
+
+	// ManageOrganization covers renaming the organization and starting new
+	// ones.
+	ManageOrganization
+)
+
+var policy = map[Permission][]string{
+	ManageWorkers:    {RoleOwner},
+	ManageFields:     {RoleOwner, RoleAgronomist},
+	ManageSchedules:  {RoleOwner, RoleForeman},
+	ManageOperations: {RoleOwner, RoleForeman},
+	WorkAnyOperation: {RoleOwner, RoleForeman},
+	ViewReports:      {RoleOwner, RoleAgronomist, RoleForeman},
+
+	ManageOrganization: {RoleOwner},
+}
+
+// Can reports whether an access role has a permission.
+func Can(role string, p Permission) bool {
+	for _, r := range policy[p] {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidRole reports whether role is one of Roles.
+func ValidRole(role string) bool {
+	for _, r := range Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
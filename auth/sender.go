@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/smtp"
+	"strings"
+)
+
+// Login code channels
+const (
+	ChannelSMS   = "sms"
+	ChannelEmail = "email"
+)
+
+// ErrChannelUnsupported is returned by senders that cannot deliver over a channel.
+var ErrChannelUnsupported = errors.New("channel not supported")
+
+// Sender delivers login codes to workers. Implementations exist for the
+// server log and email; SMS gateways plug in the same way.
+type Sender interface {
+	// SendCode sends code over channel to a phone number or email address.
+	SendCode(channel, to, code string) error
+}
+
+// LogSender writes login codes to the server log instead of sending them,
+// for local development and demos.
+type LogSender struct{}
+
+func (LogSender) SendCode(channel, to, code string) error {
+	log.Printf("Login code for %s (%s): %s", to, channel, code)
+	return nil
+}
+
+// SMTPSender emails login codes through an SMTP server. It cannot send SMS.
+type SMTPSender struct {
+	Addr string // host:port
+	From string
+	Auth smtp.Auth // nil for servers that need no authentication
+}
+
+func (s SMTPSender) SendCode(channel, to, code string) error {
+	if channel != ChannelEmail {
+		return ErrChannelUnsupported
+	}
+	if strings.ContainsAny(to, "\r\n") {
+		return fmt.Errorf("invalid email address %q", to)
+	}
+	msg := "From: " + s.From + "\r\n" +
+		"To: " + to + "\r\n" +
+		"Subject: Your Agroport login code\r\n" +
+		"\r\n" +
+		fmt.Sprintf("Your login code is %s. It expires in %d minutes.\r\n", code, int(LoginCodeTTL.Minutes()))
+	return smtp.SendMail(s.Addr, s.Auth, s.From, []string{to}, []byte(msg))
+}
@@ -0,0 +1,124 @@
+// Package auth issues and checks the credentials workers log in with: bcrypt
+// password hashes, one-time login codes and signed JWT access and refresh
+// tokens. It also holds the policy of what each access role may do.
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// AccessTokenTTL is how long an access token is accepted.
+	AccessTokenTTL = 15 * time.Minute
+
+	// RefreshTokenTTL is how long a refresh token can be traded for new tokens.
+	RefreshTokenTTL = 30 * 24 * time.Hour
+
+	issuer = "agroport"
+)
+
+// Token types, kept in the "token_type" claim so that a refresh token cannot
+// be used as an access token or the other way round.
+const (
+	AccessToken  = "access"
+	RefreshToken = "refresh"
+)
+
+// ErrInvalidToken is returned for tokens that are malformed, forged, expired
+// or of the wrong type.
+var ErrInvalidToken = errors.New("invalid token")
+
+// Claims are the claims of both token types. The subject is the worker ID
+// and "org" the organization they are logged in to; refresh tokens also carry
+// an ID (jti) under which they are stored.
+type Claims struct {
+	jwt.RegisteredClaims
+	TokenType      string `json:"token_type"`
+	OrganizationID int    `json:"org"`
+}
+
+// WorkerID returns the worker the token was issued to.
+func (c *Claims) WorkerID() int {
+	id, _ := strconv.Atoi(c.Subject)
+	return id
+}
+
+// TokenPair is what logging in or refreshing returns to the client.
+type TokenPair struct {
+	AccessToken      string    `json:"access_token"`
+	RefreshToken     string    `json:"refresh_token"`
+	TokenType        string    `json:"token_type"` // always "Bearer"
+	ExpiresAt        time.Time `json:"expires_at"` // of the access token
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+// Tokens signs and verifies tokens with an HMAC secret shared by all replicas.
+type Tokens struct {
+	secret []byte
+}
+
+func NewTokens(secret []byte) *Tokens {
+	return &Tokens{secret: secret}
+}
+
+// NewTokenID returns a random ID for a refresh token.
+func NewTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Issue signs an access token and a refresh token with the given ID for a
+// worker logged in to an organization.
+func (t *Tokens) Issue(workerID, orgID int, refreshID string, now time.Time) (*TokenPair, error) {
+	pair := &TokenPair{
+		TokenType:        "Bearer",
+		ExpiresAt:        now.Add(AccessTokenTTL),
+		RefreshExpiresAt: now.Add(RefreshTokenTTL),
+	}
+	var err error
+	pair.AccessToken, err = t.sign(workerID, orgID, AccessToken, "", now, pair.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	pair.RefreshToken, err = t.sign(workerID, orgID, RefreshToken, refreshID, now, pair.RefreshExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
+func (t *Tokens) sign(workerID, orgID int, tokenType, id string, now, expiresAt time.Time) (string, error) {
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    issuer,
+			Subject:   strconv.Itoa(workerID),
+			ID:        id,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+		TokenType:      tokenType,
+		OrganizationID: orgID,
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(t.secret)
+}
+
+// Parse verifies a token of the given type and returns its claims.
+func (t *Tokens) Parse(token, tokenType string) (*Claims, error) {
+	var claims Claims
+	_, err := jwt.ParseWithClaims(token, &claims, func(*jwt.Token) (interface{}, error) {
+		return t.secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithIssuer(issuer), jwt.WithExpirationRequired())
+	if err != nil || claims.TokenType != tokenType || claims.WorkerID() < 1 || claims.OrganizationID < 1 {
+		return nil, ErrInvalidToken
+	}
+	return &claims, nil
+}
@@ -0,0 +1,237 @@
+package detect
+
+import (
+	"agroport/models"
+	"agroport/spatial"
+	"context"
+	"database/sql"
+	"log"
+	"math"
+	"time"
+)
+
+const (
+	// Actor is recorded in the operation history for proposals confirmed
+	// without a foreman, on tracks uploaded with auto_confirm.
+	Actor = "track-analyzer"
+
+	// batchSize is how many pending tracks are loaded at a time.
+	batchSize = 20
+)
+
+// Store is what the analyzer needs from the storage layer of an organization.
+type Store interface {
+	GetFields() ([]models.Field, error)
+	GetOperations() ([]models.Operation, error)
+	GetPendingMachineTracks(limit int) ([]models.MachineTrack, error)
+	GetOperationProposals(status string) ([]models.OperationProposal, error)
+	SaveTrackAnalysis(trackID int, proposals []models.OperationProposal) error
+	ConfirmOperationProposal(id int, operationType, actor string) (int, error)
+}
+
+// Organizations gives the analyzer the store of every organization.
+type Organizations interface {
+	GetOrganizations() ([]models.Organization, error)
+	ForOrganization(id int) models.Store
+}
+
+// Analyzer analyzes uploaded machine tracks in the background. Every pending
+// track is split into visits to fields, and each visit not already covered
+// by an operation or an earlier proposal becomes a proposal.
+type Analyzer struct {
+	orgs     Organizations
+	interval time.Duration
+	wake     chan struct{}
+}
+
+func NewAnalyzer(orgs Organizations, interval time.Duration) *Analyzer {
+	return &Analyzer{orgs: orgs, interval: interval, wake: make(chan struct{}, 1)}
+}
+
+// Run analyzes pending tracks every interval, and whenever Wake is called,
+// until ctx is done. Tracks left pending by a storage error are retried on
+// the next round.
+func (a *Analyzer) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+	for {
+		if err := a.analyzePending(); err != nil {
+			log.Printf("Track analyzer: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-a.wake:
+		}
+	}
+}
+
+// Wake makes Run look for pending tracks now, e.g. right after an upload.
+func (a *Analyzer) Wake() {
+	select {
+	case a.wake <- struct{}{}:
+	default:
+	}
+}
+
+// analyzePending analyzes the pending tracks of every organization. An
+// organization that fails is logged and retried on the next round, without
+// holding up the others.
+func (a *Analyzer) analyzePending() error {
+	orgs, err := a.orgs.GetOrganizations()
+	if err != nil {
+		return err
+	}
+	for _, org := range orgs {
+		if err := a.analyzeOrganization(a.orgs.ForOrganization(org.ID)); err != nil {
+			log.Printf("Track analyzer: organization %d: %v", org.ID, err)
+		}
+	}
+	return nil
+}
+
+func (a *Analyzer) analyzeOrganization(store Store) error {
+	for {
+		tracks, err := store.GetPendingMachineTracks(batchSize)
+		if err != nil || len(tracks) == 0 {
+			return err
+		}
+
+		fields, err := loadFields(store)
+		if err != nil {
+			return err
+		}
+		operations, err := store.GetOperations()
+		if err != nil {
+			return err
+		}
+		proposals, err := store.GetOperationProposals("")
+		if err != nil {
+			return err
+		}
+
+		for _, track := range tracks {
+			proposed := propose(track, fields, operations, proposals)
+			if err := store.SaveTrackAnalysis(track.ID, proposed); err != nil {
+				if err == sql.ErrNoRows {
+					// Analyzed meanwhile by another replica
+					continue
+				}
+				return err
+			}
+			proposals = append(proposals, proposed...)
+
+			if track.AutoConfirm {
+				confirm(store, proposed)
+			}
+		}
+	}
+}
+
+// loadFields loads the fields with readable boundaries.
+func loadFields(store Store) ([]Field, error) {
+	stored, err := store.GetFields()
+	if err != nil {
+		return nil, err
+	}
+	var fields []Field
+	for _, f := range stored {
+		boundary, err := spatial.ParseBoundary(f.Coordinates)
+		if err != nil {
+			continue
+		}
+		fields = append(fields, Field{ID: f.ID, Area: f.Area, Boundary: boundary})
+	}
+	return fields, nil
+}
+
+// confirm confirms the proposals of an auto_confirm track. Proposals without
+// an operation type stay in the review queue.
+func confirm(store Store, proposals []models.OperationProposal) {
+	for _, p := range proposals {
+		if _, err := store.ConfirmOperationProposal(p.ID, "", Actor); err != nil && err != models.ErrProposalType {
+			log.Printf("Track analyzer: failed to confirm proposal %d: %v", p.ID, err)
+		}
+	}
+}
+
+// propose turns the visits of a track into proposals. Visits overlapping a
+// completed operation or a proposal of the same worker on the same field are
+// skipped; the rest complete an open operation of the worker on the field
+// when there is one.
+func propose(track models.MachineTrack, fields []Field, operations []models.Operation, proposals []models.OperationProposal) []models.OperationProposal {
+	byID := make(map[int]Field, len(fields))
+	for _, f := range fields {
+		byID[f.ID] = f
+	}
+
+	// Open operations already proposed for completion are not offered twice
+	claimed := make(map[int]bool)
+	for _, p := range proposals {
+		if p.OperationID != nil && p.Status == models.ProposalPending {
+			claimed[*p.OperationID] = true
+		}
+	}
+
+	var result []models.OperationProposal
+	for _, v := range Visits(track.Points, fields) {
+		start, end := v.StartTime(), v.EndTime()
+		if recorded(operations, proposals, track.WorkerID, v.FieldID, start, end) {
+			continue
+		}
+
+		p := models.OperationProposal{
+			WorkerID:       track.WorkerID,
+			FieldID:        v.FieldID,
+			Type:           track.OperationType,
+			Points:         v.Points,
+			StartTime:      start,
+			EndTime:        end,
+			DistanceMeters: math.Round(spatial.LengthMeters(Line(v.Points))*10) / 10,
+			WidthMeters:    track.WidthMeters,
+		}
+		if track.WidthMeters != nil {
+			field := byID[v.FieldID]
+			passes := []spatial.Pass{{Line: Line(v.Points), WidthMeters: *track.WidthMeters}}
+			area := math.Min(math.Round(spatial.CoveredAreaDecares(field.Boundary, passes)*100)/100, field.Area)
+			p.CoveredArea = &area
+		}
+
+		for _, o := range operations {
+			if o.WorkerID != track.WorkerID || o.FieldID != v.FieldID || claimed[o.ID] {
+				continue
+			}
+			open := o.Status == models.StatusPlanned || o.Status == models.StatusInProgress || o.Status == models.StatusPaused
+			if open && (o.StartTime == nil || o.StartTime.Before(end)) {
+				id := o.ID
+				p.OperationID = &id
+				if p.Type == "" {
+					p.Type = o.Type
+				}
+				claimed[o.ID] = true
+				break
+			}
+		}
+		result = append(result, p)
+	}
+	return result
+}
+
+// recorded tells whether the worker's work on the field in [start, end] is
+// already known from a completed operation or a proposal that was not dismissed.
+func recorded(operations []models.Operation, proposals []models.OperationProposal, workerID, fieldID int, start, end time.Time) bool {
+	for _, o := range operations {
+		if o.WorkerID == workerID && o.FieldID == fieldID && o.Status == models.StatusCompleted &&
+			o.StartTime != nil && o.EndTime != nil && o.StartTime.Before(end) && o.EndTime.After(start) {
+			return true
+		}
+	}
+	for _, p := range proposals {
+		if p.WorkerID == workerID && p.FieldID == fieldID && p.Status != models.ProposalDismissed &&
+			p.StartTime.Before(end) && p.EndTime.After(start) {
+			return true
+		}
+	}
+	return false
+}
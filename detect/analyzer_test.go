@@ -0,0 +1,158 @@
+package detect
+
+import (
+	"agroport/models"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/paulmach/orb"
+)
+
+func TestPropose(t *testing.T) {
+	at := func(minutes int) *time.Time {
+		t := trackStart.Add(time.Duration(minutes) * time.Minute)
+		return &t
+	}
+	width := 12.0
+	track := models.MachineTrack{
+		WorkerID:      3,
+		OperationType: "plowing",
+		Points:        drive(orb.Point{25.001, 42.001}, leg{orb.Point{25.009, 42.001}, 20}),
+	}
+	untyped := track
+	untyped.OperationType = ""
+	measured := track
+	measured.WidthMeters = &width
+
+	planned := models.Operation{ID: 10, WorkerID: 3, FieldID: 1, Type: "sowing", Status: models.StatusPlanned}
+	proposal := func(status string, operationID *int) models.OperationProposal {
+		return models.OperationProposal{WorkerID: 3, FieldID: 1, StartTime: *at(5), EndTime: *at(15), Status: status, OperationID: operationID}
+	}
+	ten := 10
+
+	tests := []struct {
+		name        string
+		track       models.MachineTrack
+		operations  []models.Operation
+		proposals   []models.OperationProposal
+		proposed    bool
+		operationID int
+		kind        string
+	}{
+		{name: "new work", track: track, proposed: true, kind: "plowing"},
+		{name: "completes the open operation", track: track, operations: []models.Operation{planned}, proposed: true, operationID: 10, kind: "plowing"},
+		{name: "type of the open operation", track: untyped, operations: []models.Operation{planned}, proposed: true, operationID: 10, kind: "sowing"},
+		{name: "started after the visit", track: track, proposed: true, kind: "plowing", operations: []models.Operation{
+			{ID: 10, WorkerID: 3, FieldID: 1, Status: models.StatusPlanned, StartTime: at(30)},
+		}},
+		{name: "another worker's operation", track: track, proposed: true, kind: "plowing", operations: []models.Operation{
+			{ID: 10, WorkerID: 4, FieldID: 1, Status: models.StatusPlanned},
+		}},
+		{name: "already completed", track: track, operations: []models.Operation{
+			{ID: 10, WorkerID: 3, FieldID: 1, Status: models.StatusCompleted, StartTime: at(-30), EndTime: at(5)},
+		}},
+		{name: "completed before the visit", track: track, proposed: true, kind: "plowing", operations: []models.Operation{
+			{ID: 10, WorkerID: 3, FieldID: 1, Status: models.StatusCompleted, StartTime: at(-30), EndTime: at(-1)},
+		}},
+		{name: "already proposed", track: track, proposals: []models.OperationProposal{proposal(models.ProposalPending, nil)}},
+		{name: "proposal dismissed", track: track, proposed: true, kind: "plowing",
+			proposals: []models.OperationProposal{proposal(models.ProposalDismissed, nil)}},
+		{name: "operation claimed by another proposal", track: track, proposed: true, kind: "plowing",
+			operations: []models.Operation{planned},
+			proposals: []models.OperationProposal{{WorkerID: 3, FieldID: 1, StartTime: *at(-60), EndTime: *at(-50),
+				Status: models.ProposalPending, OperationID: &ten}}},
+		{name: "measured", track: measured, proposed: true, kind: "plowing"},
+	}
+	for _, tt := range tests {
+		proposals := propose(tt.track, testFields, tt.operations, tt.proposals)
+		if !tt.proposed {
+			if len(proposals) != 0 {
+				t.Errorf("%s: got %d proposals, want none", tt.name, len(proposals))
+			}
+			continue
+		}
+		if len(proposals) != 1 {
+			t.Errorf("%s: got %d proposals, want 1", tt.name, len(proposals))
+			continue
+		}
+
+		p := proposals[0]
+		operationID := 0
+		if p.OperationID != nil {
+			operationID = *p.OperationID
+		}
+		if p.WorkerID != 3 || p.FieldID != 1 || p.Type != tt.kind || operationID != tt.operationID {
+			t.Errorf("%s: got a %q proposal of worker %d on field %d for operation %d, want a %q one on field 1 for operation %d",
+				tt.name, p.Type, p.WorkerID, p.FieldID, operationID, tt.kind, tt.operationID)
+		}
+		if !p.StartTime.Equal(trackStart) || !p.EndTime.Equal(*at(20)) || p.DistanceMeters < 600 || p.DistanceMeters > 700 {
+			t.Errorf("%s: proposal runs from %s to %s over %g m", tt.name, p.StartTime, p.EndTime, p.DistanceMeters)
+		}
+		switch {
+		case tt.track.WidthMeters == nil && p.CoveredArea != nil:
+			t.Errorf("%s: covered area is %g without a working width", tt.name, *p.CoveredArea)
+		case tt.track.WidthMeters != nil && (p.CoveredArea == nil || *p.CoveredArea < 7 || *p.CoveredArea > 9):
+			t.Errorf("%s: covered area is %v, want about 8 decares", tt.name, p.CoveredArea)
+		}
+	}
+}
+
+// failingOrganizations fails to load the tracks of one organization.
+type failingOrganizations struct {
+	*models.MemoryStore
+	failing int
+}
+
+type failingStore struct{ models.Store }
+
+func (failingStore) GetPendingMachineTracks(limit int) ([]models.MachineTrack, error) {
+	return nil, errors.New("connection reset")
+}
+
+func (o failingOrganizations) ForOrganization(id int) models.Store {
+	if id == o.failing {
+		return failingStore{o.MemoryStore.ForOrganization(id)}
+	}
+	return o.MemoryStore.ForOrganization(id)
+}
+
+func TestAnalyzeEveryOrganization(t *testing.T) {
+	memory := models.NewMemoryStore()
+	first, second := &models.Organization{Name: "Alpha"}, &models.Organization{Name: "Beta"}
+	if err := memory.Setup(first, &models.Worker{Name: "Ann", Role: "manager", Email: "ann@example.com", AccessRole: "owner"}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := memory.CreateOrganization(second); err != nil {
+		t.Fatal(err)
+	}
+
+	store := memory.ForOrganization(second.ID)
+	worker := &models.Worker{Name: "Ivan", Role: "tractor_driver", Email: "ivan@example.com", AccessRole: "operator"}
+	if err := store.CreateWorker(worker); err != nil {
+		t.Fatal(err)
+	}
+	boundary, _ := json.Marshal(map[string]interface{}{"type": "MultiPolygon", "coordinates": testFields[0].Boundary})
+	field := &models.Field{Name: "North", Coordinates: boundary, Area: testFields[0].Area}
+	if err := store.CreateField(field); err != nil {
+		t.Fatal(err)
+	}
+	points := drive(orb.Point{25.001, 42.001}, leg{orb.Point{25.009, 42.001}, 20})
+	track := &models.MachineTrack{WorkerID: worker.ID, Points: points, StartedAt: points[0].Time, EndedAt: points[len(points)-1].Time}
+	if err := store.CreateMachineTrack(track); err != nil {
+		t.Fatal(err)
+	}
+
+	analyzer := NewAnalyzer(failingOrganizations{memory, first.ID}, time.Hour)
+	if err := analyzer.analyzePending(); err != nil {
+		t.Fatal(err)
+	}
+	proposals, err := store.GetOperationProposals("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(proposals) != 1 || proposals[0].FieldID != field.ID {
+		t.Errorf("got proposals %+v, want one on field %d", proposals, field.ID)
+	}
+}
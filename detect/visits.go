@@ -0,0 +1,123 @@
+// Package detect finds the fields a machine worked from its GPS track and,
+// in the background, proposes operations for them that workers forgot to
+// start or complete in the app.
+package detect
+
+import (
+	"agroport/models"
+	"agroport/spatial"
+	"time"
+
+	"github.com/paulmach/orb"
+)
+
+const (
+	// MinWorkDuration is how long a machine must stay on a field for it to
+	// count as worked rather than driven across.
+	MinWorkDuration = 5 * time.Minute
+
+	// MinWorkDistanceMeters keeps a machine parked on a field, e.g. over
+	// lunch, from counting as work.
+	MinWorkDistanceMeters = 300
+
+	// MaxVisitGap joins stays on the same field separated by a short
+	// excursion, like turning on a headland outside the boundary or a GPS
+	// dropout. Longer breaks start a new visit.
+	MaxVisitGap = 10 * time.Minute
+)
+
+// Field is a field with its parsed boundary.
+type Field struct {
+	ID       int
+	Area     float64
+	Boundary orb.MultiPolygon
+}
+
+// Visit is a stretch of a track spent working one field.
+type Visit struct {
+	FieldID int
+	Points  []models.TrackPoint
+}
+
+func (v Visit) StartTime() time.Time { return v.Points[0].Time }
+
+func (v Visit) EndTime() time.Time { return v.Points[len(v.Points)-1].Time }
+
+// Line returns the points of a track as a line.
+func Line(points []models.TrackPoint) orb.LineString {
+	line := make(orb.LineString, len(points))
+	for i, p := range points {
+		line[i] = orb.Point{p.Lon, p.Lat}
+	}
+	return line
+}
+
+// Visits splits a track, sorted by time, into the visits that worked a
+// field: at least MinWorkDuration on it, driving MinWorkDistanceMeters or
+// more. Stays on a field broken by less than MaxVisitGap are joined, even
+// across a short pass over a neighbouring field.
+func Visits(points []models.TrackPoint, fields []Field) []Visit {
+	bounds := make([]orb.Bound, len(fields))
+	for i, f := range fields {
+		bounds[i] = f.Boundary.Bound()
+	}
+	fieldAt := func(p models.TrackPoint) int {
+		point := orb.Point{p.Lon, p.Lat}
+		for i, f := range fields {
+			if bounds[i].Contains(point) && spatial.Contains(f.Boundary, point) {
+				return f.ID
+			}
+		}
+		return 0
+	}
+
+	// A run is a sequence of consecutive points inside one field
+	type run struct{ fieldID, first, last int }
+	duration := func(r run) time.Duration { return points[r.last].Time.Sub(points[r.first].Time) }
+
+	var runs []run
+	for i, p := range points {
+		id := fieldAt(p)
+		if id == 0 {
+			continue
+		}
+		if n := len(runs); n > 0 && runs[n-1].fieldID == id && runs[n-1].last == i-1 &&
+			p.Time.Sub(points[i-1].Time) <= MaxVisitGap {
+			runs[n-1].last = i
+			continue
+		}
+		runs = append(runs, run{fieldID: id, first: i, last: i})
+	}
+
+	var joined []run
+	for _, r := range runs {
+		merged := false
+		for j := len(joined) - 1; j >= 0; j-- {
+			prev := joined[j]
+			if prev.fieldID == r.fieldID {
+				if points[r.first].Time.Sub(points[prev.last].Time) <= MaxVisitGap {
+					joined[j].last = r.last
+					joined = joined[:j+1]
+					merged = true
+				}
+				break
+			}
+			// Only short stays elsewhere may be skipped over
+			if duration(prev) >= MinWorkDuration {
+				break
+			}
+		}
+		if !merged {
+			joined = append(joined, r)
+		}
+	}
+
+	var visits []Visit
+	for _, r := range joined {
+		v := Visit{FieldID: r.fieldID, Points: points[r.first : r.last+1]}
+		if duration(r) >= MinWorkDuration && spatial.LengthMeters(Line(v.Points)) >= MinWorkDistanceMeters {
+			visits = append(visits, v)
+		}
+	}
+	return visits
+}
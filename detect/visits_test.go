@@ -0,0 +1,103 @@
+package detect
+
+import (
+	"agroport/models"
+	"agroport/spatial"
+	"testing"
+	"time"
+
+	"github.com/paulmach/orb"
+)
+
+var trackStart = time.Date(2026, 5, 4, 8, 0, 0, 0, time.UTC)
+
+func square(id int, lon, lat, size float64) Field {
+	boundary := orb.MultiPolygon{{{{lon, lat}, {lon + size, lat}, {lon + size, lat + size}, {lon, lat + size}, {lon, lat}}}}
+	return Field{ID: id, Area: spatial.AreaDecares(boundary), Boundary: boundary}
+}
+
+// Two fields of about 800 by 1100 m side by side, 800 m apart
+var testFields = []Field{square(1, 25, 42, 0.01), square(2, 25.02, 42, 0.01)}
+
+// leg is a straight drive to a point taking the given minutes.
+type leg struct {
+	to      orb.Point
+	minutes int
+}
+
+// drive records a fix every 30 seconds along the legs, starting at from.
+func drive(from orb.Point, legs ...leg) []models.TrackPoint {
+	points := []models.TrackPoint{{Lon: from[0], Lat: from[1], Time: trackStart}}
+	for _, l := range legs {
+		steps := l.minutes * 2
+		for i := 1; i <= steps; i++ {
+			f := float64(i) / float64(steps)
+			points = append(points, models.TrackPoint{
+				Lon:  from[0] + (l.to[0]-from[0])*f,
+				Lat:  from[1] + (l.to[1]-from[1])*f,
+				Time: points[len(points)-1].Time.Add(30 * time.Second),
+			})
+		}
+		from = l.to
+	}
+	return points
+}
+
+func TestVisits(t *testing.T) {
+	west, east := orb.Point{25.001, 42.001}, orb.Point{25.009, 42.001}
+	eastNorth, westNorth := orb.Point{25.009, 42.002}, orb.Point{25.001, 42.002}
+	between := orb.Point{25.015, 42.001}
+	neighbour, neighbourEast := orb.Point{25.021, 42.001}, orb.Point{25.029, 42.001}
+
+	type visit struct {
+		fieldID          int
+		startMin, endMin int // minutes into the track
+	}
+	tests := []struct {
+		name   string
+		points []models.TrackPoint
+		want   []visit
+	}{
+		{"worked", drive(west, leg{east, 20}), []visit{{1, 0, 20}}},
+		{"too short", drive(west, leg{east, 3}), nil},
+		{"parked", drive(west, leg{orb.Point{25.0011, 42.001}, 20}), nil},
+		{"outside every field", drive(between, leg{orb.Point{25.016, 42.009}, 20}), nil},
+		{
+			"headland turn",
+			drive(west, leg{east, 10}, leg{orb.Point{25.012, 42.0015}, 1}, leg{eastNorth, 1}, leg{westNorth, 10}),
+			[]visit{{1, 0, 22}},
+		},
+		{
+			"long break",
+			drive(west, leg{east, 10}, leg{between, 1}, leg{between, 15}, leg{eastNorth, 1}, leg{westNorth, 10}),
+			[]visit{{1, 0, 10}, {1, 27, 37}},
+		},
+		{
+			"two fields",
+			drive(west, leg{east, 10}, leg{neighbour, 1}, leg{neighbourEast, 10}),
+			[]visit{{1, 0, 10}, {2, 11, 21}},
+		},
+		{
+			"short pass over the neighbour",
+			drive(west, leg{east, 10}, leg{neighbour, 1}, leg{orb.Point{25.025, 42.001}, 2}, leg{eastNorth, 1}, leg{westNorth, 10}),
+			[]visit{{1, 0, 24}},
+		},
+	}
+	for _, tt := range tests {
+		visits := Visits(tt.points, testFields)
+		if len(visits) != len(tt.want) {
+			t.Errorf("%s: got %d visits, want %d", tt.name, len(visits), len(tt.want))
+			continue
+		}
+		for i, v := range visits {
+			w := tt.want[i]
+			start := trackStart.Add(time.Duration(w.startMin) * time.Minute)
+			end := trackStart.Add(time.Duration(w.endMin) * time.Minute)
+			if v.FieldID != w.fieldID || !v.StartTime().Equal(start) || !v.EndTime().Equal(end) {
+				t.Errorf("%s: visit %d is field %d from %s to %s, want field %d from %s to %s", tt.name, i+1,
+					v.FieldID, v.StartTime().Format("15:04:05"), v.EndTime().Format("15:04:05"),
+					w.fieldID, start.Format("15:04:05"), end.Format("15:04:05"))
+			}
+		}
+	}
+}
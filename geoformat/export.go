@@ -0,0 +1,138 @@
+package geoformat
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+)
+
+// ExportFeature is a polygon with its attributes, written by WriteGeoJSON and
+// WriteKML. Property values are strings, numbers, times or nil.
+type ExportFeature struct {
+	Geometry   orb.MultiPolygon
+	Properties map[string]interface{}
+}
+
+// exportGeometry writes single polygons as a Polygon, which GIS tools style
+// the same but some older ones require.
+func exportGeometry(boundary orb.MultiPolygon) orb.Geometry {
+	if len(boundary) == 1 {
+		return boundary[0]
+	}
+	return boundary
+}
+
+// WriteGeoJSON writes the features as a FeatureCollection. Times are written
+// in RFC 3339.
+func WriteGeoJSON(w io.Writer, features []ExportFeature) error {
+	collection := geojson.NewFeatureCollection()
+	for _, f := range features {
+		feature := geojson.NewFeature(exportGeometry(f.Geometry))
+		for key, value := range f.Properties {
+			if t, ok := value.(time.Time); ok {
+				value = t.Format(time.RFC3339)
+			}
+			feature.Properties[key] = value
+		}
+		collection.Append(feature)
+	}
+
+	data, err := collection.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// WriteKML writes the features as KML placemarks in one document. The
+// nameProperty becomes the placemark name; every property, including that
+// one, is also written as ExtendedData so it can be styled on in GIS tools.
+func WriteKML(w io.Writer, documentName, nameProperty string, features []ExportFeature) error {
+	type kmlData struct {
+		Name  string `xml:"name,attr"`
+		Value string `xml:"value"`
+	}
+	type kmlRing struct {
+		Coordinates string `xml:"LinearRing>coordinates"`
+	}
+	type kmlPolygon struct {
+		Outer kmlRing   `xml:"outerBoundaryIs"`
+		Inner []kmlRing `xml:"innerBoundaryIs"`
+	}
+	type kmlPlacemark struct {
+		Name     string       `xml:"name"`
+		Data     []kmlData    `xml:"ExtendedData>Data"`
+		Polygons []kmlPolygon `xml:"MultiGeometry>Polygon"`
+	}
+	type kmlDocument struct {
+		XMLName    xml.Name       `xml:"http://www.opengis.net/kml/2.2 kml"`
+		Name       string         `xml:"Document>name"`
+		Placemarks []kmlPlacemark `xml:"Document>Placemark"`
+	}
+
+	doc := kmlDocument{Name: documentName}
+	for _, f := range features {
+		placemark := kmlPlacemark{Name: kmlValue(f.Properties[nameProperty])}
+
+		keys := make([]string, 0, len(f.Properties))
+		for key := range f.Properties {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if f.Properties[key] != nil {
+				placemark.Data = append(placemark.Data, kmlData{Name: key, Value: kmlValue(f.Properties[key])})
+			}
+		}
+
+		for _, polygon := range f.Geometry {
+			var p kmlPolygon
+			for i, ring := range polygon {
+				if i == 0 {
+					p.Outer = kmlRing{Coordinates: kmlCoordinates(ring)}
+				} else {
+					p.Inner = append(p.Inner, kmlRing{Coordinates: kmlCoordinates(ring)})
+				}
+			}
+			placemark.Polygons = append(placemark.Polygons, p)
+		}
+		doc.Placemarks = append(doc.Placemarks, placemark)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	return encoder.Encode(doc)
+}
+
+func kmlValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	}
+	return fmt.Sprint(value)
+}
+
+func kmlCoordinates(ring orb.Ring) string {
+	positions := make([]string, len(ring))
+	for i, p := range ring {
+		positions[i] = strconv.FormatFloat(p[0], 'f', -1, 64) + "," + strconv.FormatFloat(p[1], 'f', -1, 64)
+	}
+	return strings.Join(positions, " ")
+}
@@ -0,0 +1,82 @@
+// Package geoformat reads parcels from the file formats they are exchanged
+// in: GeoJSON, KML/KMZ from Google Earth and zipped ESRI Shapefiles from the
+// land registry (IACS/LPIS). It writes GeoJSON and KML for GIS tools.
+package geoformat
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Supported import formats
+const (
+	GeoJSON   = "geojson"
+	KML       = "kml"
+	KMZ       = "kmz"
+	Shapefile = "shapefile"
+)
+
+// Feature is a single parcel read from a file. Geometry is a GeoJSON Polygon
+// or MultiPolygon. Err is set when the feature itself could not be read, e.g.
+// because it is a point; the other features of the file are still returned.
+type Feature struct {
+	Properties map[string]string
+	Geometry   json.RawMessage
+	Err        error
+}
+
+// Read parses the contents of a file in the given format.
+func Read(format string, data []byte) ([]Feature, error) {
+	switch format {
+	case GeoJSON:
+		return ReadGeoJSON(data)
+	case KML:
+		return ReadKML(data)
+	case KMZ:
+		return ReadKMZ(data)
+	case Shapefile:
+		return ReadShapefile(data)
+	}
+	return nil, fmt.Errorf("unsupported format %q, use %s, %s, %s or %s", format, GeoJSON, KML, KMZ, Shapefile)
+}
+
+// DetectFormat guesses the format of an uploaded file from its name or content type.
+func DetectFormat(filename, contentType string) string {
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".geojson", ".json":
+		return GeoJSON
+	case ".kml":
+		return KML
+	case ".kmz":
+		return KMZ
+	case ".zip":
+		return Shapefile
+	}
+
+	switch strings.TrimSpace(strings.Split(contentType, ";")[0]) {
+	case "application/geo+json", "application/json":
+		return GeoJSON
+	case "application/vnd.google-earth.kml+xml":
+		return KML
+	case "application/vnd.google-earth.kmz":
+		return KMZ
+	case "application/zip", "application/x-zip-compressed":
+		return Shapefile
+	}
+	return ""
+}
+
+// polygonGeometry encodes polygons given as rings of [lon, lat] positions as a
+// GeoJSON Polygon, or a MultiPolygon when there is more than one.
+func polygonGeometry(polygons [][][][]float64) json.RawMessage {
+	var geometry interface{}
+	if len(polygons) == 1 {
+		geometry = map[string]interface{}{"type": "Polygon", "coordinates": polygons[0]}
+	} else {
+		geometry = map[string]interface{}{"type": "MultiPolygon", "coordinates": polygons}
+	}
+	raw, _ := json.Marshal(geometry)
+	return raw
+}
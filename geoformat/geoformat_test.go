@@ -0,0 +1,108 @@
+package geoformat
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		filename, contentType string
+		want                  string
+	}{
+		{"parcels.geojson", "", GeoJSON},
+		{"parcels.JSON", "application/octet-stream", GeoJSON},
+		{"parcels.kml", "", KML},
+		{"parcels.kmz", "", KMZ},
+		{"LPIS_2026.zip", "", Shapefile},
+		{"", "application/geo+json; charset=utf-8", GeoJSON},
+		{"", "application/vnd.google-earth.kml+xml", KML},
+		{"", "application/vnd.google-earth.kmz", KMZ},
+		{"", "application/x-zip-compressed", Shapefile},
+		{"parcels.shp", "", ""},
+		{"", "text/plain", ""},
+	}
+	for _, tt := range tests {
+		if got := DetectFormat(tt.filename, tt.contentType); got != tt.want {
+			t.Errorf("DetectFormat(%q, %q) = %q, want %q", tt.filename, tt.contentType, got, tt.want)
+		}
+	}
+}
+
+func TestReadGeoJSON(t *testing.T) {
+	polygon := `{"type":"Polygon","coordinates":[[[25,42],[25.01,42],[25.01,42.01],[25,42]]]}`
+	tests := []struct {
+		name   string
+		data   string
+		errs   []string // per feature, empty for none
+		props  map[string]string
+		failed string
+	}{
+		{
+			name:  "feature collection",
+			data:  `{"type":"FeatureCollection","features":[{"type":"Feature","properties":{"name":"North","area":12.5,"parcel":1234567890123,"crop":null},"geometry":` + polygon + `}]}`,
+			errs:  []string{""},
+			props: map[string]string{"name": "North", "area": "12.5", "parcel": "1234567890123"},
+		},
+		{
+			name:  "single feature",
+			data:  `{"type":"Feature","properties":{"name":"South"},"geometry":` + polygon + `}`,
+			errs:  []string{""},
+			props: map[string]string{"name": "South"},
+		},
+		{
+			name: "invalid features",
+			data: `{"type":"FeatureCollection","features":[
+				{"type":"Feature","properties":{},"geometry":null},
+				{"type":"Feature","properties":{},"geometry":[1]},
+				{"type":"Feature","properties":{},"geometry":{"type":"Point","coordinates":[25,42]}}]}`,
+			errs: []string{"feature has no geometry", "not a GeoJSON geometry object", `geometry type "Point" is not supported`},
+		},
+		{name: "geometry only", data: polygon, failed: `must be a FeatureCollection or a Feature, got "Polygon"`},
+		{name: "not JSON", data: `<kml/>`, failed: "invalid GeoJSON"},
+	}
+	for _, tt := range tests {
+		features, err := Read(GeoJSON, []byte(tt.data))
+		if tt.failed != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.failed) {
+				t.Errorf("%s: got error %v, want %q", tt.name, err, tt.failed)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		checkFeatures(t, tt.name, features, tt.errs)
+		for key, want := range tt.props {
+			if got := features[0].Properties[key]; got != want {
+				t.Errorf("%s: property %s is %q, want %q", tt.name, key, got, want)
+			}
+		}
+		if len(tt.props) > 0 && len(features[0].Properties) != len(tt.props) {
+			t.Errorf("%s: properties are %v, want %v", tt.name, features[0].Properties, tt.props)
+		}
+	}
+
+	if _, err := Read("gml", nil); err == nil {
+		t.Error("reading an unsupported format succeeded")
+	}
+}
+
+// checkFeatures compares the errors of the features read with the wanted
+// ones, an empty string standing for a feature that has a geometry.
+func checkFeatures(t *testing.T, name string, features []Feature, errs []string) {
+	t.Helper()
+	if len(features) != len(errs) {
+		t.Fatalf("%s: got %d features, want %d", name, len(features), len(errs))
+	}
+	for i, f := range features {
+		switch {
+		case errs[i] == "" && f.Err != nil:
+			t.Errorf("%s: feature %d: %v", name, i+1, f.Err)
+		case errs[i] == "" && len(f.Geometry) == 0:
+			t.Errorf("%s: feature %d has no geometry", name, i+1)
+		case errs[i] != "" && (f.Err == nil || !strings.Contains(f.Err.Error(), errs[i])):
+			t.Errorf("%s: feature %d has error %v, want %q", name, i+1, f.Err, errs[i])
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package geoformat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+type geoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Properties map[string]interface{} `json:"properties"`
+	Geometry   json.RawMessage        `json:"geometry"`
+}
+
+// ReadGeoJSON reads a FeatureCollection, or a single Feature, of polygons.
+func ReadGeoJSON(data []byte) ([]Feature, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var doc struct {
+		Type     string           `json:"type"`
+		Features []geoJSONFeature `json:"features"`
+		geoJSONFeature
+	}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid GeoJSON: %v", err)
+	}
+
+	var features []geoJSONFeature
+	switch doc.Type {
+	case "FeatureCollection":
+		features = doc.Features
+	case "Feature":
+		features = []geoJSONFeature{{Type: doc.Type, Properties: doc.geoJSONFeature.Properties, Geometry: doc.geoJSONFeature.Geometry}}
+	default:
+		return nil, fmt.Errorf("GeoJSON must be a FeatureCollection or a Feature, got %q", doc.Type)
+	}
+
+	result := make([]Feature, 0, len(features))
+	for _, f := range features {
+		feature := Feature{Properties: make(map[string]string)}
+		for key, value := range f.Properties {
+			if value == nil {
+				continue
+			}
+			if s, ok := value.(string); ok {
+				feature.Properties[key] = s
+			} else {
+				feature.Properties[key] = fmt.Sprint(value)
+			}
+		}
+
+		var geometry struct {
+			Type string `json:"type"`
+		}
+		switch {
+		case len(f.Geometry) == 0 || string(f.Geometry) == "null":
+			feature.Err = fmt.Errorf("feature has no geometry")
+		case json.Unmarshal(f.Geometry, &geometry) != nil:
+			feature.Err = fmt.Errorf("feature geometry is not a GeoJSON geometry object")
+		case geometry.Type != "Polygon" && geometry.Type != "MultiPolygon":
+			feature.Err = fmt.Errorf("geometry type %q is not supported, only Polygon and MultiPolygon", geometry.Type)
+		default:
+			feature.Geometry = f.Geometry
+		}
+		result = append(result, feature)
+	}
+	return result, nil
+}
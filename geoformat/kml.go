@@ -0,0 +1,180 @@
+package geoformat
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+type kmlPlacemark struct {
+	Name        string `xml:"name"`
+	Description string `xml:"description"`
+	Data        []struct {
+		Name  string `xml:"name,attr"`
+		Value string `xml:"value"`
+	} `xml:"ExtendedData>Data"`
+	SimpleData []struct {
+		Name  string `xml:"name,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"ExtendedData>SchemaData>SimpleData"`
+	Polygons      []kmlPolygon `xml:"Polygon"`
+	MultiPolygons []kmlPolygon `xml:"MultiGeometry>Polygon"`
+	Points        []struct{}   `xml:"Point"`
+	Lines         []struct{}   `xml:"LineString"`
+}
+
+type kmlPolygon struct {
+	Outer string   `xml:"outerBoundaryIs>LinearRing>coordinates"`
+	Inner []string `xml:"innerBoundaryIs>LinearRing>coordinates"`
+}
+
+// ReadKML reads the Placemarks of a KML document, at any folder depth. The
+// name and description of a placemark and its ExtendedData become properties.
+func ReadKML(data []byte) ([]Feature, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	var features []Feature
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid KML: %v", err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "Placemark" {
+			continue
+		}
+		var placemark kmlPlacemark
+		if err := decoder.DecodeElement(&placemark, &start); err != nil {
+			return nil, fmt.Errorf("invalid KML: %v", err)
+		}
+		features = append(features, placemark.feature())
+	}
+	if features == nil {
+		return nil, fmt.Errorf("KML has no placemarks")
+	}
+	return features, nil
+}
+
+// ReadKMZ reads the main KML document of a KMZ archive.
+func ReadKMZ(data []byte) ([]Feature, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid KMZ: %v", err)
+	}
+	// The main document is doc.kml by convention, otherwise the first .kml file
+	var document *zip.File
+	for _, f := range archive.File {
+		if strings.EqualFold(path.Ext(f.Name), ".kml") && (document == nil || path.Base(f.Name) == "doc.kml") {
+			document = f
+		}
+	}
+	if document == nil {
+		return nil, fmt.Errorf("KMZ has no .kml document")
+	}
+	contents, err := readZipFile(document)
+	if err != nil {
+		return nil, err
+	}
+	return ReadKML(contents)
+}
+
+func (p kmlPlacemark) feature() Feature {
+	feature := Feature{Properties: make(map[string]string)}
+	if name := strings.TrimSpace(p.Name); name != "" {
+		feature.Properties["name"] = name
+	}
+	if description := strings.TrimSpace(p.Description); description != "" {
+		feature.Properties["description"] = description
+	}
+	for _, d := range p.Data {
+		feature.Properties[d.Name] = strings.TrimSpace(d.Value)
+	}
+	for _, d := range p.SimpleData {
+		feature.Properties[d.Name] = strings.TrimSpace(d.Value)
+	}
+
+	kmlPolygons := append(p.Polygons, p.MultiPolygons...)
+	if len(kmlPolygons) == 0 {
+		if len(p.Points) > 0 || len(p.Lines) > 0 {
+			feature.Err = fmt.Errorf("placemark is a point or a line, only polygons are supported")
+		} else {
+			feature.Err = fmt.Errorf("placemark has no polygon")
+		}
+		return feature
+	}
+
+	polygons := make([][][][]float64, 0, len(kmlPolygons))
+	for i, kp := range kmlPolygons {
+		rings := make([][][]float64, 0, 1+len(kp.Inner))
+		for j, coordinates := range append([]string{kp.Outer}, kp.Inner...) {
+			ring, err := parseKMLCoordinates(coordinates)
+			if err != nil {
+				feature.Err = fmt.Errorf("polygon %d, ring %d: %v", i+1, j+1, err)
+				return feature
+			}
+			rings = append(rings, ring)
+		}
+		polygons = append(polygons, rings)
+	}
+	feature.Geometry = polygonGeometry(polygons)
+	return feature
+}
+
+// parseKMLCoordinates parses whitespace separated "lon,lat[,alt]" tuples.
+func parseKMLCoordinates(s string) ([][]float64, error) {
+	var positions [][]float64
+	for _, tuple := range strings.Fields(s) {
+		parts := strings.Split(tuple, ",")
+		if len(parts) < 2 {
+			return nil, fmt.Errorf("coordinate %q must be longitude,latitude", tuple)
+		}
+		lon, err := strconv.ParseFloat(parts[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("coordinate %q has an invalid longitude", tuple)
+		}
+		lat, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("coordinate %q has an invalid latitude", tuple)
+		}
+		positions = append(positions, []float64{lon, lat})
+	}
+	if positions == nil {
+		return nil, fmt.Errorf("ring has no coordinates")
+	}
+	return positions, nil
+}
+
+// maxZipFileSize limits the size of a file unpacked from a KMZ or zipped
+// shapefile, whatever the size of the archive.
+const maxZipFileSize = 64 << 20
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	if f.UncompressedSize64 > maxZipFileSize {
+		return nil, zipFileTooLarge(f)
+	}
+	r, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %v", f.Name, err)
+	}
+	defer r.Close()
+	// The size in the archive is not to be trusted
+	contents, err := io.ReadAll(io.LimitReader(r, maxZipFileSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %v", f.Name, err)
+	}
+	if len(contents) > maxZipFileSize {
+		return nil, zipFileTooLarge(f)
+	}
+	return contents, nil
+}
+
+func zipFileTooLarge(f *zip.File) error {
+	return fmt.Errorf("%s is larger than %d MB unpacked", f.Name, maxZipFileSize>>20)
+}
@@ -0,0 +1,157 @@
+package geoformat
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const testKML = `<?xml version="1.0" encoding="UTF-8"?>
+<kml xmlns="http://www.opengis.net/kml/2.2"><Document><Folder><Folder>
+  <Placemark>
+    <name> North </name>
+    <description>Rented</description>
+    <ExtendedData>
+      <Data name="crop_type"><value>wheat</value></Data>
+      <SchemaData><SimpleData name="PARCEL_ID">BG-1234</SimpleData></SchemaData>
+    </ExtendedData>
+    <Polygon>
+      <outerBoundaryIs><LinearRing><coordinates>
+        25,42,0 25.01,42,0 25.01,42.01,0 25,42,0
+      </coordinates></LinearRing></outerBoundaryIs>
+      <innerBoundaryIs><LinearRing><coordinates>
+        25.002,42.002 25.004,42.002 25.004,42.004 25.002,42.002
+      </coordinates></LinearRing></innerBoundaryIs>
+    </Polygon>
+  </Placemark>
+  <Placemark>
+    <name>Two parts</name>
+    <MultiGeometry>
+      <Polygon><outerBoundaryIs><LinearRing><coordinates>25,42 25.01,42 25.01,42.01 25,42</coordinates></LinearRing></outerBoundaryIs></Polygon>
+      <Polygon><outerBoundaryIs><LinearRing><coordinates>26,42 26.01,42 26.01,42.01 26,42</coordinates></LinearRing></outerBoundaryIs></Polygon>
+    </MultiGeometry>
+  </Placemark>
+</Folder></Folder>
+  <Placemark><name>Well</name><Point><coordinates>25,42</coordinates></Point></Placemark>
+  <Placemark><name>Empty</name></Placemark>
+  <Placemark><name>Bad</name><Polygon><outerBoundaryIs><LinearRing><coordinates>25,x 25.01,42</coordinates></LinearRing></outerBoundaryIs></Polygon></Placemark>
+</Document></kml>`
+
+func TestReadKML(t *testing.T) {
+	features, err := Read(KML, []byte(testKML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFeatures(t, "KML", features, []string{
+		"", "", "placemark is a point or a line", "placemark has no polygon", `polygon 1, ring 1: coordinate "25,x" has an invalid latitude`,
+	})
+
+	want := map[string]string{"name": "North", "description": "Rented", "crop_type": "wheat", "PARCEL_ID": "BG-1234"}
+	for key, value := range want {
+		if got := features[0].Properties[key]; got != value {
+			t.Errorf("property %s is %q, want %q", key, got, value)
+		}
+	}
+
+	geometries := []struct {
+		kind  string
+		rings int
+	}{{"Polygon", 2}, {"MultiPolygon", 2}}
+	for i, g := range geometries {
+		var geometry struct {
+			Type        string          `json:"type"`
+			Coordinates json.RawMessage `json:"coordinates"`
+		}
+		if err := json.Unmarshal(features[i].Geometry, &geometry); err != nil {
+			t.Fatal(err)
+		}
+		var parts []json.RawMessage
+		json.Unmarshal(geometry.Coordinates, &parts)
+		if geometry.Type != g.kind || len(parts) != g.rings {
+			t.Errorf("feature %d is a %s of %d, want a %s of %d", i+1, geometry.Type, len(parts), g.kind, g.rings)
+		}
+	}
+}
+
+func TestReadKMLErrors(t *testing.T) {
+	tests := []struct {
+		name, data, err string
+	}{
+		{"no placemarks", `<kml><Document/></kml>`, "KML has no placemarks"},
+		{"not XML", `{"type":"FeatureCollection"}`, "KML has no placemarks"},
+		{"broken XML", `<kml><Placemark><name>x</Placemark></kml>`, "invalid KML"},
+	}
+	for _, tt := range tests {
+		if _, err := ReadKML([]byte(tt.data)); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: got error %v, want %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+// zipFiles builds a zip archive of the given files, in order.
+func zipFiles(t *testing.T, files ...[2]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, f := range files {
+		fw, err := w.Create(f[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write([]byte(f[1]))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadKMZ(t *testing.T) {
+	other := `<kml><Placemark><name>Other</name><Point><coordinates>25,42</coordinates></Point></Placemark></kml>`
+	tests := []struct {
+		name     string
+		data     []byte
+		features int
+		err      string
+	}{
+		{"doc.kml", zipFiles(t, [2]string{"other.kml", other}, [2]string{"files/doc.kml", testKML}), 5, ""},
+		{"first .kml", zipFiles(t, [2]string{"icon.png", "png"}, [2]string{"Parcels.KML", testKML}), 5, ""},
+		{"no document", zipFiles(t, [2]string{"icon.png", "png"}), 0, "KMZ has no .kml document"},
+		{"not a zip", []byte(testKML), 0, "invalid KMZ"},
+	}
+	for _, tt := range tests {
+		features, err := Read(KMZ, tt.data)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: got error %v, want %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(features) != tt.features {
+			t.Errorf("%s: got %d features, want %d", tt.name, len(features), tt.features)
+		}
+	}
+}
+
+func TestReadZipFileLimit(t *testing.T) {
+	huge := strings.Repeat(" ", maxZipFileSize+1)
+	tests := []struct {
+		name   string
+		format string
+		data   []byte
+		err    string
+	}{
+		{"KMZ", KMZ, zipFiles(t, [2]string{"doc.kml", huge}), "doc.kml is larger than 64 MB unpacked"},
+		{"shapefile", Shapefile, zipFiles(t, [2]string{"p.shp", huge}, [2]string{"p.dbf", ""}), "p.shp is larger than 64 MB unpacked"},
+	}
+	for _, tt := range tests {
+		if _, err := Read(tt.format, tt.data); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: got error %v, want %q", tt.name, err, tt.err)
+		}
+	}
+}
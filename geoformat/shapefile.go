@@ -0,0 +1,228 @@
+package geoformat
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/jonas-p/go-shp"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/planar"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// ReadShapefile reads a zipped ESRI Shapefile. The archive must contain one
+// .shp file with its .dbf; a .prj, if present, must describe geographic
+// (longitude/latitude) coordinates and a .cpg sets the attribute encoding.
+func ReadShapefile(data []byte) (features []Feature, err error) {
+	// go-shp trusts the lengths in file headers and panics on truncated files
+	defer func() {
+		if r := recover(); r != nil {
+			features, err = nil, fmt.Errorf("invalid shapefile: %v", r)
+		}
+	}()
+
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid zip archive: %v", err)
+	}
+
+	files := make(map[string]*zip.File)
+	var shapes []string
+	for _, f := range archive.File {
+		ext := strings.ToLower(path.Ext(f.Name))
+		base := strings.TrimSuffix(f.Name, path.Ext(f.Name))
+		if strings.HasPrefix(path.Base(f.Name), ".") || strings.HasPrefix(f.Name, "__MACOSX/") {
+			continue
+		}
+		files[base+ext] = f
+		if ext == ".shp" {
+			shapes = append(shapes, base)
+		}
+	}
+	switch len(shapes) {
+	case 0:
+		return nil, fmt.Errorf("zip archive has no .shp file")
+	case 1:
+	default:
+		return nil, fmt.Errorf("zip archive has %d .shp files, upload one shapefile at a time", len(shapes))
+	}
+	base := shapes[0]
+	if files[base+".dbf"] == nil {
+		return nil, fmt.Errorf("%s.shp has no matching .dbf file", base)
+	}
+
+	if f := files[base+".prj"]; f != nil {
+		prj, err := readZipFile(f)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkProjection(string(prj)); err != nil {
+			return nil, err
+		}
+	}
+
+	var decoder *encoding.Decoder
+	if f := files[base+".cpg"]; f != nil {
+		cpg, err := readZipFile(f)
+		if err != nil {
+			return nil, err
+		}
+		if decoder, err = cpgDecoder(string(cpg)); err != nil {
+			return nil, err
+		}
+	}
+
+	shpFile, err := readZipFile(files[base+".shp"])
+	if err != nil {
+		return nil, err
+	}
+	dbfFile, err := readZipFile(files[base+".dbf"])
+	if err != nil {
+		return nil, err
+	}
+	reader := shp.SequentialReaderFromExt(io.NopCloser(bytes.NewReader(shpFile)), io.NopCloser(bytes.NewReader(dbfFile)))
+	defer reader.Close()
+
+	for reader.Next() {
+		feature := Feature{Properties: make(map[string]string)}
+		for i, field := range reader.Fields() {
+			value := strings.TrimRight(reader.Attribute(i), "\x00 ")
+			if decoder != nil {
+				if decoded, err := decoder.String(value); err == nil {
+					value = decoded
+				}
+			}
+			if value != "" {
+				feature.Properties[field.String()] = value
+			}
+		}
+
+		_, shape := reader.Shape()
+		feature.Geometry, feature.Err = shapeGeometry(shape)
+		features = append(features, feature)
+	}
+	if err := reader.Err(); err != nil {
+		return nil, fmt.Errorf("invalid shapefile: %v", err)
+	}
+	return features, nil
+}
+
+// checkProjection rejects shapefiles in projected coordinates, e.g. UTM or the
+// national grid, since boundaries are stored as longitude/latitude.
+func checkProjection(prj string) error {
+	wkt := strings.TrimSpace(prj)
+	if strings.HasPrefix(strings.ToUpper(wkt), "PROJCS") {
+		name := regexp.MustCompile(`^PROJCS\["([^"]*)"`).FindStringSubmatch(wkt)
+		if name != nil {
+			return fmt.Errorf("shapefile uses the projected coordinate system %q, reproject it to WGS84 (EPSG:4326)", name[1])
+		}
+		return fmt.Errorf("shapefile uses a projected coordinate system, reproject it to WGS84 (EPSG:4326)")
+	}
+	return nil
+}
+
+var cpgAliases = regexp.MustCompile(`^(?:ansi |cp|windows-?)?(125\d|874)$`)
+
+// cpgDecoder returns the decoder for the code page named in a .cpg file, or
+// nil for UTF-8.
+func cpgDecoder(cpg string) (*encoding.Decoder, error) {
+	name := strings.ToLower(strings.TrimSpace(cpg))
+	if m := cpgAliases.FindStringSubmatch(name); m != nil {
+		name = "windows-" + m[1]
+	} else if strings.HasPrefix(name, "8859") {
+		name = "iso-" + strings.Replace(name, "_", "-", 1)
+	}
+	if name == "" || name == "utf-8" || name == "utf8" {
+		return nil, nil
+	}
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported shapefile encoding %q", strings.TrimSpace(cpg))
+	}
+	return enc.NewDecoder(), nil
+}
+
+// shapeGeometry converts a shapefile polygon to GeoJSON. Shapefiles store all
+// rings of a record in one list: outer rings run clockwise and holes
+// counter-clockwise, each hole belonging to the outer ring around it.
+func shapeGeometry(shape shp.Shape) ([]byte, error) {
+	var parts []int32
+	var points []shp.Point
+	switch s := shape.(type) {
+	case *shp.Polygon:
+		parts, points = s.Parts, s.Points
+	case *shp.PolygonZ:
+		parts, points = s.Parts, s.Points
+	case *shp.PolygonM:
+		parts, points = s.Parts, s.Points
+	case *shp.Null:
+		return nil, fmt.Errorf("feature has no geometry")
+	default:
+		return nil, fmt.Errorf("shape type %T is not supported, only polygons", shape)
+	}
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("feature has no geometry")
+	}
+
+	var outers []orb.Ring
+	var holes []orb.Ring
+	for i, start := range parts {
+		end := int32(len(points))
+		if i+1 < len(parts) {
+			end = parts[i+1]
+		}
+		if start < 0 || start > end || end > int32(len(points)) {
+			return nil, fmt.Errorf("ring %d has invalid point offsets", i+1)
+		}
+		ring := make(orb.Ring, 0, end-start)
+		for _, p := range points[start:end] {
+			ring = append(ring, orb.Point{p.X, p.Y})
+		}
+		if ring.Orientation() == orb.CW {
+			outers = append(outers, ring)
+		} else {
+			holes = append(holes, ring)
+		}
+	}
+	if len(outers) == 0 {
+		return nil, fmt.Errorf("polygon has no outer ring (outer rings must run clockwise)")
+	}
+
+	polygons := make([]orb.Polygon, len(outers))
+	for i, outer := range outers {
+		polygons[i] = orb.Polygon{outer}
+	}
+	for i, hole := range holes {
+		owner := -1
+		for j, outer := range outers {
+			if len(outers) == 1 || (len(hole) > 0 && planar.RingContains(outer, hole[0])) {
+				owner = j
+				break
+			}
+		}
+		if owner < 0 {
+			return nil, fmt.Errorf("hole %d lies outside every outer ring", i+1)
+		}
+		polygons[owner] = append(polygons[owner], hole)
+	}
+
+	// GeoJSON rings run the other way round: outer counter-clockwise, holes clockwise
+	coordinates := make([][][][]float64, len(polygons))
+	for i, polygon := range polygons {
+		for _, ring := range polygon {
+			positions := make([][]float64, len(ring))
+			for k := range ring {
+				p := ring[len(ring)-1-k]
+				positions[k] = []float64{p[0], p[1]}
+			}
+			coordinates[i] = append(coordinates[i], positions)
+		}
+	}
+	return polygonGeometry(coordinates), nil
+}
@@ -0,0 +1,142 @@
+package geoformat
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jonas-p/go-shp"
+)
+
+// A clockwise square with a counter-clockwise hole, as shapefiles store them
+var (
+	testOuter = []shp.Point{{X: 25, Y: 42}, {X: 25, Y: 42.01}, {X: 25.01, Y: 42.01}, {X: 25.01, Y: 42}, {X: 25, Y: 42}}
+	testHole  = []shp.Point{{X: 25.002, Y: 42.002}, {X: 25.004, Y: 42.002}, {X: 25.004, Y: 42.004}, {X: 25.002, Y: 42.002}}
+)
+
+// writeShapefile writes polygons with a NAME attribute and returns the .shp,
+// .shx and .dbf files as they go into a zip archive.
+func writeShapefile(t *testing.T, base string, names []string, shapes []shp.Shape) [][2]string {
+	t.Helper()
+	dir := t.TempDir()
+	w, err := shp.Create(filepath.Join(dir, "parcels.shp"), shp.POLYGON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetFields([]shp.Field{shp.StringField("NAME", 20)})
+	for i, shape := range shapes {
+		row := w.Write(shape)
+		w.WriteAttribute(int(row), 0, names[i])
+	}
+	w.Close()
+
+	// go-shp names the attribute file "parcelsdbf", without the dot
+	var files [][2]string
+	for _, ext := range []string{".shp", ".shx", "dbf"} {
+		data, err := os.ReadFile(filepath.Join(dir, "parcels"+ext))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, [2]string{base + "." + strings.TrimPrefix(ext, "."), string(data)})
+	}
+	return files
+}
+
+func polygon(rings ...[]shp.Point) *shp.Polygon {
+	p := shp.Polygon(*shp.NewPolyLine(rings))
+	return &p
+}
+
+// with returns a copy of files with more files added.
+func with(files [][2]string, more ...[2]string) [][2]string {
+	return append(append([][2]string(nil), files...), more...)
+}
+
+func TestReadShapefile(t *testing.T) {
+	valid := writeShapefile(t, "LPIS/parcels", []string{"North", "Flat"},
+		[]shp.Shape{polygon(testOuter, testHole), polygon(testHole)})
+	wgs84 := [2]string{"LPIS/parcels.prj", `GEOGCS["GCS_WGS_1984",DATUM["D_WGS_1984"]]`}
+	utm := [2]string{"LPIS/parcels.prj", `PROJCS["WGS_1984_UTM_Zone_35N",GEOGCS["GCS_WGS_1984"]]`}
+	// The number of parts of the first record follows the file header, the
+	// record header, the shape type and the bounding box
+	corrupt := []byte(valid[0][1])
+	corrupt[100+8+4+32] = 0x7f
+
+	tests := []struct {
+		name  string
+		files [][2]string
+		errs  []string
+		err   string
+	}{
+		{"shapefile", valid, []string{"", "polygon has no outer ring"}, ""},
+		{"with projection and code page", with(valid, wgs84, [2]string{"LPIS/parcels.cpg", "UTF-8"}), []string{"", "no outer ring"}, ""},
+		{"macOS metadata", with(valid, [2]string{"__MACOSX/LPIS/._parcels.shp", "x"}), []string{"", "no outer ring"}, ""},
+		{"projected", with(valid, utm), nil, `projected coordinate system "WGS_1984_UTM_Zone_35N"`},
+		{"unknown code page", with(valid, [2]string{"LPIS/parcels.cpg", "klingon"}), nil, `unsupported shapefile encoding "klingon"`},
+		{"no .shp", valid[2:], nil, "zip archive has no .shp file"},
+		{"no .dbf", valid[:2], nil, "parcels.shp has no matching .dbf file"},
+		{"two shapefiles", with(valid, [2]string{"other.shp", valid[0][1]}), nil, "zip archive has 2 .shp files"},
+		{"truncated", [][2]string{{"p.shp", valid[0][1][:50]}, {"p.dbf", valid[2][1]}}, nil, "invalid shapefile"},
+		{"corrupt", [][2]string{{"p.shp", string(corrupt)}, {"p.dbf", valid[2][1]}}, nil, "invalid shapefile"},
+	}
+	for _, tt := range tests {
+		features, err := Read(Shapefile, zipFiles(t, tt.files...))
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: got error %v, want %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		checkFeatures(t, tt.name, features, tt.errs)
+		if features[0].Properties["NAME"] != "North" {
+			t.Errorf("%s: properties are %v", tt.name, features[0].Properties)
+		}
+	}
+
+	if _, err := Read(Shapefile, []byte("not a zip")); err == nil || !strings.Contains(err.Error(), "invalid zip archive") {
+		t.Errorf("reading a non-zip file gave %v", err)
+	}
+}
+
+func TestShapeGeometry(t *testing.T) {
+	geometry, err := shapeGeometry(polygon(testOuter, testHole))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		Type        string
+		Coordinates [][][]float64
+	}
+	if err := json.Unmarshal(geometry, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Type != "Polygon" || len(decoded.Coordinates) != 2 {
+		t.Fatalf("got %s", geometry)
+	}
+	// GeoJSON outer rings run counter-clockwise, so east comes second
+	if second := decoded.Coordinates[0][1]; second[0] != 25.01 || second[1] != 42 {
+		t.Errorf("outer ring is %v, want it reversed", decoded.Coordinates[0])
+	}
+
+	far := []shp.Point{{X: 30, Y: 40}, {X: 30.001, Y: 40}, {X: 30.001, Y: 40.001}, {X: 30, Y: 40}}
+	other := []shp.Point{{X: 26, Y: 42}, {X: 26, Y: 42.01}, {X: 26.01, Y: 42.01}, {X: 26, Y: 42}}
+	errs := []struct {
+		name  string
+		shape shp.Shape
+		err   string
+	}{
+		{"null", &shp.Null{}, "feature has no geometry"},
+		{"point", &shp.Point{X: 25, Y: 42}, "only polygons"},
+		{"stray hole", polygon(testOuter, other, far), "hole 1 lies outside every outer ring"},
+	}
+	for _, tt := range errs {
+		if _, err := shapeGeometry(tt.shape); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: got error %v, want %q", tt.name, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package geoformat
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Supported GPS track formats
+const (
+	GPX       = "gpx"
+	NMEA      = "nmea"
+	TrackJSON = "json"
+)
+
+// TrackPoint is a timestamped GPS fix.
+type TrackPoint struct {
+	Lon  float64   `json:"lon"`
+	Lat  float64   `json:"lat"`
+	Time time.Time `json:"time"`
+}
+
+// ReadTrack parses a GPS track. An empty format is detected from the content.
+// Points are returned in time order; a track needs at least two.
+func ReadTrack(format string, data []byte) ([]TrackPoint, error) {
+	if format == "" {
+		format = DetectTrackFormat(data)
+	}
+
+	var points []TrackPoint
+	var err error
+	switch format {
+	case GPX:
+		points, err = readGPX(data)
+	case NMEA:
+		points, err = readNMEA(data)
+	case TrackJSON:
+		points, err = readTrackJSON(data)
+	default:
+		return nil, fmt.Errorf("unsupported track format %q, use %s, %s or %s", format, GPX, NMEA, TrackJSON)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i, p := range points {
+		if math.IsNaN(p.Lon) || p.Lon < -180 || p.Lon > 180 || math.IsNaN(p.Lat) || p.Lat < -90 || p.Lat > 90 {
+			return nil, fmt.Errorf("point %d has invalid coordinates %g, %g", i+1, p.Lon, p.Lat)
+		}
+		if p.Time.IsZero() {
+			return nil, fmt.Errorf("point %d has no time", i+1)
+		}
+	}
+	if len(points) < 2 {
+		return nil, fmt.Errorf("track has %d points, at least 2 are required", len(points))
+	}
+	sort.SliceStable(points, func(i, j int) bool { return points[i].Time.Before(points[j].Time) })
+	return points, nil
+}
+
+// DetectTrackFormat guesses the format of a track from its first character.
+func DetectTrackFormat(data []byte) string {
+	trimmed := bytes.TrimLeft(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")), " \t\r\n")
+	if len(trimmed) == 0 {
+		return ""
+	}
+	switch trimmed[0] {
+	case '<':
+		return GPX
+	case '$':
+		return NMEA
+	case '{', '[':
+		return TrackJSON
+	}
+	return ""
+}
+
+// readGPX reads the trkpt elements of every track segment.
+func readGPX(data []byte) ([]TrackPoint, error) {
+	var points []TrackPoint
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid GPX: %v", err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "trkpt" {
+			continue
+		}
+		var trkpt struct {
+			Lat  float64 `xml:"lat,attr"`
+			Lon  float64 `xml:"lon,attr"`
+			Time string  `xml:"time"`
+		}
+		if err := decoder.DecodeElement(&trkpt, &start); err != nil {
+			return nil, fmt.Errorf("invalid GPX track point %d: %v", len(points)+1, err)
+		}
+		t, err := time.Parse(time.RFC3339, strings.TrimSpace(trkpt.Time))
+		if err != nil {
+			return nil, fmt.Errorf("GPX track point %d has an invalid time %q", len(points)+1, trkpt.Time)
+		}
+		points = append(points, TrackPoint{Lon: trkpt.Lon, Lat: trkpt.Lat, Time: t})
+	}
+	return points, nil
+}
+
+// readNMEA reads the RMC sentences of an NMEA 0183 log, the only ones that
+// carry both the date and the position. Fixes marked void are skipped.
+func readNMEA(data []byte) ([]TrackPoint, error) {
+	var points []TrackPoint
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	line := 0
+	for scanner.Scan() {
+		line++
+		sentence := strings.TrimSpace(scanner.Text())
+		if len(sentence) < 6 || sentence[0] != '$' || sentence[3:6] != "RMC" {
+			continue
+		}
+		if i := strings.IndexByte(sentence, '*'); i >= 0 {
+			if !nmeaChecksumValid(sentence[1:i], sentence[i+1:]) {
+				return nil, fmt.Errorf("NMEA line %d has a bad checksum", line)
+			}
+			sentence = sentence[:i]
+		}
+
+		f := strings.Split(sentence, ",")
+		if len(f) < 10 {
+			return nil, fmt.Errorf("NMEA line %d: RMC sentence has %d fields, expected at least 10", line, len(f))
+		}
+		if f[2] != "A" {
+			continue
+		}
+		lat, err := nmeaCoordinate(f[3], f[4], 2)
+		if err != nil {
+			return nil, fmt.Errorf("NMEA line %d: %v", line, err)
+		}
+		lon, err := nmeaCoordinate(f[5], f[6], 3)
+		if err != nil {
+			return nil, fmt.Errorf("NMEA line %d: %v", line, err)
+		}
+		t, err := time.Parse("020106150405", f[9]+nmeaWholeSeconds(f[1]))
+		if err != nil {
+			return nil, fmt.Errorf("NMEA line %d has an invalid date or time", line)
+		}
+		if frac := strings.IndexByte(f[1], '.'); frac >= 0 {
+			if s, err := strconv.ParseFloat("0"+f[1][frac:], 64); err == nil {
+				t = t.Add(time.Duration(s * float64(time.Second)))
+			}
+		}
+		points = append(points, TrackPoint{Lon: lon, Lat: lat, Time: t})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("invalid NMEA: %v", err)
+	}
+	return points, nil
+}
+
+// nmeaWholeSeconds returns the hhmmss part of an NMEA time.
+func nmeaWholeSeconds(s string) string {
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		s = s[:i]
+	}
+	return s
+}
+
+func nmeaChecksumValid(body, checksum string) bool {
+	want, err := strconv.ParseUint(strings.TrimSpace(checksum), 16, 8)
+	if err != nil {
+		return false
+	}
+	var sum byte
+	for i := 0; i < len(body); i++ {
+		sum ^= body[i]
+	}
+	return uint64(sum) == want
+}
+
+// nmeaCoordinate converts a (d)ddmm.mmmm value with its hemisphere to degrees.
+func nmeaCoordinate(value, hemisphere string, degreeDigits int) (float64, error) {
+	if len(value) < degreeDigits+2 {
+		return 0, fmt.Errorf("invalid coordinate %q", value)
+	}
+	degrees, err := strconv.ParseFloat(value[:degreeDigits], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid coordinate %q", value)
+	}
+	minutes, err := strconv.ParseFloat(value[degreeDigits:], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid coordinate %q", value)
+	}
+	result := degrees + minutes/60
+	switch hemisphere {
+	case "N", "E":
+		return result, nil
+	case "S", "W":
+		return -result, nil
+	}
+	return 0, fmt.Errorf("invalid hemisphere %q", hemisphere)
+}
+
+// readTrackJSON reads {"points": [{"lat", "lon", "time"}, ...]} or just the array.
+func readTrackJSON(data []byte) ([]TrackPoint, error) {
+	var points []TrackPoint
+	trimmed := bytes.TrimLeft(data, " \t\r\n")
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(data, &points); err != nil {
+			return nil, fmt.Errorf("invalid track JSON: %v", err)
+		}
+		return points, nil
+	}
+
+	var stream struct {
+		Points []TrackPoint `json:"points"`
+	}
+	if err := json.Unmarshal(data, &stream); err != nil {
+		return nil, fmt.Errorf("invalid track JSON: %v", err)
+	}
+	return stream.Points, nil
+}
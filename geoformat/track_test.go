@@ -0,0 +1,118 @@
+package geoformat
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// nmea appends the checksum to an NMEA sentence body.
+func nmea(body string) string {
+	var sum byte
+	for i := 0; i < len(body); i++ {
+		sum ^= body[i]
+	}
+	return fmt.Sprintf("$%s*%02X", body, sum)
+}
+
+func TestReadTrack(t *testing.T) {
+	gpx := `<?xml version="1.0"?><gpx><trk><trkseg>
+		<trkpt lat="42.002" lon="25.002"><time>2026-05-04T08:01:00Z</time></trkpt>
+		<trkpt lat="42.001" lon="25.001"><ele>200</ele><time> 2026-05-04T08:00:00Z </time></trkpt>
+	</trkseg><trkseg>
+		<trkpt lat="42.003" lon="25.003"><time>2026-05-04T08:02:00+00:00</time></trkpt>
+	</trkseg></trk></gpx>`
+	rmc := strings.Join([]string{
+		nmea("GPGGA,080000,4200.060,N,02500.060,E,1,08,0.9,200,M,,M,,"),
+		nmea("GPRMC,080000.50,A,4200.060,N,02500.060,E,5.0,90.0,040526,,"),
+		nmea("GPRMC,080030,V,4200.090,N,02500.090,E,5.0,90.0,040526,,"),
+		nmea("GNRMC,080100,A,4200.120,S,02500.120,W,5.0,90.0,040526,,"),
+	}, "\r\n")
+
+	tests := []struct {
+		name   string
+		format string
+		data   string
+		first  TrackPoint
+		points int
+		err    string
+	}{
+		{"GPX", "", gpx, TrackPoint{25.001, 42.001, time.Date(2026, 5, 4, 8, 0, 0, 0, time.UTC)}, 3, ""},
+		{"NMEA", "", rmc, TrackPoint{25.001, 42.001, time.Date(2026, 5, 4, 8, 0, 0, 5e8, time.UTC)}, 2, ""},
+		{"JSON array", "", `[{"lat": 42.001, "lon": 25.001, "time": "2026-05-04T08:00:00Z"}, {"lat": 42.002, "lon": 25.002, "time": "2026-05-04T08:01:00Z"}]`,
+			TrackPoint{25.001, 42.001, time.Date(2026, 5, 4, 8, 0, 0, 0, time.UTC)}, 2, ""},
+		{"JSON points", TrackJSON, ` {"points": [{"lat": 42.002, "lon": 25.002, "time": "2026-05-04T08:01:00Z"}, {"lat": 42.001, "lon": 25.001, "time": "2026-05-04T08:00:00Z"}]}`,
+			TrackPoint{25.001, 42.001, time.Date(2026, 5, 4, 8, 0, 0, 0, time.UTC)}, 2, ""},
+
+		{"unknown", "", `lat,lon,time`, TrackPoint{}, 0, `unsupported track format ""`},
+		{"one point", TrackJSON, `[{"lat": 42, "lon": 25, "time": "2026-05-04T08:00:00Z"}]`, TrackPoint{}, 0, "track has 1 points, at least 2 are required"},
+		{"no time", TrackJSON, `[{"lat": 42, "lon": 25}, {"lat": 42, "lon": 25, "time": "2026-05-04T08:00:00Z"}]`, TrackPoint{}, 0, "point 1 has no time"},
+		{"off the globe", TrackJSON, `[{"lat": 42, "lon": 25, "time": "2026-05-04T08:00:00Z"}, {"lat": 91, "lon": 25, "time": "2026-05-04T08:01:00Z"}]`,
+			TrackPoint{}, 0, "point 2 has invalid coordinates 25, 91"},
+		{"bad JSON", TrackJSON, `[{"lat": "north"}]`, TrackPoint{}, 0, "invalid track JSON"},
+		{"GPX time", GPX, `<gpx><trkpt lat="42" lon="25"><time>8 o'clock</time></trkpt></gpx>`, TrackPoint{}, 0, `GPX track point 1 has an invalid time "8 o'clock"`},
+		{"broken GPX", GPX, `<gpx><trkpt lat="42" lon="25">`, TrackPoint{}, 0, "invalid GPX"},
+		{"NMEA checksum", NMEA, "$GPRMC,080000,A,4200.060,N,02500.060,E,5.0,90.0,040526,,*00", TrackPoint{}, 0, "NMEA line 1 has a bad checksum"},
+		{"NMEA fields", NMEA, nmea("GPRMC,080000,A,4200.060,N"), TrackPoint{}, 0, "RMC sentence has 5 fields, expected at least 10"},
+		{"NMEA hemisphere", NMEA, nmea("GPRMC,080000,A,4200.060,X,02500.060,E,5.0,90.0,040526,,"), TrackPoint{}, 0, `NMEA line 1: invalid hemisphere "X"`},
+		{"NMEA coordinate", NMEA, nmea("GPRMC,080000,A,42,N,02500.060,E,5.0,90.0,040526,,"), TrackPoint{}, 0, `NMEA line 1: invalid coordinate "42"`},
+		{"NMEA date", NMEA, nmea("GPRMC,080000,A,4200.060,N,02500.060,E,5.0,90.0,310226,,"), TrackPoint{}, 0, "NMEA line 1 has an invalid date or time"},
+	}
+	for _, tt := range tests {
+		points, err := ReadTrack(tt.format, []byte(tt.data))
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: got error %v, want %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if len(points) != tt.points {
+			t.Errorf("%s: got %d points, want %d", tt.name, len(points), tt.points)
+			continue
+		}
+		first := points[0]
+		if math.Abs(first.Lon-tt.first.Lon) > 1e-9 || math.Abs(first.Lat-tt.first.Lat) > 1e-9 || !first.Time.Equal(tt.first.Time) {
+			t.Errorf("%s: first point is %+v, want %+v", tt.name, first, tt.first)
+		}
+		for i := 1; i < len(points); i++ {
+			if points[i].Time.Before(points[i-1].Time) {
+				t.Errorf("%s: points are not in time order", tt.name)
+			}
+		}
+	}
+}
+
+func TestReadNMEAHemispheres(t *testing.T) {
+	points, err := ReadTrack(NMEA, []byte(nmea("GPRMC,080000,A,3330.000,S,07030.000,W,0,0,040526,,")+"\n"+
+		nmea("GPRMC,080100,A,3330.000,S,07030.000,W,0,0,040526,,")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if points[0].Lat != -33.5 || points[0].Lon != -70.5 {
+		t.Errorf("got %+v, want 33.5°S 70.5°W", points[0])
+	}
+}
+
+func TestDetectTrackFormat(t *testing.T) {
+	tests := []struct {
+		data, want string
+	}{
+		{"\xef\xbb\xbf<?xml?><gpx/>", GPX},
+		{"\r\n  $GPRMC", NMEA},
+		{`{"points": []}`, TrackJSON},
+		{`[]`, TrackJSON},
+		{"lat,lon", ""},
+		{"  ", ""},
+	}
+	for _, tt := range tests {
+		if got := DetectTrackFormat([]byte(tt.data)); got != tt.want {
+			t.Errorf("DetectTrackFormat(%q) = %q, want %q", tt.data, got, tt.want)
+		}
+	}
+}
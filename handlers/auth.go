@@ -0,0 +1,472 @@
+package handlers
+
+import (
+	"agroport/auth"
+	"agroport/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type contextKey int
+
+const (
+	// workerKey holds the authenticated worker in the request context.
+	workerKey contextKey = iota
+
+	// organizationKey holds the ID of the organization they are logged in to.
+	organizationKey
+)
+
+// RequireAuth lets through only requests with a valid access token in the
+// Authorization header ("Bearer <token>") of a worker that still belongs to
+// the organization the token is for.
+func (h *Handler) RequireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			h.respondWithError(w, http.StatusUnauthorized, "Authentication required")
+			return
+		}
+		claims, err := h.tokens.Parse(token, auth.AccessToken)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			h.respondWithError(w, http.StatusUnauthorized, "Invalid or expired token")
+			return
+		}
+		worker, err := h.store.ForOrganization(claims.OrganizationID).GetWorkerByID(claims.WorkerID())
+		if err != nil {
+			if err == sql.ErrNoRows {
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				h.respondWithError(w, http.StatusUnauthorized, "Invalid or expired token")
+			} else {
+				h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch worker")
+			}
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), workerKey, worker)
+		ctx = context.WithValue(ctx, organizationKey, claims.OrganizationID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// currentWorker returns the worker authenticated by RequireAuth.
+func currentWorker(r *http.Request) *models.Worker {
+	worker, _ := r.Context().Value(workerKey).(*models.Worker)
+	return worker
+}
+
+// currentOrganization returns the organization the worker authenticated by
+// RequireAuth is logged in to.
+func currentOrganization(r *http.Request) int {
+	org, _ := r.Context().Value(organizationKey).(int)
+	return org
+}
+
+// loginResponse is returned by every way of logging in.
+type loginResponse struct {
+	*auth.TokenPair
+	Worker       models.Worker       `json:"worker"`
+	Organization models.Organization `json:"organization"`
+}
+
+// issueTokens logs a worker in to an organization: it stores a new refresh
+// token and responds with it and an access token.
+func (h *Handler) issueTokens(w http.ResponseWriter, worker models.Worker, membership models.Membership, message string) {
+	now := time.Now()
+	refresh := models.RefreshToken{WorkerID: worker.ID, ExpiresAt: now.Add(auth.RefreshTokenTTL)}
+	var err error
+	if refresh.ID, err = auth.NewTokenID(); err == nil {
+		err = h.accounts.CreateRefreshToken(&refresh)
+	}
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to issue tokens")
+		return
+	}
+	h.respondWithTokens(w, worker, membership, refresh, now, message)
+}
+
+func (h *Handler) respondWithTokens(w http.ResponseWriter, worker models.Worker, membership models.Membership,
+	refresh models.RefreshToken, now time.Time, message string) {
+	pair, err := h.tokens.Issue(worker.ID, membership.ID, refresh.ID, now)
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to issue tokens")
+		return
+	}
+	h.respondWithJSON(w, http.StatusOK, SuccessResponse{
+		Message: message,
+		Data:    loginResponse{TokenPair: pair, Worker: worker, Organization: membership.Organization},
+	})
+}
+
+// Login logs a worker in with their email or phone number and password:
+// {"login": "ivan@example.com", "password": "..."}. An "organization_id"
+// picks the organization to log in to; it defaults to the one they joined
+// first.
+func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Login          string `json:"login"`
+		Password       string `json:"password"`
+		OrganizationID int    `json:"organization_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid JSON payload")
+		return
+	}
+	if req.Login == "" || req.Password == "" {
+		h.respondWithError(w, http.StatusBadRequest, "login and password are required")
+		return
+	}
+
+	account, err := h.accounts.GetWorkerAccount(strings.TrimSpace(req.Login))
+	if err != nil && err != sql.ErrNoRows {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to log in")
+		return
+	}
+	if err == sql.ErrNoRows || !auth.CheckPassword(account.PasswordHash, req.Password) {
+		h.respondWithError(w, http.StatusUnauthorized, "Invalid login or password")
+		return
+	}
+
+	h.logIn(w, account.Worker, req.OrganizationID)
+}
+
+// SendLoginCode sends a one-time login code to a worker's phone or email:
+// {"login": "+359888123456", "channel": "sms"}. The channel defaults to sms
+// for workers with a phone number. The response is the same whether or not
+// the worker exists, and 503 when the server has no way to send codes.
+func (h *Handler) SendLoginCode(w http.ResponseWriter, r *http.Request) {
+	if h.codes == nil {
+		h.respondWithError(w, http.StatusServiceUnavailable, "Login codes are disabled; log in with a password")
+		return
+	}
+	var req struct {
+		Login   string `json:"login"`
+		Channel string `json:"channel"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid JSON payload")
+		return
+	}
+	if req.Login == "" {
+		h.respondWithError(w, http.StatusBadRequest, "login is required")
+		return
+	}
+	if req.Channel != "" && req.Channel != auth.ChannelSMS && req.Channel != auth.ChannelEmail {
+		h.respondWithError(w, http.StatusBadRequest, "channel must be sms or email")
+		return
+	}
+	sent := SuccessResponse{Message: "If the account exists, a login code has been sent"}
+
+	account, err := h.accounts.GetWorkerAccount(strings.TrimSpace(req.Login))
+	if err == sql.ErrNoRows {
+		h.respondWithJSON(w, http.StatusAccepted, sent)
+		return
+	}
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to send login code")
+		return
+	}
+
+	channel, to := req.Channel, ""
+	if channel == "" {
+		channel = auth.ChannelSMS
+		if account.Phone == "" {
+			channel = auth.ChannelEmail
+		}
+	}
+	if channel == auth.ChannelSMS {
+		to = account.Phone
+	} else {
+		to = account.Email
+	}
+	if to == "" {
+		h.respondWithJSON(w, http.StatusAccepted, sent)
+		return
+	}
+
+	code, err := auth.NewLoginCode()
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to send login code")
+		return
+	}
+	now := time.Now()
+	err = h.accounts.CreateLoginCode(&models.LoginCode{
+		WorkerID:  account.ID,
+		Channel:   channel,
+		CodeHash:  auth.HashLoginCode(code),
+		ExpiresAt: now.Add(auth.LoginCodeTTL),
+		CreatedAt: now,
+	}, auth.LoginCodeResendAfter)
+	if err == models.ErrLoginCodeTooSoon {
+		h.respondWithJSON(w, http.StatusAccepted, sent)
+		return
+	}
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to send login code")
+		return
+	}
+
+	if err := h.codes.SendCode(channel, to, code); err != nil {
+		if err == auth.ErrChannelUnsupported {
+			h.respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Login codes cannot be sent by %s", channel))
+		} else {
+			h.respondWithError(w, http.StatusBadGateway, "Failed to send login code")
+		}
+		return
+	}
+	h.respondWithJSON(w, http.StatusAccepted, sent)
+}
+
+// VerifyLoginCode logs a worker in with a code from SendLoginCode:
+// {"login": "+359888123456", "code": "123456"}. It takes an
+// "organization_id" like Login.
+func (h *Handler) VerifyLoginCode(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Login          string `json:"login"`
+		Code           string `json:"code"`
+		OrganizationID int    `json:"organization_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid JSON payload")
+		return
+	}
+	if req.Login == "" || req.Code == "" {
+		h.respondWithError(w, http.StatusBadRequest, "login and code are required")
+		return
+	}
+
+	account, err := h.accounts.GetWorkerAccount(strings.TrimSpace(req.Login))
+	if err == nil {
+		err = h.accounts.UseLoginCode(account.ID, auth.HashLoginCode(strings.TrimSpace(req.Code)), time.Now())
+	}
+	if err != nil {
+		if err == sql.ErrNoRows || err == models.ErrLoginCodeInvalid {
+			h.respondWithError(w, http.StatusUnauthorized, "Invalid or expired login code")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to log in")
+		}
+		return
+	}
+
+	h.logIn(w, account.Worker, req.OrganizationID)
+}
+
+// logIn issues tokens for an organization of the worker, see membership.
+func (h *Handler) logIn(w http.ResponseWriter, worker models.Worker, orgID int) {
+	membership, ok := h.membership(w, worker.ID, orgID)
+	if !ok {
+		return
+	}
+	worker.AccessRole = membership.AccessRole
+	h.issueTokens(w, worker, *membership, "Logged in successfully")
+}
+
+// RefreshTokens trades a refresh token for a new access and refresh token:
+// {"refresh_token": "..."}. Each refresh token can be used once.
+func (h *Handler) RefreshTokens(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid JSON payload")
+		return
+	}
+
+	claims, err := h.tokens.Parse(req.RefreshToken, auth.RefreshToken)
+	if err != nil {
+		h.respondWithError(w, http.StatusUnauthorized, "Invalid or expired refresh token")
+		return
+	}
+	worker, err := h.store.ForOrganization(claims.OrganizationID).GetWorkerByID(claims.WorkerID())
+	var org *models.Organization
+	if err == nil {
+		org, err = h.store.GetOrganizationByID(claims.OrganizationID)
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			h.respondWithError(w, http.StatusUnauthorized, "Invalid or expired refresh token")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to refresh tokens")
+		}
+		return
+	}
+
+	now := time.Now()
+	next := models.RefreshToken{WorkerID: worker.ID, ExpiresAt: now.Add(auth.RefreshTokenTTL)}
+	if next.ID, err = auth.NewTokenID(); err == nil {
+		err = h.accounts.RotateRefreshToken(claims.ID, &next, now)
+	}
+	if err != nil {
+		if err == models.ErrRefreshTokenInvalid {
+			h.respondWithError(w, http.StatusUnauthorized, "Invalid or expired refresh token")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to refresh tokens")
+		}
+		return
+	}
+
+	membership := models.Membership{Organization: *org, AccessRole: worker.AccessRole}
+	h.respondWithTokens(w, *worker, membership, next, now, "Tokens refreshed successfully")
+}
+
+// Logout revokes all refresh tokens of the worker a refresh token belongs to,
+// logging them out on every device once their access tokens expire.
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid JSON payload")
+		return
+	}
+
+	claims, err := h.tokens.Parse(req.RefreshToken, auth.RefreshToken)
+	if err != nil {
+		h.respondWithError(w, http.StatusUnauthorized, "Invalid or expired refresh token")
+		return
+	}
+	if err := h.accounts.RevokeRefreshTokens(claims.WorkerID()); err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to log out")
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, SuccessResponse{
+		Message: "Logged out successfully",
+	})
+}
+
+// ChangePassword sets the password of the logged in worker:
+// {"current_password": "...", "password": "..."}. Workers who have only
+// logged in with codes so far have no current password and send a fresh
+// login code instead, {"code": "...", "password": "..."}, so a stolen access
+// token alone cannot take over the account. Other sessions are logged out and
+// new tokens returned for this one.
+func (h *Handler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		CurrentPassword string `json:"current_password"`
+		Code            string `json:"code"`
+		Password        string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid JSON payload")
+		return
+	}
+	if !h.validPassword(w, req.Password) {
+		return
+	}
+
+	account, err := h.accounts.GetWorkerAccountByID(currentWorker(r).ID)
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to change password")
+		return
+	}
+	if account.PasswordHash != "" {
+		if !auth.CheckPassword(account.PasswordHash, req.CurrentPassword) {
+			h.respondWithError(w, http.StatusForbidden, "Current password is wrong")
+			return
+		}
+	} else {
+		if req.Code == "" {
+			h.respondWithError(w, http.StatusForbidden, "A fresh login code is required to set the first password")
+			return
+		}
+		err := h.accounts.UseLoginCode(account.ID, auth.HashLoginCode(strings.TrimSpace(req.Code)), time.Now())
+		if err == models.ErrLoginCodeInvalid {
+			h.respondWithError(w, http.StatusForbidden, "Invalid or expired login code")
+			return
+		}
+		if err != nil {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to change password")
+			return
+		}
+	}
+
+	hash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to change password")
+		return
+	}
+	if err := h.accounts.SetWorkerPassword(account.ID, hash); err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to change password")
+		return
+	}
+	if err := h.accounts.RevokeRefreshTokens(account.ID); err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to change password")
+		return
+	}
+
+	org, err := h.store.GetOrganizationByID(currentOrganization(r))
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch organization")
+		return
+	}
+	worker := *currentWorker(r)
+	h.issueTokens(w, worker, models.Membership{Organization: *org, AccessRole: worker.AccessRole}, "Password changed successfully")
+}
+
+// Setup creates the first organization and its owner, a worker with a
+// password, on a fresh install where nobody could log in yet:
+// {"organization": "...", "name": "...", "role": "...", "email": "...",
+// "password": "..."}. It is refused once there are organizations.
+func (h *Handler) Setup(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		models.Worker
+		Organization string `json:"organization"`
+		Password     string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid JSON payload")
+		return
+	}
+	worker := req.Worker
+	worker.AccessRole = auth.RoleOwner
+	org := models.Organization{Name: strings.TrimSpace(req.Organization)}
+	if org.Name == "" || worker.Name == "" || worker.Role == "" {
+		h.respondWithError(w, http.StatusBadRequest, "Organization, name and role are required")
+		return
+	}
+	if worker.Email == "" && worker.Phone == "" {
+		h.respondWithError(w, http.StatusBadRequest, "Email or phone is required to log in")
+		return
+	}
+	if !h.validPassword(w, req.Password) {
+		return
+	}
+	hash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to set up")
+		return
+	}
+
+	if err := h.store.Setup(&org, &worker, hash); err != nil {
+		if err == models.ErrAlreadySetUp {
+			h.respondWithError(w, http.StatusConflict, "Already set up; log in instead")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to set up")
+		}
+		return
+	}
+
+	h.issueTokens(w, worker, models.Membership{Organization: org, AccessRole: worker.AccessRole}, "Set up successfully")
+}
+
+// validPassword checks the length of a new password.
+func (h *Handler) validPassword(w http.ResponseWriter, password string) bool {
+	if len(password) < auth.MinPasswordLength {
+		h.respondWithError(w, http.StatusBadRequest, fmt.Sprintf("password must be at least %d characters", auth.MinPasswordLength))
+		return false
+	}
+	// bcrypt only looks at the first 72 bytes
+	if len(password) > 72 {
+		h.respondWithError(w, http.StatusBadRequest, "password must be at most 72 bytes")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"agroport/models"
+	"agroport/spatial"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// SetOperationCoverage records how much of its field an operation has covered,
+// either as decares ({"covered_area": 420}) or as the GeoJSON polygon of the
+// covered part ({"coverage": {...}}), whose area is then computed. Sending
+// neither clears the coverage.
+func (h *Handler) SetOperationCoverage(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid operation ID")
+		return
+	}
+	if !h.authorizeOperationWork(w, r, id) {
+		return
+	}
+
+	var req struct {
+		CoveredArea *float64        `json:"covered_area"`
+		Coverage    json.RawMessage `json:"coverage"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid JSON payload")
+		return
+	}
+	if string(req.Coverage) == "null" {
+		req.Coverage = nil
+	}
+
+	operation, err := h.operations.GetOperationByID(id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			h.respondWithError(w, http.StatusNotFound, "Operation not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch operation")
+		}
+		return
+	}
+	if operation.Status == models.StatusCancelled || operation.Status == models.StatusRejected {
+		h.respondWithError(w, http.StatusConflict, fmt.Sprintf("Cannot record coverage of a %s operation", operation.Status))
+		return
+	}
+	field, err := h.fields.GetFieldByID(operation.FieldID)
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch field")
+		return
+	}
+
+	if req.Coverage != nil {
+		coverage, err := spatial.ParseBoundary(req.Coverage)
+		if err != nil {
+			h.respondWithError(w, http.StatusBadRequest, "Invalid coverage: "+err.Error())
+			return
+		}
+		if boundary, err := spatial.ParseBoundary(field.Coordinates); err == nil && !spatial.Within(coverage, boundary) {
+			h.respondWithError(w, http.StatusBadRequest, "Invalid coverage: it extends outside the field")
+			return
+		}
+		area := math.Min(math.Round(spatial.AreaDecares(coverage)*100)/100, field.Area)
+		req.CoveredArea = &area
+	} else if req.CoveredArea != nil {
+		if *req.CoveredArea < 0 || *req.CoveredArea > field.Area {
+			h.respondWithError(w, http.StatusBadRequest,
+				fmt.Sprintf("covered_area must be between 0 and the field area of %g decares", field.Area))
+			return
+		}
+	}
+
+	if err := h.operations.SetOperationCoverage(id, req.CoveredArea, req.Coverage, actorFromRequest(r)); err != nil {
+		if err == sql.ErrNoRows {
+			h.respondWithError(w, http.StatusNotFound, "Operation not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to record coverage")
+		}
+		return
+	}
+
+	operation, err = h.operations.GetOperationByID(id)
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch operation")
+		return
+	}
+	h.respondWithJSON(w, http.StatusOK, SuccessResponse{
+		Message: "Coverage recorded successfully",
+		Data:    operation,
+	})
+}
+
+// GetFieldCoverage reports how much of a field each type of operation has
+// covered. The optional from and to dates (inclusive) limit it to a season.
+func (h *Handler) GetFieldCoverage(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid field ID")
+		return
+	}
+
+	var from, to *time.Time
+	if r.URL.Query().Get("from") != "" || r.URL.Query().Get("to") != "" {
+		start, end, ok := h.dateRangeParams(w, r)
+		if !ok {
+			return
+		}
+		from, to = &start, &end
+	}
+
+	field, err := h.fields.GetFieldByID(id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			h.respondWithError(w, http.StatusNotFound, "Field not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch field")
+		}
+		return
+	}
+	coverage, err := h.fields.GetFieldCoverage(id, from, to)
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to compute field coverage")
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, SuccessResponse{
+		Message: "Field coverage retrieved successfully",
+		Data: map[string]interface{}{
+			"field_id":   field.ID,
+			"field_name": field.Name,
+			"area":       field.Area,
+			"coverage":   coverage,
+		},
+	})
+}
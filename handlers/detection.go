@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"agroport/auth"
+	"agroport/detect"
+	"agroport/models"
+	"agroport/spatial"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// UploadMachineTrack stores the GPS track of a worker's machine (GPX, NMEA or
+// JSON points, like operation tracks) for the track analyzer, which proposes
+// an operation for every field the machine worked. The worker is given as
+// worker_id; type sets the type of the proposed operations and width the
+// working width in metres, used to measure coverage. With auto_confirm=true
+// the proposals are confirmed without the foreman's review, which only those
+// who may confirm them can ask for.
+func (h *Handler) UploadMachineTrack(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	workerID, err := strconv.Atoi(query.Get("worker_id"))
+	if err != nil || workerID < 1 {
+		h.respondWithError(w, http.StatusBadRequest, "worker_id is required")
+		return
+	}
+	operationType := query.Get("type")
+	if len(operationType) > 100 {
+		h.respondWithError(w, http.StatusBadRequest, "type must be at most 100 characters")
+		return
+	}
+	autoConfirm := false
+	if s := query.Get("auto_confirm"); s != "" {
+		if autoConfirm, err = strconv.ParseBool(s); err != nil {
+			h.respondWithError(w, http.StatusBadRequest, "auto_confirm must be true or false")
+			return
+		}
+	}
+	width, ok := h.widthParam(w, r)
+	if !ok {
+		return
+	}
+	points, ok := h.readTrack(w, r)
+	if !ok {
+		return
+	}
+
+	me := currentWorker(r)
+	if workerID != me.ID && !auth.Can(me.AccessRole, auth.WorkAnyOperation) {
+		h.respondWithError(w, http.StatusForbidden, "You can only upload your own tracks")
+		return
+	}
+	if autoConfirm && !auth.Can(me.AccessRole, auth.ManageOperations) {
+		h.respondWithError(w, http.StatusForbidden, "Your access role does not allow confirming proposals")
+		return
+	}
+	if _, err := h.workers.GetWorkerByID(workerID); err != nil {
+		if err == sql.ErrNoRows {
+			h.respondWithError(w, http.StatusNotFound, "Worker not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch worker")
+		}
+		return
+	}
+
+	track := models.MachineTrack{
+		WorkerID:       workerID,
+		OperationType:  operationType,
+		Points:         points,
+		StartedAt:      points[0].Time,
+		EndedAt:        points[len(points)-1].Time,
+		DistanceMeters: math.Round(spatial.LengthMeters(detect.Line(points))*10) / 10,
+		WidthMeters:    width,
+		AutoConfirm:    autoConfirm,
+	}
+	if err := h.detection.CreateMachineTrack(&track); err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to store track")
+		return
+	}
+	h.analyzer.Wake()
+
+	track.Points = nil
+	h.respondWithJSON(w, http.StatusAccepted, SuccessResponse{
+		Message: fmt.Sprintf("Track of %d points queued for analysis", len(points)),
+		Data:    track,
+	})
+}
+
+func (h *Handler) GetMachineTracks(w http.ResponseWriter, r *http.Request) {
+	tracks, err := h.detection.GetMachineTracks()
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch tracks")
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, SuccessResponse{
+		Message: "Tracks retrieved successfully",
+		Data:    tracks,
+	})
+}
+
+// GetMachineTrack returns a machine track with its points and the operations
+// proposed from it.
+func (h *Handler) GetMachineTrack(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid track ID")
+		return
+	}
+
+	track, err := h.detection.GetMachineTrackByID(id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			h.respondWithError(w, http.StatusNotFound, "Track not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch track")
+		}
+		return
+	}
+	all, err := h.detection.GetOperationProposals("")
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch proposals")
+		return
+	}
+	proposals := []models.OperationProposal{}
+	for _, p := range all {
+		if p.TrackID == id {
+			proposals = append(proposals, p)
+		}
+	}
+
+	h.respondWithJSON(w, http.StatusOK, SuccessResponse{
+		Message: "Track retrieved successfully",
+		Data: map[string]interface{}{
+			"track":     track,
+			"proposals": proposals,
+		},
+	})
+}
+
+// GetOperationProposals is the foreman's review queue: the pending proposals,
+// or with status=confirmed|dismissed|all the reviewed ones.
+func (h *Handler) GetOperationProposals(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "":
+		status = models.ProposalPending
+	case "all":
+		status = ""
+	case models.ProposalPending, models.ProposalConfirmed, models.ProposalDismissed:
+	default:
+		h.respondWithError(w, http.StatusBadRequest, "status must be pending, confirmed, dismissed or all")
+		return
+	}
+
+	proposals, err := h.detection.GetOperationProposals(status)
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch proposals")
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, SuccessResponse{
+		Message: "Proposals retrieved successfully",
+		Data:    proposals,
+	})
+}
+
+func (h *Handler) GetOperationProposal(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid proposal ID")
+		return
+	}
+
+	proposal, err := h.detection.GetOperationProposalByID(id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			h.respondWithError(w, http.StatusNotFound, "Proposal not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch proposal")
+		}
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, SuccessResponse{
+		Message: "Proposal retrieved successfully",
+		Data:    proposal,
+	})
+}
+
+// ConfirmOperationProposal accepts a proposal: the open operation it points
+// to is completed with the detected times, or else a completed operation is
+// created. The body may give {"type": "plowing"} for proposals from tracks
+// uploaded without a type.
+func (h *Handler) ConfirmOperationProposal(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid proposal ID")
+		return
+	}
+
+	var req struct {
+		Type string `json:"type"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid JSON payload")
+		return
+	}
+	if len(req.Type) > 100 {
+		h.respondWithError(w, http.StatusBadRequest, "type must be at most 100 characters")
+		return
+	}
+
+	operationID, err := h.detection.ConfirmOperationProposal(id, req.Type, actorFromRequest(r))
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			h.respondWithError(w, http.StatusNotFound, "Proposal not found")
+		case models.ErrProposalReviewed:
+			h.respondWithError(w, http.StatusConflict, "Proposal has already been reviewed")
+		case models.ErrProposalType:
+			h.respondWithError(w, http.StatusBadRequest, "Type is required to confirm this proposal")
+		default:
+			if !h.respondWithConflict(w, err) {
+				h.respondWithError(w, http.StatusInternalServerError, "Failed to confirm proposal")
+			}
+		}
+		return
+	}
+
+	operation, err := h.operations.GetOperationByID(operationID)
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch operation")
+		return
+	}
+	h.respondWithJSON(w, http.StatusOK, SuccessResponse{
+		Message: "Proposal confirmed successfully",
+		Data:    operation,
+	})
+}
+
+func (h *Handler) DismissOperationProposal(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid proposal ID")
+		return
+	}
+
+	if err := h.detection.DismissOperationProposal(id, actorFromRequest(r)); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			h.respondWithError(w, http.StatusNotFound, "Proposal not found")
+		case models.ErrProposalReviewed:
+			h.respondWithError(w, http.StatusConflict, "Proposal has already been reviewed")
+		default:
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to dismiss proposal")
+		}
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, SuccessResponse{
+		Message: "Proposal dismissed successfully",
+	})
+}
@@ -0,0 +1,315 @@
+package handlers
+
+import (
+	"agroport/models"
+	"agroport/render"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+var (
+	workerStatsColumns = []render.Column{
+		{Key: "worker_id", Title: "Worker ID", Kind: render.Int},
+		{Key: "worker_name", Title: "Worker Name", Kind: render.Text},
+		{Key: "operations", Title: "Operations", Kind: render.Int},
+		{Key: "hours_worked", Title: "Hours Worked", Kind: render.Decimal},
+		{Key: "fields_worked", Title: "Fields Worked", Kind: render.Int},
+	}
+	fieldStatsColumns = []render.Column{
+		{Key: "field_id", Title: "Field ID", Kind: render.Int},
+		{Key: "field_name", Title: "Field Name", Kind: render.Text},
+		{Key: "operations", Title: "Operations", Kind: render.Int},
+		{Key: "hours_worked", Title: "Hours Worked", Kind: render.Decimal},
+		{Key: "decares_worked", Title: "Decares Worked", Kind: render.Decimal},
+		{Key: "workers_count", Title: "Workers Count", Kind: render.Int},
+	}
+	regionStatsColumns = []render.Column{
+		{Key: "region_id", Title: "Region ID", Kind: render.Int},
+		{Key: "region_name", Title: "Region Name", Kind: render.Text},
+		{Key: "operations", Title: "Operations", Kind: render.Int},
+		{Key: "hours_worked", Title: "Hours Worked", Kind: render.Decimal},
+		{Key: "decares_worked", Title: "Decares Worked", Kind: render.Decimal},
+		{Key: "fields_worked", Title: "Fields Worked", Kind: render.Int},
+		{Key: "workers_count", Title: "Workers Count", Kind: render.Int},
+	}
+	entryColumns = []render.Column{
+		{Key: "operation_id", Title: "Operation ID", Kind: render.Int},
+		{Key: "date", Title: "Date", Kind: render.Date},
+		{Key: "worker_name", Title: "Worker", Kind: render.Text},
+		{Key: "field_name", Title: "Field", Kind: render.Text},
+		{Key: "type", Title: "Type", Kind: render.Text},
+		{Key: "status", Title: "Status", Kind: render.Text},
+		{Key: "start_time", Title: "Start", Kind: render.DateTime},
+		{Key: "end_time", Title: "End", Kind: render.DateTime},
+		{Key: "hours_worked", Title: "Hours Worked", Kind: render.Decimal},
+	}
+)
+
+// exportRenderer picks the renderer for the "format" query parameter, defaulting to xlsx.
+func (h *Handler) exportRenderer(w http.ResponseWriter, r *http.Request) (render.Renderer, bool) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "xlsx"
+	}
+	renderer, ok := render.ForFormat(format)
+	if !ok {
+		h.respondWithError(w, http.StatusBadRequest,
+			fmt.Sprintf("Invalid format. Use one of: %s", strings.Join(render.Formats(), ", ")))
+		return nil, false
+	}
+	return renderer, true
+}
+
+// writeDocument renders the document as a file download. The optional "table"
+// query parameter limits the export to a single table, e.g. table=worker_stats.
+func (h *Handler) writeDocument(w http.ResponseWriter, r *http.Request, renderer render.Renderer, doc *render.Document, basename string) {
+	if table := r.URL.Query().Get("table"); table != "" {
+		only, ok := doc.Only(table)
+		if !ok {
+			h.respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Unknown table %q", table))
+			return
+		}
+		doc = only
+		basename += "_" + table
+	}
+
+	var buf bytes.Buffer
+	if err := renderer.Render(&buf, doc); err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to render report")
+		return
+	}
+
+	// Set response headers for file download
+	filename := fmt.Sprintf("%s.%s", basename, renderer.Extension())
+	w.Header().Set("Content-Type", renderer.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+	w.Write(buf.Bytes())
+}
+
+func dailyReportDocument(report *models.DailyReport, entries []models.ReportEntry, shapes []render.Shape) *render.Document {
+	const sheet = "Daily Report"
+	doc := &render.Document{
+		Title:    "Daily Report",
+		Subtitle: report.Date.Format("2006-01-02"),
+		Sheet:    sheet,
+		Summary: []render.Metric{
+			{Key: "total_workers", Label: "Total Workers", Kind: render.Int, Value: report.TotalWorkers},
+			{Key: "total_operations", Label: "Total Operations", Kind: render.Int, Value: report.TotalOperations},
+			{Key: "completed_operations", Label: "Completed Operations", Kind: render.Int, Value: report.CompletedOps},
+			{Key: "in_progress_operations", Label: "In Progress Operations", Kind: render.Int, Value: report.InProgressOps},
+		},
+		Shapes: shapes,
+	}
+
+	doc.Tables = append(doc.Tables,
+		operationsByTypeTable(sheet, report.OperationsByType),
+		workerStatsTable(sheet, report.WorkerStats),
+		fieldStatsTable(sheet, report.FieldStats),
+		regionStatsTable(sheet, report.RegionStats),
+		entriesTable(entries),
+	)
+	return doc
+}
+
+// periodReportDocument describes a monthly or yearly export: the summary,
+// daily (and optionally monthly) timelines, every operation, and the
+// operations broken down per worker and per field.
+func periodReportDocument(title string, report *models.PeriodReport, daily, monthly []models.PeriodStats, entries []models.ReportEntry, shapes []render.Shape) *render.Document {
+	const sheet = "Summary"
+	doc := &render.Document{
+		Title:    title,
+		Subtitle: fmt.Sprintf("%s – %s", report.From.Format("2006-01-02"), report.To.AddDate(0, 0, -1).Format("2006-01-02")),
+		Sheet:    sheet,
+		Summary: []render.Metric{
+			{Key: "total_workers", Label: "Total Workers", Kind: render.Int, Value: report.TotalWorkers},
+			{Key: "total_operations", Label: "Total Operations", Kind: render.Int, Value: report.TotalOperations},
+			{Key: "completed_operations", Label: "Completed Operations", Kind: render.Int, Value: report.CompletedOps},
+			{Key: "in_progress_operations", Label: "In Progress Operations", Kind: render.Int, Value: report.InProgressOps},
+			{Key: "hours_worked", Label: "Hours Worked", Kind: render.Decimal, Value: report.HoursWorked},
+		},
+		Shapes: shapes,
+	}
+
+	doc.Tables = append(doc.Tables,
+		operationsByTypeTable(sheet, report.OperationsByType),
+		workerStatsTable(sheet, report.WorkerStats),
+		fieldStatsTable(sheet, report.FieldStats),
+		regionStatsTable(sheet, report.RegionStats),
+		timelineTable("daily_timeline", "Daily Timeline", render.Date, daily),
+	)
+	if len(monthly) > 0 {
+		doc.Tables = append(doc.Tables, timelineTable("monthly_timeline", "Monthly Timeline", render.Month, monthly))
+	}
+	doc.Tables = append(doc.Tables, entriesTable(entries))
+
+	for _, ws := range report.WorkerStats {
+		t := render.Table{
+			Name:      "worker_operations",
+			Title:     "Worker " + ws.WorkerName,
+			Sheet:     "Worker " + ws.WorkerName,
+			Breakdown: true,
+			Columns:   entryColumns,
+		}
+		for _, e := range entries {
+			if e.WorkerID == ws.WorkerID {
+				t.Rows = append(t.Rows, entryRow(e))
+			}
+		}
+		doc.Tables = append(doc.Tables, t)
+	}
+	for _, fs := range report.FieldStats {
+		t := render.Table{
+			Name:      "field_operations",
+			Title:     "Field " + fs.FieldName,
+			Sheet:     "Field " + fs.FieldName,
+			Breakdown: true,
+			Columns:   entryColumns,
+		}
+		for _, e := range entries {
+			if e.FieldID == fs.FieldID {
+				t.Rows = append(t.Rows, entryRow(e))
+			}
+		}
+		doc.Tables = append(doc.Tables, t)
+	}
+	return doc
+}
+
+func operationsByTypeTable(sheet string, byType map[string]int) render.Table {
+	t := render.Table{
+		Name:  "operations_by_type",
+		Title: "Operations by Type",
+		Sheet: sheet,
+		Columns: []render.Column{
+			{Key: "type", Title: "Operation Type", Kind: render.Text},
+			{Key: "operations", Title: "Operations", Kind: render.Int},
+		},
+	}
+	types := make([]string, 0, len(byType))
+	for opType := range byType {
+		types = append(types, opType)
+	}
+	sort.Strings(types)
+	for _, opType := range types {
+		t.Rows = append(t.Rows, []interface{}{opType, byType[opType]})
+	}
+	return t
+}
+
+func workerStatsTable(sheet string, stats []models.WorkerDailyStats) render.Table {
+	t := render.Table{Name: "worker_stats", Title: "Worker Statistics", Sheet: sheet, Columns: workerStatsColumns}
+	for _, ws := range stats {
+		t.Rows = append(t.Rows, []interface{}{ws.WorkerID, ws.WorkerName, ws.Operations, ws.HoursWorked, ws.FieldsWorked})
+	}
+	return t
+}
+
+func fieldStatsTable(sheet string, stats []models.FieldDailyStats) render.Table {
+	t := render.Table{Name: "field_stats", Title: "Field Statistics", Sheet: sheet, Columns: fieldStatsColumns}
+	for _, fs := range stats {
+		t.Rows = append(t.Rows, []interface{}{fs.FieldID, fs.FieldName, fs.Operations, fs.HoursWorked, fs.DecaresWorked, fs.WorkersCount})
+	}
+	return t
+}
+
+func regionStatsTable(sheet string, stats []models.RegionStats) render.Table {
+	t := render.Table{Name: "region_stats", Title: "Region Statistics", Sheet: sheet, Columns: regionStatsColumns}
+	for _, rs := range stats {
+		t.Rows = append(t.Rows, []interface{}{rs.RegionID, rs.RegionName, rs.Operations, rs.HoursWorked, rs.DecaresWorked, rs.FieldsWorked, rs.WorkersCount})
+	}
+	return t
+}
+
+func timelineTable(name, title string, periodKind render.Kind, timeline []models.PeriodStats) render.Table {
+	t := render.Table{
+		Name:  name,
+		Title: title,
+		Sheet: title,
+		Columns: []render.Column{
+			{Key: "period", Title: "Period", Kind: periodKind},
+			{Key: "total_workers", Title: "Workers", Kind: render.Int},
+			{Key: "total_operations", Title: "Operations", Kind: render.Int},
+			{Key: "completed_operations", Title: "Completed", Kind: render.Int},
+			{Key: "in_progress_operations", Title: "In Progress", Kind: render.Int},
+			{Key: "hours_worked", Title: "Hours Worked", Kind: render.Decimal},
+		},
+	}
+	for _, ps := range timeline {
+		t.Rows = append(t.Rows, []interface{}{ps.Period, ps.TotalWorkers, ps.TotalOperations, ps.CompletedOps, ps.InProgressOps, ps.HoursWorked})
+	}
+	return t
+}
+
+func entriesTable(entries []models.ReportEntry) render.Table {
+	t := render.Table{Name: "operations", Title: "Operations", Sheet: "Operations", Columns: entryColumns}
+	for _, e := range entries {
+		t.Rows = append(t.Rows, entryRow(e))
+	}
+	return t
+}
+
+func entryRow(e models.ReportEntry) []interface{} {
+	return []interface{}{e.OperationID, e.StartTime, e.WorkerName, e.FieldName, e.Type, e.Status, e.StartTime, e.EndTime, e.HoursWorked}
+}
+
+// reportShapes returns the outlines of the fields that appear in a report,
+// used to draw the map in printed exports.
+func (h *Handler) reportShapes(stats []models.FieldDailyStats) ([]render.Shape, error) {
+	if len(stats) == 0 {
+		return nil, nil
+	}
+
+	fields, err := h.fields.GetFields()
+	if err != nil {
+		return nil, err
+	}
+
+	inReport := make(map[int]bool)
+	for _, fs := range stats {
+		inReport[fs.FieldID] = true
+	}
+
+	var shapes []render.Shape
+	for _, f := range fields {
+		if !inReport[f.ID] {
+			continue
+		}
+		polygons := fieldPolygons(f.Coordinates)
+		if len(polygons) > 0 {
+			shapes = append(shapes, render.Shape{Label: f.Name, Polygons: polygons})
+		}
+	}
+	return shapes, nil
+}
+
+// fieldPolygons extracts the polygons of a GeoJSON Polygon or MultiPolygon,
+// ignoring anything it cannot read.
+func fieldPolygons(raw json.RawMessage) [][][][2]float64 {
+	var geometry struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+	}
+	if err := json.Unmarshal(raw, &geometry); err != nil {
+		return nil
+	}
+
+	switch geometry.Type {
+	case "Polygon":
+		var polygon [][][2]float64
+		if err := json.Unmarshal(geometry.Coordinates, &polygon); err != nil {
+			return nil
+		}
+		return [][][][2]float64{polygon}
+	case "MultiPolygon":
+		var polygons [][][][2]float64
+		if err := json.Unmarshal(geometry.Coordinates, &polygons); err != nil {
+			return nil
+		}
+		return polygons
+	}
+	return nil
+}
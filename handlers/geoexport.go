@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"agroport/geoformat"
+	"agroport/models"
+	"agroport/spatial"
+	"bytes"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// geoExportFormat reads the "format" query parameter of the GIS exports,
+// defaulting to GeoJSON.
+func (h *Handler) geoExportFormat(w http.ResponseWriter, r *http.Request) (string, bool) {
+	format := r.URL.Query().Get("format")
+	switch format {
+	case "":
+		return geoformat.GeoJSON, true
+	case geoformat.GeoJSON, geoformat.KML:
+		return format, true
+	}
+	h.respondWithError(w, http.StatusBadRequest, "Invalid format. Use one of: geojson, kml")
+	return "", false
+}
+
+// writeGeoExport sends the features as a GeoJSON or KML file download.
+func (h *Handler) writeGeoExport(w http.ResponseWriter, format, basename, nameProperty string, features []geoformat.ExportFeature) {
+	var buf bytes.Buffer
+	var err error
+	contentType := "application/geo+json"
+	if format == geoformat.KML {
+		contentType = "application/vnd.google-earth.kml+xml"
+		err = geoformat.WriteKML(&buf, basename, nameProperty, features)
+	} else {
+		err = geoformat.WriteGeoJSON(&buf, features)
+	}
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to export")
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.%s\"", basename, format))
+	w.Write(buf.Bytes())
+}
+
+// ExportFields exports every field with a readable boundary, with its crop,
+// region and area and the last operation completed on it. With region_id only
+// the fields in that region and the regions below it are exported.
+func (h *Handler) ExportFields(w http.ResponseWriter, r *http.Request) {
+	format, ok := h.geoExportFormat(w, r)
+	if !ok {
+		return
+	}
+	filter, ok := h.reportFilter(w, r)
+	if !ok {
+		return
+	}
+
+	fields, err := h.fieldBoundaries()
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch fields")
+		return
+	}
+	last, err := h.operations.GetLastCompletedOperations()
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch operations")
+		return
+	}
+
+	features := make([]geoformat.ExportFeature, 0, len(fields))
+	for _, fb := range fields {
+		f := fb.field
+		if !filter.Includes(f.RegionID) {
+			continue
+		}
+		properties := map[string]interface{}{
+			"id":                  f.ID,
+			"name":                f.Name,
+			"description":         f.Description,
+			"crop_type":           f.CropType,
+			"region":              f.Region,
+			"period":              f.Period,
+			"area":                f.Area,
+			"last_operation_id":   nil,
+			"last_operation_type": nil,
+			"last_operation_date": nil,
+		}
+		if o, ok := last[f.ID]; ok {
+			properties["last_operation_id"] = o.ID
+			properties["last_operation_type"] = o.Type
+			if date := o.CompletedAt; date != nil {
+				properties["last_operation_date"] = date.Format("2006-01-02")
+			}
+		}
+		features = append(features, geoformat.ExportFeature{Geometry: fb.boundary, Properties: properties})
+	}
+
+	h.writeGeoExport(w, format, "fields", "name", features)
+}
+
+// ExportOperations exports the operations started between the from and to
+// dates (inclusive), each drawn as the boundary its field had at the time.
+func (h *Handler) ExportOperations(w http.ResponseWriter, r *http.Request) {
+	format, ok := h.geoExportFormat(w, r)
+	if !ok {
+		return
+	}
+	from, to, ok := h.dateRangeParams(w, r)
+	if !ok {
+		return
+	}
+	filter, ok := h.reportFilter(w, r)
+	if !ok {
+		return
+	}
+
+	entries, err := h.reports.GetReportEntries(from, to, filter)
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch operations")
+		return
+	}
+
+	// Fields are looked up for their crop type, including retired ones
+	fields := make(map[int]*models.Field)
+	var features []geoformat.ExportFeature
+	for _, e := range entries {
+		// Drawn as the field was when the operation was done
+		boundary, err := spatial.ParseBoundary(e.Boundary)
+		if err != nil {
+			continue
+		}
+		field, ok := fields[e.FieldID]
+		if !ok {
+			if field, err = h.fields.GetFieldByID(e.FieldID); err != nil {
+				h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch fields")
+				return
+			}
+			fields[e.FieldID] = field
+		}
+		properties := map[string]interface{}{
+			"operation_id": e.OperationID,
+			"name":         fmt.Sprintf("%s – %s", e.Type, e.FieldName),
+			"type":         e.Type,
+			"status":       e.Status,
+			"field_id":     e.FieldID,
+			"field_name":   e.FieldName,
+			"crop_type":    field.CropType,
+			"worker_id":    nil,
+			"worker_name":  nil,
+			"date":         e.StartTime.Format("2006-01-02"),
+			"start_time":   timeOrNil(e.StartTime),
+			"end_time":     timeOrNil(e.EndTime),
+			"hours_worked": e.HoursWorked,
+		}
+		if e.WorkerID != 0 {
+			properties["worker_id"] = e.WorkerID
+			properties["worker_name"] = e.WorkerName
+		}
+		features = append(features, geoformat.ExportFeature{Geometry: boundary, Properties: properties})
+	}
+
+	basename := fmt.Sprintf("operations_%s_%s", from.Format("2006-01-02"), to.AddDate(0, 0, -1).Format("2006-01-02"))
+	h.writeGeoExport(w, format, basename, "name", features)
+}
+
+func timeOrNil(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return *t
+}
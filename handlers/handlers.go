@@ -1,25 +1,55 @@
 package handlers
 
 import (
+	"agroport/auth"
+	"agroport/detect"
 	"agroport/models"
+	"agroport/spatial"
+	"agroport/tiles"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
-	"github.com/tealeg/xlsx/v3"
 )
 
+// Handler serves the API. The stores below are limited to one organization
+// only in the copies made by Scoped; store is never limited.
 type Handler struct {
-	db *sql.DB
+	store      models.Store
+	workers    models.WorkerStore
+	accounts   models.AccountStore
+	fields     models.FieldStore
+	regions    models.RegionStore
+	schedules  models.ScheduleStore
+	operations models.OperationStore
+	reports    models.ReportStore
+	detection  models.DetectionStore
+
+	// analyzer turns uploaded machine tracks into operation proposals in the background
+	analyzer *detect.Analyzer
+
+	// fieldTiles caches rendered field map tiles; every change to fields must invalidate it
+	fieldTiles *tiles.Cache
+	tileCaches *tileCaches
+
+	// tokens issues and checks the JWTs workers authenticate with
+	tokens *auth.Tokens
+
+	// codes delivers one-time login codes; nil when they are disabled
+	codes auth.Sender
 }
 
 type ErrorResponse struct {
-	Error   string `json:"error"`
-	Message string `json:"message,omitempty"`
+	Error     string                `json:"error"`
+	Message   string                `json:"message,omitempty"`
+	Conflicts *models.ConflictError `json:"conflicts,omitempty"`
 }
 
 type SuccessResponse struct {
@@ -27,8 +57,22 @@ type SuccessResponse struct {
 	Data    interface{} `json:"data,omitempty"`
 }
 
-func NewHandler(db *sql.DB) *Handler {
-	return &Handler{db: db}
+func NewHandler(store models.Store, analyzer *detect.Analyzer, tokens *auth.Tokens, codes auth.Sender) *Handler {
+	return &Handler{
+		store:      store,
+		workers:    store,
+		accounts:   store,
+		fields:     store,
+		regions:    store,
+		schedules:  store,
+		operations: store,
+		reports:    store,
+		detection:  store,
+		analyzer:   analyzer,
+		tileCaches: &tileCaches{caches: make(map[int]*tiles.Cache)},
+		tokens:     tokens,
+		codes:      codes,
+	}
 }
 
 func (h *Handler) respondWithError(w http.ResponseWriter, code int, message string) {
@@ -43,6 +87,52 @@ func (h *Handler) respondWithJSON(w http.ResponseWriter, code int, payload inter
 	json.NewEncoder(w).Encode(payload)
 }
 
+// respondWithConflict answers 409 with the records in the way when err is a
+// *models.ConflictError, and tells whether it did.
+func (h *Handler) respondWithConflict(w http.ResponseWriter, err error) bool {
+	var conflictErr *models.ConflictError
+	if !errors.As(err, &conflictErr) {
+		return false
+	}
+	message := "Worker is busy with other operations at that time"
+	if len(conflictErr.Schedules) > 0 {
+		message = "Worker already has a schedule on that date"
+	}
+	h.respondWithJSON(w, http.StatusConflict, ErrorResponse{Error: "error", Message: message, Conflicts: conflictErr})
+	return true
+}
+
+// statusChangeMessage answers attempts to set an operation's status other than
+// through the transition endpoints.
+const statusChangeMessage = "Operations are created planned and change status only through the start, pause, resume, complete, cancel, reject and assign endpoints"
+
+// respondWithTransitionError maps errors from operation status changes to responses:
+// 409 for transitions the state machine forbids or that would double-book the
+// worker, and 404 for unknown operations.
+func (h *Handler) respondWithTransitionError(w http.ResponseWriter, err error, message string) {
+	var transitionErr *models.TransitionError
+	switch {
+	case err == models.ErrStatusChange:
+		h.respondWithError(w, http.StatusConflict, statusChangeMessage)
+	case h.respondWithConflict(w, err):
+	case errors.As(err, &transitionErr):
+		h.respondWithError(w, http.StatusConflict, transitionErr.Error())
+	case err == sql.ErrNoRows:
+		h.respondWithError(w, http.StatusNotFound, "Operation not found")
+	default:
+		h.respondWithError(w, http.StatusInternalServerError, message)
+	}
+}
+
+// actorFromRequest identifies who is making a change: the authenticated worker.
+func actorFromRequest(r *http.Request) string {
+	worker := currentWorker(r)
+	if worker == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s (#%d)", worker.Name, worker.ID)
+}
+
 // Worker handlers
 func (h *Handler) CreateWorker(w http.ResponseWriter, r *http.Request) {
 	var worker models.Worker
@@ -55,8 +145,15 @@ func (h *Handler) CreateWorker(w http.ResponseWriter, r *http.Request) {
 		h.respondWithError(w, http.StatusBadRequest, "Name and role are required")
 		return
 	}
+	if worker.AccessRole == "" {
+		worker.AccessRole = auth.RoleOperator
+	}
+	if !auth.ValidRole(worker.AccessRole) {
+		h.respondWithError(w, http.StatusBadRequest, "access_role must be one of "+strings.Join(auth.Roles, ", "))
+		return
+	}
 
-	if err := models.CreateWorker(&worker); err != nil {
+	if err := h.workers.CreateWorker(&worker); err != nil {
 		h.respondWithError(w, http.StatusInternalServerError, "Failed to create worker")
 		return
 	}
@@ -68,7 +165,7 @@ func (h *Handler) CreateWorker(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) GetWorkers(w http.ResponseWriter, r *http.Request) {
-	workers, err := models.GetWorkers()
+	workers, err := h.workers.GetWorkers()
 	if err != nil {
 		h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch workers")
 		return
@@ -88,7 +185,7 @@ func (h *Handler) GetWorker(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	worker, err := models.GetWorkerByID(id)
+	worker, err := h.workers.GetWorkerByID(id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			h.respondWithError(w, http.StatusNotFound, "Worker not found")
@@ -119,9 +216,32 @@ func (h *Handler) UpdateWorker(w http.ResponseWriter, r *http.Request) {
 	}
 
 	worker.ID = id
-	if err := models.UpdateWorker(&worker); err != nil {
+
+	current, err := h.workers.GetWorkerByID(id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			h.respondWithError(w, http.StatusNotFound, "Worker not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch worker")
+		}
+		return
+	}
+	if worker.AccessRole == "" {
+		worker.AccessRole = current.AccessRole
+	}
+	if !auth.ValidRole(worker.AccessRole) {
+		h.respondWithError(w, http.StatusBadRequest, "access_role must be one of "+strings.Join(auth.Roles, ", "))
+		return
+	}
+	if worker.AccessRole != current.AccessRole && !h.keepOwner(w, current) {
+		return
+	}
+
+	if err := h.workers.UpdateWorker(&worker); err != nil {
 		if err == sql.ErrNoRows {
 			h.respondWithError(w, http.StatusNotFound, "Worker not found")
+		} else if err == models.ErrSharedWorker {
+			h.respondWithError(w, http.StatusConflict, "Worker also belongs to other organizations; their email and phone cannot be changed here")
 		} else {
 			h.respondWithError(w, http.StatusInternalServerError, "Failed to update worker")
 		}
@@ -142,7 +262,20 @@ func (h *Handler) DeleteWorker(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := models.DeleteWorker(id); err != nil {
+	worker, err := h.workers.GetWorkerByID(id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			h.respondWithError(w, http.StatusNotFound, "Worker not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch worker")
+		}
+		return
+	}
+	if !h.keepOwner(w, worker) {
+		return
+	}
+
+	if err := h.workers.DeleteWorker(id); err != nil {
 		h.respondWithError(w, http.StatusInternalServerError, "Failed to delete worker")
 		return
 	}
@@ -165,10 +298,19 @@ func (h *Handler) CreateField(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := models.CreateField(&field); err != nil {
+	if err := setFieldArea(&field); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid coordinates: "+err.Error())
+		return
+	}
+	if !h.setFieldRegions(w, &field) {
+		return
+	}
+
+	if err := h.fields.CreateField(&field); err != nil {
 		h.respondWithError(w, http.StatusInternalServerError, "Failed to create field")
 		return
 	}
+	h.fieldTiles.Invalidate()
 
 	h.respondWithJSON(w, http.StatusCreated, SuccessResponse{
 		Message: "Field created successfully",
@@ -176,8 +318,24 @@ func (h *Handler) CreateField(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// setFieldArea validates the field boundary and replaces whatever area the
+// client sent with the one computed from it, rounded as stored.
+func setFieldArea(field *models.Field) error {
+	boundary, err := spatial.ParseBoundary(field.Coordinates)
+	if err != nil {
+		return err
+	}
+	field.Area = math.Round(spatial.AreaDecares(boundary)*100) / 100
+	return nil
+}
+
 func (h *Handler) GetFields(w http.ResponseWriter, r *http.Request) {
-	fields, err := models.GetFields()
+	if r.URL.Query().Get("bbox") != "" {
+		h.getFieldsInBBox(w, r)
+		return
+	}
+
+	fields, err := h.fields.GetFields()
 	if err != nil {
 		h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch fields")
 		return
@@ -197,7 +355,7 @@ func (h *Handler) GetField(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	field, err := models.GetFieldByID(id)
+	field, err := h.fields.GetFieldByID(id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			h.respondWithError(w, http.StatusNotFound, "Field not found")
@@ -227,15 +385,20 @@ func (h *Handler) UpdateField(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := setFieldArea(&field); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid coordinates: "+err.Error())
+		return
+	}
+	if !h.setFieldRegions(w, &field) {
+		return
+	}
+
 	field.ID = id
-	if err := models.UpdateField(&field); err != nil {
-		if err == sql.ErrNoRows {
-			h.respondWithError(w, http.StatusNotFound, "Field not found")
-		} else {
-			h.respondWithError(w, http.StatusInternalServerError, "Failed to update field")
-		}
+	if err := h.fields.UpdateField(&field); err != nil {
+		h.respondWithLineageError(w, err, "Failed to update field")
 		return
 	}
+	h.fieldTiles.Invalidate()
 
 	h.respondWithJSON(w, http.StatusOK, SuccessResponse{
 		Message: "Field updated successfully",
@@ -251,10 +414,11 @@ func (h *Handler) DeleteField(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := models.DeleteField(id); err != nil {
+	if err := h.fields.DeleteField(id); err != nil {
 		h.respondWithError(w, http.StatusInternalServerError, "Failed to delete field")
 		return
 	}
+	h.fieldTiles.Invalidate()
 
 	h.respondWithJSON(w, http.StatusOK, SuccessResponse{
 		Message: "Field deleted successfully",
@@ -274,8 +438,10 @@ func (h *Handler) CreateSchedule(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := models.CreateSchedule(&schedule); err != nil {
-		h.respondWithError(w, http.StatusInternalServerError, "Failed to create schedule")
+	if err := h.schedules.CreateSchedule(&schedule); err != nil {
+		if !h.respondWithConflict(w, err) {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to create schedule")
+		}
 		return
 	}
 
@@ -286,7 +452,7 @@ func (h *Handler) CreateSchedule(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) GetSchedules(w http.ResponseWriter, r *http.Request) {
-	schedules, err := models.GetSchedules()
+	schedules, err := h.schedules.GetSchedules()
 	if err != nil {
 		h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch schedules")
 		return
@@ -306,7 +472,7 @@ func (h *Handler) GetSchedule(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	schedule, err := models.GetScheduleByID(id)
+	schedule, err := h.schedules.GetScheduleByID(id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			h.respondWithError(w, http.StatusNotFound, "Schedule not found")
@@ -330,7 +496,7 @@ func (h *Handler) GetWorkerSchedules(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	schedules, err := models.GetWorkerSchedules(workerID)
+	schedules, err := h.schedules.GetWorkerSchedules(workerID)
 	if err != nil {
 		h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch worker schedules")
 		return
@@ -357,10 +523,10 @@ func (h *Handler) UpdateSchedule(w http.ResponseWriter, r *http.Request) {
 	}
 
 	schedule.ID = id
-	if err := models.UpdateSchedule(&schedule); err != nil {
+	if err := h.schedules.UpdateSchedule(&schedule); err != nil {
 		if err == sql.ErrNoRows {
 			h.respondWithError(w, http.StatusNotFound, "Schedule not found")
-		} else {
+		} else if !h.respondWithConflict(w, err) {
 			h.respondWithError(w, http.StatusInternalServerError, "Failed to update schedule")
 		}
 		return
@@ -380,7 +546,7 @@ func (h *Handler) DeleteSchedule(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := models.DeleteSchedule(id); err != nil {
+	if err := h.schedules.DeleteSchedule(id); err != nil {
 		h.respondWithError(w, http.StatusInternalServerError, "Failed to delete schedule")
 		return
 	}
@@ -404,11 +570,25 @@ func (h *Handler) CreateOperation(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if operation.Status == "" {
-		operation.Status = "planned"
+		operation.Status = models.StatusPlanned
+	}
+	if !models.ValidOperationStatus(operation.Status) {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid operation status")
+		return
+	}
+	if operation.Status != models.StatusPlanned {
+		h.respondWithError(w, http.StatusConflict, statusChangeMessage)
+		return
+	}
+	if field, err := h.fields.GetFieldByID(operation.FieldID); err == nil && field.RetiredAt != nil {
+		h.respondWithError(w, http.StatusConflict, "Field has been split or merged; plan the operation on its successors")
+		return
 	}
 
-	if err := models.CreateOperation(&operation); err != nil {
-		h.respondWithError(w, http.StatusInternalServerError, "Failed to create operation")
+	if err := h.operations.CreateOperation(&operation, actorFromRequest(r)); err != nil {
+		if !h.respondWithConflict(w, err) {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to create operation")
+		}
 		return
 	}
 
@@ -419,7 +599,7 @@ func (h *Handler) CreateOperation(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) GetOperations(w http.ResponseWriter, r *http.Request) {
-	operations, err := models.GetOperations()
+	operations, err := h.operations.GetOperations()
 	if err != nil {
 		h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch operations")
 		return
@@ -439,7 +619,7 @@ func (h *Handler) GetOperation(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	operation, err := models.GetOperationByID(id)
+	operation, err := h.operations.GetOperationByID(id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			h.respondWithError(w, http.StatusNotFound, "Operation not found")
@@ -469,13 +649,30 @@ func (h *Handler) UpdateOperation(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	operation.ID = id
-	if err := models.UpdateOperation(&operation); err != nil {
+	if operation.Status != "" && !models.ValidOperationStatus(operation.Status) {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid operation status")
+		return
+	}
+	current, err := h.operations.GetOperationByID(id)
+	if err != nil {
 		if err == sql.ErrNoRows {
 			h.respondWithError(w, http.StatusNotFound, "Operation not found")
 		} else {
-			h.respondWithError(w, http.StatusInternalServerError, "Failed to update operation")
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch operation")
+		}
+		return
+	}
+	// Operations done on a field before it was retired keep it
+	if operation.FieldID != current.FieldID {
+		if field, err := h.fields.GetFieldByID(operation.FieldID); err == nil && field.RetiredAt != nil {
+			h.respondWithError(w, http.StatusConflict, "Field has been split or merged; plan the operation on its successors")
+			return
 		}
+	}
+
+	operation.ID = id
+	if err := h.operations.UpdateOperation(&operation, actorFromRequest(r)); err != nil {
+		h.respondWithTransitionError(w, err, "Failed to update operation")
 		return
 	}
 
@@ -485,6 +682,39 @@ func (h *Handler) UpdateOperation(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+func (h *Handler) GetOperationHistory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid operation ID")
+		return
+	}
+
+	events, err := h.operations.GetOperationEvents(id)
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch operation history")
+		return
+	}
+
+	// Operations created before the history was recorded have no events yet
+	if len(events) == 0 {
+		if _, err := h.operations.GetOperationByID(id); err != nil {
+			if err == sql.ErrNoRows {
+				h.respondWithError(w, http.StatusNotFound, "Operation not found")
+			} else {
+				h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch operation history")
+			}
+			return
+		}
+		events = []models.OperationEvent{}
+	}
+
+	h.respondWithJSON(w, http.StatusOK, SuccessResponse{
+		Message: "Operation history retrieved successfully",
+		Data:    events,
+	})
+}
+
 func (h *Handler) CompleteOperation(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
@@ -492,9 +722,12 @@ func (h *Handler) CompleteOperation(w http.ResponseWriter, r *http.Request) {
 		h.respondWithError(w, http.StatusBadRequest, "Invalid operation ID")
 		return
 	}
+	if !h.authorizeOperationWork(w, r, id) {
+		return
+	}
 
-	if err := models.CompleteOperation(id); err != nil {
-		h.respondWithError(w, http.StatusInternalServerError, "Failed to complete operation")
+	if err := h.operations.CompleteOperation(id, actorFromRequest(r)); err != nil {
+		h.respondWithTransitionError(w, err, "Failed to complete operation")
 		return
 	}
 
@@ -510,9 +743,12 @@ func (h *Handler) StartOperation(w http.ResponseWriter, r *http.Request) {
 		h.respondWithError(w, http.StatusBadRequest, "Invalid operation ID")
 		return
 	}
+	if !h.authorizeOperationWork(w, r, id) {
+		return
+	}
 
-	if err := models.StartOperation(id); err != nil {
-		h.respondWithError(w, http.StatusInternalServerError, "Failed to start operation")
+	if err := h.operations.StartOperation(id, actorFromRequest(r)); err != nil {
+		h.respondWithTransitionError(w, err, "Failed to start operation")
 		return
 	}
 
@@ -521,6 +757,48 @@ func (h *Handler) StartOperation(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+func (h *Handler) PauseOperation(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid operation ID")
+		return
+	}
+	if !h.authorizeOperationWork(w, r, id) {
+		return
+	}
+
+	if err := h.operations.PauseOperation(id, actorFromRequest(r)); err != nil {
+		h.respondWithTransitionError(w, err, "Failed to pause operation")
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, SuccessResponse{
+		Message: "Operation paused successfully",
+	})
+}
+
+func (h *Handler) ResumeOperation(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid operation ID")
+		return
+	}
+	if !h.authorizeOperationWork(w, r, id) {
+		return
+	}
+
+	if err := h.operations.ResumeOperation(id, actorFromRequest(r)); err != nil {
+		h.respondWithTransitionError(w, err, "Failed to resume operation")
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, SuccessResponse{
+		Message: "Operation resumed successfully",
+	})
+}
+
 func (h *Handler) RejectOperation(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
@@ -528,9 +806,25 @@ func (h *Handler) RejectOperation(w http.ResponseWriter, r *http.Request) {
 		h.respondWithError(w, http.StatusBadRequest, "Invalid operation ID")
 		return
 	}
+	if !h.authorizeOperationWork(w, r, id) {
+		return
+	}
 
-	if err := models.RejectOperation(id); err != nil {
-		h.respondWithError(w, http.StatusInternalServerError, "Failed to reject operation")
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid JSON payload")
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		h.respondWithError(w, http.StatusBadRequest, "Reason is required")
+		return
+	}
+
+	if err := h.operations.RejectOperation(id, req.Reason, actorFromRequest(r)); err != nil {
+		h.respondWithTransitionError(w, err, "Failed to reject operation")
 		return
 	}
 
@@ -539,6 +833,102 @@ func (h *Handler) RejectOperation(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// GetUnassignedOperations lists the rejected operations waiting for a new worker.
+func (h *Handler) GetUnassignedOperations(w http.ResponseWriter, r *http.Request) {
+	operations, err := h.operations.GetUnassignedOperations()
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch unassigned operations")
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, SuccessResponse{
+		Message: "Unassigned operations retrieved successfully",
+		Data:    operations,
+	})
+}
+
+// AssignOperation plans a rejected operation for another worker, optionally
+// attaching it to one of that worker's schedules.
+func (h *Handler) AssignOperation(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid operation ID")
+		return
+	}
+
+	var req struct {
+		WorkerID   int  `json:"worker_id"`
+		ScheduleID *int `json:"schedule_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid JSON payload")
+		return
+	}
+	if req.WorkerID == 0 {
+		h.respondWithError(w, http.StatusBadRequest, "Worker ID is required")
+		return
+	}
+
+	if _, err := h.workers.GetWorkerByID(req.WorkerID); err != nil {
+		if err == sql.ErrNoRows {
+			h.respondWithError(w, http.StatusBadRequest, "Worker not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch worker")
+		}
+		return
+	}
+	if req.ScheduleID != nil {
+		schedule, err := h.schedules.GetScheduleByID(*req.ScheduleID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				h.respondWithError(w, http.StatusBadRequest, "Schedule not found")
+			} else {
+				h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch schedule")
+			}
+			return
+		}
+		if schedule.WorkerID != req.WorkerID {
+			h.respondWithError(w, http.StatusBadRequest, "Schedule belongs to another worker")
+			return
+		}
+	}
+
+	if err := h.operations.AssignOperation(id, req.WorkerID, req.ScheduleID, actorFromRequest(r)); err != nil {
+		h.respondWithTransitionError(w, err, "Failed to assign operation")
+		return
+	}
+
+	operation, err := h.operations.GetOperationByID(id)
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch operation")
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, SuccessResponse{
+		Message: "Operation assigned successfully",
+		Data:    operation,
+	})
+}
+
+func (h *Handler) CancelOperation(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid operation ID")
+		return
+	}
+
+	if err := h.operations.CancelOperation(id, actorFromRequest(r)); err != nil {
+		h.respondWithTransitionError(w, err, "Failed to cancel operation")
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, SuccessResponse{
+		Message: "Operation cancelled successfully",
+	})
+}
+
 func (h *Handler) DeleteOperation(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
@@ -547,7 +937,7 @@ func (h *Handler) DeleteOperation(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := models.DeleteOperation(id); err != nil {
+	if err := h.operations.DeleteOperation(id, actorFromRequest(r)); err != nil {
 		h.respondWithError(w, http.StatusInternalServerError, "Failed to delete operation")
 		return
 	}
@@ -573,7 +963,12 @@ func (h *Handler) GetDailyReport(w http.ResponseWriter, r *http.Request) {
 		date = time.Now()
 	}
 
-	report, err := models.GetDailyReport(date)
+	filter, ok := h.reportFilter(w, r)
+	if !ok {
+		return
+	}
+
+	report, err := h.reports.GetDailyReport(date, filter)
 	if err != nil {
 		h.respondWithError(w, http.StatusInternalServerError, "Failed to generate daily report")
 		return
@@ -586,20 +981,132 @@ func (h *Handler) GetDailyReport(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) GetMonthlyReport(w http.ResponseWriter, r *http.Request) {
-	// Placeholder for monthly report logic
+	month, err := parseMonthParam(r)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid month format. Use YYYY-MM")
+		return
+	}
+
+	filter, ok := h.reportFilter(w, r)
+	if !ok {
+		return
+	}
+
+	report, err := h.reports.GetMonthlyReport(month.Year(), month.Month(), filter)
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to generate monthly report")
+		return
+	}
+
 	h.respondWithJSON(w, http.StatusOK, SuccessResponse{
-		Message: "Monthly report endpoint - implementation needed",
+		Message: "Monthly report generated successfully",
+		Data:    report,
 	})
 }
 
 func (h *Handler) GetYearlyReport(w http.ResponseWriter, r *http.Request) {
-	// Placeholder for yearly report logic
+	year, err := parseYearParam(r)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid year format. Use YYYY")
+		return
+	}
+
+	filter, ok := h.reportFilter(w, r)
+	if !ok {
+		return
+	}
+
+	report, err := h.reports.GetYearlyReport(year, filter)
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to generate yearly report")
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, SuccessResponse{
+		Message: "Yearly report generated successfully",
+		Data:    report,
+	})
+}
+
+// dateRangeParams reads the from and to query parameters. The to date is
+// inclusive, so the returned end is the day after it.
+func (h *Handler) dateRangeParams(w http.ResponseWriter, r *http.Request) (from, end time.Time, ok bool) {
+	query := r.URL.Query()
+	from, err := time.Parse("2006-01-02", query.Get("from"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid or missing from date. Use YYYY-MM-DD")
+		return from, end, false
+	}
+	to, err := time.Parse("2006-01-02", query.Get("to"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid or missing to date. Use YYYY-MM-DD")
+		return from, end, false
+	}
+	if to.Before(from) {
+		h.respondWithError(w, http.StatusBadRequest, "The to date must not be before the from date")
+		return from, end, false
+	}
+	return from, to.AddDate(0, 0, 1), true
+}
+
+func (h *Handler) GetRangeReport(w http.ResponseWriter, r *http.Request) {
+	from, to, ok := h.dateRangeParams(w, r)
+	if !ok {
+		return
+	}
+
+	groupBy, err := models.ParseReportDimensions(r.URL.Query().Get("group_by"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	filter, ok := h.reportFilter(w, r)
+	if !ok {
+		return
+	}
+
+	report, err := h.reports.GetRangeReport(from, to, groupBy, filter)
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to generate range report")
+		return
+	}
+
 	h.respondWithJSON(w, http.StatusOK, SuccessResponse{
-		Message: "Yearly report endpoint - implementation needed",
+		Message: "Range report generated successfully",
+		Data:    report,
 	})
 }
 
+// parseMonthParam reads the "month" query parameter (YYYY-MM), defaulting to the current month.
+func parseMonthParam(r *http.Request) (time.Time, error) {
+	monthStr := r.URL.Query().Get("month")
+	if monthStr == "" {
+		now := time.Now()
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC), nil
+	}
+	return time.Parse("2006-01", monthStr)
+}
+
+// parseYearParam reads the "year" query parameter (YYYY), defaulting to the current year.
+func parseYearParam(r *http.Request) (int, error) {
+	yearStr := r.URL.Query().Get("year")
+	if yearStr == "" {
+		return time.Now().Year(), nil
+	}
+	year, err := strconv.Atoi(yearStr)
+	if err != nil || year < 1 || year > 9999 {
+		return 0, fmt.Errorf("invalid year %q", yearStr)
+	}
+	return year, nil
+}
+
 func (h *Handler) ExportDailyReport(w http.ResponseWriter, r *http.Request) {
+	renderer, ok := h.exportRenderer(w, r)
+	if !ok {
+		return
+	}
+
 	dateStr := r.URL.Query().Get("date")
 	var date time.Time
 	var err error
@@ -614,123 +1121,116 @@ func (h *Handler) ExportDailyReport(w http.ResponseWriter, r *http.Request) {
 		date = time.Now()
 	}
 
-	report, err := models.GetDailyReport(date)
+	filter, ok := h.reportFilter(w, r)
+	if !ok {
+		return
+	}
+
+	report, err := h.reports.GetDailyReport(date, filter)
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to generate daily report")
+		return
+	}
+
+	from := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+	entries, err := h.reports.GetReportEntries(from, from.AddDate(0, 0, 1), filter)
 	if err != nil {
 		h.respondWithError(w, http.StatusInternalServerError, "Failed to generate daily report")
 		return
 	}
 
-	// Create Excel file
-	file := xlsx.NewFile()
-	sheet, err := file.AddSheet("Daily Report")
+	shapes, err := h.reportShapes(report.FieldStats)
 	if err != nil {
-		h.respondWithError(w, http.StatusInternalServerError, "Failed to create Excel sheet")
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to generate daily report")
+		return
+	}
+
+	doc := dailyReportDocument(report, entries, shapes)
+	h.writeDocument(w, r, renderer, doc, fmt.Sprintf("daily_report_%s", date.Format("2006-01-02")))
+}
+
+func (h *Handler) ExportMonthlyReport(w http.ResponseWriter, r *http.Request) {
+	renderer, ok := h.exportRenderer(w, r)
+	if !ok {
 		return
 	}
 
-	// Add report summary
-	row := sheet.AddRow()
-	row.AddCell().Value = "Daily Report"
-	row.AddCell().Value = date.Format("2006-01-02")
+	month, err := parseMonthParam(r)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid month format. Use YYYY-MM")
+		return
+	}
 
-	sheet.AddRow() // Empty row
+	filter, ok := h.reportFilter(w, r)
+	if !ok {
+		return
+	}
 
-	// Summary section
-	summaryRow := sheet.AddRow()
-	summaryRow.AddCell().Value = "Summary"
+	report, err := h.reports.GetMonthlyReport(month.Year(), month.Month(), filter)
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to generate monthly report")
+		return
+	}
 
-	row = sheet.AddRow()
-	row.AddCell().Value = "Total Workers"
-	row.AddCell().Value = fmt.Sprintf("%d", report.TotalWorkers)
+	entries, err := h.reports.GetReportEntries(report.From, report.To, filter)
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to generate monthly report")
+		return
+	}
 
-	row = sheet.AddRow()
-	row.AddCell().Value = "Total Operations"
-	row.AddCell().Value = fmt.Sprintf("%d", report.TotalOperations)
+	shapes, err := h.reportShapes(report.FieldStats)
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to generate monthly report")
+		return
+	}
 
-	row = sheet.AddRow()
-	row.AddCell().Value = "Completed Operations"
-	row.AddCell().Value = fmt.Sprintf("%d", report.CompletedOps)
+	title := fmt.Sprintf("Monthly Report %s", month.Format("2006-01"))
+	doc := periodReportDocument(title, &report.PeriodReport, report.DailyBreakdown, nil, entries, shapes)
+	h.writeDocument(w, r, renderer, doc, fmt.Sprintf("monthly_report_%s", month.Format("2006-01")))
+}
 
-	row = sheet.AddRow()
-	row.AddCell().Value = "In Progress Operations"
-	row.AddCell().Value = fmt.Sprintf("%d", report.InProgressOps)
+func (h *Handler) ExportYearlyReport(w http.ResponseWriter, r *http.Request) {
+	renderer, ok := h.exportRenderer(w, r)
+	if !ok {
+		return
+	}
 
-	sheet.AddRow() // Empty row
+	year, err := parseYearParam(r)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid year format. Use YYYY")
+		return
+	}
 
-	// Operations by type
-	if len(report.OperationsByType) > 0 {
-		typeRow := sheet.AddRow()
-		typeRow.AddCell().Value = "Operations by Type"
+	filter, ok := h.reportFilter(w, r)
+	if !ok {
+		return
+	}
 
-		for opType, count := range report.OperationsByType {
-			row = sheet.AddRow()
-			row.AddCell().Value = opType
-			row.AddCell().Value = fmt.Sprintf("%d", count)
-		}
-		sheet.AddRow() // Empty row
-	}
-
-	// Worker statistics
-	if len(report.WorkerStats) > 0 {
-		workerHeaderRow := sheet.AddRow()
-		workerHeaderRow.AddCell().Value = "Worker Statistics"
-
-		headerRow := sheet.AddRow()
-		headerRow.AddCell().Value = "Worker Name"
-		headerRow.AddCell().Value = "Operations"
-		headerRow.AddCell().Value = "Hours Worked"
-		headerRow.AddCell().Value = "Fields Worked"
-
-		for _, ws := range report.WorkerStats {
-			row = sheet.AddRow()
-			row.AddCell().Value = ws.WorkerName
-			row.AddCell().Value = fmt.Sprintf("%d", ws.Operations)
-			row.AddCell().Value = fmt.Sprintf("%.2f", ws.HoursWorked)
-			row.AddCell().Value = fmt.Sprintf("%d", ws.FieldsWorked)
-		}
-		sheet.AddRow() // Empty row
-	}
-
-	// Field statistics
-	if len(report.FieldStats) > 0 {
-		fieldHeaderRow := sheet.AddRow()
-		fieldHeaderRow.AddCell().Value = "Field Statistics"
-
-		headerRow := sheet.AddRow()
-		headerRow.AddCell().Value = "Field Name"
-		headerRow.AddCell().Value = "Operations"
-		headerRow.AddCell().Value = "Hours Worked"
-		headerRow.AddCell().Value = "Workers Count"
-
-		for _, fs := range report.FieldStats {
-			row = sheet.AddRow()
-			row.AddCell().Value = fs.FieldName
-			row.AddCell().Value = fmt.Sprintf("%d", fs.Operations)
-			row.AddCell().Value = fmt.Sprintf("%.2f", fs.HoursWorked)
-			row.AddCell().Value = fmt.Sprintf("%d", fs.WorkersCount)
-		}
+	report, err := h.reports.GetYearlyReport(year, filter)
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to generate yearly report")
+		return
 	}
 
-	// Set response headers for file download
-	filename := fmt.Sprintf("daily_report_%s.xlsx", date.Format("2006-01-02"))
-	w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+	daily, err := h.reports.GetPeriodBreakdown("day", report.From, report.To, filter)
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to generate yearly report")
+		return
+	}
 
-	// Write Excel file to response
-	if err := file.Write(w); err != nil {
-		h.respondWithError(w, http.StatusInternalServerError, "Failed to write Excel file")
+	entries, err := h.reports.GetReportEntries(report.From, report.To, filter)
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to generate yearly report")
 		return
 	}
-}
 
-func (h *Handler) ExportMonthlyReport(w http.ResponseWriter, r *http.Request) {
-	h.respondWithJSON(w, http.StatusOK, SuccessResponse{
-		Message: "Monthly report export endpoint - implementation needed",
-	})
-}
+	shapes, err := h.reportShapes(report.FieldStats)
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to generate yearly report")
+		return
+	}
 
-func (h *Handler) ExportYearlyReport(w http.ResponseWriter, r *http.Request) {
-	h.respondWithJSON(w, http.StatusOK, SuccessResponse{
-		Message: "Yearly report export endpoint - implementation needed",
-	})
+	title := fmt.Sprintf("Yearly Report %d", year)
+	doc := periodReportDocument(title, &report.PeriodReport, daily, report.MonthlyBreakdown, entries, shapes)
+	h.writeDocument(w, r, renderer, doc, fmt.Sprintf("yearly_report_%d", year))
 }
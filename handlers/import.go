@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"agroport/geoformat"
+	"agroport/models"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxImportSize limits uploaded import files; LPIS exports of a whole farm are
+// a few megabytes.
+const maxImportSize = 32 << 20
+
+// importProperties lists, per field attribute, the feature properties it is
+// read from when the request names none. Matching ignores case.
+var importProperties = map[string][]string{
+	"name":        {"name", "parcel_name", "parcel", "parcel_id"},
+	"crop_type":   {"crop_type", "crop", "culture"},
+	"region":      {"region", "municipality"},
+	"period":      {"period", "season", "year"},
+	"description": {"description"},
+}
+
+// importedFeature is the outcome for one feature of an import file. Feature
+// counts from 1 in file order.
+type importedFeature struct {
+	Feature int           `json:"feature"`
+	Name    string        `json:"name,omitempty"`
+	Field   *models.Field `json:"field,omitempty"`
+	Errors  []string      `json:"errors,omitempty"`
+}
+
+type importReport struct {
+	Format   string            `json:"format"`
+	DryRun   bool              `json:"dry_run"`
+	Total    int               `json:"total"`
+	Valid    int               `json:"valid"`
+	Imported int               `json:"imported"`
+	Features []importedFeature `json:"features"`
+}
+
+// ImportFields creates fields from a GeoJSON, KML/KMZ or zipped Shapefile upload,
+// sent either as the "file" part of a multipart form or as the request body.
+//
+// Query parameters:
+//   - format: geojson, kml, kmz or shapefile; guessed from the file name or
+//     content type when omitted
+//   - name_property, crop_type_property, region_property, period_property:
+//     the feature property each field attribute is read from
+//   - crop_type, region, period: values for features that lack the property
+//   - dry_run=true: validate and report without creating anything
+//   - skip_invalid=true: import the valid features even if others fail; by
+//     default any invalid feature fails the whole import with 422
+func (h *Handler) ImportFields(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+
+	data, filename, err := readImportFile(r)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	format := strings.ToLower(query.Get("format"))
+	if format == "" {
+		format = geoformat.DetectFormat(filename, r.Header.Get("Content-Type"))
+	}
+	if format == "" {
+		h.respondWithError(w, http.StatusBadRequest, "Cannot tell the file format, set format to geojson, kml, kmz or shapefile")
+		return
+	}
+
+	features, err := geoformat.Read(format, data)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	report := importReport{
+		Format:   format,
+		DryRun:   query.Get("dry_run") == "true",
+		Total:    len(features),
+		Features: make([]importedFeature, 0, len(features)),
+	}
+	var valid []*models.Field
+	for i, feature := range features {
+		result := importedFeature{Feature: i + 1}
+		field, errs := importField(feature, r)
+		result.Name = field.Name
+		if len(errs) == 0 {
+			result.Field = field
+			valid = append(valid, field)
+		}
+		result.Errors = errs
+		report.Features = append(report.Features, result)
+	}
+	report.Valid = len(valid)
+
+	if report.DryRun {
+		h.respondWithJSON(w, http.StatusOK, SuccessResponse{
+			Message: fmt.Sprintf("Dry run: %d of %d features are valid", report.Valid, report.Total),
+			Data:    report,
+		})
+		return
+	}
+
+	if report.Valid < report.Total && query.Get("skip_invalid") != "true" {
+		h.respondWithJSON(w, http.StatusUnprocessableEntity, SuccessResponse{
+			Message: fmt.Sprintf("%d of %d features are invalid, nothing was imported", report.Total-report.Valid, report.Total),
+			Data:    report,
+		})
+		return
+	}
+
+	if len(valid) > 0 {
+		if !h.setFieldRegions(w, valid...) {
+			return
+		}
+		if err := h.fields.CreateFields(valid); err != nil {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to import fields")
+			return
+		}
+		h.fieldTiles.Invalidate()
+	}
+	report.Imported = len(valid)
+
+	h.respondWithJSON(w, http.StatusCreated, SuccessResponse{
+		Message: fmt.Sprintf("Imported %d of %d features", report.Imported, report.Total),
+		Data:    report,
+	})
+}
+
+// readImportFile returns the uploaded file and its name, if known.
+func readImportFile(r *http.Request) ([]byte, string, error) {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			return nil, "", fmt.Errorf("multipart upload must have a \"file\" part")
+		}
+		defer file.Close()
+		data, err := io.ReadAll(file)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read the uploaded file")
+		}
+		return data, header.Filename, nil
+	}
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read the request body, files are limited to %d MB", maxImportSize>>20)
+	}
+	if len(data) == 0 {
+		return nil, "", fmt.Errorf("request body is empty")
+	}
+	return data, r.URL.Query().Get("filename"), nil
+}
+
+// importField maps a feature to a field and validates it the way CreateField
+// does. The returned field carries whatever could be read even when invalid.
+func importField(feature geoformat.Feature, r *http.Request) (*models.Field, []string) {
+	query := r.URL.Query()
+	field := &models.Field{
+		Name:        importProperty(feature, "name", query.Get("name_property")),
+		CropType:    importProperty(feature, "crop_type", query.Get("crop_type_property")),
+		Region:      importProperty(feature, "region", query.Get("region_property")),
+		Period:      importProperty(feature, "period", query.Get("period_property")),
+		Description: importProperty(feature, "description", ""),
+	}
+	if field.CropType == "" {
+		field.CropType = query.Get("crop_type")
+	}
+	if field.Region == "" {
+		field.Region = query.Get("region")
+	}
+	if field.Period == "" {
+		field.Period = query.Get("period")
+	}
+
+	var errs []string
+	if field.Name == "" {
+		property := query.Get("name_property")
+		if property == "" {
+			property = strings.Join(importProperties["name"], ", ")
+		}
+		errs = append(errs, "Field name is required, no value in property "+property)
+	}
+	if feature.Err != nil {
+		errs = append(errs, feature.Err.Error())
+	} else {
+		field.Coordinates = feature.Geometry
+		if err := setFieldArea(field); err != nil {
+			errs = append(errs, "Invalid coordinates: "+err.Error())
+		}
+	}
+	return field, errs
+}
+
+// importProperty reads a field attribute from the named property, or from the
+// default candidates when none is named.
+func importProperty(feature geoformat.Feature, attribute, property string) string {
+	candidates := importProperties[attribute]
+	if property != "" {
+		candidates = []string{property}
+	}
+	for _, candidate := range candidates {
+		for key, value := range feature.Properties {
+			if strings.EqualFold(key, candidate) && strings.TrimSpace(value) != "" {
+				return strings.TrimSpace(value)
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,277 @@
+package handlers
+
+import (
+	"agroport/models"
+	"agroport/spatial"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+)
+
+// respondWithLineageError maps errors from changing, splitting and merging
+// fields to responses: 409 for retired fields and fields with open
+// operations, 404 for unknown fields.
+func (h *Handler) respondWithLineageError(w http.ResponseWriter, err error, message string) {
+	var inUse *models.FieldInUseError
+	switch {
+	case errors.As(err, &inUse):
+		h.respondWithError(w, http.StatusConflict,
+			fmt.Sprintf("Complete or cancel the open operations on the field first: %v", inUse.OperationIDs))
+	case err == models.ErrFieldRetired:
+		h.respondWithError(w, http.StatusConflict, "Field has been split or merged; change its successors instead")
+	case err == sql.ErrNoRows:
+		h.respondWithError(w, http.StatusNotFound, "Field not found")
+	default:
+		h.respondWithError(w, http.StatusInternalServerError, message)
+	}
+}
+
+// inheritField fills in what a successor field does not say from the field
+// it was split or merged from.
+func inheritField(field *models.Field, from *models.Field) {
+	if field.Description == "" {
+		field.Description = from.Description
+	}
+	if field.CropType == "" {
+		field.CropType = from.CropType
+	}
+	if field.Period == "" {
+		field.Period = from.Period
+	}
+	if field.RegionID == nil && field.Region == "" {
+		field.RegionID, field.Region = from.RegionID, from.Region
+	}
+}
+
+// SplitField replaces a field by two or more parts, e.g. when part of it is
+// rented out: {"parts": [{"name": "North A", "coordinates": {...}}, ...]}.
+// Each part becomes a new field, inheriting the crop type, period and region
+// it does not set, and must lie inside the field. The field itself is retired
+// with its operations and boundary history.
+func (h *Handler) SplitField(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid field ID")
+		return
+	}
+
+	var req struct {
+		Parts []*models.Field `json:"parts"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid JSON payload")
+		return
+	}
+	if len(req.Parts) < 2 {
+		h.respondWithError(w, http.StatusBadRequest, "A field must be split into at least two parts")
+		return
+	}
+
+	field, err := h.fields.GetFieldByID(id)
+	if err != nil {
+		h.respondWithLineageError(w, err, "Failed to fetch field")
+		return
+	}
+	boundary, boundaryErr := spatial.ParseBoundary(field.Coordinates)
+
+	for i, part := range req.Parts {
+		if part == nil || part.Name == "" {
+			h.respondWithError(w, http.StatusBadRequest, "Part "+strconv.Itoa(i+1)+": name is required")
+			return
+		}
+		if err := setFieldArea(part); err != nil {
+			h.respondWithError(w, http.StatusBadRequest, "Part "+strconv.Itoa(i+1)+": invalid coordinates: "+err.Error())
+			return
+		}
+		// Fields saved before boundaries were validated cannot be checked
+		if partBoundary, _ := spatial.ParseBoundary(part.Coordinates); boundaryErr == nil && !spatial.Within(partBoundary, boundary) {
+			h.respondWithError(w, http.StatusBadRequest, "Part "+strconv.Itoa(i+1)+" must lie inside the field")
+			return
+		}
+		inheritField(part, field)
+	}
+	if !h.setFieldRegions(w, req.Parts...) {
+		return
+	}
+
+	if err := h.fields.SplitField(id, req.Parts); err != nil {
+		h.respondWithLineageError(w, err, "Failed to split field")
+		return
+	}
+	h.fieldTiles.Invalidate()
+
+	h.respondWithJSON(w, http.StatusCreated, SuccessResponse{
+		Message: "Field split successfully",
+		Data:    req.Parts,
+	})
+}
+
+// MergeFields replaces two or more fields by a single new one:
+// {"field_ids": [4, 7], "name": "East"}. Without coordinates the merged field
+// is the MultiPolygon of the merged boundaries; crop type, period and region
+// default to those of the first field. The merged fields are retired with
+// their operations and boundary history.
+func (h *Handler) MergeFields(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		models.Field
+		FieldIDs []int `json:"field_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid JSON payload")
+		return
+	}
+
+	seen := make(map[int]bool)
+	for _, id := range req.FieldIDs {
+		if seen[id] {
+			h.respondWithError(w, http.StatusBadRequest, "field_ids must not repeat a field")
+			return
+		}
+		seen[id] = true
+	}
+	if len(req.FieldIDs) < 2 {
+		h.respondWithError(w, http.StatusBadRequest, "At least two field_ids are required")
+		return
+	}
+
+	var parents []*models.Field
+	var names []string
+	var union orb.MultiPolygon
+	for _, id := range req.FieldIDs {
+		field, err := h.fields.GetFieldByID(id)
+		if err != nil {
+			h.respondWithLineageError(w, err, "Failed to fetch field")
+			return
+		}
+		if field.RetiredAt != nil {
+			h.respondWithLineageError(w, models.ErrFieldRetired, "")
+			return
+		}
+		boundary, err := spatial.ParseBoundary(field.Coordinates)
+		if err != nil && req.Coordinates == nil {
+			h.respondWithError(w, http.StatusBadRequest, "Field "+strconv.Itoa(id)+" has an invalid boundary; send the merged coordinates")
+			return
+		}
+		parents = append(parents, field)
+		names = append(names, field.Name)
+		union = append(union, boundary...)
+	}
+
+	merged := req.Field
+	if merged.Name == "" {
+		merged.Name = strings.Join(names, " + ")
+	}
+	if merged.Coordinates == nil {
+		coordinates, err := json.Marshal(geojson.NewGeometry(union))
+		if err != nil {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to merge boundaries")
+			return
+		}
+		merged.Coordinates = coordinates
+	}
+	if err := setFieldArea(&merged); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid coordinates: "+err.Error())
+		return
+	}
+	inheritField(&merged, parents[0])
+	if !h.setFieldRegions(w, &merged) {
+		return
+	}
+
+	if err := h.fields.MergeFields(req.FieldIDs, &merged); err != nil {
+		h.respondWithLineageError(w, err, "Failed to merge fields")
+		return
+	}
+	h.fieldTiles.Invalidate()
+
+	h.respondWithJSON(w, http.StatusCreated, SuccessResponse{
+		Message: "Fields merged successfully",
+		Data:    merged,
+	})
+}
+
+// GetFieldVersions lists the boundaries a field has had, oldest first, or
+// with at=YYYY-MM-DD only the one in effect on that day.
+func (h *Handler) GetFieldVersions(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid field ID")
+		return
+	}
+
+	var at *time.Time
+	if s := r.URL.Query().Get("at"); s != "" {
+		t, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			h.respondWithError(w, http.StatusBadRequest, "Invalid at date format. Use YYYY-MM-DD")
+			return
+		}
+		// The version in effect at the end of the day
+		t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		at = &t
+	}
+
+	if _, err := h.fields.GetFieldByID(id); err != nil {
+		h.respondWithLineageError(w, err, "Failed to fetch field")
+		return
+	}
+	versions, err := h.fields.GetFieldVersions(id)
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch field versions")
+		return
+	}
+
+	if at != nil {
+		version := models.FieldVersionAt(versions, *at)
+		if version == nil {
+			h.respondWithError(w, http.StatusNotFound, "Field has no boundary on that date")
+			return
+		}
+		h.respondWithJSON(w, http.StatusOK, SuccessResponse{
+			Message: "Field version retrieved successfully",
+			Data:    version,
+		})
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, SuccessResponse{
+		Message: "Field versions retrieved successfully",
+		Data:    versions,
+	})
+}
+
+// GetFieldLineage lists the fields a field was split or merged from and into.
+func (h *Handler) GetFieldLineage(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid field ID")
+		return
+	}
+
+	if _, err := h.fields.GetFieldByID(id); err != nil {
+		h.respondWithLineageError(w, err, "Failed to fetch field")
+		return
+	}
+	lineage, err := h.fields.GetFieldLineage(id)
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch field lineage")
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, SuccessResponse{
+		Message: "Field lineage retrieved successfully",
+		Data:    lineage,
+	})
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"agroport/models"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// todayResponse is a worker's day as the mobile app shows it.
+type todayResponse struct {
+	Date       string             `json:"date"`
+	Schedule   *models.Schedule   `json:"schedule"`
+	Operations []models.Operation `json:"operations"`
+	Current    *models.Operation  `json:"current"` // the operation in progress
+}
+
+// GetMyDay returns the logged in worker's schedule for today (or ?date=),
+// their operations on it or still open, each with its field boundary, and
+// the one they are working on.
+func (h *Handler) GetMyDay(w http.ResponseWriter, r *http.Request) {
+	date := time.Now()
+	if dateStr := r.URL.Query().Get("date"); dateStr != "" {
+		var err error
+		date, err = time.Parse("2006-01-02", dateStr)
+		if err != nil {
+			h.respondWithError(w, http.StatusBadRequest, "Invalid date format. Use YYYY-MM-DD")
+			return
+		}
+	}
+	worker := currentWorker(r)
+
+	schedule, err := h.workerSchedule(worker.ID, date)
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch schedule")
+		return
+	}
+	operations, err := h.operations.GetWorkerOperations(worker.ID, date)
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch operations")
+		return
+	}
+
+	today := todayResponse{Date: date.Format("2006-01-02"), Schedule: schedule, Operations: []models.Operation{}}
+	fields := make(map[int]*models.Field)
+	for _, o := range operations {
+		field, ok := fields[o.FieldID]
+		if !ok {
+			field, err = h.fields.GetFieldByID(o.FieldID)
+			if err != nil && err != sql.ErrNoRows {
+				h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch fields")
+				return
+			}
+			fields[o.FieldID] = field
+		}
+		if field != nil {
+			o.Field = field
+		}
+		today.Operations = append(today.Operations, o)
+	}
+	for i := range today.Operations {
+		if today.Operations[i].Status == models.StatusInProgress {
+			today.Current = &today.Operations[i]
+			break
+		}
+	}
+
+	h.respondWithJSON(w, http.StatusOK, SuccessResponse{
+		Message: "Day retrieved successfully",
+		Data:    today,
+	})
+}
+
+// CreateMyOperation creates an operation for the logged in worker on their
+// schedule for today, creating the schedule if needed, and starts it:
+// {"field_id": 1, "type": "plowing", "description": "..."}. It is refused
+// while another of their operations is in progress.
+func (h *Handler) CreateMyOperation(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		FieldID     int    `json:"field_id"`
+		Type        string `json:"type"`
+		Description string `json:"description"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid JSON payload")
+		return
+	}
+	if req.FieldID == 0 || req.Type == "" {
+		h.respondWithError(w, http.StatusBadRequest, "Field ID and Type are required")
+		return
+	}
+	worker := currentWorker(r)
+	actor := actorFromRequest(r)
+
+	field, err := h.fields.GetFieldByID(req.FieldID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			h.respondWithError(w, http.StatusNotFound, "Field not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch field")
+		}
+		return
+	}
+	if field.RetiredAt != nil {
+		h.respondWithError(w, http.StatusConflict, "Field has been split or merged; plan the operation on its successors")
+		return
+	}
+
+	now := time.Now()
+	open, err := h.operations.GetWorkerOperations(worker.ID, now)
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch operations")
+		return
+	}
+	for _, o := range open {
+		if o.Status == models.StatusInProgress {
+			h.respondWithError(w, http.StatusConflict, fmt.Sprintf("Pause or complete operation %d first", o.ID))
+			return
+		}
+	}
+
+	schedule, err := h.workerSchedule(worker.ID, now)
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch schedule")
+		return
+	}
+	if schedule == nil {
+		date, _ := time.Parse("2006-01-02", now.Format("2006-01-02"))
+		schedule = &models.Schedule{WorkerID: worker.ID, Date: date}
+	}
+
+	operation := models.Operation{
+		WorkerID:    worker.ID,
+		FieldID:     req.FieldID,
+		Type:        req.Type,
+		Description: req.Description,
+	}
+	// Created, started and scheduled together, so a failed start leaves nothing behind
+	if err := h.operations.StartNewOperation(schedule, &operation, actor); err != nil {
+		h.respondWithTransitionError(w, err, "Failed to start operation")
+		return
+	}
+
+	started, err := h.operations.GetOperationByID(operation.ID)
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch operation")
+		return
+	}
+	started.Field = field
+	h.respondWithJSON(w, http.StatusCreated, SuccessResponse{
+		Message: "Operation started successfully",
+		Data:    started,
+	})
+}
+
+// workerSchedule finds a worker's schedule for a date, or nil.
+func (h *Handler) workerSchedule(workerID int, date time.Time) (*models.Schedule, error) {
+	schedules, err := h.schedules.GetWorkerSchedules(workerID)
+	if err != nil {
+		return nil, err
+	}
+	day := date.Format("2006-01-02")
+	for _, s := range schedules {
+		if s.Date.Format("2006-01-02") == day {
+			return &s, nil
+		}
+	}
+	return nil, nil
+}
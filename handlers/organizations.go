@@ -0,0 +1,293 @@
+package handlers
+
+import (
+	"agroport/auth"
+	"agroport/models"
+	"agroport/tiles"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
+)
+
+// Method is a handler method, to be bound to the Handler of an organization
+// by Scoped or Allow.
+type Method func(*Handler, http.ResponseWriter, *http.Request)
+
+// Scoped serves a request with the stores of the organization the worker is
+// logged in to, so that it cannot see or change the data of any other. It
+// goes inside RequireAuth.
+func (h *Handler) Scoped(m Method) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		m(h.forOrganization(currentOrganization(r)), w, r)
+	}
+}
+
+func (h *Handler) forOrganization(org int) *Handler {
+	store := h.store.ForOrganization(org)
+	scoped := *h
+	scoped.workers = store
+	scoped.accounts = store
+	scoped.fields = store
+	scoped.regions = store
+	scoped.schedules = store
+	scoped.operations = store
+	scoped.reports = store
+	scoped.detection = store
+	scoped.fieldTiles = h.tileCaches.get(org)
+	return &scoped
+}
+
+// tileCaches keeps a field tile cache per organization.
+type tileCaches struct {
+	mu     sync.Mutex
+	caches map[int]*tiles.Cache
+}
+
+func (c *tileCaches) get(org int) *tiles.Cache {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cache, ok := c.caches[org]
+	if !ok {
+		cache = tiles.NewCache(fieldTileCacheSize)
+		c.caches[org] = cache
+	}
+	return cache
+}
+
+// membership finds the organization a worker logs in to: the requested one,
+// or when orgID is 0 the one they joined first.
+func (h *Handler) membership(w http.ResponseWriter, workerID, orgID int) (*models.Membership, bool) {
+	memberships, err := h.store.GetWorkerOrganizations(workerID)
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch organizations")
+		return nil, false
+	}
+	for _, m := range memberships {
+		if orgID == 0 || m.ID == orgID {
+			return &m, true
+		}
+	}
+	if orgID == 0 {
+		h.respondWithError(w, http.StatusForbidden, "You do not belong to any organization")
+	} else {
+		h.respondWithError(w, http.StatusForbidden, "You do not belong to the organization")
+	}
+	return nil, false
+}
+
+// SwitchOrganization logs the worker in to another of their organizations:
+// {"organization_id": 2}. The tokens returned are for that organization.
+func (h *Handler) SwitchOrganization(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		OrganizationID int `json:"organization_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid JSON payload")
+		return
+	}
+	if req.OrganizationID < 1 {
+		h.respondWithError(w, http.StatusBadRequest, "organization_id is required")
+		return
+	}
+
+	worker := *currentWorker(r)
+	membership, ok := h.membership(w, worker.ID, req.OrganizationID)
+	if !ok {
+		return
+	}
+	h.issueTokens(w, worker, *membership, "Switched organization successfully")
+}
+
+// GetOrganizations lists the organizations the logged in worker belongs to.
+func (h *Handler) GetOrganizations(w http.ResponseWriter, r *http.Request) {
+	memberships, err := h.store.GetWorkerOrganizations(currentWorker(r).ID)
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch organizations")
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, SuccessResponse{
+		Message: "Organizations retrieved successfully",
+		Data:    memberships,
+	})
+}
+
+// CreateOrganization creates an organization with the logged in owner as its
+// owner too: {"name": "..."}. Switch to it to work there.
+func (h *Handler) CreateOrganization(w http.ResponseWriter, r *http.Request) {
+	var org models.Organization
+	if err := json.NewDecoder(r.Body).Decode(&org); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid JSON payload")
+		return
+	}
+	org.Name = strings.TrimSpace(org.Name)
+	if org.Name == "" {
+		h.respondWithError(w, http.StatusBadRequest, "Name is required")
+		return
+	}
+
+	if err := h.store.CreateOrganization(&org); err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to create organization")
+		return
+	}
+	if err := h.store.ForOrganization(org.ID).AddMember(currentWorker(r).ID, auth.RoleOwner); err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to add owner")
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusCreated, SuccessResponse{
+		Message: "Organization created successfully",
+		Data:    models.Membership{Organization: org, AccessRole: auth.RoleOwner},
+	})
+}
+
+// GetOrganization returns the organization the worker is logged in to.
+func (h *Handler) GetOrganization(w http.ResponseWriter, r *http.Request) {
+	org, err := h.store.GetOrganizationByID(currentOrganization(r))
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch organization")
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, SuccessResponse{
+		Message: "Organization retrieved successfully",
+		Data:    org,
+	})
+}
+
+// UpdateOrganization renames the organization the worker is logged in to.
+func (h *Handler) UpdateOrganization(w http.ResponseWriter, r *http.Request) {
+	var org models.Organization
+	if err := json.NewDecoder(r.Body).Decode(&org); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid JSON payload")
+		return
+	}
+	org.ID = currentOrganization(r)
+	org.Name = strings.TrimSpace(org.Name)
+	if org.Name == "" {
+		h.respondWithError(w, http.StatusBadRequest, "Name is required")
+		return
+	}
+
+	if err := h.store.UpdateOrganization(&org); err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to update organization")
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, SuccessResponse{
+		Message: "Organization updated successfully",
+		Data:    org,
+	})
+}
+
+// InviteMember invites a worker of another organization to join this one:
+// {"login": "ivan@example.com", "access_role": "operator"}. They become a
+// member once they accept. The answer is the same whether or not anyone logs
+// in with that email or phone, so that it cannot be used to look up accounts.
+// New workers are created with CreateWorker instead.
+func (h *Handler) InviteMember(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Login      string `json:"login"`
+		AccessRole string `json:"access_role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid JSON payload")
+		return
+	}
+	if req.Login == "" {
+		h.respondWithError(w, http.StatusBadRequest, "login is required")
+		return
+	}
+	if req.AccessRole == "" {
+		req.AccessRole = auth.RoleOperator
+	}
+	if !auth.ValidRole(req.AccessRole) {
+		h.respondWithError(w, http.StatusBadRequest, "access_role must be one of "+strings.Join(auth.Roles, ", "))
+		return
+	}
+
+	account, err := h.store.GetWorkerAccount(strings.TrimSpace(req.Login))
+	if err == nil {
+		err = h.workers.InviteMember(account.ID, req.AccessRole, actorFromRequest(r))
+	}
+	switch err {
+	case nil, sql.ErrNoRows:
+	case models.ErrMemberExists:
+		h.respondWithError(w, http.StatusConflict, "Worker already belongs to the organization")
+		return
+	default:
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to invite worker")
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusAccepted, SuccessResponse{
+		Message: "If a worker logs in with that email or phone, they have been invited",
+	})
+}
+
+// GetInvitations lists the invitations the logged in worker has not answered.
+func (h *Handler) GetInvitations(w http.ResponseWriter, r *http.Request) {
+	invitations, err := h.store.GetWorkerInvitations(currentWorker(r).ID)
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch invitations")
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, SuccessResponse{
+		Message: "Invitations retrieved successfully",
+		Data:    invitations,
+	})
+}
+
+// AcceptInvitation makes the logged in worker a member of the organization
+// that invited them. Switch to it to work there.
+func (h *Handler) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	orgID, err := strconv.Atoi(mux.Vars(r)["organizationId"])
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid organization ID")
+		return
+	}
+
+	membership, err := h.store.AcceptInvitation(currentWorker(r).ID, orgID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			h.respondWithError(w, http.StatusNotFound, "Invitation not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to accept invitation")
+		}
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, SuccessResponse{
+		Message: "Invitation accepted successfully",
+		Data:    membership,
+	})
+}
+
+// DeclineInvitation drops an invitation of the logged in worker.
+func (h *Handler) DeclineInvitation(w http.ResponseWriter, r *http.Request) {
+	orgID, err := strconv.Atoi(mux.Vars(r)["organizationId"])
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid organization ID")
+		return
+	}
+
+	if err := h.store.DeclineInvitation(currentWorker(r).ID, orgID); err != nil {
+		if err == sql.ErrNoRows {
+			h.respondWithError(w, http.StatusNotFound, "Invitation not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to decline invitation")
+		}
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, SuccessResponse{
+		Message: "Invitation declined successfully",
+	})
+}
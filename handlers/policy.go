@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"agroport/auth"
+	"agroport/models"
+	"database/sql"
+	"net/http"
+)
+
+// Allow lets through only workers whose access role has the permission, and
+// serves them like Scoped. It goes inside RequireAuth.
+func (h *Handler) Allow(p auth.Permission, m Method) http.HandlerFunc {
+	next := h.Scoped(m)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if worker := currentWorker(r); worker == nil || !auth.Can(worker.AccessRole, p) {
+			h.respondWithError(w, http.StatusForbidden, "Your access role does not allow this")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// authorizeOperationWork checks that the logged in worker may work on an
+// operation: their own, or anyone's with WorkAnyOperation.
+func (h *Handler) authorizeOperationWork(w http.ResponseWriter, r *http.Request, id int) bool {
+	worker := currentWorker(r)
+	if auth.Can(worker.AccessRole, auth.WorkAnyOperation) {
+		return true
+	}
+
+	operation, err := h.operations.GetOperationByID(id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			h.respondWithError(w, http.StatusNotFound, "Operation not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch operation")
+		}
+		return false
+	}
+	if operation.WorkerID != worker.ID {
+		h.respondWithError(w, http.StatusForbidden, "Only the worker assigned to the operation can do this")
+		return false
+	}
+	return true
+}
+
+// keepOwner checks that a worker about to lose the owner access role, by an
+// update or by being deleted, is not the last owner.
+func (h *Handler) keepOwner(w http.ResponseWriter, worker *models.Worker) bool {
+	if worker.AccessRole != auth.RoleOwner {
+		return true
+	}
+
+	workers, err := h.workers.GetWorkers()
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch workers")
+		return false
+	}
+	for _, other := range workers {
+		if other.ID != worker.ID && other.AccessRole == auth.RoleOwner {
+			return true
+		}
+	}
+	h.respondWithError(w, http.StatusConflict, "Make another worker an owner first")
+	return false
+}
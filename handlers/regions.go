@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"agroport/models"
+	"agroport/spatial"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// validateRegion checks a region about to be created or, when region.ID is
+// set, updated against all the regions: the parent must exist and not lie
+// below the region itself, and the name must be new among its siblings.
+func (h *Handler) validateRegion(w http.ResponseWriter, region *models.Region) bool {
+	if region.Name == "" {
+		h.respondWithError(w, http.StatusBadRequest, "Region name is required")
+		return false
+	}
+	if len(region.Name) > 255 {
+		h.respondWithError(w, http.StatusBadRequest, "Region name must be at most 255 characters")
+		return false
+	}
+	if len(region.Boundary) > 0 && string(region.Boundary) != "null" {
+		if _, err := spatial.ParseBoundary(region.Boundary); err != nil {
+			h.respondWithError(w, http.StatusBadRequest, "Invalid boundary: "+err.Error())
+			return false
+		}
+	} else {
+		region.Boundary = nil
+	}
+
+	regions, err := h.regions.GetRegions()
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch regions")
+		return false
+	}
+
+	if region.ParentID != nil {
+		found := false
+		for _, r := range regions {
+			found = found || r.ID == *region.ParentID
+		}
+		if !found {
+			h.respondWithError(w, http.StatusBadRequest, "Parent region not found")
+			return false
+		}
+		if region.ID != 0 {
+			for _, id := range models.RegionSubtree(regions, region.ID) {
+				if id == *region.ParentID {
+					h.respondWithError(w, http.StatusBadRequest, "A region cannot be moved below itself")
+					return false
+				}
+			}
+		}
+	}
+
+	for _, r := range regions {
+		if r.ID != region.ID && r.Name == region.Name && sameRegion(r.ParentID, region.ParentID) {
+			h.respondWithError(w, http.StatusConflict, "A region named "+strconv.Quote(region.Name)+" already exists there")
+			return false
+		}
+	}
+	return true
+}
+
+func sameRegion(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// setFieldRegions points fields at their regions: the one with the field's
+// region_id or else the one named by its region, which is created as a top
+// level region when there is none yet. Fields with neither have no region.
+func (h *Handler) setFieldRegions(w http.ResponseWriter, fields ...*models.Field) bool {
+	regions, err := h.regions.GetRegions()
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch regions")
+		return false
+	}
+	byID := make(map[int]models.Region)
+	byName := make(map[string][]models.Region)
+	for _, r := range regions {
+		byID[r.ID] = r
+		byName[r.Name] = append(byName[r.Name], r)
+	}
+
+	for _, field := range fields {
+		switch {
+		case field.RegionID != nil:
+			region, ok := byID[*field.RegionID]
+			if !ok {
+				h.respondWithError(w, http.StatusBadRequest, "Region "+strconv.Itoa(*field.RegionID)+" not found")
+				return false
+			}
+			field.Region = region.Name
+		case field.Region != "":
+			matches := byName[field.Region]
+			if len(matches) > 1 {
+				h.respondWithError(w, http.StatusBadRequest, "There are several regions named "+strconv.Quote(field.Region)+"; give region_id instead")
+				return false
+			}
+			if len(matches) == 0 {
+				if len(field.Region) > 255 {
+					h.respondWithError(w, http.StatusBadRequest, "Region name must be at most 255 characters")
+					return false
+				}
+				region := models.Region{Name: field.Region}
+				if err := h.regions.CreateRegion(&region); err != nil {
+					h.respondWithError(w, http.StatusInternalServerError, "Failed to create region")
+					return false
+				}
+				byID[region.ID] = region
+				matches = []models.Region{region}
+				byName[region.Name] = matches
+			}
+			id := matches[0].ID
+			field.RegionID = &id
+		}
+	}
+	return true
+}
+
+// reportFilter reads the region_id query parameter, which limits a report to
+// the fields in that region and all the regions below it.
+func (h *Handler) reportFilter(w http.ResponseWriter, r *http.Request) (models.ReportFilter, bool) {
+	var filter models.ReportFilter
+	s := r.URL.Query().Get("region_id")
+	if s == "" {
+		return filter, true
+	}
+	id, err := strconv.Atoi(s)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid region_id")
+		return filter, false
+	}
+
+	regions, err := h.regions.GetRegions()
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch regions")
+		return filter, false
+	}
+	for _, region := range regions {
+		if region.ID == id {
+			filter.RegionIDs = models.RegionSubtree(regions, id)
+			return filter, true
+		}
+	}
+	h.respondWithError(w, http.StatusNotFound, "Region not found")
+	return filter, false
+}
+
+// Region handlers
+func (h *Handler) CreateRegion(w http.ResponseWriter, r *http.Request) {
+	var region models.Region
+	if err := json.NewDecoder(r.Body).Decode(&region); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid JSON payload")
+		return
+	}
+	region.ID = 0
+	if !h.validateRegion(w, &region) {
+		return
+	}
+
+	if err := h.regions.CreateRegion(&region); err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to create region")
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusCreated, SuccessResponse{
+		Message: "Region created successfully",
+		Data:    region,
+	})
+}
+
+func (h *Handler) GetRegions(w http.ResponseWriter, r *http.Request) {
+	regions, err := h.regions.GetRegions()
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch regions")
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, SuccessResponse{
+		Message: "Regions retrieved successfully",
+		Data:    regions,
+	})
+}
+
+func (h *Handler) GetRegion(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid region ID")
+		return
+	}
+
+	region, err := h.regions.GetRegionByID(id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			h.respondWithError(w, http.StatusNotFound, "Region not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch region")
+		}
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, SuccessResponse{
+		Message: "Region retrieved successfully",
+		Data:    region,
+	})
+}
+
+// UpdateRegion renames a region, changes its boundary or moves it below
+// another region, together with its subregions and fields.
+func (h *Handler) UpdateRegion(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid region ID")
+		return
+	}
+
+	var region models.Region
+	if err := json.NewDecoder(r.Body).Decode(&region); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid JSON payload")
+		return
+	}
+	region.ID = id
+	if !h.validateRegion(w, &region) {
+		return
+	}
+
+	if err := h.regions.UpdateRegion(&region); err != nil {
+		if err == sql.ErrNoRows {
+			h.respondWithError(w, http.StatusNotFound, "Region not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to update region")
+		}
+		return
+	}
+	// Field tiles show the region name
+	h.fieldTiles.Invalidate()
+
+	h.respondWithJSON(w, http.StatusOK, SuccessResponse{
+		Message: "Region updated successfully",
+		Data:    region,
+	})
+}
+
+// DeleteRegion deletes a region that has no fields and no subregions.
+func (h *Handler) DeleteRegion(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid region ID")
+		return
+	}
+
+	if err := h.regions.DeleteRegion(id); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			h.respondWithError(w, http.StatusNotFound, "Region not found")
+		case models.ErrRegionInUse:
+			h.respondWithError(w, http.StatusConflict, "Move the region's fields and subregions elsewhere first")
+		default:
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to delete region")
+		}
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, SuccessResponse{
+		Message: "Region deleted successfully",
+	})
+}
@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"agroport/models"
+	"agroport/spatial"
+	"agroport/tiles"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/maptile"
+)
+
+const (
+	defaultNearestLimit = 5
+	maxNearestLimit     = 50
+
+	// fieldTileCacheSize bounds the tile cache; a farm viewed down to field
+	// level needs a few hundred tiles
+	fieldTileCacheSize = 4096
+)
+
+type fieldBoundary struct {
+	field    models.Field
+	boundary orb.MultiPolygon
+}
+
+// nearbyField is a field together with its distance from the requested point.
+type nearbyField struct {
+	models.Field
+	DistanceMeters float64 `json:"distance_m"`
+}
+
+// fieldBoundaries loads every field with its parsed boundary. Fields whose
+// stored coordinates cannot be read (saved before boundaries were validated)
+// are left out.
+func (h *Handler) fieldBoundaries() ([]fieldBoundary, error) {
+	fields, err := h.fields.GetFields()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []fieldBoundary
+	for _, f := range fields {
+		boundary, err := spatial.ParseBoundary(f.Coordinates)
+		if err != nil {
+			continue
+		}
+		result = append(result, fieldBoundary{field: f, boundary: boundary})
+	}
+	return result, nil
+}
+
+// pointParam reads the lat and lon query parameters.
+func (h *Handler) pointParam(w http.ResponseWriter, r *http.Request) (orb.Point, bool) {
+	p, err := spatial.ParsePoint(r.URL.Query().Get("lat"), r.URL.Query().Get("lon"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return orb.Point{}, false
+	}
+	return p, true
+}
+
+// getFieldsInBBox lists the fields overlapping the bbox query parameter,
+// e.g. bbox=24.70,42.10,24.80,42.20 (minLon,minLat,maxLon,maxLat).
+func (h *Handler) getFieldsInBBox(w http.ResponseWriter, r *http.Request) {
+	bbox, err := spatial.ParseBBox(r.URL.Query().Get("bbox"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	boundaries, err := h.fieldBoundaries()
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch fields")
+		return
+	}
+
+	fields := []models.Field{}
+	for _, fb := range boundaries {
+		if spatial.Intersects(fb.boundary, bbox) {
+			fields = append(fields, fb.field)
+		}
+	}
+
+	h.respondWithJSON(w, http.StatusOK, SuccessResponse{
+		Message: "Fields retrieved successfully",
+		Data:    fields,
+	})
+}
+
+// GetFieldsAt lists the fields containing the point given by lat and lon,
+// e.g. the parcel a worker tapped on the map.
+func (h *Handler) GetFieldsAt(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.pointParam(w, r)
+	if !ok {
+		return
+	}
+
+	boundaries, err := h.fieldBoundaries()
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch fields")
+		return
+	}
+
+	fields := []models.Field{}
+	for _, fb := range boundaries {
+		if spatial.Contains(fb.boundary, p) {
+			fields = append(fields, fb.field)
+		}
+	}
+
+	h.respondWithJSON(w, http.StatusOK, SuccessResponse{
+		Message: "Fields retrieved successfully",
+		Data:    fields,
+	})
+}
+
+// GetNearestFields lists the fields closest to the point given by lat and lon,
+// nearest first. Fields containing the point have distance 0.
+func (h *Handler) GetNearestFields(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.pointParam(w, r)
+	if !ok {
+		return
+	}
+
+	limit := defaultNearestLimit
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			h.respondWithError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		limit = n
+		if limit > maxNearestLimit {
+			limit = maxNearestLimit
+		}
+	}
+
+	boundaries, err := h.fieldBoundaries()
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch fields")
+		return
+	}
+
+	fields := make([]nearbyField, 0, len(boundaries))
+	for _, fb := range boundaries {
+		distance := math.Round(spatial.DistanceMeters(fb.boundary, p)*10) / 10
+		fields = append(fields, nearbyField{Field: fb.field, DistanceMeters: distance})
+	}
+	sort.SliceStable(fields, func(i, j int) bool {
+		return fields[i].DistanceMeters < fields[j].DistanceMeters
+	})
+	if len(fields) > limit {
+		fields = fields[:limit]
+	}
+
+	h.respondWithJSON(w, http.StatusOK, SuccessResponse{
+		Message: "Nearest fields retrieved successfully",
+		Data:    fields,
+	})
+}
+
+// GetFieldTile serves the fields as a Mapbox Vector Tile with a single
+// "fields" layer, for map clients that cannot afford the full field list.
+func (h *Handler) GetFieldTile(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	z, errZ := strconv.ParseUint(vars["z"], 10, 32)
+	x, errX := strconv.ParseUint(vars["x"], 10, 32)
+	y, errY := strconv.ParseUint(vars["y"], 10, 32)
+	if errZ != nil || errX != nil || errY != nil || z > tiles.MaxZoom {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid tile coordinates")
+		return
+	}
+	tile := maptile.New(uint32(x), uint32(y), maptile.Zoom(z))
+	if !tile.Valid() {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid tile coordinates")
+		return
+	}
+
+	data, generation, ok := h.fieldTiles.Get(tile)
+	if !ok {
+		boundaries, err := h.fieldBoundaries()
+		if err != nil {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch fields")
+			return
+		}
+
+		features := make([]tiles.Feature, 0, len(boundaries))
+		for _, fb := range boundaries {
+			features = append(features, tiles.Feature{
+				ID:       fb.field.ID,
+				Boundary: fb.boundary,
+				Properties: map[string]interface{}{
+					"id":        fb.field.ID,
+					"name":      fb.field.Name,
+					"crop_type": fb.field.CropType,
+					"region":    fb.field.Region,
+					"area":      fb.field.Area,
+				},
+			})
+		}
+
+		data, err = tiles.Render(tile, features)
+		if err != nil {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to render tile")
+			return
+		}
+		h.fieldTiles.Put(tile, data, generation)
+	}
+
+	w.Header().Set("Content-Type", tiles.ContentType)
+	w.Write(data)
+}
@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"agroport/detect"
+	"agroport/geoformat"
+	"agroport/models"
+	"agroport/spatial"
+	"database/sql"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/paulmach/orb/geojson"
+)
+
+// maxTrackSize limits uploaded tracks; a day of 1 Hz GPX is around 5 MB.
+const maxTrackSize = 32 << 20
+
+// widthParam reads the optional working width of the implement, in metres.
+func (h *Handler) widthParam(w http.ResponseWriter, r *http.Request) (*float64, bool) {
+	s := r.URL.Query().Get("width")
+	if s == "" {
+		return nil, true
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v <= 0 || v > 100 {
+		h.respondWithError(w, http.StatusBadRequest, "width must be the working width in metres, between 0 and 100")
+		return nil, false
+	}
+	return &v, true
+}
+
+// readTrack reads an uploaded GPS track in the format given by the format
+// parameter, or else detected from its content.
+func (h *Handler) readTrack(w http.ResponseWriter, r *http.Request) ([]models.TrackPoint, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxTrackSize)
+	data, _, err := readImportFile(r)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+	read, err := geoformat.ReadTrack(r.URL.Query().Get("format"), data)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid track: "+err.Error())
+		return nil, false
+	}
+
+	points := make([]models.TrackPoint, len(read))
+	for i, p := range read {
+		points[i] = models.TrackPoint{Lon: p.Lon, Lat: p.Lat, Time: p.Time}
+	}
+	return points, true
+}
+
+// AddOperationTrack stores a GPS track of an operation, sent as GPX, NMEA
+// (RMC sentences) or JSON points in the body or as the "file" part of a form.
+// The operation's start and end times are set from its tracks and, with the
+// implement's working width in metres given as "width", so is the area of
+// the field covered.
+func (h *Handler) AddOperationTrack(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid operation ID")
+		return
+	}
+	if !h.authorizeOperationWork(w, r, id) {
+		return
+	}
+
+	width, ok := h.widthParam(w, r)
+	if !ok {
+		return
+	}
+	points, ok := h.readTrack(w, r)
+	if !ok {
+		return
+	}
+
+	operation, err := h.operations.GetOperationByID(id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			h.respondWithError(w, http.StatusNotFound, "Operation not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch operation")
+		}
+		return
+	}
+	if operation.Status == models.StatusCancelled || operation.Status == models.StatusRejected {
+		h.respondWithError(w, http.StatusConflict, fmt.Sprintf("Cannot add a track to a %s operation", operation.Status))
+		return
+	}
+	field, err := h.fields.GetFieldByID(operation.FieldID)
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch field")
+		return
+	}
+	tracks, err := h.operations.GetOperationTracks(id)
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch tracks")
+		return
+	}
+
+	track := models.Track{
+		OperationID: id,
+		Points:      points,
+		StartedAt:   points[0].Time,
+		EndedAt:     points[len(points)-1].Time,
+		WidthMeters: width,
+	}
+	track.DistanceMeters = math.Round(spatial.LengthMeters(detect.Line(track.Points))*10) / 10
+
+	// Coverage can only be measured on a readable boundary
+	boundary, boundaryErr := spatial.ParseBoundary(field.Coordinates)
+	coveredArea := func(tracks []models.Track) *float64 {
+		var passes []spatial.Pass
+		for _, t := range tracks {
+			if t.WidthMeters != nil {
+				passes = append(passes, spatial.Pass{Line: detect.Line(t.Points), WidthMeters: *t.WidthMeters})
+			}
+		}
+		if boundaryErr != nil || len(passes) == 0 {
+			return nil
+		}
+		area := math.Min(math.Round(spatial.CoveredAreaDecares(boundary, passes)*100)/100, field.Area)
+		return &area
+	}
+	track.CoveredArea = coveredArea([]models.Track{track})
+
+	all := append(tracks, track)
+	summary := models.TrackSummary{StartTime: track.StartedAt, EndTime: track.EndedAt, CoveredArea: coveredArea(all)}
+	for _, t := range tracks {
+		if t.StartedAt.Before(summary.StartTime) {
+			summary.StartTime = t.StartedAt
+		}
+		if t.EndedAt.After(summary.EndTime) {
+			summary.EndTime = t.EndedAt
+		}
+	}
+
+	if err := h.operations.AddOperationTrack(&track, summary, actorFromRequest(r)); err != nil {
+		if err == sql.ErrNoRows {
+			h.respondWithError(w, http.StatusNotFound, "Operation not found")
+		} else if !h.respondWithConflict(w, err) {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to store track")
+		}
+		return
+	}
+
+	operation, err = h.operations.GetOperationByID(id)
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch operation")
+		return
+	}
+	track.Points = nil
+	h.respondWithJSON(w, http.StatusCreated, SuccessResponse{
+		Message: fmt.Sprintf("Track of %d points added successfully", len(points)),
+		Data: map[string]interface{}{
+			"track":     track,
+			"operation": operation,
+		},
+	})
+}
+
+// GetOperationTrack returns the tracks of an operation as a GeoJSON
+// FeatureCollection of LineStrings, with the time of every point in the
+// "times" property.
+func (h *Handler) GetOperationTrack(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid operation ID")
+		return
+	}
+
+	if _, err := h.operations.GetOperationByID(id); err != nil {
+		if err == sql.ErrNoRows {
+			h.respondWithError(w, http.StatusNotFound, "Operation not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch operation")
+		}
+		return
+	}
+	tracks, err := h.operations.GetOperationTracks(id)
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch tracks")
+		return
+	}
+
+	collection := geojson.NewFeatureCollection()
+	for _, t := range tracks {
+		feature := geojson.NewFeature(detect.Line(t.Points))
+		times := make([]string, len(t.Points))
+		for i, p := range t.Points {
+			times[i] = p.Time.Format(time.RFC3339)
+		}
+		feature.Properties["id"] = t.ID
+		feature.Properties["operation_id"] = t.OperationID
+		feature.Properties["started_at"] = t.StartedAt.Format(time.RFC3339)
+		feature.Properties["ended_at"] = t.EndedAt.Format(time.RFC3339)
+		feature.Properties["distance_m"] = t.DistanceMeters
+		feature.Properties["width_m"] = t.WidthMeters
+		feature.Properties["covered_area"] = t.CoveredArea
+		feature.Properties["times"] = times
+		collection.Append(feature)
+	}
+
+	data, err := collection.MarshalJSON()
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to encode tracks")
+		return
+	}
+	w.Header().Set("Content-Type", "application/geo+json")
+	w.Write(data)
+}
@@ -1,18 +1,98 @@
 package main
 
 import (
+	"agroport/auth"
+	"agroport/detect"
 	"agroport/handlers"
+	"agroport/migrations"
 	"agroport/models"
+	"context"
 	"database/sql"
 	"log"
 	"net/http"
+	"net/smtp"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 	_ "github.com/lib/pq"
 )
 
+// trackAnalysisInterval is how often pending machine tracks are looked for,
+// e.g. ones left by a replica that stopped before analyzing them.
+const trackAnalysisInterval = time.Minute
+
 func main() {
+	var store models.Store
+	secret := os.Getenv("JWT_SECRET")
+	memory := os.Getenv("STORAGE") == "memory"
+	if memory {
+		// Everything is lost on restart; meant for local demos and tests
+		log.Println("Using in-memory storage")
+		store = models.NewMemoryStore()
+		if secret == "" {
+			// Tokens die with the process anyway
+			log.Println("JWT_SECRET not set; using a random secret")
+			id, err := auth.NewTokenID()
+			if err != nil {
+				log.Fatal("Failed to generate JWT secret:", err)
+			}
+			secret = id
+		}
+	} else {
+		db := openDatabase()
+		defer db.Close()
+
+		// "main migrate up|down|status" manages the schema without starting the server
+		if len(os.Args) > 1 && os.Args[1] == "migrate" {
+			if err := runMigrate(db, os.Args[2:]); err != nil {
+				log.Fatal("Migration failed:", err)
+			}
+			return
+		}
+
+		// Run migrations
+		if _, err := migrations.Up(db); err != nil {
+			log.Fatal("Failed to run migrations:", err)
+		}
+
+		// "main owner <login> [organization_id]" names an owner without starting the server
+		if len(os.Args) > 1 && os.Args[1] == "owner" {
+			if err := runOwner(db, os.Args[2:]); err != nil {
+				log.Fatal("Failed to set owner:", err)
+			}
+			return
+		}
+
+		store = models.NewPostgresStore(db)
+		if secret == "" {
+			log.Fatal("JWT_SECRET environment variable is required")
+		}
+	}
+
+	// Analyze uploaded machine tracks in the background; uploads wake it up sooner
+	analyzer := detect.NewAnalyzer(store, trackAnalysisInterval)
+	go analyzer.Run(context.Background())
+
+	// Initialize handlers
+	h := handlers.NewHandler(store, analyzer, auth.NewTokens([]byte(secret)), codeSender(memory))
+
+	// Setup routes
+	r := mux.NewRouter()
+	setupRoutes(r, h)
+
+	// Get port from environment or use default
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+
+	log.Printf("Server starting on port %s", port)
+	log.Fatal(http.ListenAndServe(":"+port, r))
+}
+
+func openDatabase() *sql.DB {
 	// Get database URL from environment
 	databaseURL := os.Getenv("DATABASE_URL")
 	if databaseURL == "" {
@@ -24,7 +104,6 @@ func main() {
 	if err != nil {
 		log.Fatal("Failed to connect to database:", err)
 	}
-	defer db.Close()
 
 	// Test database connection
 	if err := db.Ping(); err != nil {
@@ -32,75 +111,140 @@ func main() {
 	}
 
 	log.Println("Database connection established")
+	return db
+}
 
-	// Initialize models with database connection
-	models.InitDB(db)
-
-	// Run migrations
-	if err := models.RunMigrations(); err != nil {
-		log.Fatal("Failed to run migrations:", err)
+// codeSender emails login codes through the SMTP server in SMTP_ADDR
+// ("host:port"). Without one, codes are only logged with in-memory storage,
+// and login codes are disabled (nil) otherwise: anyone reading the log of a
+// real deployment could log in as any worker.
+func codeSender(memory bool) auth.Sender {
+	addr := os.Getenv("SMTP_ADDR")
+	if addr == "" {
+		if !memory {
+			log.Println("SMTP_ADDR not set; login codes are disabled")
+			return nil
+		}
+		log.Println("SMTP_ADDR not set; login codes will only be logged")
+		return auth.LogSender{}
 	}
 
-	// Initialize handlers
-	h := handlers.NewHandler(db)
-
-	// Setup routes
-	r := mux.NewRouter()
-	setupRoutes(r, h)
-
-	// Get port from environment or use default
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	sender := auth.SMTPSender{Addr: addr, From: os.Getenv("SMTP_FROM")}
+	if user := os.Getenv("SMTP_USER"); user != "" {
+		host, _, _ := strings.Cut(addr, ":")
+		sender.Auth = smtp.PlainAuth("", user, os.Getenv("SMTP_PASSWORD"), host)
 	}
-
-	log.Printf("Server starting on port %s", port)
-	log.Fatal(http.ListenAndServe(":"+port, r))
+	return sender
 }
 
 func setupRoutes(r *mux.Router, h *handlers.Handler) {
-	// API prefix
+	// Authentication endpoints; registered first as they are the only ones open
+	authAPI := r.PathPrefix("/api/v1/auth").Subrouter()
+	authAPI.HandleFunc("/setup", h.Setup).Methods("POST")
+	authAPI.HandleFunc("/login", h.Login).Methods("POST")
+	authAPI.HandleFunc("/code", h.SendLoginCode).Methods("POST")
+	authAPI.HandleFunc("/code/verify", h.VerifyLoginCode).Methods("POST")
+	authAPI.HandleFunc("/refresh", h.RefreshTokens).Methods("POST")
+	authAPI.HandleFunc("/logout", h.Logout).Methods("POST")
+	authAPI.Handle("/password", h.RequireAuth(http.HandlerFunc(h.ChangePassword))).Methods("PUT")
+	authAPI.Handle("/switch", h.RequireAuth(http.HandlerFunc(h.SwitchOrganization))).Methods("POST")
+
+	// API prefix; every request needs an access token. h.Scoped limits the
+	// stores to the organization the worker is logged in to, and h.Allow
+	// also limits changes to the access roles in auth.Can
 	api := r.PathPrefix("/api/v1").Subrouter()
+	api.Use(h.RequireAuth)
+
+	// Organizations endpoints
+	api.HandleFunc("/organizations", h.GetOrganizations).Methods("GET")
+	api.HandleFunc("/organizations", h.Allow(auth.ManageOrganization, (*handlers.Handler).CreateOrganization)).Methods("POST")
+	api.HandleFunc("/organization", h.GetOrganization).Methods("GET")
+	api.HandleFunc("/organization", h.Allow(auth.ManageOrganization, (*handlers.Handler).UpdateOrganization)).Methods("PUT")
+	api.HandleFunc("/organization/invitations", h.Allow(auth.ManageWorkers, (*handlers.Handler).InviteMember)).Methods("POST")
+	api.HandleFunc("/invitations", h.GetInvitations).Methods("GET")
+	api.HandleFunc("/invitations/{organizationId}/accept", h.AcceptInvitation).Methods("POST")
+	api.HandleFunc("/invitations/{organizationId}/decline", h.DeclineInvitation).Methods("POST")
 
 	// Workers endpoints
-	api.HandleFunc("/workers", h.CreateWorker).Methods("POST")
-	api.HandleFunc("/workers", h.GetWorkers).Methods("GET")
-	api.HandleFunc("/workers/{id}", h.GetWorker).Methods("GET")
-	api.HandleFunc("/workers/{id}", h.UpdateWorker).Methods("PUT")
-	api.HandleFunc("/workers/{id}", h.DeleteWorker).Methods("DELETE")
+	api.HandleFunc("/workers", h.Allow(auth.ManageWorkers, (*handlers.Handler).CreateWorker)).Methods("POST")
+	api.HandleFunc("/workers", h.Scoped((*handlers.Handler).GetWorkers)).Methods("GET")
+	api.HandleFunc("/workers/{id}", h.Scoped((*handlers.Handler).GetWorker)).Methods("GET")
+	api.HandleFunc("/workers/{id}", h.Allow(auth.ManageWorkers, (*handlers.Handler).UpdateWorker)).Methods("PUT")
+	api.HandleFunc("/workers/{id}", h.Allow(auth.ManageWorkers, (*handlers.Handler).DeleteWorker)).Methods("DELETE")
 
 	// Fields endpoints
-	api.HandleFunc("/fields", h.CreateField).Methods("POST")
-	api.HandleFunc("/fields", h.GetFields).Methods("GET")
-	api.HandleFunc("/fields/{id}", h.GetField).Methods("GET")
-	api.HandleFunc("/fields/{id}", h.UpdateField).Methods("PUT")
-	api.HandleFunc("/fields/{id}", h.DeleteField).Methods("DELETE")
+	api.HandleFunc("/fields", h.Allow(auth.ManageFields, (*handlers.Handler).CreateField)).Methods("POST")
+	api.HandleFunc("/fields", h.Scoped((*handlers.Handler).GetFields)).Methods("GET")
+	api.HandleFunc("/fields/import", h.Allow(auth.ManageFields, (*handlers.Handler).ImportFields)).Methods("POST")
+	api.HandleFunc("/fields/export", h.Scoped((*handlers.Handler).ExportFields)).Methods("GET")
+	api.HandleFunc("/fields/at", h.Scoped((*handlers.Handler).GetFieldsAt)).Methods("GET")
+	api.HandleFunc("/fields/nearest", h.Scoped((*handlers.Handler).GetNearestFields)).Methods("GET")
+	api.HandleFunc("/fields/merge", h.Allow(auth.ManageFields, (*handlers.Handler).MergeFields)).Methods("POST")
+	api.HandleFunc("/fields/{id}", h.Scoped((*handlers.Handler).GetField)).Methods("GET")
+	api.HandleFunc("/fields/{id}", h.Allow(auth.ManageFields, (*handlers.Handler).UpdateField)).Methods("PUT")
+	api.HandleFunc("/fields/{id}", h.Allow(auth.ManageFields, (*handlers.Handler).DeleteField)).Methods("DELETE")
+	api.HandleFunc("/fields/{id}/coverage", h.Scoped((*handlers.Handler).GetFieldCoverage)).Methods("GET")
+	api.HandleFunc("/fields/{id}/split", h.Allow(auth.ManageFields, (*handlers.Handler).SplitField)).Methods("POST")
+	api.HandleFunc("/fields/{id}/versions", h.Scoped((*handlers.Handler).GetFieldVersions)).Methods("GET")
+	api.HandleFunc("/fields/{id}/lineage", h.Scoped((*handlers.Handler).GetFieldLineage)).Methods("GET")
+	api.HandleFunc("/tiles/fields/{z:[0-9]+}/{x:[0-9]+}/{y:[0-9]+}.mvt", h.Scoped((*handlers.Handler).GetFieldTile)).Methods("GET")
+
+	// Regions endpoints
+	api.HandleFunc("/regions", h.Allow(auth.ManageFields, (*handlers.Handler).CreateRegion)).Methods("POST")
+	api.HandleFunc("/regions", h.Scoped((*handlers.Handler).GetRegions)).Methods("GET")
+	api.HandleFunc("/regions/{id}", h.Scoped((*handlers.Handler).GetRegion)).Methods("GET")
+	api.HandleFunc("/regions/{id}", h.Allow(auth.ManageFields, (*handlers.Handler).UpdateRegion)).Methods("PUT")
+	api.HandleFunc("/regions/{id}", h.Allow(auth.ManageFields, (*handlers.Handler).DeleteRegion)).Methods("DELETE")
 
 	// Schedules endpoints
-	api.HandleFunc("/schedules", h.CreateSchedule).Methods("POST")
-	api.HandleFunc("/schedules", h.GetSchedules).Methods("GET")
-	api.HandleFunc("/schedules/{id}", h.GetSchedule).Methods("GET")
-	api.HandleFunc("/schedules/{id}", h.UpdateSchedule).Methods("PUT")
-	api.HandleFunc("/schedules/{id}", h.DeleteSchedule).Methods("DELETE")
-	api.HandleFunc("/workers/{workerId}/schedules", h.GetWorkerSchedules).Methods("GET")
+	api.HandleFunc("/schedules", h.Allow(auth.ManageSchedules, (*handlers.Handler).CreateSchedule)).Methods("POST")
+	api.HandleFunc("/schedules", h.Scoped((*handlers.Handler).GetSchedules)).Methods("GET")
+	api.HandleFunc("/schedules/{id}", h.Scoped((*handlers.Handler).GetSchedule)).Methods("GET")
+	api.HandleFunc("/schedules/{id}", h.Allow(auth.ManageSchedules, (*handlers.Handler).UpdateSchedule)).Methods("PUT")
+	api.HandleFunc("/schedules/{id}", h.Allow(auth.ManageSchedules, (*handlers.Handler).DeleteSchedule)).Methods("DELETE")
+	api.HandleFunc("/workers/{workerId}/schedules", h.Scoped((*handlers.Handler).GetWorkerSchedules)).Methods("GET")
 
 	// Operations endpoints
-	api.HandleFunc("/operations", h.CreateOperation).Methods("POST")
-	api.HandleFunc("/operations", h.GetOperations).Methods("GET")
-	api.HandleFunc("/operations/{id}", h.GetOperation).Methods("GET")
-	api.HandleFunc("/operations/{id}", h.UpdateOperation).Methods("PUT")
-	api.HandleFunc("/operations/{id}", h.DeleteOperation).Methods("DELETE")
-	api.HandleFunc("/operations/{id}/complete", h.CompleteOperation).Methods("POST")
-	api.HandleFunc("/operations/{id}/start", h.StartOperation).Methods("POST")
-	api.HandleFunc("/operations/{id}/reject", h.RejectOperation).Methods("POST")
+	api.HandleFunc("/operations", h.Allow(auth.ManageOperations, (*handlers.Handler).CreateOperation)).Methods("POST")
+	api.HandleFunc("/operations", h.Scoped((*handlers.Handler).GetOperations)).Methods("GET")
+	api.HandleFunc("/operations/unassigned", h.Scoped((*handlers.Handler).GetUnassignedOperations)).Methods("GET")
+	api.HandleFunc("/operations/export", h.Scoped((*handlers.Handler).ExportOperations)).Methods("GET")
+	api.HandleFunc("/operations/{id}", h.Scoped((*handlers.Handler).GetOperation)).Methods("GET")
+	api.HandleFunc("/operations/{id}", h.Allow(auth.ManageOperations, (*handlers.Handler).UpdateOperation)).Methods("PUT")
+	api.HandleFunc("/operations/{id}", h.Allow(auth.ManageOperations, (*handlers.Handler).DeleteOperation)).Methods("DELETE")
+	api.HandleFunc("/operations/{id}/history", h.Scoped((*handlers.Handler).GetOperationHistory)).Methods("GET")
+	api.HandleFunc("/operations/{id}/coverage", h.Scoped((*handlers.Handler).SetOperationCoverage)).Methods("PUT")
+	api.HandleFunc("/operations/{id}/track", h.Scoped((*handlers.Handler).AddOperationTrack)).Methods("POST")
+	api.HandleFunc("/operations/{id}/track", h.Scoped((*handlers.Handler).GetOperationTrack)).Methods("GET")
+	api.HandleFunc("/operations/{id}/complete", h.Scoped((*handlers.Handler).CompleteOperation)).Methods("POST")
+	api.HandleFunc("/operations/{id}/start", h.Scoped((*handlers.Handler).StartOperation)).Methods("POST")
+	api.HandleFunc("/operations/{id}/pause", h.Scoped((*handlers.Handler).PauseOperation)).Methods("POST")
+	api.HandleFunc("/operations/{id}/resume", h.Scoped((*handlers.Handler).ResumeOperation)).Methods("POST")
+	api.HandleFunc("/operations/{id}/reject", h.Scoped((*handlers.Handler).RejectOperation)).Methods("POST")
+	api.HandleFunc("/operations/{id}/assign", h.Allow(auth.ManageOperations, (*handlers.Handler).AssignOperation)).Methods("POST")
+	api.HandleFunc("/operations/{id}/cancel", h.Allow(auth.ManageOperations, (*handlers.Handler).CancelOperation)).Methods("POST")
+
+	// The logged in worker's day, for the mobile app; open to every worker
+	api.HandleFunc("/me/today", h.Scoped((*handlers.Handler).GetMyDay)).Methods("GET")
+	api.HandleFunc("/me/operations", h.Scoped((*handlers.Handler).CreateMyOperation)).Methods("POST")
+
+	// Operation detection endpoints
+	api.HandleFunc("/tracks", h.Scoped((*handlers.Handler).UploadMachineTrack)).Methods("POST")
+	api.HandleFunc("/tracks", h.Scoped((*handlers.Handler).GetMachineTracks)).Methods("GET")
+	api.HandleFunc("/tracks/{id}", h.Scoped((*handlers.Handler).GetMachineTrack)).Methods("GET")
+	api.HandleFunc("/proposals", h.Scoped((*handlers.Handler).GetOperationProposals)).Methods("GET")
+	api.HandleFunc("/proposals/{id}", h.Scoped((*handlers.Handler).GetOperationProposal)).Methods("GET")
+	api.HandleFunc("/proposals/{id}/confirm", h.Allow(auth.ManageOperations, (*handlers.Handler).ConfirmOperationProposal)).Methods("POST")
+	api.HandleFunc("/proposals/{id}/dismiss", h.Allow(auth.ManageOperations, (*handlers.Handler).DismissOperationProposal)).Methods("POST")
 
 	// Reports endpoints
-	api.HandleFunc("/reports/daily", h.GetDailyReport).Methods("GET")
-	api.HandleFunc("/reports/monthly", h.GetMonthlyReport).Methods("GET")
-	api.HandleFunc("/reports/yearly", h.GetYearlyReport).Methods("GET")
-	api.HandleFunc("/reports/daily/export", h.ExportDailyReport).Methods("GET")
-	api.HandleFunc("/reports/monthly/export", h.ExportMonthlyReport).Methods("GET")
-	api.HandleFunc("/reports/yearly/export", h.ExportYearlyReport).Methods("GET")
+	api.HandleFunc("/reports/daily", h.Allow(auth.ViewReports, (*handlers.Handler).GetDailyReport)).Methods("GET")
+	api.HandleFunc("/reports/monthly", h.Allow(auth.ViewReports, (*handlers.Handler).GetMonthlyReport)).Methods("GET")
+	api.HandleFunc("/reports/yearly", h.Allow(auth.ViewReports, (*handlers.Handler).GetYearlyReport)).Methods("GET")
+	api.HandleFunc("/reports/range", h.Allow(auth.ViewReports, (*handlers.Handler).GetRangeReport)).Methods("GET")
+	api.HandleFunc("/reports/daily/export", h.Allow(auth.ViewReports, (*handlers.Handler).ExportDailyReport)).Methods("GET")
+	api.HandleFunc("/reports/monthly/export", h.Allow(auth.ViewReports, (*handlers.Handler).ExportMonthlyReport)).Methods("GET")
+	api.HandleFunc("/reports/yearly/export", h.Allow(auth.ViewReports, (*handlers.Handler).ExportYearlyReport)).Methods("GET")
 
 	// Health check
 	r.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,560 @@
+package main
+
+import (
+	"agroport/auth"
+	"agroport/detect"
+	"agroport/handlers"
+	"agroport/models"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// The tests run the whole HTTP API against the in-memory store.
+
+// testCodes keeps the login codes sent, by address.
+type testCodes struct {
+	mu    sync.Mutex
+	codes map[string]string
+}
+
+func (c *testCodes) SendCode(channel, to, code string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.codes[to] = code
+	return nil
+}
+
+type testAPI struct {
+	t      *testing.T
+	server *httptest.Server
+	codes  *testCodes
+}
+
+// response is the envelope of every answer.
+type response struct {
+	Message   string                `json:"message"`
+	Data      json.RawMessage       `json:"data"`
+	Conflicts *models.ConflictError `json:"conflicts"`
+}
+
+func newTestAPI(t *testing.T) *testAPI {
+	t.Helper()
+	store := models.NewMemoryStore()
+	codes := &testCodes{codes: make(map[string]string)}
+	h := handlers.NewHandler(store, detect.NewAnalyzer(store, time.Hour), auth.NewTokens([]byte("test secret")), codes)
+	r := mux.NewRouter()
+	setupRoutes(r, h)
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+	return &testAPI{t: t, server: server, codes: codes}
+}
+
+// call sends body as JSON with the token, fails the test unless the answer
+// has the wanted status, and decodes its data into out.
+func (a *testAPI) call(token, method, path string, body interface{}, want int, out interface{}) response {
+	a.t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			a.t.Fatal(err)
+		}
+	}
+	req, err := http.NewRequest(method, a.server.URL+"/api/v1"+path, &buf)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var r response
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		a.t.Fatalf("%s %s: decoding response: %v", method, path, err)
+	}
+	if resp.StatusCode != want {
+		a.t.Fatalf("%s %s: status %d (%s), want %d", method, path, resp.StatusCode, r.Message, want)
+	}
+	if out != nil {
+		if err := json.Unmarshal(r.Data, out); err != nil {
+			a.t.Fatalf("%s %s: decoding data: %v", method, path, err)
+		}
+	}
+	return r
+}
+
+type tokens struct {
+	AccessToken  string              `json:"access_token"`
+	Worker       models.Worker       `json:"worker"`
+	Organization models.Organization `json:"organization"`
+}
+
+// setup creates the first organization and returns its owner's token.
+func (a *testAPI) setup() string {
+	a.t.Helper()
+	var t tokens
+	a.call("", "POST", "/auth/setup", map[string]string{
+		"organization": "Alpha", "name": "Ann", "role": "manager", "email": "ann@example.com", "password": "secret123",
+	}, http.StatusOK, &t)
+	return t.AccessToken
+}
+
+// logIn logs a worker in with a one-time code.
+func (a *testAPI) logIn(login string, orgID int) string {
+	a.t.Helper()
+	a.call("", "POST", "/auth/code", map[string]string{"login": login, "channel": "email"}, http.StatusAccepted, nil)
+	a.codes.mu.Lock()
+	code := a.codes.codes[login]
+	a.codes.mu.Unlock()
+	var t tokens
+	a.call("", "POST", "/auth/code/verify", map[string]interface{}{
+		"login": login, "code": code, "organization_id": orgID,
+	}, http.StatusOK, &t)
+	return t.AccessToken
+}
+
+func (a *testAPI) createWorker(token, name, email, accessRole string) models.Worker {
+	a.t.Helper()
+	var w models.Worker
+	a.call(token, "POST", "/workers", map[string]string{
+		"name": name, "role": "tractor_driver", "email": email, "access_role": accessRole,
+	}, http.StatusCreated, &w)
+	return w
+}
+
+var testBoundary = json.RawMessage(`{"type":"Polygon","coordinates":[[[25,42],[25.01,42],[25.01,42.01],[25,42.01],[25,42]]]}`)
+
+func (a *testAPI) createField(token, name string) models.Field {
+	a.t.Helper()
+	var f models.Field
+	a.call(token, "POST", "/fields", map[string]interface{}{"name": name, "coordinates": testBoundary}, http.StatusCreated, &f)
+	return f
+}
+
+func (a *testAPI) createOperation(token string, body map[string]interface{}, want int) models.Operation {
+	a.t.Helper()
+	var o models.Operation
+	if want != http.StatusCreated {
+		a.call(token, "POST", "/operations", body, want, nil)
+		return o
+	}
+	a.call(token, "POST", "/operations", body, want, &o)
+	return o
+}
+
+func TestSetupOnlyOnce(t *testing.T) {
+	api := newTestAPI(t)
+	api.setup()
+	api.call("", "POST", "/auth/setup", map[string]string{
+		"organization": "Beta", "name": "Bob", "role": "manager", "email": "bob@example.com", "password": "secret123",
+	}, http.StatusConflict, nil)
+	api.call("", "POST", "/auth/login", map[string]string{"login": "ann@example.com", "password": "secret123"}, http.StatusOK, nil)
+	api.call("", "GET", "/fields", nil, http.StatusUnauthorized, nil)
+}
+
+func TestLoginCodesDisabled(t *testing.T) {
+	store := models.NewMemoryStore()
+	h := handlers.NewHandler(store, detect.NewAnalyzer(store, time.Hour), auth.NewTokens([]byte("test secret")), nil)
+	r := mux.NewRouter()
+	setupRoutes(r, h)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("POST", "/api/v1/auth/code", strings.NewReader(`{"login": "ann@example.com"}`)))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("sending a code without a sender answered %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestFirstPasswordNeedsLoginCode(t *testing.T) {
+	api := newTestAPI(t)
+	owner := api.setup()
+	w := api.createWorker(owner, "Ivan", "ivan@example.com", auth.RoleOperator)
+	var org models.Organization
+	api.call(owner, "GET", "/organization", nil, http.StatusOK, &org)
+
+	// An access token alone, e.g. a stolen one, is not enough
+	pair, err := auth.NewTokens([]byte("test secret")).Issue(w.ID, org.ID, "stolen", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := pair.AccessToken
+	api.call(token, "PUT", "/auth/password", map[string]string{"password": "secret123"}, http.StatusForbidden, nil)
+	api.call(token, "PUT", "/auth/password", map[string]string{"code": "000000", "password": "secret123"}, http.StatusForbidden, nil)
+
+	api.call("", "POST", "/auth/code", map[string]string{"login": "ivan@example.com", "channel": "email"}, http.StatusAccepted, nil)
+	api.codes.mu.Lock()
+	code := api.codes.codes["ivan@example.com"]
+	api.codes.mu.Unlock()
+	api.call(token, "PUT", "/auth/password", map[string]string{"code": code, "password": "secret123"}, http.StatusOK, nil)
+	api.call("", "POST", "/auth/login", map[string]string{"login": "ivan@example.com", "password": "secret123"}, http.StatusOK, nil)
+	api.call(token, "PUT", "/auth/password", map[string]string{"code": code, "password": "secret456"}, http.StatusForbidden, nil)
+}
+
+func TestWorkerCRUD(t *testing.T) {
+	api := newTestAPI(t)
+	owner := api.setup()
+
+	w := api.createWorker(owner, "Ivan", "ivan@example.com", auth.RoleOperator)
+	path := fmt.Sprintf("/workers/%d", w.ID)
+
+	var got models.Worker
+	api.call(owner, "GET", path, nil, http.StatusOK, &got)
+	if got.Name != "Ivan" || got.AccessRole != auth.RoleOperator {
+		t.Errorf("got worker %+v", got)
+	}
+
+	api.call(owner, "PUT", path, map[string]string{
+		"name": "Ivan Petrov", "role": "harvester_driver", "email": "ivan@example.com", "access_role": auth.RoleForeman,
+	}, http.StatusOK, nil)
+	api.call(owner, "GET", path, nil, http.StatusOK, &got)
+	if got.Name != "Ivan Petrov" || got.Role != "harvester_driver" || got.AccessRole != auth.RoleForeman {
+		t.Errorf("updated worker is %+v", got)
+	}
+
+	var workers []models.Worker
+	api.call(owner, "GET", "/workers", nil, http.StatusOK, &workers)
+	if len(workers) != 2 {
+		t.Errorf("got %d workers, want 2", len(workers))
+	}
+
+	api.call(owner, "DELETE", path, nil, http.StatusOK, nil)
+	api.call(owner, "GET", path, nil, http.StatusNotFound, nil)
+}
+
+func TestFieldCRUD(t *testing.T) {
+	api := newTestAPI(t)
+	owner := api.setup()
+
+	f := api.createField(owner, "North")
+	path := fmt.Sprintf("/fields/%d", f.ID)
+	if f.Area <= 0 {
+		t.Errorf("field area is %v", f.Area)
+	}
+
+	api.call(owner, "PUT", path, map[string]interface{}{"name": "North 2", "coordinates": testBoundary}, http.StatusOK, nil)
+	var got models.Field
+	api.call(owner, "GET", path, nil, http.StatusOK, &got)
+	if got.Name != "North 2" {
+		t.Errorf("field name is %q, want %q", got.Name, "North 2")
+	}
+
+	api.call(owner, "POST", "/fields", map[string]interface{}{"name": "Bad", "coordinates": json.RawMessage(`{"type":"Point"}`)},
+		http.StatusBadRequest, nil)
+
+	api.call(owner, "DELETE", path, nil, http.StatusOK, nil)
+	api.call(owner, "GET", path, nil, http.StatusNotFound, nil)
+}
+
+func TestScheduleCRUD(t *testing.T) {
+	api := newTestAPI(t)
+	owner := api.setup()
+	w := api.createWorker(owner, "Ivan", "ivan@example.com", auth.RoleOperator)
+
+	var s models.Schedule
+	api.call(owner, "POST", "/schedules", map[string]interface{}{"worker_id": w.ID, "date": "2026-05-04T00:00:00Z"},
+		http.StatusCreated, &s)
+	path := fmt.Sprintf("/schedules/%d", s.ID)
+
+	r := api.call(owner, "POST", "/schedules", map[string]interface{}{"worker_id": w.ID, "date": "2026-05-04T00:00:00Z"},
+		http.StatusConflict, nil)
+	if r.Conflicts == nil || len(r.Conflicts.Schedules) != 1 || r.Conflicts.Schedules[0].ID != s.ID {
+		t.Errorf("conflicts are %+v, want schedule %d", r.Conflicts, s.ID)
+	}
+
+	api.call(owner, "PUT", path, map[string]interface{}{"worker_id": w.ID, "date": "2026-05-05T00:00:00Z"}, http.StatusOK, nil)
+	var schedules []models.Schedule
+	api.call(owner, "GET", fmt.Sprintf("/workers/%d/schedules", w.ID), nil, http.StatusOK, &schedules)
+	if len(schedules) != 1 || schedules[0].Date.Format("2006-01-02") != "2026-05-05" {
+		t.Errorf("worker schedules are %+v", schedules)
+	}
+
+	api.call(owner, "DELETE", path, nil, http.StatusOK, nil)
+	api.call(owner, "GET", path, nil, http.StatusNotFound, nil)
+}
+
+func TestOperationTransitions(t *testing.T) {
+	api := newTestAPI(t)
+	owner := api.setup()
+	w := api.createWorker(owner, "Ivan", "ivan@example.com", auth.RoleOperator)
+	f := api.createField(owner, "North")
+
+	api.createOperation(owner, map[string]interface{}{
+		"worker_id": w.ID, "field_id": f.ID, "type": "plowing", "status": models.StatusCompleted,
+	}, http.StatusConflict)
+	o := api.createOperation(owner, map[string]interface{}{"worker_id": w.ID, "field_id": f.ID, "type": "plowing"}, http.StatusCreated)
+	if o.Status != models.StatusPlanned {
+		t.Fatalf("new operation is %q, want planned", o.Status)
+	}
+	path := fmt.Sprintf("/operations/%d", o.ID)
+
+	api.call(owner, "PUT", path, map[string]interface{}{
+		"worker_id": w.ID, "field_id": f.ID, "type": "plowing", "status": models.StatusInProgress,
+	}, http.StatusConflict, nil)
+
+	steps := []struct {
+		action string
+		want   int
+		status string
+	}{
+		{"complete", http.StatusConflict, models.StatusPlanned},
+		{"start", http.StatusOK, models.StatusInProgress},
+		{"start", http.StatusConflict, models.StatusInProgress},
+		{"pause", http.StatusOK, models.StatusPaused},
+		{"resume", http.StatusOK, models.StatusInProgress},
+		{"complete", http.StatusOK, models.StatusCompleted},
+		{"cancel", http.StatusConflict, models.StatusCompleted},
+		{"start", http.StatusConflict, models.StatusCompleted},
+	}
+	for _, step := range steps {
+		api.call(owner, "POST", path+"/"+step.action, nil, step.want, nil)
+		var got models.Operation
+		api.call(owner, "GET", path, nil, http.StatusOK, &got)
+		if got.Status != step.status {
+			t.Fatalf("after %s the operation is %q, want %q", step.action, got.Status, step.status)
+		}
+	}
+
+	var events []models.OperationEvent
+	api.call(owner, "GET", path+"/history", nil, http.StatusOK, &events)
+	var kinds []string
+	for _, e := range events {
+		kinds = append(kinds, e.Event)
+		if e.Actor == "" {
+			t.Errorf("event %q has no actor", e.Event)
+		}
+	}
+	if len(events) != 5 {
+		t.Errorf("history is %v, want created and four transitions", kinds)
+	}
+
+	api.call(owner, "POST", "/operations/999/start", nil, http.StatusNotFound, nil)
+}
+
+func TestUpdateKeepsWorker(t *testing.T) {
+	api := newTestAPI(t)
+	owner := api.setup()
+	w := api.createWorker(owner, "Ivan", "ivan@example.com", auth.RoleOperator)
+	f := api.createField(owner, "North")
+	o := api.createOperation(owner, map[string]interface{}{"worker_id": w.ID, "field_id": f.ID, "type": "plowing"}, http.StatusCreated)
+	path := fmt.Sprintf("/operations/%d", o.ID)
+
+	api.call(owner, "POST", path+"/start", nil, http.StatusOK, nil)
+	api.call(owner, "PUT", path, map[string]interface{}{"field_id": f.ID, "type": "plowing", "notes": "wet"}, http.StatusOK, nil)
+	var got models.Operation
+	api.call(owner, "GET", path, nil, http.StatusOK, &got)
+	if got.WorkerID != w.ID || got.Notes != "wet" {
+		t.Errorf("updated operation has worker %d and notes %q, want %d and %q", got.WorkerID, got.Notes, w.ID, "wet")
+	}
+}
+
+func TestOperationsStayOffRetiredFields(t *testing.T) {
+	api := newTestAPI(t)
+	owner := api.setup()
+	w := api.createWorker(owner, "Ivan", "ivan@example.com", auth.RoleOperator)
+	north, south, east := api.createField(owner, "North"), api.createField(owner, "South"), api.createField(owner, "East")
+	done := api.createOperation(owner, map[string]interface{}{"worker_id": w.ID, "field_id": north.ID, "type": "plowing"}, http.StatusCreated)
+	api.call(owner, "POST", fmt.Sprintf("/operations/%d/start", done.ID), nil, http.StatusOK, nil)
+	api.call(owner, "POST", fmt.Sprintf("/operations/%d/complete", done.ID), nil, http.StatusOK, nil)
+	o := api.createOperation(owner, map[string]interface{}{"worker_id": w.ID, "field_id": east.ID, "type": "sowing"}, http.StatusCreated)
+
+	var merged models.Field
+	api.call(owner, "POST", "/fields/merge", map[string]interface{}{"field_ids": []int{north.ID, south.ID}, "name": "Big"},
+		http.StatusCreated, &merged)
+
+	path := fmt.Sprintf("/operations/%d", o.ID)
+	api.call(owner, "PUT", path, map[string]interface{}{"field_id": north.ID, "type": "sowing"}, http.StatusConflict, nil)
+	api.call(owner, "PUT", path, map[string]interface{}{"field_id": merged.ID, "type": "sowing"}, http.StatusOK, nil)
+	api.call(owner, "PUT", fmt.Sprintf("/operations/%d", done.ID), map[string]interface{}{
+		"field_id": north.ID, "type": "plowing", "notes": "stony",
+	}, http.StatusOK, nil)
+}
+
+func TestOperatorWorksOnlyOwnOperations(t *testing.T) {
+	api := newTestAPI(t)
+	owner := api.setup()
+	ivan := api.createWorker(owner, "Ivan", "ivan@example.com", auth.RoleOperator)
+	petar := api.createWorker(owner, "Petar", "petar@example.com", auth.RoleOperator)
+	f := api.createField(owner, "North")
+	own := api.createOperation(owner, map[string]interface{}{"worker_id": ivan.ID, "field_id": f.ID, "type": "plowing"}, http.StatusCreated)
+	other := api.createOperation(owner, map[string]interface{}{"worker_id": petar.ID, "field_id": f.ID, "type": "sowing"}, http.StatusCreated)
+
+	operator := api.logIn("ivan@example.com", 0)
+	api.call(operator, "POST", "/fields", map[string]interface{}{"name": "South", "coordinates": testBoundary}, http.StatusForbidden, nil)
+	api.call(operator, "GET", "/reports/daily", nil, http.StatusForbidden, nil)
+	api.call(operator, "POST", fmt.Sprintf("/operations/%d/start", other.ID), nil, http.StatusForbidden, nil)
+	api.call(operator, "POST", fmt.Sprintf("/operations/%d/start", own.ID), nil, http.StatusOK, nil)
+}
+
+func TestOnlyManagersAutoConfirmTracks(t *testing.T) {
+	api := newTestAPI(t)
+	owner := api.setup()
+	ivan := api.createWorker(owner, "Ivan", "ivan@example.com", auth.RoleOperator)
+	track := []map[string]interface{}{
+		{"lat": 42.001, "lon": 25.001, "time": "2026-05-04T08:00:00Z"},
+		{"lat": 42.009, "lon": 25.009, "time": "2026-05-04T09:00:00Z"},
+	}
+
+	operator := api.logIn("ivan@example.com", 0)
+	path := fmt.Sprintf("/tracks?worker_id=%d", ivan.ID)
+	api.call(operator, "POST", path+"&auto_confirm=true", track, http.StatusForbidden, nil)
+	api.call(operator, "POST", path, track, http.StatusAccepted, nil)
+	api.call(owner, "POST", path+"&auto_confirm=true", track, http.StatusAccepted, nil)
+}
+
+func TestDoubleBookedOperations(t *testing.T) {
+	api := newTestAPI(t)
+	owner := api.setup()
+	w := api.createWorker(owner, "Ivan", "ivan@example.com", auth.RoleOperator)
+	f := api.createField(owner, "North")
+
+	first := api.createOperation(owner, map[string]interface{}{
+		"worker_id": w.ID, "field_id": f.ID, "type": "plowing",
+		"start_time": "2026-05-04T08:00:00Z", "end_time": "2026-05-04T10:00:00Z",
+	}, http.StatusCreated)
+	r := api.call(owner, "POST", "/operations", map[string]interface{}{
+		"worker_id": w.ID, "field_id": f.ID, "type": "sowing",
+		"start_time": "2026-05-04T09:00:00Z", "end_time": "2026-05-04T11:00:00Z",
+	}, http.StatusConflict, nil)
+	if r.Conflicts == nil || len(r.Conflicts.Operations) != 1 || r.Conflicts.Operations[0].ID != first.ID {
+		t.Errorf("conflicts are %+v, want operation %d", r.Conflicts, first.ID)
+	}
+	api.createOperation(owner, map[string]interface{}{
+		"worker_id": w.ID, "field_id": f.ID, "type": "sowing",
+		"start_time": "2026-05-04T10:00:00Z", "end_time": "2026-05-04T11:00:00Z",
+	}, http.StatusCreated)
+
+	a := api.createOperation(owner, map[string]interface{}{"worker_id": w.ID, "field_id": f.ID, "type": "a"}, http.StatusCreated)
+	b := api.createOperation(owner, map[string]interface{}{"worker_id": w.ID, "field_id": f.ID, "type": "b"}, http.StatusCreated)
+	api.call(owner, "POST", fmt.Sprintf("/operations/%d/start", a.ID), nil, http.StatusOK, nil)
+	api.call(owner, "POST", fmt.Sprintf("/operations/%d/start", b.ID), nil, http.StatusConflict, nil)
+	api.call(owner, "POST", fmt.Sprintf("/operations/%d/pause", a.ID), nil, http.StatusOK, nil)
+	api.call(owner, "POST", fmt.Sprintf("/operations/%d/start", b.ID), nil, http.StatusOK, nil)
+	api.call(owner, "POST", fmt.Sprintf("/operations/%d/complete", b.ID), nil, http.StatusOK, nil)
+	api.call(owner, "POST", fmt.Sprintf("/operations/%d/resume", a.ID), nil, http.StatusOK, nil)
+}
+
+func TestStartMyOperation(t *testing.T) {
+	api := newTestAPI(t)
+	owner := api.setup()
+	w := api.createWorker(owner, "Ivan", "ivan@example.com", auth.RoleOperator)
+	f := api.createField(owner, "North")
+	operator := api.logIn("ivan@example.com", 0)
+
+	var first, second models.Operation
+	body := map[string]interface{}{"field_id": f.ID, "type": "plowing"}
+	api.call(operator, "POST", "/me/operations", body, http.StatusCreated, &first)
+	if first.Status != models.StatusInProgress || first.ScheduleID == nil {
+		t.Fatalf("new operation is %q on schedule %v, want in progress on today's schedule", first.Status, first.ScheduleID)
+	}
+	api.call(operator, "POST", "/me/operations", body, http.StatusConflict, nil)
+	api.call(operator, "POST", fmt.Sprintf("/operations/%d/pause", first.ID), nil, http.StatusOK, nil)
+	api.call(operator, "POST", "/me/operations", body, http.StatusCreated, &second)
+
+	var schedules []models.Schedule
+	api.call(owner, "GET", fmt.Sprintf("/workers/%d/schedules", w.ID), nil, http.StatusOK, &schedules)
+	if len(schedules) != 1 || second.ScheduleID == nil || *second.ScheduleID != *first.ScheduleID {
+		t.Errorf("worker has schedules %+v, want both operations on one", schedules)
+	}
+}
+
+func TestStartNewOperationIsAtomic(t *testing.T) {
+	memory := models.NewMemoryStore()
+	org, worker := &models.Organization{Name: "Alpha"}, &models.Worker{Name: "Ann", Role: "manager", Email: "ann@example.com", AccessRole: auth.RoleOwner}
+	if err := memory.Setup(org, worker, ""); err != nil {
+		t.Fatal(err)
+	}
+	store := memory.ForOrganization(org.ID)
+	field := &models.Field{Name: "North", Coordinates: testBoundary}
+	if err := store.CreateField(field); err != nil {
+		t.Fatal(err)
+	}
+	busy := &models.Operation{WorkerID: worker.ID, FieldID: field.ID, Type: "plowing"}
+	if err := store.CreateOperation(busy, "ann"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.StartOperation(busy.ID, "ann"); err != nil {
+		t.Fatal(err)
+	}
+
+	schedule := &models.Schedule{WorkerID: worker.ID, Date: time.Date(2026, 5, 4, 0, 0, 0, 0, time.UTC)}
+	err := store.StartNewOperation(schedule, &models.Operation{WorkerID: worker.ID, FieldID: field.ID, Type: "sowing"}, "ann")
+	var conflict *models.ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("got error %v, want a conflict with operation %d", err, busy.ID)
+	}
+	schedules, _ := store.GetSchedules()
+	operations, _ := store.GetOperations()
+	if len(schedules) != 0 || len(operations) != 1 {
+		t.Errorf("failed start left %d schedules and %d operations, want none and 1", len(schedules), len(operations))
+	}
+}
+
+func TestOrganizationsAreIsolated(t *testing.T) {
+	api := newTestAPI(t)
+	owner := api.setup()
+	api.createField(owner, "North")
+	api.createWorker(owner, "Ivan", "ivan@example.com", auth.RoleOperator)
+
+	var beta models.Membership
+	api.call(owner, "POST", "/organizations", map[string]string{"name": "Beta"}, http.StatusCreated, &beta)
+	var switched tokens
+	api.call(owner, "POST", "/auth/switch", map[string]int{"organization_id": beta.ID}, http.StatusOK, &switched)
+	betaOwner := switched.AccessToken
+
+	var fields []models.Field
+	api.call(betaOwner, "GET", "/fields", nil, http.StatusOK, &fields)
+	if len(fields) != 0 {
+		t.Errorf("Beta sees %d fields of Alpha", len(fields))
+	}
+
+	operator := api.logIn("ivan@example.com", 0)
+	api.call(operator, "POST", "/organizations", map[string]string{"name": "Gamma"}, http.StatusForbidden, nil)
+	api.call(operator, "POST", "/auth/switch", map[string]int{"organization_id": beta.ID}, http.StatusForbidden, nil)
+}
+
+func TestInvitations(t *testing.T) {
+	api := newTestAPI(t)
+	owner := api.setup()
+	ivan := api.createWorker(owner, "Ivan", "ivan@example.com", auth.RoleOperator)
+
+	var beta models.Membership
+	api.call(owner, "POST", "/organizations", map[string]string{"name": "Beta"}, http.StatusCreated, &beta)
+	var switched tokens
+	api.call(owner, "POST", "/auth/switch", map[string]int{"organization_id": beta.ID}, http.StatusOK, &switched)
+	betaOwner := switched.AccessToken
+
+	known := api.call(betaOwner, "POST", "/organization/invitations", map[string]string{"login": "ivan@example.com"}, http.StatusAccepted, nil)
+	unknown := api.call(betaOwner, "POST", "/organization/invitations", map[string]string{"login": "nobody@example.com"}, http.StatusAccepted, nil)
+	if known.Message != unknown.Message {
+		t.Errorf("inviting answers %q for an account and %q for none", known.Message, unknown.Message)
+	}
+
+	operator := api.logIn("ivan@example.com", 0)
+	api.call(operator, "POST", "/auth/switch", map[string]int{"organization_id": beta.ID}, http.StatusForbidden, nil)
+	var invitations []models.Invitation
+	api.call(operator, "GET", "/invitations", nil, http.StatusOK, &invitations)
+	if len(invitations) != 1 || invitations[0].ID != beta.ID {
+		t.Fatalf("invitations are %+v", invitations)
+	}
+	api.call(operator, "POST", fmt.Sprintf("/invitations/%d/accept", beta.ID), nil, http.StatusOK, nil)
+	api.call(operator, "POST", "/auth/switch", map[string]int{"organization_id": beta.ID}, http.StatusOK, nil)
+
+	// Ivan logs in with his email in both organizations now
+	api.call(betaOwner, "PUT", fmt.Sprintf("/workers/%d", ivan.ID), map[string]string{
+		"name": "Ivan", "role": "tractor_driver", "email": "someone@example.com", "access_role": auth.RoleOperator,
+	}, http.StatusConflict, nil)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"agroport/migrations"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+)
+
+// runMigrate handles the migrate subcommand:
+//
+//	main migrate up          apply all pending migrations
+//	main migrate down [n]    revert the last n migrations (default 1)
+//	main migrate status      list migrations and when they were applied
+func runMigrate(db *sql.DB, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: migrate up|down [n]|status")
+	}
+
+	switch args[0] {
+	case "up":
+		count, err := migrations.Up(db)
+		if err != nil {
+			return err
+		}
+		log.Printf("%d migration(s) applied", count)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+			steps = n
+		}
+		count, err := migrations.Down(db, steps)
+		if err != nil {
+			return err
+		}
+		log.Printf("%d migration(s) reverted", count)
+	case "status":
+		statuses, err := migrations.Statuses(db)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(tw, "%06d\t%s\t%s\n", s.Version, s.Name, applied)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown migrate command %q, use up, down or status", args[0])
+	}
+	return nil
+}
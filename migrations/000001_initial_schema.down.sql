@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS operations;
+DROP TABLE IF EXISTS schedules;
+DROP TABLE IF EXISTS fields;
+DROP TABLE IF EXISTS workers;
@@ -0,0 +1,54 @@
+CREATE TABLE IF NOT EXISTS workers (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    email VARCHAR(255) UNIQUE,
+    phone VARCHAR(50),
+    role VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS fields (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    coordinates JSONB NOT NULL,
+    area DECIMAL(10,2) DEFAULT 0,
+    crop_type VARCHAR(100) NOT NULL,
+    period VARCHAR(100) NOT NULL,
+    region VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS schedules (
+    id SERIAL PRIMARY KEY,
+    worker_id INTEGER REFERENCES workers(id) ON DELETE CASCADE,
+    date DATE NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS operations (
+    id SERIAL PRIMARY KEY,
+    schedule_id INTEGER REFERENCES schedules(id) ON DELETE CASCADE,
+    worker_id INTEGER REFERENCES workers(id) ON DELETE CASCADE,
+    field_id INTEGER REFERENCES fields(id) ON DELETE CASCADE,
+    type VARCHAR(100) NOT NULL,
+    description TEXT,
+    status VARCHAR(50) DEFAULT 'planned',
+    start_time TIMESTAMP,
+    end_time TIMESTAMP,
+    completed_at TIMESTAMP,
+    notes TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_schedules_worker_date ON schedules(worker_id, date);
+CREATE INDEX IF NOT EXISTS idx_operations_schedule ON operations(schedule_id) WHERE schedule_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_operations_worker ON operations(worker_id);
+CREATE INDEX IF NOT EXISTS idx_operations_field ON operations(field_id);
+CREATE INDEX IF NOT EXISTS idx_operations_worker_date ON operations(worker_id, DATE(start_time));
+CREATE INDEX IF NOT EXISTS idx_operations_field_date ON operations(field_id, DATE(start_time));
+CREATE INDEX IF NOT EXISTS idx_operations_status ON operations(status);
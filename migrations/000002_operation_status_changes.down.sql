@@ -0,0 +1,2 @@
+ALTER TABLE operations DROP COLUMN IF EXISTS status_changed_by;
+ALTER TABLE operations DROP COLUMN IF EXISTS status_changed_at;
@@ -0,0 +1,2 @@
+ALTER TABLE operations ADD COLUMN IF NOT EXISTS status_changed_at TIMESTAMP;
+ALTER TABLE operations ADD COLUMN IF NOT EXISTS status_changed_by VARCHAR(255);
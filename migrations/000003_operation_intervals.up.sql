@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS operation_intervals (
+    id SERIAL PRIMARY KEY,
+    operation_id INTEGER NOT NULL REFERENCES operations(id) ON DELETE CASCADE,
+    started_at TIMESTAMP NOT NULL,
+    ended_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_operation_intervals_operation ON operation_intervals(operation_id);
+
+-- Operations started before intervals were tracked count as a single interval
+INSERT INTO operation_intervals (operation_id, started_at, ended_at)
+SELECT o.id, o.start_time,
+       COALESCE(o.end_time, o.completed_at, CASE WHEN o.status = 'in_progress' THEN NULL ELSE o.updated_at END)
+FROM operations o
+WHERE o.start_time IS NOT NULL AND o.status IN ('in_progress', 'paused', 'completed')
+  AND NOT EXISTS (SELECT 1 FROM operation_intervals i WHERE i.operation_id = o.id);
@@ -0,0 +1,13 @@
+-- No foreign key, so that the history outlives deleted operations
+CREATE TABLE IF NOT EXISTS operation_events (
+    id SERIAL PRIMARY KEY,
+    operation_id INTEGER NOT NULL,
+    event VARCHAR(50) NOT NULL,
+    old_status VARCHAR(50),
+    new_status VARCHAR(50),
+    changes JSONB,
+    actor VARCHAR(255),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_operation_events_operation ON operation_events(operation_id, created_at);
@@ -0,0 +1,2 @@
+ALTER TABLE operations DROP COLUMN IF EXISTS rejection_reason;
+ALTER TABLE operations DROP COLUMN IF EXISTS rejected_by;
@@ -0,0 +1,2 @@
+ALTER TABLE operations ADD COLUMN IF NOT EXISTS rejected_by INTEGER REFERENCES workers(id) ON DELETE SET NULL;
+ALTER TABLE operations ADD COLUMN IF NOT EXISTS rejection_reason TEXT;
@@ -0,0 +1,2 @@
+ALTER TABLE operations DROP COLUMN IF EXISTS coverage;
+ALTER TABLE operations DROP COLUMN IF EXISTS covered_area;
@@ -0,0 +1,2 @@
+ALTER TABLE operations ADD COLUMN IF NOT EXISTS covered_area DECIMAL(10,2);
+ALTER TABLE operations ADD COLUMN IF NOT EXISTS coverage JSONB;
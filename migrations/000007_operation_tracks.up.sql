@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS operation_tracks (
+    id SERIAL PRIMARY KEY,
+    operation_id INTEGER NOT NULL REFERENCES operations(id) ON DELETE CASCADE,
+    points JSONB NOT NULL,
+    started_at TIMESTAMP NOT NULL,
+    ended_at TIMESTAMP NOT NULL,
+    distance_m DOUBLE PRECISION NOT NULL DEFAULT 0,
+    width_m DOUBLE PRECISION,
+    covered_area DECIMAL(10,2),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_operation_tracks_operation ON operation_tracks(operation_id, started_at);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS operation_proposals;
+DROP TABLE IF EXISTS machine_tracks;
@@ -0,0 +1,37 @@
+CREATE TABLE IF NOT EXISTS machine_tracks (
+    id SERIAL PRIMARY KEY,
+    worker_id INTEGER NOT NULL REFERENCES workers(id) ON DELETE CASCADE,
+    operation_type VARCHAR(100) NOT NULL DEFAULT '',
+    points JSONB NOT NULL,
+    started_at TIMESTAMP NOT NULL,
+    ended_at TIMESTAMP NOT NULL,
+    distance_m DOUBLE PRECISION NOT NULL DEFAULT 0,
+    width_m DOUBLE PRECISION,
+    auto_confirm BOOLEAN NOT NULL DEFAULT FALSE,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    analyzed_at TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS operation_proposals (
+    id SERIAL PRIMARY KEY,
+    track_id INTEGER NOT NULL REFERENCES machine_tracks(id) ON DELETE CASCADE,
+    worker_id INTEGER NOT NULL REFERENCES workers(id) ON DELETE CASCADE,
+    field_id INTEGER NOT NULL REFERENCES fields(id) ON DELETE CASCADE,
+    operation_id INTEGER REFERENCES operations(id) ON DELETE SET NULL,
+    type VARCHAR(100) NOT NULL DEFAULT '',
+    points JSONB NOT NULL,
+    start_time TIMESTAMP NOT NULL,
+    end_time TIMESTAMP NOT NULL,
+    distance_m DOUBLE PRECISION NOT NULL DEFAULT 0,
+    width_m DOUBLE PRECISION,
+    covered_area DECIMAL(10,2),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    reviewed_by VARCHAR(255),
+    reviewed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_machine_tracks_pending ON machine_tracks(created_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_operation_proposals_status ON operation_proposals(status, start_time);
+CREATE INDEX IF NOT EXISTS idx_operation_proposals_worker_field ON operation_proposals(worker_id, field_id);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS field_lineage;
+ALTER TABLE fields DROP COLUMN IF EXISTS retired_at;
+DROP TABLE IF EXISTS field_boundaries;
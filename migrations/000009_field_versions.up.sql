@@ -0,0 +1,30 @@
+-- Every boundary a field has had. The first version has no valid_from and
+-- covers everything before the second; valid_to is NULL on the current one.
+CREATE TABLE IF NOT EXISTS field_boundaries (
+    id SERIAL PRIMARY KEY,
+    field_id INTEGER NOT NULL REFERENCES fields(id) ON DELETE CASCADE,
+    coordinates JSONB NOT NULL,
+    area DECIMAL(10,2) DEFAULT 0,
+    valid_from TIMESTAMP,
+    valid_to TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_field_boundaries_field ON field_boundaries(field_id, valid_from);
+
+INSERT INTO field_boundaries (field_id, coordinates, area)
+SELECT id, coordinates, area FROM fields;
+
+-- Fields that were split or merged into others are retired rather than
+-- deleted, so their operations keep pointing at them
+ALTER TABLE fields ADD COLUMN IF NOT EXISTS retired_at TIMESTAMP;
+
+CREATE TABLE IF NOT EXISTS field_lineage (
+    parent_id INTEGER NOT NULL REFERENCES fields(id) ON DELETE CASCADE,
+    child_id INTEGER NOT NULL REFERENCES fields(id) ON DELETE CASCADE,
+    event VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (parent_id, child_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_field_lineage_child ON field_lineage(child_id);
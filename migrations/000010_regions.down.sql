@@ -0,0 +1,10 @@
+ALTER TABLE fields ADD COLUMN IF NOT EXISTS region VARCHAR(255) NOT NULL DEFAULT '';
+
+UPDATE fields f SET region = r.name
+FROM regions r
+WHERE r.id = f.region_id;
+
+ALTER TABLE fields ALTER COLUMN region DROP DEFAULT;
+DROP INDEX IF EXISTS idx_fields_region;
+ALTER TABLE fields DROP COLUMN IF EXISTS region_id;
+DROP TABLE IF EXISTS regions;
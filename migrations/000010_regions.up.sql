@@ -0,0 +1,27 @@
+-- Regions form a hierarchy, e.g. a province and its municipalities. Names are
+-- unique among the subregions of a region.
+CREATE TABLE IF NOT EXISTS regions (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    parent_id INTEGER REFERENCES regions(id) ON DELETE RESTRICT,
+    boundary JSONB,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_regions_name ON regions(COALESCE(parent_id, 0), name);
+CREATE INDEX IF NOT EXISTS idx_regions_parent ON regions(parent_id);
+
+-- The free text regions of existing fields become top level regions
+INSERT INTO regions (name)
+SELECT DISTINCT region FROM fields WHERE region <> '';
+
+ALTER TABLE fields ADD COLUMN IF NOT EXISTS region_id INTEGER REFERENCES regions(id) ON DELETE RESTRICT;
+
+UPDATE fields f SET region_id = r.id
+FROM regions r
+WHERE r.parent_id IS NULL AND r.name = f.region;
+
+ALTER TABLE fields DROP COLUMN IF EXISTS region;
+
+CREATE INDEX IF NOT EXISTS idx_fields_region ON fields(region_id);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS refresh_tokens;
+DROP TABLE IF EXISTS login_codes;
+ALTER TABLE workers DROP COLUMN IF EXISTS password_hash;
@@ -0,0 +1,6 @@
+-- The access role decides what a worker may do; role stays the machine they drive
+ALTER TABLE workers ADD COLUMN IF NOT EXISTS access_role VARCHAR(20) NOT NULL DEFAULT 'operator'
+    CHECK (access_role IN ('owner', 'agronomist', 'foreman', 'operator'));
+
+-- Existing workers all become operators. Which of them may grant the other
+-- roles is not for the schema to guess: "main owner <login>" names the owner.
@@ -0,0 +1,22 @@
+ALTER TABLE operation_events DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE operation_proposals DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE machine_tracks DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE operations DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE schedules DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE fields DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE regions DROP COLUMN IF EXISTS organization_id;
+
+-- Fails when several organizations used the same region name; rename those first
+DROP INDEX IF EXISTS idx_regions_name;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_regions_name ON regions(COALESCE(parent_id, 0), name);
+
+-- Workers keep their highest access role of any organization
+ALTER TABLE workers ADD COLUMN IF NOT EXISTS access_role VARCHAR(20) NOT NULL DEFAULT 'operator'
+    CHECK (access_role IN ('owner', 'agronomist', 'foreman', 'operator'));
+UPDATE workers w SET access_role = (
+    SELECT m.access_role FROM memberships m WHERE m.worker_id = w.id
+    ORDER BY array_position(ARRAY['owner', 'agronomist', 'foreman', 'operator']::varchar[], m.access_role) LIMIT 1
+) WHERE EXISTS (SELECT 1 FROM memberships m WHERE m.worker_id = w.id);
+
+DROP TABLE IF EXISTS memberships;
+DROP TABLE IF EXISTS organizations;
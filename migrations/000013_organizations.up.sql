@@ -0,0 +1,96 @@
+-- Organizations are the farming companies sharing a deployment. Workers log in
+-- once and belong to any number of them, with an access role in each; all
+-- other data belongs to exactly one.
+CREATE TABLE IF NOT EXISTS organizations (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS memberships (
+    organization_id INTEGER NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    worker_id INTEGER NOT NULL REFERENCES workers(id) ON DELETE CASCADE,
+    access_role VARCHAR(20) NOT NULL DEFAULT 'operator'
+        CHECK (access_role IN ('owner', 'agronomist', 'foreman', 'operator')),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (organization_id, worker_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_memberships_worker ON memberships(worker_id);
+
+-- Existing data becomes the first organization
+INSERT INTO organizations (name)
+SELECT 'Default' WHERE EXISTS (SELECT 1 FROM workers) OR EXISTS (SELECT 1 FROM fields) OR EXISTS (SELECT 1 FROM regions)
+    OR EXISTS (SELECT 1 FROM operation_events);
+
+INSERT INTO memberships (organization_id, worker_id, access_role)
+SELECT (SELECT MIN(id) FROM organizations), id, access_role FROM workers;
+
+ALTER TABLE workers DROP COLUMN IF EXISTS access_role;
+
+ALTER TABLE regions ADD COLUMN IF NOT EXISTS organization_id INTEGER REFERENCES organizations(id) ON DELETE CASCADE;
+ALTER TABLE fields ADD COLUMN IF NOT EXISTS organization_id INTEGER REFERENCES organizations(id) ON DELETE CASCADE;
+ALTER TABLE schedules ADD COLUMN IF NOT EXISTS organization_id INTEGER REFERENCES organizations(id) ON DELETE CASCADE;
+ALTER TABLE operations ADD COLUMN IF NOT EXISTS organization_id INTEGER REFERENCES organizations(id) ON DELETE CASCADE;
+ALTER TABLE machine_tracks ADD COLUMN IF NOT EXISTS organization_id INTEGER REFERENCES organizations(id) ON DELETE CASCADE;
+ALTER TABLE operation_proposals ADD COLUMN IF NOT EXISTS organization_id INTEGER REFERENCES organizations(id) ON DELETE CASCADE;
+ALTER TABLE operation_events ADD COLUMN IF NOT EXISTS organization_id INTEGER REFERENCES organizations(id) ON DELETE CASCADE;
+
+UPDATE regions SET organization_id = (SELECT MIN(id) FROM organizations);
+UPDATE fields SET organization_id = (SELECT MIN(id) FROM organizations);
+UPDATE schedules SET organization_id = (SELECT MIN(id) FROM organizations);
+UPDATE operations SET organization_id = (SELECT MIN(id) FROM organizations);
+UPDATE machine_tracks SET organization_id = (SELECT MIN(id) FROM organizations);
+UPDATE operation_proposals SET organization_id = (SELECT MIN(id) FROM organizations);
+UPDATE operation_events SET organization_id = (SELECT MIN(id) FROM organizations);
+
+ALTER TABLE regions ALTER COLUMN organization_id SET NOT NULL;
+ALTER TABLE fields ALTER COLUMN organization_id SET NOT NULL;
+ALTER TABLE schedules ALTER COLUMN organization_id SET NOT NULL;
+ALTER TABLE operations ALTER COLUMN organization_id SET NOT NULL;
+ALTER TABLE machine_tracks ALTER COLUMN organization_id SET NOT NULL;
+ALTER TABLE operation_proposals ALTER COLUMN organization_id SET NOT NULL;
+ALTER TABLE operation_events ALTER COLUMN organization_id SET NOT NULL;
+
+-- Region names are unique per organization
+DROP INDEX IF EXISTS idx_regions_name;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_regions_name ON regions(organization_id, COALESCE(parent_id, 0), name);
+
+-- References between records must stay inside their organization; the
+-- composite foreign keys below make the database refuse any that do not.
+-- Removing a worker from an organization removes their work there.
+ALTER TABLE regions ADD CONSTRAINT regions_organization_id_id_key UNIQUE (organization_id, id);
+ALTER TABLE fields ADD CONSTRAINT fields_organization_id_id_key UNIQUE (organization_id, id);
+ALTER TABLE schedules ADD CONSTRAINT schedules_organization_id_id_key UNIQUE (organization_id, id);
+ALTER TABLE operations ADD CONSTRAINT operations_organization_id_id_key UNIQUE (organization_id, id);
+ALTER TABLE machine_tracks ADD CONSTRAINT machine_tracks_organization_id_id_key UNIQUE (organization_id, id);
+
+ALTER TABLE regions ADD CONSTRAINT regions_organization_parent_fkey
+    FOREIGN KEY (organization_id, parent_id) REFERENCES regions(organization_id, id) ON DELETE RESTRICT;
+ALTER TABLE fields ADD CONSTRAINT fields_organization_region_fkey
+    FOREIGN KEY (organization_id, region_id) REFERENCES regions(organization_id, id) ON DELETE RESTRICT;
+ALTER TABLE schedules ADD CONSTRAINT schedules_organization_worker_fkey
+    FOREIGN KEY (organization_id, worker_id) REFERENCES memberships(organization_id, worker_id) ON DELETE CASCADE;
+ALTER TABLE operations ADD CONSTRAINT operations_organization_schedule_fkey
+    FOREIGN KEY (organization_id, schedule_id) REFERENCES schedules(organization_id, id) ON DELETE CASCADE;
+ALTER TABLE operations ADD CONSTRAINT operations_organization_worker_fkey
+    FOREIGN KEY (organization_id, worker_id) REFERENCES memberships(organization_id, worker_id) ON DELETE CASCADE;
+ALTER TABLE operations ADD CONSTRAINT operations_organization_field_fkey
+    FOREIGN KEY (organization_id, field_id) REFERENCES fields(organization_id, id) ON DELETE CASCADE;
+ALTER TABLE machine_tracks ADD CONSTRAINT machine_tracks_organization_worker_fkey
+    FOREIGN KEY (organization_id, worker_id) REFERENCES memberships(organization_id, worker_id) ON DELETE CASCADE;
+ALTER TABLE operation_proposals ADD CONSTRAINT operation_proposals_organization_track_fkey
+    FOREIGN KEY (organization_id, track_id) REFERENCES machine_tracks(organization_id, id) ON DELETE CASCADE;
+ALTER TABLE operation_proposals ADD CONSTRAINT operation_proposals_organization_worker_fkey
+    FOREIGN KEY (organization_id, worker_id) REFERENCES memberships(organization_id, worker_id) ON DELETE CASCADE;
+ALTER TABLE operation_proposals ADD CONSTRAINT operation_proposals_organization_field_fkey
+    FOREIGN KEY (organization_id, field_id) REFERENCES fields(organization_id, id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_regions_organization ON regions(organization_id);
+CREATE INDEX IF NOT EXISTS idx_fields_organization ON fields(organization_id);
+CREATE INDEX IF NOT EXISTS idx_schedules_organization ON schedules(organization_id, date);
+CREATE INDEX IF NOT EXISTS idx_operations_organization ON operations(organization_id, start_time);
+CREATE INDEX IF NOT EXISTS idx_machine_tracks_organization ON machine_tracks(organization_id);
+CREATE INDEX IF NOT EXISTS idx_operation_proposals_organization ON operation_proposals(organization_id);
+CREATE INDEX IF NOT EXISTS idx_operation_events_organization ON operation_events(organization_id, operation_id);
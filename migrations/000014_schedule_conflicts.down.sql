@@ -0,0 +1 @@
+ALTER TABLE schedules DROP CONSTRAINT IF EXISTS schedules_organization_id_worker_id_date_key;
@@ -0,0 +1,12 @@
+-- A worker has one schedule a day. Operations on duplicates move to the
+-- oldest schedule of the day before the duplicates are dropped.
+UPDATE operations o SET schedule_id = d.keep_id
+FROM (
+    SELECT id, MIN(id) OVER (PARTITION BY organization_id, worker_id, date) AS keep_id FROM schedules
+) d
+WHERE o.schedule_id = d.id AND d.id <> d.keep_id;
+
+DELETE FROM schedules s USING schedules k
+WHERE s.organization_id = k.organization_id AND s.worker_id = k.worker_id AND s.date = k.date AND s.id > k.id;
+
+ALTER TABLE schedules ADD CONSTRAINT schedules_organization_id_worker_id_date_key UNIQUE (organization_id, worker_id, date);
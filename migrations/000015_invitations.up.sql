@@ -0,0 +1,13 @@
+-- Workers join another organization only by accepting its invitation, so that
+-- no organization can take in a worker, and their login, on its own.
+CREATE TABLE IF NOT EXISTS invitations (
+    organization_id INTEGER NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    worker_id INTEGER NOT NULL REFERENCES workers(id) ON DELETE CASCADE,
+    access_role VARCHAR(20) NOT NULL DEFAULT 'operator'
+        CHECK (access_role IN ('owner', 'agronomist', 'foreman', 'operator')),
+    invited_by VARCHAR(255),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (organization_id, worker_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_invitations_worker ON invitations(worker_id);
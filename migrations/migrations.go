@@ -0,0 +1,270 @@
+// Package migrations versions the database schema.
+//
+// Each change is a pair of numbered SQL files, 000001_name.up.sql and
+// 000001_name.down.sql, embedded into the binary. Applied versions are
+// recorded in the schema_migrations table and every run holds a Postgres
+// advisory lock, so replicas starting at the same time apply each
+// migration only once.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+// lockID identifies the advisory lock held while migrating.
+const lockID = 20250614
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status describes a migration known to the binary or recorded in the database.
+type Status struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// Load reads the embedded migrations ordered by version.
+func Load() ([]Migration, error) {
+	return load(files)
+}
+
+// load reads the migrations in the top directory of fsys.
+func load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migration file %q is not named like 000001_name.up.sql", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		contents, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has files named %q and %q", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(contents)
+		} else {
+			m.Down = string(contents)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %06d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Up applies every migration that has not been applied yet and returns how many ran.
+func Up(db *sql.DB) (int, error) {
+	migrations, err := Load()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err = withLock(db, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range migrations {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			if err := apply(conn, m, m.Up, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, m.Version, m.Name); err != nil {
+				return err
+			}
+			log.Printf("Applied migration %06d_%s", m.Version, m.Name)
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Down reverts the given number of most recently applied migrations and returns how many were reverted.
+func Down(db *sql.DB, steps int) (int, error) {
+	migrations, err := Load()
+	if err != nil {
+		return 0, err
+	}
+	known := make(map[int]Migration)
+	for _, m := range migrations {
+		known[m.Version] = m
+	}
+
+	count := 0
+	err = withLock(db, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		versions := make([]int, 0, len(applied))
+		for version := range applied {
+			versions = append(versions, version)
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+
+		for _, version := range versions {
+			if count == steps {
+				break
+			}
+			m, ok := known[version]
+			if !ok {
+				return fmt.Errorf("migration %d is applied but not known to this binary", version)
+			}
+			if m.Down == "" {
+				return fmt.Errorf("migration %06d_%s has no down file", m.Version, m.Name)
+			}
+			if err := apply(conn, m, m.Down, `DELETE FROM schema_migrations WHERE version = $1`, m.Version); err != nil {
+				return err
+			}
+			log.Printf("Reverted migration %06d_%s", m.Version, m.Name)
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Statuses lists the embedded migrations together with any applied versions
+// this binary does not know about, ordered by version.
+func Statuses(db *sql.DB) ([]Status, error) {
+	migrations, err := Load()
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []Status
+	err = withLock(db, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range migrations {
+			s := Status{Version: m.Version, Name: m.Name}
+			if a, ok := applied[m.Version]; ok {
+				s.AppliedAt = &a.at
+				delete(applied, m.Version)
+			}
+			statuses = append(statuses, s)
+		}
+		for version, a := range applied {
+			at := a.at
+			statuses = append(statuses, Status{Version: version, Name: a.name, AppliedAt: &at})
+		}
+		return nil
+	})
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+	return statuses, err
+}
+
+// withLock runs fn on a dedicated connection holding the migration lock.
+// Advisory locks belong to a session, so the lock, the bookkeeping and the
+// migrations must all use the same connection.
+func withLock(db *sql.DB, fn func(conn *sql.Conn) error) error {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockID); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, lockID)
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+type appliedMigration struct {
+	name string
+	at   time.Time
+}
+
+func appliedVersions(conn *sql.Conn) (map[int]appliedMigration, error) {
+	rows, err := conn.QueryContext(context.Background(), `SELECT version, name, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]appliedMigration)
+	for rows.Next() {
+		var version int
+		var a appliedMigration
+		if err := rows.Scan(&version, &a.name, &a.at); err != nil {
+			return nil, err
+		}
+		applied[version] = a
+	}
+	return applied, rows.Err()
+}
+
+// apply runs a migration script and its bookkeeping statement in one transaction.
+func apply(conn *sql.Conn, m Migration, script, bookkeeping string, args ...interface{}) error {
+	ctx := context.Background()
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return fmt.Errorf("migration %06d_%s: %w", m.Version, m.Name, err)
+	}
+	if _, err := tx.ExecContext(ctx, bookkeeping, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
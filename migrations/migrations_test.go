@@ -0,0 +1,99 @@
+package migrations
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoad(t *testing.T) {
+	sql := func(s string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(s)} }
+	tests := []struct {
+		name     string
+		files    fstest.MapFS
+		versions []int
+		err      string
+	}{
+		{
+			name: "ordered by version",
+			files: fstest.MapFS{
+				"000010_ten.up.sql":   sql("ten"),
+				"000002_two.up.sql":   sql("two"),
+				"000002_two.down.sql": sql("undo two"),
+				"000001_one.up.sql":   sql("one"),
+			},
+			versions: []int{1, 2, 10},
+		},
+		{
+			name:  "badly named file",
+			files: fstest.MapFS{"000001_one.sql": sql("one")},
+			err:   "is not named like",
+		},
+		{
+			name: "names differ",
+			files: fstest.MapFS{
+				"000001_one.up.sql":   sql("one"),
+				"000001_uno.down.sql": sql("undo one"),
+			},
+			err: `has files named "one" and "uno"`,
+		},
+		{
+			name:  "no up file",
+			files: fstest.MapFS{"000003_three.down.sql": sql("undo three")},
+			err:   "000003_three has no up file",
+		},
+	}
+	for _, tt := range tests {
+		migrations, err := load(tt.files)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: got error %v, want %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(migrations) != len(tt.versions) {
+			t.Fatalf("%s: got %d migrations, want %d", tt.name, len(migrations), len(tt.versions))
+		}
+		for i, m := range migrations {
+			if m.Version != tt.versions[i] {
+				t.Errorf("%s: migration %d is version %d, want %d", tt.name, i, m.Version, tt.versions[i])
+			}
+		}
+	}
+
+	two := loadOrFail(t, fstest.MapFS{"000002_two.up.sql": sql("two"), "000002_two.down.sql": sql("undo two")})[0]
+	if two.Name != "two" || two.Up != "two" || two.Down != "undo two" {
+		t.Errorf("got migration %+v", two)
+	}
+}
+
+func loadOrFail(t *testing.T, files fstest.MapFS) []Migration {
+	t.Helper()
+	migrations, err := load(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return migrations
+}
+
+// The embedded migrations are numbered without gaps and can all be reverted.
+func TestEmbedded(t *testing.T) {
+	migrations, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("no migrations embedded")
+	}
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("migration %d_%s is number %d", m.Version, m.Name, i+1)
+		}
+		if strings.TrimSpace(m.Down) == "" {
+			t.Errorf("migration %d_%s has no down file", m.Version, m.Name)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// MaxLoginCodeAttempts is how many wrong guesses a login code survives.
+const MaxLoginCodeAttempts = 5
+
+var (
+	// ErrLoginCodeInvalid is returned for a wrong, expired, used or
+	// exhausted login code.
+	ErrLoginCodeInvalid = errors.New("login code is invalid")
+
+	// ErrLoginCodeTooSoon is returned when a worker asks for another login
+	// code too soon after the last one.
+	ErrLoginCodeTooSoon = errors.New("login code was sent recently")
+
+	// ErrRefreshTokenInvalid is returned for an unknown, expired or revoked
+	// refresh token.
+	ErrRefreshTokenInvalid = errors.New("refresh token is invalid")
+)
+
+// WorkerAccount is a worker with the credentials to log in as them. Accounts
+// are shared by all organizations, so AccessRole is left empty.
+type WorkerAccount struct {
+	Worker
+	PasswordHash string // empty when the worker can only log in with codes
+}
+
+// LoginCode is a one-time code sent to a worker's phone or email. Only a hash
+// of the code is stored.
+type LoginCode struct {
+	ID        int
+	WorkerID  int
+	Channel   string // "sms" or "email"
+	CodeHash  string
+	Attempts  int
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// RefreshToken records an issued refresh token by its ID (the JWT "jti").
+type RefreshToken struct {
+	ID        string
+	WorkerID  int
+	ExpiresAt time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+// GetWorkerAccount finds the worker who logs in as login, their email
+// (ignoring case) or phone number. A phone number shared by several workers
+// does not identify any of them.
+func (s *PostgresStore) GetWorkerAccount(login string) (*WorkerAccount, error) {
+	rows, err := s.db.Query(`SELECT id, name, email, phone, role, created_at, updated_at, COALESCE(password_hash, '')
+							 FROM workers
+							 WHERE (email <> '' AND LOWER(email) = LOWER($1)) OR (phone <> '' AND phone = $1)
+							 ORDER BY id LIMIT 2`, login)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []WorkerAccount
+	for rows.Next() {
+		var a WorkerAccount
+		if err := rows.Scan(&a.ID, &a.Name, &a.Email, &a.Phone, &a.Role, &a.CreatedAt, &a.UpdatedAt, &a.PasswordHash); err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(accounts) != 1 {
+		return nil, sql.ErrNoRows
+	}
+	return &accounts[0], nil
+}
+
+func (s *PostgresStore) GetWorkerAccountByID(id int) (*WorkerAccount, error) {
+	var a WorkerAccount
+	err := s.db.QueryRow(`SELECT id, name, email, phone, role, created_at, updated_at, COALESCE(password_hash, '')
+						  FROM workers WHERE id = $1`, id).
+		Scan(&a.ID, &a.Name, &a.Email, &a.Phone, &a.Role, &a.CreatedAt, &a.UpdatedAt, &a.PasswordHash)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (s *PostgresStore) SetWorkerPassword(workerID int, passwordHash string) error {
+	result, err := s.db.Exec(`UPDATE workers SET password_hash = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`,
+		passwordHash, workerID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// CreateLoginCode stores a new login code for a worker, replacing any earlier
+// unused one. It returns ErrLoginCodeTooSoon when the last code was created
+// less than resendAfter ago.
+func (s *PostgresStore) CreateLoginCode(code *LoginCode, resendAfter time.Duration) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Serializes code requests of the worker
+	if _, err := tx.Exec(`SELECT id FROM workers WHERE id = $1 FOR UPDATE`, code.WorkerID); err != nil {
+		return err
+	}
+	var recent bool
+	err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM login_codes WHERE worker_id = $1 AND created_at > $2)`,
+		code.WorkerID, code.CreatedAt.Add(-resendAfter)).Scan(&recent)
+	if err != nil {
+		return err
+	}
+	if recent {
+		return ErrLoginCodeTooSoon
+	}
+
+	_, err = tx.Exec(`UPDATE login_codes SET used_at = $1 WHERE worker_id = $2 AND used_at IS NULL`, code.CreatedAt, code.WorkerID)
+	if err != nil {
+		return err
+	}
+	err = tx.QueryRow(`INSERT INTO login_codes (worker_id, channel, code_hash, expires_at, created_at)
+					   VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+		code.WorkerID, code.Channel, code.CodeHash, code.ExpiresAt, code.CreatedAt).Scan(&code.ID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UseLoginCode checks a code against the worker's current login code and
+// uses it up when it matches. Every wrong guess counts against the code.
+func (s *PostgresStore) UseLoginCode(workerID int, codeHash string, now time.Time) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var id, attempts int
+	var stored string
+	err = tx.QueryRow(`SELECT id, code_hash, attempts FROM login_codes
+					   WHERE worker_id = $1 AND used_at IS NULL AND expires_at > $2
+					   ORDER BY created_at DESC LIMIT 1 FOR UPDATE`, workerID, now).Scan(&id, &stored, &attempts)
+	if err == sql.ErrNoRows {
+		return ErrLoginCodeInvalid
+	}
+	if err != nil {
+		return err
+	}
+
+	if attempts >= MaxLoginCodeAttempts || stored != codeHash {
+		if _, err := tx.Exec(`UPDATE login_codes SET attempts = attempts + 1 WHERE id = $1`, id); err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		return ErrLoginCodeInvalid
+	}
+	if _, err := tx.Exec(`UPDATE login_codes SET used_at = $1 WHERE id = $2`, now, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *PostgresStore) CreateRefreshToken(token *RefreshToken) error {
+	return s.db.QueryRow(`INSERT INTO refresh_tokens (id, worker_id, expires_at) VALUES ($1, $2, $3) RETURNING created_at`,
+		token.ID, token.WorkerID, token.ExpiresAt).Scan(&token.CreatedAt)
+}
+
+// RotateRefreshToken revokes the refresh token with the given ID and stores
+// next, issued to the same worker in its place. Presenting a revoked token
+// again means it was stolen or replayed, so all the worker's tokens are
+// revoked.
+func (s *PostgresStore) RotateRefreshToken(id string, next *RefreshToken, now time.Time) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var current RefreshToken
+	err = tx.QueryRow(`SELECT id, worker_id, expires_at, revoked_at FROM refresh_tokens WHERE id = $1 FOR UPDATE`, id).
+		Scan(&current.ID, &current.WorkerID, &current.ExpiresAt, &current.RevokedAt)
+	if err == sql.ErrNoRows {
+		return ErrRefreshTokenInvalid
+	}
+	if err != nil {
+		return err
+	}
+	if current.RevokedAt != nil {
+		if _, err := tx.Exec(`UPDATE refresh_tokens SET revoked_at = $1 WHERE worker_id = $2 AND revoked_at IS NULL`,
+			now, current.WorkerID); err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		return ErrRefreshTokenInvalid
+	}
+	if !current.ExpiresAt.After(now) || current.WorkerID != next.WorkerID {
+		return ErrRefreshTokenInvalid
+	}
+
+	if _, err := tx.Exec(`UPDATE refresh_tokens SET revoked_at = $1 WHERE id = $2`, now, id); err != nil {
+		return err
+	}
+	err = tx.QueryRow(`INSERT INTO refresh_tokens (id, worker_id, expires_at) VALUES ($1, $2, $3) RETURNING created_at`,
+		next.ID, next.WorkerID, next.ExpiresAt).Scan(&next.CreatedAt)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RevokeRefreshTokens logs a worker out everywhere.
+func (s *PostgresStore) RevokeRefreshTokens(workerID int) error {
+	_, err := s.db.Exec(`UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE worker_id = $1 AND revoked_at IS NULL`, workerID)
+	return err
+}
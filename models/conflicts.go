@@ -0,0 +1,172 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ConflictError is returned when a change would book a worker twice: a
+// second schedule for a day, or an operation at the same time as others. It
+// lists the records in the way.
+type ConflictError struct {
+	Schedules  []Schedule  `json:"schedules,omitempty"`
+	Operations []Operation `json:"operations,omitempty"`
+}
+
+func (e *ConflictError) Error() string {
+	if len(e.Schedules) > 0 {
+		return fmt.Sprintf("worker already has schedule %d on that date", e.Schedules[0].ID)
+	}
+	ids := make([]string, len(e.Operations))
+	for i, o := range e.Operations {
+		ids[i] = fmt.Sprint(o.ID)
+	}
+	return fmt.Sprintf("worker is busy with operation %s at that time", strings.Join(ids, ", "))
+}
+
+// timeSpan is a stretch of time, not including its end.
+type timeSpan struct {
+	start, end time.Time
+}
+
+// operationSpans are the times an operation takes up its worker: the
+// intervals worked on it, the open one until now, or for an operation not yet
+// worked on its planned start to end. Cancelled and rejected operations take
+// up no time; see operationSpansSQL.
+func operationSpans(o *Operation, intervals []WorkInterval, now time.Time) []timeSpan {
+	if o.Status == StatusCancelled || o.Status == StatusRejected {
+		return nil
+	}
+	var spans []timeSpan
+	for _, i := range intervals {
+		end := now
+		if i.EndedAt != nil {
+			end = *i.EndedAt
+		}
+		if end.After(i.StartedAt) {
+			spans = append(spans, timeSpan{i.StartedAt, end})
+		}
+	}
+	if len(intervals) == 0 && o.StartTime != nil {
+		end := o.EndTime
+		if end == nil {
+			end = o.CompletedAt
+		}
+		if end != nil && end.After(*o.StartTime) {
+			spans = append(spans, timeSpan{*o.StartTime, *end})
+		}
+	}
+	return spans
+}
+
+// operationsOverlap tells whether two operations, with their work intervals,
+// take up the same time. A worker is never on two operations in progress at
+// once, even when one has only just started.
+func operationsOverlap(a *Operation, aIntervals []WorkInterval, b *Operation, bIntervals []WorkInterval, now time.Time) bool {
+	if a.Status == StatusInProgress && b.Status == StatusInProgress {
+		return true
+	}
+	for _, x := range operationSpans(a, aIntervals, now) {
+		for _, y := range operationSpans(b, bIntervals, now) {
+			if x.start.Before(y.end) && y.start.Before(x.end) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// operationSpansSQL mirrors operationSpans as a query of the spans of the
+// operation aliased a, leaving out the status check.
+func operationSpansSQL(a string) string {
+	return fmt.Sprintf(`SELECT tsrange(i.started_at, GREATEST(i.started_at, COALESCE(i.ended_at, LOCALTIMESTAMP))) AS span
+		FROM operation_intervals i WHERE i.operation_id = %[1]s.id
+		UNION ALL
+		SELECT tsrange(%[1]s.start_time, GREATEST(%[1]s.start_time, COALESCE(%[1]s.end_time, %[1]s.completed_at)))
+		WHERE %[1]s.start_time IS NOT NULL
+		  AND NOT EXISTS (SELECT 1 FROM operation_intervals i WHERE i.operation_id = %[1]s.id)`, a)
+}
+
+// checkOperationConflicts returns a *ConflictError when operation id, as
+// changed in tx, takes up its worker at the same time as other operations.
+// Checks for a worker are serialized on their membership.
+func checkOperationConflicts(tx *sql.Tx, org, id int) error {
+	var workerID sql.NullInt64
+	if err := tx.QueryRow(`SELECT worker_id FROM operations WHERE id = $1`, id).Scan(&workerID); err != nil {
+		return err
+	}
+	if !workerID.Valid {
+		return nil
+	}
+	_, err := tx.Exec(`SELECT 1 FROM memberships WHERE organization_id = $1 AND worker_id = $2 FOR NO KEY UPDATE`,
+		org, workerID.Int64)
+	if err != nil {
+		return err
+	}
+
+	query := `SELECT ` + operationColumns + `
+			  FROM operations o
+			  JOIN operations c ON c.id = $2
+			  LEFT JOIN workers w ON o.worker_id = w.id
+			  LEFT JOIN fields f ON o.field_id = f.id
+			  ` + fieldVersionJoin + `
+			  WHERE o.organization_id = $1 AND o.worker_id = c.worker_id AND o.id <> c.id
+			    AND ((o.status = 'in_progress' AND c.status = 'in_progress')
+			      OR (o.status NOT IN ('cancelled', 'rejected') AND c.status NOT IN ('cancelled', 'rejected')
+			        AND EXISTS (SELECT 1 FROM (` + operationSpansSQL("o") + `) x
+			                    JOIN (` + operationSpansSQL("c") + `) y ON x.span && y.span)))
+			  ORDER BY o.start_time, o.id`
+	rows, err := tx.Query(query, org, id)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var conflicts []Operation
+	for rows.Next() {
+		o, err := scanOperation(rows)
+		if err != nil {
+			return err
+		}
+		conflicts = append(conflicts, *o)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(conflicts) > 0 {
+		return &ConflictError{Operations: conflicts}
+	}
+	return nil
+}
+
+// checkScheduleConflicts returns a *ConflictError when the worker of a
+// schedule already has another one on its date. Like operations, checks for
+// a worker are serialized on their membership.
+func checkScheduleConflicts(tx *sql.Tx, org int, schedule *Schedule) error {
+	_, err := tx.Exec(`SELECT 1 FROM memberships WHERE organization_id = $1 AND worker_id = $2 FOR NO KEY UPDATE`,
+		org, schedule.WorkerID)
+	if err != nil {
+		return err
+	}
+
+	var sc Schedule
+	var workerName sql.NullString
+	err = tx.QueryRow(`SELECT s.id, s.worker_id, s.date, s.created_at, s.updated_at, w.name
+					   FROM schedules s
+					   LEFT JOIN workers w ON s.worker_id = w.id
+					   WHERE s.organization_id = $1 AND s.worker_id = $2 AND s.date = $3 AND s.id <> $4`,
+		org, schedule.WorkerID, schedule.Date, schedule.ID).
+		Scan(&sc.ID, &sc.WorkerID, &sc.Date, &sc.CreatedAt, &sc.UpdatedAt, &workerName)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if workerName.Valid {
+		sc.Worker = &Worker{ID: sc.WorkerID, Name: workerName.String}
+	}
+	return &ConflictError{Schedules: []Schedule{sc}}
+}
@@ -0,0 +1,129 @@
+package models
+
+import (
+	"encoding/json"
+	"math"
+	"time"
+)
+
+// FieldCoverage is how much of a field the operations of one type have
+// covered, e.g. that a field is 60% harvested.
+type FieldCoverage struct {
+	Type        string  `json:"type"`
+	Operations  int     `json:"operations"`
+	CoveredArea float64 `json:"covered_area"` // in decares, at most the field area
+	Percent     float64 `json:"percent"`
+}
+
+// coveredAreaSQL is the number of decares an operation aliased as "o" has
+// covered of its field aliased as "f": the recorded coverage, or the whole
+// field once the operation is completed, as it was then (fieldVersionJoin).
+const coveredAreaSQL = `COALESCE(o.covered_area, CASE WHEN o.status = 'completed' THEN COALESCE(fb.area, f.area) ELSE 0 END)`
+
+// coveredArea mirrors coveredAreaSQL.
+func coveredArea(o *Operation, fieldArea float64) float64 {
+	if o.CoveredArea != nil {
+		return *o.CoveredArea
+	}
+	if o.Status == StatusCompleted {
+		return fieldArea
+	}
+	return 0
+}
+
+// coveragePercent is the share of the field an operation has covered, rounded
+// to a tenth of a percent.
+func coveragePercent(o *Operation, fieldArea float64) float64 {
+	return percentOf(coveredArea(o, fieldArea), fieldArea)
+}
+
+func percentOf(area, fieldArea float64) float64 {
+	if fieldArea <= 0 {
+		return 0
+	}
+	return math.Round(math.Min(area/fieldArea, 1)*1000) / 10
+}
+
+// SetOperationCoverage records how much of its field an operation has covered.
+// A nil coveredArea clears what was recorded.
+func (s *PostgresStore) SetOperationCoverage(id int, coveredArea *float64, coverage json.RawMessage, actor string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	old, err := lockOperation(tx, s.org, id)
+	if err != nil {
+		return err
+	}
+
+	// A nil json.RawMessage would be sent as an empty string rather than NULL
+	var coverageArg interface{}
+	if coverage != nil {
+		coverageArg = coverage
+	}
+	_, err = tx.Exec(`UPDATE operations SET covered_area = $1, coverage = $2, updated_at = CURRENT_TIMESTAMP
+					  WHERE id = $3`, coveredArea, coverageArg, id)
+	if err != nil {
+		return err
+	}
+
+	updated := *old
+	updated.CoveredArea, updated.Coverage = coveredArea, coverage
+	event := &OperationEvent{
+		OperationID: id,
+		Event:       EventUpdated,
+		OldStatus:   old.Status,
+		NewStatus:   old.Status,
+		Changes:     operationChanges(old, &updated),
+		Actor:       actor,
+	}
+	if err := recordOperationEvent(tx, s.org, event); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetFieldCoverage sums, per operation type, the area covered on a field by
+// operations that were not cancelled or rejected. With from and to set only
+// operations started (or, if not started, created) in [from, to) count.
+func (s *PostgresStore) GetFieldCoverage(fieldID int, from, to *time.Time) ([]FieldCoverage, error) {
+	field, err := s.GetFieldByID(fieldID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT o.type, COUNT(*), COALESCE(SUM(`+coveredAreaSQL+`), 0)
+		FROM operations o
+		JOIN fields f ON o.field_id = f.id
+		`+fieldVersionJoin+`
+		WHERE o.field_id = $1 AND o.organization_id = $6 AND o.status NOT IN ($2, $3)
+		  AND ($4::timestamp IS NULL OR COALESCE(o.start_time, o.created_at) >= $4)
+		  AND ($5::timestamp IS NULL OR COALESCE(o.start_time, o.created_at) < $5)
+		GROUP BY o.type
+		ORDER BY o.type`, fieldID, StatusCancelled, StatusRejected, from, to, s.org)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	coverage := []FieldCoverage{}
+	for rows.Next() {
+		var c FieldCoverage
+		if err := rows.Scan(&c.Type, &c.Operations, &c.CoveredArea); err != nil {
+			return nil, err
+		}
+		coverage = append(coverage, fieldCoverage(c, field.Area))
+	}
+	return coverage, nil
+}
+
+// fieldCoverage caps the covered area at the field area, since operations of
+// the same type may overlap, and computes the percentage.
+func fieldCoverage(c FieldCoverage, fieldArea float64) FieldCoverage {
+	c.CoveredArea = math.Round(math.Min(c.CoveredArea, fieldArea)*100) / 100
+	c.Percent = percentOf(c.CoveredArea, fieldArea)
+	return c
+}
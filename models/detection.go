@@ -0,0 +1,412 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Machine track statuses
+const (
+	MachineTrackPending  = "pending"
+	MachineTrackAnalyzed = "analyzed"
+)
+
+// Operation proposal statuses
+const (
+	ProposalPending   = "pending"
+	ProposalConfirmed = "confirmed"
+	ProposalDismissed = "dismissed"
+)
+
+var (
+	// ErrProposalReviewed is returned when confirming or dismissing a proposal
+	// that is no longer pending.
+	ErrProposalReviewed = errors.New("proposal has already been reviewed")
+
+	// ErrProposalType is returned when confirming a proposal that would create
+	// an operation without knowing its type.
+	ErrProposalType = errors.New("proposal needs an operation type")
+)
+
+// MachineTrack is a GPS track of a worker's machine uploaded without saying
+// which operation it belongs to. The track analyzer looks for the fields the
+// machine worked and proposes an operation for each of them.
+type MachineTrack struct {
+	ID             int          `json:"id"`
+	WorkerID       int          `json:"worker_id"`
+	OperationType  string       `json:"operation_type"` // type of the proposed operations, if known
+	Points         []TrackPoint `json:"points,omitempty"`
+	StartedAt      time.Time    `json:"started_at"`
+	EndedAt        time.Time    `json:"ended_at"`
+	DistanceMeters float64      `json:"distance_m"`
+	WidthMeters    *float64     `json:"width_m"`
+	AutoConfirm    bool         `json:"auto_confirm"` // confirm the proposals without waiting for the foreman
+	Status         string       `json:"status"`       // see the MachineTrack* constants
+	CreatedAt      time.Time    `json:"created_at"`
+	AnalyzedAt     *time.Time   `json:"analyzed_at"`
+}
+
+// OperationProposal is an operation detected on a field from a machine track,
+// waiting for the foreman to confirm or dismiss it. OperationID is the open
+// operation of the worker on that field that confirming completes, or once
+// confirmed, the operation that was completed or created.
+type OperationProposal struct {
+	ID             int          `json:"id"`
+	TrackID        int          `json:"track_id"`
+	WorkerID       int          `json:"worker_id"`
+	FieldID        int          `json:"field_id"`
+	OperationID    *int         `json:"operation_id"`
+	Type           string       `json:"type"`
+	Points         []TrackPoint `json:"points,omitempty"`
+	StartTime      time.Time    `json:"start_time"`
+	EndTime        time.Time    `json:"end_time"`
+	DistanceMeters float64      `json:"distance_m"`
+	WidthMeters    *float64     `json:"width_m"`
+	CoveredArea    *float64     `json:"covered_area"`
+	Status         string       `json:"status"` // see the Proposal* constants
+	ReviewedBy     string       `json:"reviewed_by,omitempty"`
+	ReviewedAt     *time.Time   `json:"reviewed_at"`
+	CreatedAt      time.Time    `json:"created_at"`
+}
+
+// openForProposal tells whether confirming a proposal may complete the operation.
+func openForProposal(o *Operation) bool {
+	return o.Status == StatusPlanned || o.Status == StatusInProgress || o.Status == StatusPaused
+}
+
+// proposalOperation returns the operation created by confirming a proposal
+// that does not complete an existing one.
+func proposalOperation(p *OperationProposal) *Operation {
+	start, end := p.StartTime, p.EndTime
+	return &Operation{
+		WorkerID:    p.WorkerID,
+		FieldID:     p.FieldID,
+		Type:        p.Type,
+		Description: fmt.Sprintf("Detected from machine track %d", p.TrackID),
+		Status:      StatusCompleted,
+		StartTime:   &start,
+		EndTime:     &end,
+		CompletedAt: &end,
+		CoveredArea: p.CoveredArea,
+	}
+}
+
+// completedByProposal returns the open operation o as completed by a proposal.
+func completedByProposal(o *Operation, p *OperationProposal) *Operation {
+	updated := *o
+	start, end := p.StartTime, p.EndTime
+	updated.Status = StatusCompleted
+	updated.StartTime, updated.EndTime, updated.CompletedAt = &start, &end, &end
+	if p.CoveredArea != nil {
+		updated.CoveredArea, updated.Coverage = p.CoveredArea, nil
+	}
+	return &updated
+}
+
+// Machine tracks are listed without their points, which can run to tens of
+// thousands per track.
+const machineTrackColumns = `id, worker_id, operation_type, started_at, ended_at, distance_m, width_m,
+							 auto_confirm, status, created_at, analyzed_at`
+
+func scanMachineTrack(row interface{ Scan(...interface{}) error }) (*MachineTrack, error) {
+	var t MachineTrack
+	var points []byte
+	err := row.Scan(&t.ID, &t.WorkerID, &t.OperationType, &t.StartedAt, &t.EndedAt, &t.DistanceMeters, &t.WidthMeters,
+		&t.AutoConfirm, &t.Status, &t.CreatedAt, &t.AnalyzedAt, &points)
+	if err != nil {
+		return nil, err
+	}
+	if points != nil {
+		if err := json.Unmarshal(points, &t.Points); err != nil {
+			return nil, err
+		}
+	}
+	return &t, nil
+}
+
+func (s *PostgresStore) CreateMachineTrack(track *MachineTrack) error {
+	points, err := json.Marshal(track.Points)
+	if err != nil {
+		return err
+	}
+	track.Status = MachineTrackPending
+	return s.db.QueryRow(`INSERT INTO machine_tracks (organization_id, worker_id, operation_type, points, started_at, ended_at,
+							  distance_m, width_m, auto_confirm, status)
+						  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+						  RETURNING id, created_at`,
+		s.org, track.WorkerID, track.OperationType, points, track.StartedAt, track.EndedAt, track.DistanceMeters,
+		track.WidthMeters, track.AutoConfirm, track.Status).
+		Scan(&track.ID, &track.CreatedAt)
+}
+
+func (s *PostgresStore) GetMachineTracks() ([]MachineTrack, error) {
+	return s.queryMachineTracks(`SELECT `+machineTrackColumns+`, NULL::jsonb FROM machine_tracks
+								 WHERE organization_id = $1 ORDER BY started_at DESC, id DESC`, s.org)
+}
+
+func (s *PostgresStore) GetMachineTrackByID(id int) (*MachineTrack, error) {
+	return scanMachineTrack(s.db.QueryRow(`SELECT `+machineTrackColumns+`, points FROM machine_tracks WHERE id = $1 AND organization_id = $2`, id, s.org))
+}
+
+// GetPendingMachineTracks returns up to limit tracks waiting for the analyzer,
+// oldest first, with their points.
+func (s *PostgresStore) GetPendingMachineTracks(limit int) ([]MachineTrack, error) {
+	return s.queryMachineTracks(`SELECT `+machineTrackColumns+`, points FROM machine_tracks
+								 WHERE status = $1 AND organization_id = $3 ORDER BY created_at, id LIMIT $2`, MachineTrackPending, limit, s.org)
+}
+
+func (s *PostgresStore) queryMachineTracks(query string, args ...interface{}) ([]MachineTrack, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tracks := []MachineTrack{}
+	for rows.Next() {
+		t, err := scanMachineTrack(rows)
+		if err != nil {
+			return nil, err
+		}
+		tracks = append(tracks, *t)
+	}
+	return tracks, rows.Err()
+}
+
+// SaveTrackAnalysis marks a pending track as analyzed and stores the
+// operations proposed from it, setting their IDs. It returns sql.ErrNoRows
+// when the track is gone or has already been analyzed, e.g. by another replica.
+func (s *PostgresStore) SaveTrackAnalysis(trackID int, proposals []OperationProposal) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE machine_tracks SET status = $1, analyzed_at = CURRENT_TIMESTAMP
+							WHERE id = $2 AND status = $3 AND organization_id = $4`, MachineTrackAnalyzed, trackID, MachineTrackPending, s.org)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	for i := range proposals {
+		p := &proposals[i]
+		points, err := json.Marshal(p.Points)
+		if err != nil {
+			return err
+		}
+		p.TrackID, p.Status = trackID, ProposalPending
+		err = tx.QueryRow(`INSERT INTO operation_proposals (organization_id, track_id, worker_id, field_id, operation_id, type, points,
+							   start_time, end_time, distance_m, width_m, covered_area, status)
+						   VALUES ($13, $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+						   RETURNING id, created_at`,
+			p.TrackID, p.WorkerID, p.FieldID, p.OperationID, p.Type, points, p.StartTime, p.EndTime,
+			p.DistanceMeters, p.WidthMeters, p.CoveredArea, p.Status, s.org).
+			Scan(&p.ID, &p.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+const proposalColumns = `id, track_id, worker_id, field_id, operation_id, type, start_time, end_time, distance_m,
+						 width_m, covered_area, status, COALESCE(reviewed_by, ''), reviewed_at, created_at`
+
+func scanProposal(row interface{ Scan(...interface{}) error }) (*OperationProposal, error) {
+	var p OperationProposal
+	var points []byte
+	err := row.Scan(&p.ID, &p.TrackID, &p.WorkerID, &p.FieldID, &p.OperationID, &p.Type, &p.StartTime, &p.EndTime,
+		&p.DistanceMeters, &p.WidthMeters, &p.CoveredArea, &p.Status, &p.ReviewedBy, &p.ReviewedAt, &p.CreatedAt, &points)
+	if err != nil {
+		return nil, err
+	}
+	if points != nil {
+		if err := json.Unmarshal(points, &p.Points); err != nil {
+			return nil, err
+		}
+	}
+	return &p, nil
+}
+
+// GetOperationProposals lists the proposals in the given status, or all of
+// them when status is empty, in the order the work was done. Points are left out.
+func (s *PostgresStore) GetOperationProposals(status string) ([]OperationProposal, error) {
+	rows, err := s.db.Query(`SELECT `+proposalColumns+`, NULL::jsonb FROM operation_proposals
+							 WHERE organization_id = $2 AND ($1 = '' OR status = $1)
+							 ORDER BY start_time, id`, status, s.org)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	proposals := []OperationProposal{}
+	for rows.Next() {
+		p, err := scanProposal(rows)
+		if err != nil {
+			return nil, err
+		}
+		proposals = append(proposals, *p)
+	}
+	return proposals, rows.Err()
+}
+
+func (s *PostgresStore) GetOperationProposalByID(id int) (*OperationProposal, error) {
+	return scanProposal(s.db.QueryRow(`SELECT `+proposalColumns+`, points FROM operation_proposals WHERE id = $1 AND organization_id = $2`, id, s.org))
+}
+
+// ConfirmOperationProposal turns a pending proposal into a completed
+// operation with the detected times, coverage and track. The open operation
+// the proposal points to is completed; without one a new operation is created,
+// of operationType if given. It returns the ID of the operation.
+func (s *PostgresStore) ConfirmOperationProposal(id int, operationType, actor string) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	p, err := scanProposal(tx.QueryRow(`SELECT `+proposalColumns+`, points FROM operation_proposals
+										WHERE id = $1 AND organization_id = $2 FOR UPDATE`, id, s.org))
+	if err != nil {
+		return 0, err
+	}
+	if p.Status != ProposalPending {
+		return 0, ErrProposalReviewed
+	}
+
+	var old *Operation
+	if p.OperationID != nil {
+		old, err = lockOperation(tx, s.org, *p.OperationID)
+		if err != nil && err != sql.ErrNoRows {
+			return 0, err
+		}
+		if old != nil && !openForProposal(old) {
+			old = nil
+		}
+	}
+
+	var operationID int
+	if old == nil {
+		if operationType != "" {
+			p.Type = operationType
+		}
+		if p.Type == "" {
+			return 0, ErrProposalType
+		}
+		operation := proposalOperation(p)
+		err = tx.QueryRow(`INSERT INTO operations (organization_id, worker_id, field_id, type, description, status, start_time, end_time,
+							   completed_at, notes, covered_area, status_changed_at, status_changed_by)
+						   VALUES ($11, $1, $2, $3, $4, $5, $6, $7, $8, '', $9, CURRENT_TIMESTAMP, NULLIF($10, ''))
+						   RETURNING id`,
+			operation.WorkerID, operation.FieldID, operation.Type, operation.Description, operation.Status,
+			operation.StartTime, operation.EndTime, operation.CompletedAt, operation.CoveredArea, actor, s.org).
+			Scan(&operation.ID)
+		if err != nil {
+			return 0, err
+		}
+		if err := checkOperationConflicts(tx, s.org, operation.ID); err != nil {
+			return 0, err
+		}
+		event := &OperationEvent{
+			OperationID: operation.ID,
+			Event:       EventCreated,
+			NewStatus:   operation.Status,
+			Changes:     operationChanges(&Operation{}, operation),
+			Actor:       actor,
+		}
+		if err := recordOperationEvent(tx, s.org, event); err != nil {
+			return 0, err
+		}
+		operationID = operation.ID
+	} else {
+		if operationType != "" {
+			p.Type = operationType
+		} else {
+			p.Type = old.Type
+		}
+		updated := completedByProposal(old, p)
+		updated.Type = p.Type
+		_, err = tx.Exec(`UPDATE operations SET type = $1, status = $2, start_time = $3, end_time = $4, completed_at = $5,
+						  covered_area = $6, coverage = CASE WHEN $7 THEN NULL ELSE coverage END, status_changed_at = CURRENT_TIMESTAMP,
+						  status_changed_by = NULLIF($8, ''), updated_at = CURRENT_TIMESTAMP
+						  WHERE id = $9`,
+			updated.Type, updated.Status, updated.StartTime, updated.EndTime, updated.CompletedAt,
+			updated.CoveredArea, p.CoveredArea != nil, actor, old.ID)
+		if err != nil {
+			return 0, err
+		}
+		if err := updateWorkIntervals(tx, old.ID, old.Status, updated.Status); err != nil {
+			return 0, err
+		}
+		if err := checkOperationConflicts(tx, s.org, old.ID); err != nil {
+			return 0, err
+		}
+		event := &OperationEvent{
+			OperationID: old.ID,
+			Event:       EventCompleted,
+			OldStatus:   old.Status,
+			NewStatus:   updated.Status,
+			Changes:     operationChanges(old, updated),
+			Actor:       actor,
+		}
+		if err := recordOperationEvent(tx, s.org, event); err != nil {
+			return 0, err
+		}
+		operationID = old.ID
+	}
+
+	// The detected part of the machine track becomes the track of the operation
+	points, err := json.Marshal(p.Points)
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec(`INSERT INTO operation_tracks (operation_id, points, started_at, ended_at, distance_m, width_m, covered_area)
+					  VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		operationID, points, p.StartTime, p.EndTime, p.DistanceMeters, p.WidthMeters, p.CoveredArea)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`UPDATE operation_proposals SET status = $1, operation_id = $2, type = $3,
+					  reviewed_by = NULLIF($4, ''), reviewed_at = CURRENT_TIMESTAMP
+					  WHERE id = $5`,
+		ProposalConfirmed, operationID, p.Type, actor, id)
+	if err != nil {
+		return 0, err
+	}
+	return operationID, tx.Commit()
+}
+
+// DismissOperationProposal rejects a pending proposal, e.g. a machine that
+// only drove across a field.
+func (s *PostgresStore) DismissOperationProposal(id int, actor string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var status string
+	err = tx.QueryRow(`SELECT status FROM operation_proposals WHERE id = $1 AND organization_id = $2 FOR UPDATE`, id, s.org).Scan(&status)
+	if err != nil {
+		return err
+	}
+	if status != ProposalPending {
+		return ErrProposalReviewed
+	}
+	_, err = tx.Exec(`UPDATE operation_proposals SET status = $1, reviewed_by = NULLIF($2, ''), reviewed_at = CURRENT_TIMESTAMP
+					  WHERE id = $3`, ProposalDismissed, actor, id)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
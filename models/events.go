@@ -0,0 +1,190 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// Operation event types
+const (
+	EventCreated    = "created"
+	EventUpdated    = "updated"
+	EventStarted    = "started"
+	EventPaused     = "paused"
+	EventResumed    = "resumed"
+	EventCompleted  = "completed"
+	EventCancelled  = "cancelled"
+	EventRejected   = "rejected"
+	EventAssigned   = "assigned"
+	EventTrackAdded = "track_added"
+	EventDeleted    = "deleted"
+)
+
+// OperationEvent is an entry in the audit trail of an operation.
+type OperationEvent struct {
+	ID          int                    `json:"id"`
+	OperationID int                    `json:"operation_id"`
+	Event       string                 `json:"event"`
+	OldStatus   string                 `json:"old_status"`
+	NewStatus   string                 `json:"new_status"`
+	Changes     map[string]FieldChange `json:"changes,omitempty"`
+	Actor       string                 `json:"actor"`
+	CreatedAt   time.Time              `json:"created_at"`
+}
+
+type FieldChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// lockOperation loads the stored columns of an operation of the organization
+// and locks its row until tx ends.
+func lockOperation(tx *sql.Tx, org, id int) (*Operation, error) {
+	var o Operation
+	var coverage []byte
+	err := tx.QueryRow(`SELECT id, schedule_id, COALESCE(worker_id, 0), field_id, type, description, status,
+							   start_time, end_time, completed_at, notes, COALESCE(rejection_reason, ''), covered_area, coverage
+						FROM operations WHERE id = $1 AND organization_id = $2 FOR UPDATE`, id, org).
+		Scan(&o.ID, &o.ScheduleID, &o.WorkerID, &o.FieldID, &o.Type, &o.Description, &o.Status,
+			&o.StartTime, &o.EndTime, &o.CompletedAt, &o.Notes, &o.RejectionReason, &o.CoveredArea, &coverage)
+	if err != nil {
+		return nil, err
+	}
+	if coverage != nil {
+		o.Coverage = json.RawMessage(coverage)
+	}
+	return &o, nil
+}
+
+// operationChanges lists the stored columns that differ between two versions of an operation.
+func operationChanges(old, new *Operation) map[string]FieldChange {
+	changes := make(map[string]FieldChange)
+	if !equalIntPtr(old.ScheduleID, new.ScheduleID) {
+		changes["schedule_id"] = FieldChange{Old: old.ScheduleID, New: new.ScheduleID}
+	}
+	if old.WorkerID != new.WorkerID {
+		changes["worker_id"] = FieldChange{Old: old.WorkerID, New: new.WorkerID}
+	}
+	if old.FieldID != new.FieldID {
+		changes["field_id"] = FieldChange{Old: old.FieldID, New: new.FieldID}
+	}
+	if old.Type != new.Type {
+		changes["type"] = FieldChange{Old: old.Type, New: new.Type}
+	}
+	if old.Description != new.Description {
+		changes["description"] = FieldChange{Old: old.Description, New: new.Description}
+	}
+	if old.Status != new.Status {
+		changes["status"] = FieldChange{Old: old.Status, New: new.Status}
+	}
+	if !equalTimePtr(old.StartTime, new.StartTime) {
+		changes["start_time"] = FieldChange{Old: old.StartTime, New: new.StartTime}
+	}
+	if !equalTimePtr(old.EndTime, new.EndTime) {
+		changes["end_time"] = FieldChange{Old: old.EndTime, New: new.EndTime}
+	}
+	if !equalTimePtr(old.CompletedAt, new.CompletedAt) {
+		changes["completed_at"] = FieldChange{Old: old.CompletedAt, New: new.CompletedAt}
+	}
+	if old.Notes != new.Notes {
+		changes["notes"] = FieldChange{Old: old.Notes, New: new.Notes}
+	}
+	if old.RejectionReason != new.RejectionReason {
+		changes["rejection_reason"] = FieldChange{Old: old.RejectionReason, New: new.RejectionReason}
+	}
+	if !equalFloatPtr(old.CoveredArea, new.CoveredArea) {
+		changes["covered_area"] = FieldChange{Old: old.CoveredArea, New: new.CoveredArea}
+	}
+	return changes
+}
+
+func equalFloatPtr(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func equalIntPtr(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func equalTimePtr(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// eventForTransition names the event recorded when an operation moves between two statuses.
+func eventForTransition(from, to string) string {
+	switch to {
+	case StatusInProgress:
+		if from == StatusPaused {
+			return EventResumed
+		}
+		return EventStarted
+	case StatusPaused:
+		return EventPaused
+	case StatusCompleted:
+		return EventCompleted
+	case StatusCancelled:
+		return EventCancelled
+	case StatusRejected:
+		return EventRejected
+	case StatusPlanned:
+		if from == StatusRejected {
+			return EventAssigned
+		}
+	}
+	return EventUpdated
+}
+
+func recordOperationEvent(tx *sql.Tx, org int, event *OperationEvent) error {
+	var changes []byte
+	if len(event.Changes) > 0 {
+		var err error
+		if changes, err = json.Marshal(event.Changes); err != nil {
+			return err
+		}
+	}
+
+	query := `INSERT INTO operation_events (organization_id, operation_id, event, old_status, new_status, changes, actor)
+			  VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), $6, NULLIF($7, ''))
+			  RETURNING id, created_at`
+	return tx.QueryRow(query, org, event.OperationID, event.Event, event.OldStatus, event.NewStatus, changes, event.Actor).
+		Scan(&event.ID, &event.CreatedAt)
+}
+
+// GetOperationEvents returns the audit trail of an operation, oldest first.
+// Events are kept after the operation itself has been deleted.
+func (s *PostgresStore) GetOperationEvents(operationID int) ([]OperationEvent, error) {
+	rows, err := s.db.Query(`SELECT id, operation_id, event, COALESCE(old_status, ''), COALESCE(new_status, ''),
+								  changes, COALESCE(actor, ''), created_at
+						   FROM operation_events WHERE operation_id = $1 AND organization_id = $2
+						   ORDER BY created_at, id`, operationID, s.org)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []OperationEvent
+	for rows.Next() {
+		var e OperationEvent
+		var changes []byte
+		if err := rows.Scan(&e.ID, &e.OperationID, &e.Event, &e.OldStatus, &e.NewStatus, &changes, &e.Actor, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		if len(changes) > 0 {
+			if err := json.Unmarshal(changes, &e.Changes); err != nil {
+				return nil, err
+			}
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
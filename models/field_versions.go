@@ -0,0 +1,262 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Field lineage events
+const (
+	LineageSplit = "split"
+	LineageMerge = "merge"
+)
+
+// ErrFieldRetired is returned when changing, splitting or merging a field
+// that has already been split or merged into others.
+var ErrFieldRetired = errors.New("field has been retired")
+
+// FieldInUseError is returned when splitting or merging fields that still
+// have planned, started or paused operations.
+type FieldInUseError struct {
+	OperationIDs []int
+}
+
+func (e *FieldInUseError) Error() string {
+	ids := make([]string, len(e.OperationIDs))
+	for i, id := range e.OperationIDs {
+		ids[i] = fmt.Sprint(id)
+	}
+	return "field has open operations: " + strings.Join(ids, ", ")
+}
+
+// FieldVersion is a boundary a field had between ValidFrom and ValidTo. The
+// first version has no ValidFrom and the current one no ValidTo.
+type FieldVersion struct {
+	ID          int             `json:"id"`
+	FieldID     int             `json:"field_id"`
+	Coordinates json.RawMessage `json:"coordinates"`
+	Area        float64         `json:"area"`
+	ValidFrom   *time.Time      `json:"valid_from"`
+	ValidTo     *time.Time      `json:"valid_to"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// FieldLink is a field a split or merge created another from, or created
+// from another.
+type FieldLink struct {
+	FieldID   int       `json:"field_id"`
+	Name      string    `json:"name"`
+	Event     string    `json:"event"` // see the Lineage* constants
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// FieldLineage lists the fields a field was split or merged from and the
+// fields it was split or merged into.
+type FieldLineage struct {
+	FieldID      int         `json:"field_id"`
+	Predecessors []FieldLink `json:"predecessors"`
+	Successors   []FieldLink `json:"successors"`
+}
+
+// FieldVersionAt returns the version of a field in effect at t, given all of
+// its versions ordered as by GetFieldVersions, or nil when there are none.
+func FieldVersionAt(versions []FieldVersion, t time.Time) *FieldVersion {
+	var found *FieldVersion
+	for i, v := range versions {
+		if v.ValidFrom == nil || !v.ValidFrom.After(t) {
+			found = &versions[i]
+		}
+	}
+	return found
+}
+
+// fieldVersionJoin joins fb, the version of the operation's field in effect
+// when the operation started or, if it has not, when it was created.
+const fieldVersionJoin = `LEFT JOIN LATERAL (
+			SELECT b.coordinates, b.area FROM field_boundaries b
+			WHERE b.field_id = o.field_id AND (b.valid_from IS NULL OR b.valid_from <= COALESCE(o.start_time, o.created_at))
+			ORDER BY b.valid_from DESC NULLS LAST, b.id DESC
+			LIMIT 1
+		) fb ON true`
+
+// lockCurrentField locks a field of the organization that has not been retired.
+func lockCurrentField(tx *sql.Tx, org, id int) error {
+	var retiredAt *time.Time
+	err := tx.QueryRow(`SELECT retired_at FROM fields WHERE id = $1 AND organization_id = $2 FOR UPDATE`, id, org).Scan(&retiredAt)
+	if err != nil {
+		return err
+	}
+	if retiredAt != nil {
+		return ErrFieldRetired
+	}
+	return nil
+}
+
+// checkNoOpenOperations returns a FieldInUseError when any of the fields has
+// open operations, which would be left on a retired field.
+func checkNoOpenOperations(tx *sql.Tx, fieldIDs []int) error {
+	rows, err := tx.Query(`SELECT id FROM operations WHERE field_id = ANY($1) AND status IN ($2, $3, $4) ORDER BY id`,
+		pq.Array(fieldIDs), StatusPlanned, StatusInProgress, StatusPaused)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(ids) > 0 {
+		return &FieldInUseError{OperationIDs: ids}
+	}
+	return nil
+}
+
+// retireFields retires the parents of a split or merge and records their
+// lineage to the children.
+func retireFields(tx *sql.Tx, parents []int, children []*Field, event string) error {
+	for _, id := range parents {
+		_, err := tx.Exec(`UPDATE fields SET retired_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP WHERE id = $1`, id)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`UPDATE field_boundaries SET valid_to = CURRENT_TIMESTAMP WHERE field_id = $1 AND valid_to IS NULL`, id)
+		if err != nil {
+			return err
+		}
+		for _, child := range children {
+			_, err := tx.Exec(`INSERT INTO field_lineage (parent_id, child_id, event) VALUES ($1, $2, $3)`, id, child.ID, event)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// SplitField replaces a field by the given parts, which are created as new
+// fields. The field is retired and keeps its operations and boundary history.
+func (s *PostgresStore) SplitField(id int, parts []*Field) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockCurrentField(tx, s.org, id); err != nil {
+		return err
+	}
+	if err := checkNoOpenOperations(tx, []int{id}); err != nil {
+		return err
+	}
+	for _, part := range parts {
+		if err := insertField(tx, s.org, part, nil); err != nil {
+			return err
+		}
+	}
+	if err := retireFields(tx, []int{id}, parts, LineageSplit); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// MergeFields replaces the fields with the given IDs by a new merged field.
+// The merged fields are retired and keep their operations and boundary history.
+func (s *PostgresStore) MergeFields(ids []int, merged *Field) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock in a fixed order so concurrent merges cannot deadlock
+	sorted := append([]int(nil), ids...)
+	sort.Ints(sorted)
+	for _, id := range sorted {
+		if err := lockCurrentField(tx, s.org, id); err != nil {
+			return err
+		}
+	}
+	if err := checkNoOpenOperations(tx, sorted); err != nil {
+		return err
+	}
+	if err := insertField(tx, s.org, merged, nil); err != nil {
+		return err
+	}
+	if err := retireFields(tx, sorted, []*Field{merged}, LineageMerge); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetFieldVersions lists the boundaries a field has had, oldest first.
+func (s *PostgresStore) GetFieldVersions(id int) ([]FieldVersion, error) {
+	rows, err := s.db.Query(`SELECT b.id, b.field_id, b.coordinates, b.area, b.valid_from, b.valid_to, b.created_at
+							 FROM field_boundaries b JOIN fields f ON f.id = b.field_id AND f.organization_id = $2
+							 WHERE b.field_id = $1
+							 ORDER BY b.valid_from NULLS FIRST, b.id`, id, s.org)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := []FieldVersion{}
+	for rows.Next() {
+		var v FieldVersion
+		if err := rows.Scan(&v.ID, &v.FieldID, &v.Coordinates, &v.Area, &v.ValidFrom, &v.ValidTo, &v.CreatedAt); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+func (s *PostgresStore) GetFieldLineage(id int) (*FieldLineage, error) {
+	lineage := &FieldLineage{FieldID: id}
+	var err error
+	lineage.Predecessors, err = s.queryFieldLinks(`SELECT l.parent_id, f.name, l.event, l.created_at
+												  FROM field_lineage l JOIN fields f ON f.id = l.parent_id AND f.organization_id = $2
+												  WHERE l.child_id = $1 ORDER BY l.created_at, l.parent_id`, id)
+	if err != nil {
+		return nil, err
+	}
+	lineage.Successors, err = s.queryFieldLinks(`SELECT l.child_id, f.name, l.event, l.created_at
+												FROM field_lineage l JOIN fields f ON f.id = l.child_id AND f.organization_id = $2
+												WHERE l.parent_id = $1 ORDER BY l.created_at, l.child_id`, id)
+	if err != nil {
+		return nil, err
+	}
+	return lineage, nil
+}
+
+func (s *PostgresStore) queryFieldLinks(query string, id int) ([]FieldLink, error) {
+	rows, err := s.db.Query(query, id, s.org)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := []FieldLink{}
+	for rows.Next() {
+		var l FieldLink
+		if err := rows.Scan(&l.FieldID, &l.Name, &l.Event, &l.CreatedAt); err != nil {
+			return nil, err
+		}
+		links = append(links, l)
+	}
+	return links, rows.Err()
+}
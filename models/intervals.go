@@ -0,0 +1,55 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// WorkInterval is a stretch of time during which an operation was actually in progress.
+// Pausing an operation closes its open interval and resuming it opens a new one.
+type WorkInterval struct {
+	ID          int        `json:"id"`
+	OperationID int        `json:"operation_id"`
+	StartedAt   time.Time  `json:"started_at"`
+	EndedAt     *time.Time `json:"ended_at"`
+}
+
+// updateWorkIntervals keeps the work intervals in line with a status change:
+// entering in_progress opens an interval and leaving it closes the open one.
+func updateWorkIntervals(tx *sql.Tx, operationID int, from, to string) error {
+	if from == StatusInProgress && to != StatusInProgress {
+		_, err := tx.Exec(`UPDATE operation_intervals SET ended_at = CURRENT_TIMESTAMP
+						   WHERE operation_id = $1 AND ended_at IS NULL`, operationID)
+		if err != nil {
+			return err
+		}
+	}
+	if to == StatusInProgress && from != StatusInProgress {
+		_, err := tx.Exec(`INSERT INTO operation_intervals (operation_id, started_at)
+						   VALUES ($1, CURRENT_TIMESTAMP)`, operationID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *PostgresStore) GetWorkIntervals(operationID int) ([]WorkInterval, error) {
+	rows, err := s.db.Query(`SELECT i.id, i.operation_id, i.started_at, i.ended_at FROM operation_intervals i
+						   JOIN operations o ON o.id = i.operation_id AND o.organization_id = $2
+						   WHERE i.operation_id = $1 ORDER BY i.started_at`, operationID, s.org)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var intervals []WorkInterval
+	for rows.Next() {
+		var i WorkInterval
+		if err := rows.Scan(&i.ID, &i.OperationID, &i.StartedAt, &i.EndedAt); err != nil {
+			return nil, err
+		}
+		intervals = append(intervals, i)
+	}
+	return intervals, rows.Err()
+}
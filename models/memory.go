@@ -0,0 +1,1101 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps all data in process memory. It mirrors PostgresStore,
+// including cascading deletes, work intervals and operation history, so the
+// API can run in tests and local demos without a database (STORAGE=memory).
+type MemoryStore struct {
+	*memoryDB
+	*tenantData
+	org int
+}
+
+// memoryDB is the data shared by all organizations.
+type memoryDB struct {
+	mu      sync.Mutex
+	lastID  map[string]int
+	workers map[int]Worker // without access roles, which are in members
+
+	passwordHashes map[int]string
+	loginCodes     []LoginCode
+	refreshTokens  map[string]RefreshToken
+
+	organizations map[int]Organization
+	members       map[int]map[int]string     // organization ID -> worker ID -> access role
+	invitations   map[int]map[int]Invitation // organization ID -> worker ID -> invitation
+	tenants       map[int]*tenantData
+}
+
+// tenantData is the data of one organization.
+type tenantData struct {
+	fields     map[int]Field
+	schedules  map[int]Schedule
+	operations map[int]Operation
+	intervals  []WorkInterval
+	tracks     []Track
+	events     []OperationEvent
+
+	machineTracks map[int]MachineTrack
+	proposals     map[int]OperationProposal
+
+	fieldVersions []FieldVersion
+	lineage       []fieldLineage
+
+	regions map[int]Region
+}
+
+func NewMemoryStore() *MemoryStore {
+	db := &memoryDB{
+		lastID:  make(map[string]int),
+		workers: make(map[int]Worker),
+
+		passwordHashes: make(map[int]string),
+		refreshTokens:  make(map[string]RefreshToken),
+
+		organizations: make(map[int]Organization),
+		members:       make(map[int]map[int]string),
+		invitations:   make(map[int]map[int]Invitation),
+		tenants:       make(map[int]*tenantData),
+	}
+	return db.store(0)
+}
+
+// store returns the store of an organization, creating its data on first use.
+func (db *memoryDB) store(org int) *MemoryStore {
+	tenant, ok := db.tenants[org]
+	if !ok {
+		tenant = &tenantData{
+			fields:     make(map[int]Field),
+			schedules:  make(map[int]Schedule),
+			operations: make(map[int]Operation),
+
+			machineTracks: make(map[int]MachineTrack),
+			proposals:     make(map[int]OperationProposal),
+
+			regions: make(map[int]Region),
+		}
+		db.tenants[org] = tenant
+	}
+	return &MemoryStore{memoryDB: db, tenantData: tenant, org: org}
+}
+
+func (m *MemoryStore) nextID(table string) int {
+	m.lastID[table]++
+	return m.lastID[table]
+}
+
+// Worker methods
+func (m *MemoryStore) CreateWorker(worker *Worker) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkWorker(worker); err != nil {
+		return err
+	}
+	if _, ok := m.organizations[m.org]; !ok {
+		return fmt.Errorf("organization %d does not exist", m.org)
+	}
+	now := time.Now()
+	worker.ID = m.nextID("workers")
+	worker.CreatedAt, worker.UpdatedAt = now, now
+	m.workers[worker.ID] = globalWorker(*worker)
+	m.members[m.org][worker.ID] = worker.AccessRole
+	return nil
+}
+
+// checkWorker enforces the unique email constraint of the workers table
+// and the check constraint on access roles.
+func (m *MemoryStore) checkWorker(worker *Worker) error {
+	if err := checkAccessRole(worker.AccessRole); err != nil {
+		return err
+	}
+	for _, w := range m.workers {
+		if w.ID != worker.ID && w.Email == worker.Email {
+			return fmt.Errorf("email %q is already used by worker %d", worker.Email, w.ID)
+		}
+	}
+	return nil
+}
+
+func checkAccessRole(accessRole string) error {
+	switch accessRole {
+	case "owner", "agronomist", "foreman", "operator":
+		return nil
+	}
+	return fmt.Errorf("invalid access role %q", accessRole)
+}
+
+// globalWorker drops what depends on the organization from a worker.
+func globalWorker(w Worker) Worker {
+	w.AccessRole = ""
+	return w
+}
+
+// member returns a worker of the store's organization with their access role
+// there.
+func (m *MemoryStore) member(id int) (Worker, bool) {
+	accessRole, ok := m.members[m.org][id]
+	if !ok {
+		return Worker{}, false
+	}
+	w := m.workers[id]
+	w.AccessRole = accessRole
+	return w, true
+}
+
+func (m *MemoryStore) GetWorkers() ([]Worker, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var workers []Worker
+	for id := range m.members[m.org] {
+		w, _ := m.member(id)
+		workers = append(workers, w)
+	}
+	sort.Slice(workers, func(i, j int) bool {
+		if workers[i].Name != workers[j].Name {
+			return workers[i].Name < workers[j].Name
+		}
+		return workers[i].ID < workers[j].ID
+	})
+	return workers, nil
+}
+
+func (m *MemoryStore) GetWorkerByID(id int) (*Worker, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w, ok := m.member(id)
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &w, nil
+}
+
+func (m *MemoryStore) UpdateWorker(worker *Worker) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	old, ok := m.member(worker.ID)
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if err := m.checkWorker(worker); err != nil {
+		return err
+	}
+	if worker.Email != old.Email || worker.Phone != old.Phone {
+		for org, members := range m.members {
+			if _, ok := members[worker.ID]; ok && org != m.org {
+				return ErrSharedWorker
+			}
+		}
+	}
+	worker.CreatedAt = old.CreatedAt
+	worker.UpdatedAt = time.Now()
+	m.workers[worker.ID] = globalWorker(*worker)
+	m.members[m.org][worker.ID] = worker.AccessRole
+	return nil
+}
+
+// DeleteWorker removes a worker from the organization together with their
+// schedules, operations and tracks there. A worker left in no organization
+// is deleted altogether.
+func (m *MemoryStore) DeleteWorker(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.members[m.org][id]; !ok {
+		return nil
+	}
+	delete(m.members[m.org], id)
+	m.deleteSchedules(func(s Schedule) bool { return s.WorkerID == id })
+	m.deleteOperations(func(o Operation) bool { return o.WorkerID == id })
+	m.deleteMachineTracks(func(t MachineTrack) bool { return t.WorkerID == id })
+
+	for _, members := range m.members {
+		if _, ok := members[id]; ok {
+			return nil
+		}
+	}
+	delete(m.workers, id)
+	m.deleteAccount(id)
+	for _, invitations := range m.invitations {
+		delete(invitations, id)
+	}
+	for _, tenant := range m.tenants {
+		for opID, o := range tenant.operations {
+			if o.RejectedBy != nil && *o.RejectedBy == id {
+				o.RejectedBy = nil
+				tenant.operations[opID] = o
+			}
+		}
+	}
+	return nil
+}
+
+// Field methods
+func (m *MemoryStore) CreateField(field *Field) error {
+	return m.CreateFields([]*Field{field})
+}
+
+func (m *MemoryStore) CreateFields(fields []*Field) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, field := range fields {
+		if err := m.checkFieldRegion(field); err != nil {
+			return err
+		}
+	}
+	now := time.Now()
+	for _, field := range fields {
+		m.insertField(field, now)
+	}
+	return nil
+}
+
+func (m *MemoryStore) GetFields() ([]Field, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var fields []Field
+	for _, f := range m.fields {
+		if f.RetiredAt == nil {
+			fields = append(fields, m.withRegion(f))
+		}
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		if fields[i].Name != fields[j].Name {
+			return fields[i].Name < fields[j].Name
+		}
+		return fields[i].ID < fields[j].ID
+	})
+	return fields, nil
+}
+
+func (m *MemoryStore) GetFieldByID(id int) (*Field, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, ok := m.fields[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	f = m.withRegion(f)
+	return &f, nil
+}
+
+func (m *MemoryStore) UpdateField(field *Field) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	old, ok := m.fields[field.ID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if old.RetiredAt != nil {
+		return ErrFieldRetired
+	}
+	if err := m.checkFieldRegion(field); err != nil {
+		return err
+	}
+	field.CreatedAt = old.CreatedAt
+	field.UpdatedAt = time.Now()
+	field.RetiredAt = nil
+	m.fields[field.ID] = *field
+
+	if !sameJSON(old.Coordinates, field.Coordinates) {
+		m.closeFieldVersion(field.ID, field.UpdatedAt)
+		validFrom := field.UpdatedAt
+		m.fieldVersions = append(m.fieldVersions, FieldVersion{
+			ID:          m.nextID("field_boundaries"),
+			FieldID:     field.ID,
+			Coordinates: field.Coordinates,
+			Area:        field.Area,
+			ValidFrom:   &validFrom,
+			CreatedAt:   field.UpdatedAt,
+		})
+	}
+	return nil
+}
+
+func (m *MemoryStore) DeleteField(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.fields, id)
+	m.deleteOperations(func(o Operation) bool { return o.FieldID == id })
+	m.deleteProposals(func(p OperationProposal) bool { return p.FieldID == id })
+
+	versions := m.fieldVersions[:0]
+	for _, v := range m.fieldVersions {
+		if v.FieldID != id {
+			versions = append(versions, v)
+		}
+	}
+	m.fieldVersions = versions
+	lineage := m.lineage[:0]
+	for _, l := range m.lineage {
+		if l.parentID != id && l.childID != id {
+			lineage = append(lineage, l)
+		}
+	}
+	m.lineage = lineage
+	return nil
+}
+
+// Schedule methods
+func (m *MemoryStore) CreateSchedule(schedule *Schedule) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.insertSchedule(schedule)
+}
+
+func (m *MemoryStore) insertSchedule(schedule *Schedule) error {
+	if _, ok := m.member(schedule.WorkerID); !ok {
+		return fmt.Errorf("worker %d does not exist", schedule.WorkerID)
+	}
+	if err := m.checkScheduleConflicts(schedule); err != nil {
+		return err
+	}
+	now := time.Now()
+	schedule.ID = m.nextID("schedules")
+	schedule.CreatedAt, schedule.UpdatedAt = now, now
+	stored := *schedule
+	stored.Worker = nil
+	m.schedules[schedule.ID] = stored
+	return nil
+}
+
+func (m *MemoryStore) GetSchedules() ([]Schedule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.listSchedules(func(s Schedule) bool { return true }), nil
+}
+
+func (m *MemoryStore) GetScheduleByID(id int) (*Schedule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.schedules[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	s = m.withScheduleWorker(s)
+	return &s, nil
+}
+
+func (m *MemoryStore) GetWorkerSchedules(workerID int) ([]Schedule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.listSchedules(func(s Schedule) bool { return s.WorkerID == workerID }), nil
+}
+
+func (m *MemoryStore) UpdateSchedule(schedule *Schedule) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	old, ok := m.schedules[schedule.ID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if _, ok := m.member(schedule.WorkerID); !ok {
+		return fmt.Errorf("worker %d does not exist", schedule.WorkerID)
+	}
+	if err := m.checkScheduleConflicts(schedule); err != nil {
+		return err
+	}
+	old.WorkerID = schedule.WorkerID
+	old.Date = schedule.Date
+	old.UpdatedAt = time.Now()
+	m.schedules[schedule.ID] = old
+	schedule.UpdatedAt = old.UpdatedAt
+	return nil
+}
+
+func (m *MemoryStore) DeleteSchedule(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.deleteSchedules(func(s Schedule) bool { return s.ID == id })
+	return nil
+}
+
+// listSchedules returns the matching schedules, latest date first.
+func (m *MemoryStore) listSchedules(match func(Schedule) bool) []Schedule {
+	var schedules []Schedule
+	for _, s := range m.schedules {
+		if match(s) {
+			schedules = append(schedules, m.withScheduleWorker(s))
+		}
+	}
+	sort.Slice(schedules, func(i, j int) bool {
+		if !schedules[i].Date.Equal(schedules[j].Date) {
+			return schedules[i].Date.After(schedules[j].Date)
+		}
+		return schedules[i].ID < schedules[j].ID
+	})
+	return schedules
+}
+
+func (m *MemoryStore) withScheduleWorker(s Schedule) Schedule {
+	if w, ok := m.workers[s.WorkerID]; ok {
+		s.Worker = &Worker{ID: w.ID, Name: w.Name}
+	}
+	return s
+}
+
+func (m *MemoryStore) deleteSchedules(match func(Schedule) bool) {
+	for id, s := range m.schedules {
+		if match(s) {
+			delete(m.schedules, id)
+			m.deleteOperations(func(o Operation) bool { return o.ScheduleID != nil && *o.ScheduleID == id })
+		}
+	}
+}
+
+// deleteOperations removes the matching operations, their work intervals and
+// tracks, and unlinks them from proposals. Like the operation_events table,
+// the history is kept.
+func (m *MemoryStore) deleteOperations(match func(Operation) bool) {
+	deleted := make(map[int]bool)
+	for id, o := range m.operations {
+		if match(o) {
+			delete(m.operations, id)
+			deleted[id] = true
+		}
+	}
+	if len(deleted) == 0 {
+		return
+	}
+	intervals := m.intervals[:0]
+	for _, i := range m.intervals {
+		if !deleted[i.OperationID] {
+			intervals = append(intervals, i)
+		}
+	}
+	m.intervals = intervals
+
+	tracks := m.tracks[:0]
+	for _, t := range m.tracks {
+		if !deleted[t.OperationID] {
+			tracks = append(tracks, t)
+		}
+	}
+	m.tracks = tracks
+
+	for id, p := range m.proposals {
+		if p.OperationID != nil && deleted[*p.OperationID] {
+			p.OperationID = nil
+			m.proposals[id] = p
+		}
+	}
+}
+
+// Operation methods
+func (m *MemoryStore) CreateOperation(operation *Operation, actor string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if operation.Status == "" {
+		operation.Status = StatusPlanned
+	}
+	if operation.Status != StatusPlanned {
+		return ErrStatusChange
+	}
+	return m.insertOperation(operation, actor)
+}
+
+func (m *MemoryStore) insertOperation(operation *Operation, actor string) error {
+	if operation.WorkerID == 0 {
+		return fmt.Errorf("worker 0 does not exist")
+	}
+	if err := m.checkOperationRefs(operation); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	operation.ID = m.nextID("operations")
+	operation.CreatedAt, operation.UpdatedAt = now, now
+	operation.StatusChangedAt = &now
+	operation.StatusChangedBy = actor
+	operation.CoveredArea, operation.Coverage = nil, nil
+
+	stored := storedOperation(operation)
+	stored.CompletedAt = nil
+	stored.RejectedBy = nil
+	stored.RejectionReason = ""
+	if err := m.checkOperationConflicts(&stored); err != nil {
+		return err
+	}
+	m.operations[operation.ID] = stored
+
+	m.recordOperationEvent(&OperationEvent{
+		OperationID: operation.ID,
+		Event:       EventCreated,
+		NewStatus:   operation.Status,
+		Changes:     operationChanges(&Operation{}, operation),
+		Actor:       actor,
+	})
+	return nil
+}
+
+// StartNewOperation works like PostgresStore.StartNewOperation, undoing the
+// schedule and the operation when a later step fails.
+func (m *MemoryStore) StartNewOperation(schedule *Schedule, operation *Operation, actor string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	newSchedule := schedule.ID == 0
+	if newSchedule {
+		if err := m.insertSchedule(schedule); err != nil {
+			return err
+		}
+	}
+	undo := func() {
+		if newSchedule {
+			delete(m.schedules, schedule.ID)
+		}
+	}
+	operation.ScheduleID = &schedule.ID
+	operation.Status = StatusPlanned
+	events := len(m.events)
+	if err := m.insertOperation(operation, actor); err != nil {
+		undo()
+		return err
+	}
+	if err := m.applyTransition(operation.ID, []string{StatusPlanned}, StatusInProgress, actor, nil); err != nil {
+		delete(m.operations, operation.ID)
+		m.events = m.events[:events]
+		undo()
+		return err
+	}
+	return nil
+}
+
+// checkOperationRefs enforces the foreign keys of the operations table. An
+// operation without a worker (WorkerID 0) is in the unassigned pool.
+func (m *MemoryStore) checkOperationRefs(o *Operation) error {
+	if _, ok := m.member(o.WorkerID); !ok && o.WorkerID != 0 {
+		return fmt.Errorf("worker %d does not exist", o.WorkerID)
+	}
+	if _, ok := m.fields[o.FieldID]; !ok {
+		return fmt.Errorf("field %d does not exist", o.FieldID)
+	}
+	if o.ScheduleID != nil {
+		if _, ok := m.schedules[*o.ScheduleID]; !ok {
+			return fmt.Errorf("schedule %d does not exist", *o.ScheduleID)
+		}
+	}
+	return nil
+}
+
+// storedOperation keeps only the columns of an operation, dropping the joined
+// records and computed values that are filled in when it is read.
+func storedOperation(o *Operation) Operation {
+	stored := *o
+	stored.HoursWorked = 0
+	stored.CoveragePercent = 0
+	stored.Schedule = nil
+	stored.Worker = nil
+	stored.Field = nil
+	stored.Intervals = nil
+	return stored
+}
+
+// loadOperation fills in what the Postgres queries join or compute.
+func (m *MemoryStore) loadOperation(o Operation) Operation {
+	if w, ok := m.workers[o.WorkerID]; ok {
+		o.Worker = &Worker{ID: w.ID, Name: w.Name}
+	}
+	var fieldArea float64
+	if f, ok := m.fields[o.FieldID]; ok {
+		o.Field = &Field{ID: f.ID, Name: f.Name}
+		fieldArea = m.operationFieldVersion(o).Area
+	}
+	o.HoursWorked = m.operationHours(o)
+	o.CoveragePercent = coveragePercent(&o, fieldArea)
+	return o
+}
+
+// operationHours mirrors operationHoursSQL.
+func (m *MemoryStore) operationHours(o Operation) float64 {
+	var seconds float64
+	found := false
+	for _, i := range m.intervals {
+		if i.OperationID != o.ID {
+			continue
+		}
+		found = true
+		end := time.Now()
+		if i.EndedAt != nil {
+			end = *i.EndedAt
+		}
+		seconds += end.Sub(i.StartedAt).Seconds()
+	}
+	if !found && o.Status == StatusCompleted && o.StartTime != nil {
+		end := o.EndTime
+		if end == nil {
+			end = o.CompletedAt
+		}
+		if end != nil {
+			seconds = end.Sub(*o.StartTime).Seconds()
+		}
+	}
+	return seconds / 3600
+}
+
+func (m *MemoryStore) GetOperations() ([]Operation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var operations []Operation
+	for _, o := range m.operations {
+		operations = append(operations, m.loadOperation(o))
+	}
+	sortByStartTime(operations)
+	return operations, nil
+}
+
+func (m *MemoryStore) GetWorkerOperations(workerID int, date time.Time) ([]Operation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	day := date.Format("2006-01-02")
+	var operations []Operation
+	for _, o := range m.operations {
+		if o.WorkerID != workerID {
+			continue
+		}
+		open := o.Status == StatusPlanned || o.Status == StatusInProgress || o.Status == StatusPaused
+		scheduled := false
+		if o.ScheduleID != nil {
+			scheduled = m.schedules[*o.ScheduleID].Date.Format("2006-01-02") == day
+		}
+		if open || scheduled {
+			operations = append(operations, m.loadOperation(o))
+		}
+	}
+	sortByStartTime(operations)
+	return operations, nil
+}
+
+// sortByStartTime puts the latest start first, with operations that have not
+// started on top as in Postgres.
+func sortByStartTime(operations []Operation) {
+	sort.Slice(operations, func(i, j int) bool {
+		a, b := operations[i].StartTime, operations[j].StartTime
+		switch {
+		case a == nil || b == nil:
+			if (a == nil) != (b == nil) {
+				return a == nil
+			}
+		case !a.Equal(*b):
+			return a.After(*b)
+		}
+		return operations[i].ID < operations[j].ID
+	})
+}
+
+func (m *MemoryStore) GetOperationByID(id int) (*Operation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	o, ok := m.operations[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	o = m.loadOperation(o)
+	o.Intervals = m.workIntervals(id)
+	return &o, nil
+}
+
+func (m *MemoryStore) UpdateOperation(operation *Operation, actor string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	old, ok := m.operations[operation.ID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	current := old.Status
+	if operation.Status == "" {
+		operation.Status = current
+	}
+	operation.CoveredArea, operation.Coverage = old.CoveredArea, old.Coverage
+	if operation.Status != current {
+		return ErrStatusChange
+	}
+	if operation.WorkerID == 0 {
+		operation.WorkerID = old.WorkerID
+	}
+	if err := m.checkOperationRefs(operation); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	updated := old
+	updated.ScheduleID = operation.ScheduleID
+	updated.WorkerID = operation.WorkerID
+	updated.FieldID = operation.FieldID
+	updated.Type = operation.Type
+	updated.Description = operation.Description
+	updated.StartTime = operation.StartTime
+	updated.EndTime = operation.EndTime
+	updated.Notes = operation.Notes
+	updated.UpdatedAt = now
+	if err := m.checkOperationConflicts(&updated); err != nil {
+		return err
+	}
+	m.operations[operation.ID] = updated
+
+	operation.UpdatedAt = updated.UpdatedAt
+	operation.CompletedAt = updated.CompletedAt
+	operation.RejectedBy = updated.RejectedBy
+	operation.RejectionReason = updated.RejectionReason
+
+	event := &OperationEvent{
+		OperationID: operation.ID,
+		Event:       EventUpdated,
+		OldStatus:   current,
+		NewStatus:   operation.Status,
+		Changes:     operationChanges(&old, operation),
+		Actor:       actor,
+	}
+	m.recordOperationEvent(event)
+	return nil
+}
+
+// transitionOperation works like PostgresStore.transitionOperation, with set
+// making any further changes to the operation.
+func (m *MemoryStore) transitionOperation(id int, from []string, to, actor string, set func(o *Operation) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.applyTransition(id, from, to, actor, set)
+}
+
+func (m *MemoryStore) applyTransition(id int, from []string, to, actor string, set func(o *Operation) error) error {
+	old, ok := m.operations[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	current := old.Status
+	if !CanTransition(current, to) || (len(from) > 0 && !containsStatus(from, current)) {
+		return &TransitionError{From: current, To: to}
+	}
+
+	now := time.Now()
+	updated := old
+	updated.Status = to
+	updated.StatusChangedAt = &now
+	updated.StatusChangedBy = actor
+	updated.UpdatedAt = now
+	switch {
+	case current == StatusPlanned && to == StatusInProgress:
+		updated.StartTime = &now
+	case to == StatusCompleted:
+		updated.CompletedAt = &now
+		updated.EndTime = &now
+	case to == StatusRejected:
+		updated.RejectedBy = nil
+		if old.WorkerID != 0 {
+			workerID := old.WorkerID
+			updated.RejectedBy = &workerID
+		}
+		updated.WorkerID = 0
+		updated.ScheduleID = nil
+	}
+	if set != nil {
+		if err := set(&updated); err != nil {
+			return err
+		}
+	}
+	if to == StatusInProgress || to == StatusPlanned {
+		if err := m.checkOperationConflicts(&updated); err != nil {
+			return err
+		}
+	}
+	m.operations[id] = updated
+
+	m.updateWorkIntervals(id, current, to, now)
+	m.recordOperationEvent(&OperationEvent{
+		OperationID: id,
+		Event:       eventForTransition(current, to),
+		OldStatus:   current,
+		NewStatus:   to,
+		Changes:     operationChanges(&old, &updated),
+		Actor:       actor,
+	})
+	return nil
+}
+
+func (m *MemoryStore) CompleteOperation(id int, actor string) error {
+	return m.transitionOperation(id, nil, StatusCompleted, actor, nil)
+}
+
+func (m *MemoryStore) StartOperation(id int, actor string) error {
+	return m.transitionOperation(id, []string{StatusPlanned}, StatusInProgress, actor, nil)
+}
+
+func (m *MemoryStore) PauseOperation(id int, actor string) error {
+	return m.transitionOperation(id, nil, StatusPaused, actor, nil)
+}
+
+func (m *MemoryStore) ResumeOperation(id int, actor string) error {
+	return m.transitionOperation(id, []string{StatusPaused}, StatusInProgress, actor, nil)
+}
+
+func (m *MemoryStore) CancelOperation(id int, actor string) error {
+	return m.transitionOperation(id, nil, StatusCancelled, actor, nil)
+}
+
+func (m *MemoryStore) RejectOperation(id int, reason, actor string) error {
+	return m.transitionOperation(id, nil, StatusRejected, actor, func(o *Operation) error {
+		o.RejectionReason = reason
+		return nil
+	})
+}
+
+func (m *MemoryStore) AssignOperation(id, workerID int, scheduleID *int, actor string) error {
+	return m.transitionOperation(id, []string{StatusRejected}, StatusPlanned, actor, func(o *Operation) error {
+		o.RejectedBy = nil
+		o.RejectionReason = ""
+		o.ScheduleID = scheduleID
+		o.WorkerID = workerID
+		return m.checkOperationRefs(o)
+	})
+}
+
+func (m *MemoryStore) SetOperationCoverage(id int, coveredArea *float64, coverage json.RawMessage, actor string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	old, ok := m.operations[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	updated := old
+	updated.CoveredArea, updated.Coverage = coveredArea, coverage
+	updated.UpdatedAt = time.Now()
+	m.operations[id] = updated
+
+	m.recordOperationEvent(&OperationEvent{
+		OperationID: id,
+		Event:       EventUpdated,
+		OldStatus:   old.Status,
+		NewStatus:   old.Status,
+		Changes:     operationChanges(&old, &updated),
+		Actor:       actor,
+	})
+	return nil
+}
+
+func (m *MemoryStore) GetFieldCoverage(fieldID int, from, to *time.Time) ([]FieldCoverage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	field, ok := m.fields[fieldID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	byType := make(map[string]*FieldCoverage)
+	for _, o := range m.operations {
+		if o.FieldID != fieldID || o.Status == StatusCancelled || o.Status == StatusRejected {
+			continue
+		}
+		started := o.CreatedAt
+		if o.StartTime != nil {
+			started = *o.StartTime
+		}
+		if (from != nil && started.Before(*from)) || (to != nil && !started.Before(*to)) {
+			continue
+		}
+		c, ok := byType[o.Type]
+		if !ok {
+			c = &FieldCoverage{Type: o.Type}
+			byType[o.Type] = c
+		}
+		c.Operations++
+		c.CoveredArea += coveredArea(&o, m.operationFieldVersion(o).Area)
+	}
+
+	coverage := []FieldCoverage{}
+	for _, c := range byType {
+		coverage = append(coverage, fieldCoverage(*c, field.Area))
+	}
+	sort.Slice(coverage, func(i, j int) bool { return coverage[i].Type < coverage[j].Type })
+	return coverage, nil
+}
+
+func (m *MemoryStore) AddOperationTrack(track *Track, summary TrackSummary, actor string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	old, ok := m.operations[track.OperationID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	now := time.Now()
+	updated := summarizeTracks(&old, summary)
+	updated.UpdatedAt = now
+	if err := m.checkOperationConflicts(updated); err != nil {
+		return err
+	}
+	track.ID = m.nextID("operation_tracks")
+	track.CreatedAt = now
+	m.tracks = append(m.tracks, *track)
+	m.operations[track.OperationID] = *updated
+
+	m.recordOperationEvent(&OperationEvent{
+		OperationID: track.OperationID,
+		Event:       EventTrackAdded,
+		OldStatus:   old.Status,
+		NewStatus:   old.Status,
+		Changes:     operationChanges(&old, updated),
+		Actor:       actor,
+	})
+	return nil
+}
+
+func (m *MemoryStore) GetOperationTracks(operationID int) ([]Track, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tracks := []Track{}
+	for _, t := range m.tracks {
+		if t.OperationID == operationID {
+			tracks = append(tracks, t)
+		}
+	}
+	sort.SliceStable(tracks, func(i, j int) bool { return tracks[i].StartedAt.Before(tracks[j].StartedAt) })
+	return tracks, nil
+}
+
+func (m *MemoryStore) GetLastCompletedOperations() (map[int]Operation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	operations := make(map[int]Operation)
+	for _, o := range m.operations {
+		if o.Status != StatusCompleted {
+			continue
+		}
+		last, ok := operations[o.FieldID]
+		if ok && !completedAfter(o, last) {
+			continue
+		}
+		operations[o.FieldID] = m.loadOperation(o)
+	}
+	return operations, nil
+}
+
+// completedAfter orders completed operations as Postgres does: by completion
+// time with missing times last, then by ID.
+func completedAfter(a, b Operation) bool {
+	switch {
+	case a.CompletedAt == nil || b.CompletedAt == nil:
+		if (a.CompletedAt == nil) != (b.CompletedAt == nil) {
+			return b.CompletedAt == nil
+		}
+	case !a.CompletedAt.Equal(*b.CompletedAt):
+		return a.CompletedAt.After(*b.CompletedAt)
+	}
+	return a.ID > b.ID
+}
+
+func (m *MemoryStore) GetUnassignedOperations() ([]Operation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var operations []Operation
+	for _, o := range m.operations {
+		if o.Status == StatusRejected {
+			operations = append(operations, m.loadOperation(o))
+		}
+	}
+	sort.Slice(operations, func(i, j int) bool {
+		a, b := operations[i].StatusChangedAt, operations[j].StatusChangedAt
+		if a != nil && b != nil && !a.Equal(*b) {
+			return a.Before(*b)
+		}
+		if (a == nil) != (b == nil) {
+			return b == nil
+		}
+		return operations[i].ID < operations[j].ID
+	})
+	return operations, nil
+}
+
+func (m *MemoryStore) DeleteOperation(id int, actor string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	old, ok := m.operations[id]
+	if !ok {
+		return nil
+	}
+	m.deleteOperations(func(o Operation) bool { return o.ID == id })
+	m.recordOperationEvent(&OperationEvent{OperationID: id, Event: EventDeleted, OldStatus: old.Status, Actor: actor})
+	return nil
+}
+
+// updateWorkIntervals mirrors the function of the same name for Postgres.
+func (m *MemoryStore) updateWorkIntervals(operationID int, from, to string, now time.Time) {
+	if from == StatusInProgress && to != StatusInProgress {
+		for i := range m.intervals {
+			if m.intervals[i].OperationID == operationID && m.intervals[i].EndedAt == nil {
+				ended := now
+				m.intervals[i].EndedAt = &ended
+			}
+		}
+	}
+	if to == StatusInProgress && from != StatusInProgress {
+		m.intervals = append(m.intervals, WorkInterval{
+			ID:          m.nextID("operation_intervals"),
+			OperationID: operationID,
+			StartedAt:   now,
+		})
+	}
+}
+
+func (m *MemoryStore) workIntervals(operationID int) []WorkInterval {
+	var intervals []WorkInterval
+	for _, i := range m.intervals {
+		if i.OperationID == operationID {
+			intervals = append(intervals, i)
+		}
+	}
+	return intervals
+}
+
+func (m *MemoryStore) recordOperationEvent(event *OperationEvent) {
+	event.ID = m.nextID("operation_events")
+	event.CreatedAt = time.Now()
+	m.events = append(m.events, *event)
+}
+
+func (m *MemoryStore) GetOperationEvents(operationID int) ([]OperationEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var events []OperationEvent
+	for _, e := range m.events {
+		if e.OperationID == operationID {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
@@ -0,0 +1,160 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+func (m *MemoryStore) GetWorkerAccount(login string) (*WorkerAccount, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var found []WorkerAccount
+	for _, w := range m.workers {
+		if (w.Email != "" && strings.EqualFold(w.Email, login)) || (w.Phone != "" && w.Phone == login) {
+			found = append(found, WorkerAccount{Worker: w, PasswordHash: m.passwordHashes[w.ID]})
+		}
+	}
+	if len(found) != 1 {
+		return nil, sql.ErrNoRows
+	}
+	return &found[0], nil
+}
+
+func (m *MemoryStore) GetWorkerAccountByID(id int) (*WorkerAccount, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w, ok := m.workers[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &WorkerAccount{Worker: w, PasswordHash: m.passwordHashes[id]}, nil
+}
+
+func (m *MemoryStore) SetWorkerPassword(workerID int, passwordHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w, ok := m.workers[workerID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	w.UpdatedAt = time.Now()
+	m.workers[workerID] = w
+	m.passwordHashes[workerID] = passwordHash
+	return nil
+}
+
+func (m *MemoryStore) CreateLoginCode(code *LoginCode, resendAfter time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, c := range m.loginCodes {
+		if c.WorkerID == code.WorkerID && c.CreatedAt.After(code.CreatedAt.Add(-resendAfter)) {
+			return ErrLoginCodeTooSoon
+		}
+	}
+	for i := range m.loginCodes {
+		if c := &m.loginCodes[i]; c.WorkerID == code.WorkerID && c.UsedAt == nil {
+			usedAt := code.CreatedAt
+			c.UsedAt = &usedAt
+		}
+	}
+	code.ID = m.nextID("login_codes")
+	m.loginCodes = append(m.loginCodes, *code)
+	return nil
+}
+
+func (m *MemoryStore) UseLoginCode(workerID int, codeHash string, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var current *LoginCode
+	for i := range m.loginCodes {
+		c := &m.loginCodes[i]
+		if c.WorkerID == workerID && c.UsedAt == nil && c.ExpiresAt.After(now) &&
+			(current == nil || !c.CreatedAt.Before(current.CreatedAt)) {
+			current = c
+		}
+	}
+	if current == nil {
+		return ErrLoginCodeInvalid
+	}
+	if current.Attempts >= MaxLoginCodeAttempts || current.CodeHash != codeHash {
+		current.Attempts++
+		return ErrLoginCodeInvalid
+	}
+	usedAt := now
+	current.UsedAt = &usedAt
+	return nil
+}
+
+func (m *MemoryStore) CreateRefreshToken(token *RefreshToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	token.CreatedAt = time.Now()
+	m.refreshTokens[token.ID] = *token
+	return nil
+}
+
+func (m *MemoryStore) RotateRefreshToken(id string, next *RefreshToken, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, ok := m.refreshTokens[id]
+	if !ok {
+		return ErrRefreshTokenInvalid
+	}
+	if current.RevokedAt != nil {
+		m.revokeRefreshTokens(current.WorkerID, now)
+		return ErrRefreshTokenInvalid
+	}
+	if !current.ExpiresAt.After(now) || current.WorkerID != next.WorkerID {
+		return ErrRefreshTokenInvalid
+	}
+
+	current.RevokedAt = &now
+	m.refreshTokens[id] = current
+	next.CreatedAt = time.Now()
+	m.refreshTokens[next.ID] = *next
+	return nil
+}
+
+func (m *MemoryStore) RevokeRefreshTokens(workerID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.revokeRefreshTokens(workerID, time.Now())
+	return nil
+}
+
+// revokeRefreshTokens revokes the worker's tokens. The caller holds the lock.
+func (m *MemoryStore) revokeRefreshTokens(workerID int, now time.Time) {
+	for id, t := range m.refreshTokens {
+		if t.WorkerID == workerID && t.RevokedAt == nil {
+			t.RevokedAt = &now
+			m.refreshTokens[id] = t
+		}
+	}
+}
+
+// deleteAccount drops the credentials of a deleted worker, like the cascading
+// foreign keys of login_codes and refresh_tokens. The caller holds the lock.
+func (m *MemoryStore) deleteAccount(workerID int) {
+	delete(m.passwordHashes, workerID)
+	codes := m.loginCodes[:0]
+	for _, c := range m.loginCodes {
+		if c.WorkerID != workerID {
+			codes = append(codes, c)
+		}
+	}
+	m.loginCodes = codes
+	for id, t := range m.refreshTokens {
+		if t.WorkerID == workerID {
+			delete(m.refreshTokens, id)
+		}
+	}
+}
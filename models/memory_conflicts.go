@@ -0,0 +1,47 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+// checkOperationConflicts mirrors the Postgres check for an operation about
+// to be stored.
+func (m *MemoryStore) checkOperationConflicts(o *Operation) error {
+	if o.WorkerID == 0 {
+		return nil
+	}
+	now := time.Now()
+	intervals := m.workIntervals(o.ID)
+	var conflicts []Operation
+	for _, other := range m.operations {
+		if other.ID == o.ID || other.WorkerID != o.WorkerID {
+			continue
+		}
+		if operationsOverlap(&other, m.workIntervals(other.ID), o, intervals, now) {
+			conflicts = append(conflicts, m.loadOperation(other))
+		}
+	}
+	if len(conflicts) == 0 {
+		return nil
+	}
+	sort.Slice(conflicts, func(i, j int) bool {
+		if a, b := *conflicts[i].StartTime, *conflicts[j].StartTime; !a.Equal(b) {
+			return a.Before(b)
+		}
+		return conflicts[i].ID < conflicts[j].ID
+	})
+	return &ConflictError{Operations: conflicts}
+}
+
+// checkScheduleConflicts enforces the unique (worker_id, date) constraint of
+// the schedules table.
+func (m *MemoryStore) checkScheduleConflicts(schedule *Schedule) error {
+	day := schedule.Date.Format("2006-01-02")
+	for _, s := range m.schedules {
+		if s.ID != schedule.ID && s.WorkerID == schedule.WorkerID && s.Date.Format("2006-01-02") == day {
+			return &ConflictError{Schedules: []Schedule{m.withScheduleWorker(s)}}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,262 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Operation detection methods
+func (m *MemoryStore) CreateMachineTrack(track *MachineTrack) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.member(track.WorkerID); !ok {
+		return fmt.Errorf("worker %d does not exist", track.WorkerID)
+	}
+	track.ID = m.nextID("machine_tracks")
+	track.Status = MachineTrackPending
+	track.CreatedAt = time.Now()
+	track.AnalyzedAt = nil
+	m.machineTracks[track.ID] = *track
+	return nil
+}
+
+func (m *MemoryStore) GetMachineTracks() ([]MachineTrack, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tracks := []MachineTrack{}
+	for _, t := range m.machineTracks {
+		t.Points = nil
+		tracks = append(tracks, t)
+	}
+	sort.Slice(tracks, func(i, j int) bool {
+		if !tracks[i].StartedAt.Equal(tracks[j].StartedAt) {
+			return tracks[i].StartedAt.After(tracks[j].StartedAt)
+		}
+		return tracks[i].ID > tracks[j].ID
+	})
+	return tracks, nil
+}
+
+func (m *MemoryStore) GetMachineTrackByID(id int) (*MachineTrack, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.machineTracks[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &t, nil
+}
+
+func (m *MemoryStore) GetPendingMachineTracks(limit int) ([]MachineTrack, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tracks := []MachineTrack{}
+	for _, t := range m.machineTracks {
+		if t.Status == MachineTrackPending {
+			tracks = append(tracks, t)
+		}
+	}
+	sort.Slice(tracks, func(i, j int) bool { return tracks[i].ID < tracks[j].ID })
+	if len(tracks) > limit {
+		tracks = tracks[:limit]
+	}
+	return tracks, nil
+}
+
+func (m *MemoryStore) SaveTrackAnalysis(trackID int, proposals []OperationProposal) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	track, ok := m.machineTracks[trackID]
+	if !ok || track.Status != MachineTrackPending {
+		return sql.ErrNoRows
+	}
+	for i := range proposals {
+		if _, ok := m.fields[proposals[i].FieldID]; !ok {
+			return fmt.Errorf("field %d does not exist", proposals[i].FieldID)
+		}
+	}
+
+	now := time.Now()
+	track.Status = MachineTrackAnalyzed
+	track.AnalyzedAt = &now
+	m.machineTracks[trackID] = track
+
+	for i := range proposals {
+		p := &proposals[i]
+		p.ID = m.nextID("operation_proposals")
+		p.TrackID, p.Status = trackID, ProposalPending
+		p.ReviewedBy, p.ReviewedAt = "", nil
+		p.CreatedAt = now
+		m.proposals[p.ID] = *p
+	}
+	return nil
+}
+
+func (m *MemoryStore) GetOperationProposals(status string) ([]OperationProposal, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	proposals := []OperationProposal{}
+	for _, p := range m.proposals {
+		if status == "" || p.Status == status {
+			p.Points = nil
+			proposals = append(proposals, p)
+		}
+	}
+	sort.Slice(proposals, func(i, j int) bool {
+		if !proposals[i].StartTime.Equal(proposals[j].StartTime) {
+			return proposals[i].StartTime.Before(proposals[j].StartTime)
+		}
+		return proposals[i].ID < proposals[j].ID
+	})
+	return proposals, nil
+}
+
+func (m *MemoryStore) GetOperationProposalByID(id int) (*OperationProposal, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.proposals[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &p, nil
+}
+
+func (m *MemoryStore) ConfirmOperationProposal(id int, operationType, actor string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.proposals[id]
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+	if p.Status != ProposalPending {
+		return 0, ErrProposalReviewed
+	}
+
+	var old *Operation
+	if p.OperationID != nil {
+		if o, ok := m.operations[*p.OperationID]; ok && openForProposal(&o) {
+			old = &o
+		}
+	}
+
+	now := time.Now()
+	var operationID int
+	if old == nil {
+		if operationType != "" {
+			p.Type = operationType
+		}
+		if p.Type == "" {
+			return 0, ErrProposalType
+		}
+		operation := proposalOperation(&p)
+		operation.ID = m.nextID("operations")
+		operation.CreatedAt, operation.UpdatedAt = now, now
+		operation.StatusChangedAt = &now
+		operation.StatusChangedBy = actor
+		if err := m.checkOperationConflicts(operation); err != nil {
+			return 0, err
+		}
+		m.operations[operation.ID] = storedOperation(operation)
+
+		m.recordOperationEvent(&OperationEvent{
+			OperationID: operation.ID,
+			Event:       EventCreated,
+			NewStatus:   operation.Status,
+			Changes:     operationChanges(&Operation{}, operation),
+			Actor:       actor,
+		})
+		operationID = operation.ID
+	} else {
+		if operationType != "" {
+			p.Type = operationType
+		} else {
+			p.Type = old.Type
+		}
+		updated := completedByProposal(old, &p)
+		updated.Type = p.Type
+		updated.StatusChangedAt = &now
+		updated.StatusChangedBy = actor
+		updated.UpdatedAt = now
+		if err := m.checkOperationConflicts(updated); err != nil {
+			return 0, err
+		}
+		m.operations[old.ID] = *updated
+
+		m.updateWorkIntervals(old.ID, old.Status, updated.Status, now)
+		m.recordOperationEvent(&OperationEvent{
+			OperationID: old.ID,
+			Event:       EventCompleted,
+			OldStatus:   old.Status,
+			NewStatus:   updated.Status,
+			Changes:     operationChanges(old, updated),
+			Actor:       actor,
+		})
+		operationID = old.ID
+	}
+
+	m.tracks = append(m.tracks, Track{
+		ID:             m.nextID("operation_tracks"),
+		OperationID:    operationID,
+		Points:         p.Points,
+		StartedAt:      p.StartTime,
+		EndedAt:        p.EndTime,
+		DistanceMeters: p.DistanceMeters,
+		WidthMeters:    p.WidthMeters,
+		CoveredArea:    p.CoveredArea,
+		CreatedAt:      now,
+	})
+
+	p.Status = ProposalConfirmed
+	p.OperationID = &operationID
+	p.ReviewedBy, p.ReviewedAt = actor, &now
+	m.proposals[id] = p
+	return operationID, nil
+}
+
+func (m *MemoryStore) DismissOperationProposal(id int, actor string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.proposals[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if p.Status != ProposalPending {
+		return ErrProposalReviewed
+	}
+	now := time.Now()
+	p.Status = ProposalDismissed
+	p.ReviewedBy, p.ReviewedAt = actor, &now
+	m.proposals[id] = p
+	return nil
+}
+
+// deleteMachineTracks removes the matching machine tracks with their proposals.
+func (m *MemoryStore) deleteMachineTracks(match func(MachineTrack) bool) {
+	deleted := make(map[int]bool)
+	for id, t := range m.machineTracks {
+		if match(t) {
+			delete(m.machineTracks, id)
+			deleted[id] = true
+		}
+	}
+	m.deleteProposals(func(p OperationProposal) bool { return deleted[p.TrackID] })
+}
+
+func (m *MemoryStore) deleteProposals(match func(OperationProposal) bool) {
+	for id, p := range m.proposals {
+		if match(p) {
+			delete(m.proposals, id)
+		}
+	}
+}
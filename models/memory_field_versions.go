@@ -0,0 +1,188 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// fieldLineage is a row of the field_lineage table.
+type fieldLineage struct {
+	parentID  int
+	childID   int
+	event     string
+	createdAt time.Time
+}
+
+// insertField mirrors the function of the same name for Postgres. The caller
+// holds the lock.
+func (m *MemoryStore) insertField(field *Field, now time.Time) {
+	field.ID = m.nextID("fields")
+	field.CreatedAt, field.UpdatedAt = now, now
+	field.RetiredAt = nil
+	m.fields[field.ID] = *field
+	m.fieldVersions = append(m.fieldVersions, FieldVersion{
+		ID:          m.nextID("field_boundaries"),
+		FieldID:     field.ID,
+		Coordinates: field.Coordinates,
+		Area:        field.Area,
+		CreatedAt:   now,
+	})
+}
+
+// closeFieldVersion ends the current version of a field's boundary.
+func (m *MemoryStore) closeFieldVersion(fieldID int, now time.Time) {
+	for i := range m.fieldVersions {
+		if v := &m.fieldVersions[i]; v.FieldID == fieldID && v.ValidTo == nil {
+			validTo := now
+			v.ValidTo = &validTo
+		}
+	}
+}
+
+// versionsOf lists the versions of a field, oldest first; they are appended
+// in that order. The caller holds the lock.
+func (m *MemoryStore) versionsOf(fieldID int) []FieldVersion {
+	versions := []FieldVersion{}
+	for _, v := range m.fieldVersions {
+		if v.FieldID == fieldID {
+			versions = append(versions, v)
+		}
+	}
+	return versions
+}
+
+// operationFieldVersion mirrors fieldVersionJoin.
+func (m *MemoryStore) operationFieldVersion(o Operation) FieldVersion {
+	at := o.CreatedAt
+	if o.StartTime != nil {
+		at = *o.StartTime
+	}
+	if v := FieldVersionAt(m.versionsOf(o.FieldID), at); v != nil {
+		return *v
+	}
+	return FieldVersion{FieldID: o.FieldID, Coordinates: m.fields[o.FieldID].Coordinates, Area: m.fields[o.FieldID].Area}
+}
+
+// sameJSON tells whether two JSON documents are equal regardless of
+// formatting, like a jsonb comparison.
+func sameJSON(a, b json.RawMessage) bool {
+	var x, y interface{}
+	if json.Unmarshal(a, &x) != nil || json.Unmarshal(b, &y) != nil {
+		return string(a) == string(b)
+	}
+	return reflect.DeepEqual(x, y)
+}
+
+// checkCurrentFields returns the error lockCurrentField and
+// checkNoOpenOperations would for the fields.
+func (m *MemoryStore) checkCurrentFields(ids []int) error {
+	for _, id := range ids {
+		f, ok := m.fields[id]
+		if !ok {
+			return sql.ErrNoRows
+		}
+		if f.RetiredAt != nil {
+			return ErrFieldRetired
+		}
+	}
+
+	var open []int
+	for _, o := range m.operations {
+		if openForProposal(&o) && containsInt(ids, o.FieldID) {
+			open = append(open, o.ID)
+		}
+	}
+	if len(open) > 0 {
+		sort.Ints(open)
+		return &FieldInUseError{OperationIDs: open}
+	}
+	return nil
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// retireFields mirrors the function of the same name for Postgres.
+func (m *MemoryStore) retireFields(parents []int, children []*Field, event string, now time.Time) {
+	for _, id := range parents {
+		f := m.fields[id]
+		f.RetiredAt, f.UpdatedAt = &now, now
+		m.fields[id] = f
+		m.closeFieldVersion(id, now)
+		for _, child := range children {
+			m.lineage = append(m.lineage, fieldLineage{parentID: id, childID: child.ID, event: event, createdAt: now})
+		}
+	}
+}
+
+func (m *MemoryStore) SplitField(id int, parts []*Field) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkCurrentFields([]int{id}); err != nil {
+		return err
+	}
+	for _, part := range parts {
+		if err := m.checkFieldRegion(part); err != nil {
+			return err
+		}
+	}
+	now := time.Now()
+	for _, part := range parts {
+		m.insertField(part, now)
+	}
+	m.retireFields([]int{id}, parts, LineageSplit, now)
+	return nil
+}
+
+func (m *MemoryStore) MergeFields(ids []int, merged *Field) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkCurrentFields(ids); err != nil {
+		return err
+	}
+	if err := m.checkFieldRegion(merged); err != nil {
+		return err
+	}
+	sorted := append([]int(nil), ids...)
+	sort.Ints(sorted)
+	now := time.Now()
+	m.insertField(merged, now)
+	m.retireFields(sorted, []*Field{merged}, LineageMerge, now)
+	return nil
+}
+
+func (m *MemoryStore) GetFieldVersions(id int) ([]FieldVersion, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.versionsOf(id), nil
+}
+
+func (m *MemoryStore) GetFieldLineage(id int) (*FieldLineage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	lineage := &FieldLineage{FieldID: id, Predecessors: []FieldLink{}, Successors: []FieldLink{}}
+	for _, l := range m.lineage {
+		if l.childID == id {
+			lineage.Predecessors = append(lineage.Predecessors,
+				FieldLink{FieldID: l.parentID, Name: m.fields[l.parentID].Name, Event: l.event, CreatedAt: l.createdAt})
+		}
+		if l.parentID == id {
+			lineage.Successors = append(lineage.Successors,
+				FieldLink{FieldID: l.childID, Name: m.fields[l.childID].Name, Event: l.event, CreatedAt: l.createdAt})
+		}
+	}
+	return lineage, nil
+}
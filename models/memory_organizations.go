@@ -0,0 +1,211 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+)
+
+func (m *MemoryStore) ForOrganization(id int) Store {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.store(id)
+}
+
+func (m *MemoryStore) CreateOrganization(org *Organization) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkOrganization(org); err != nil {
+		return err
+	}
+	now := time.Now()
+	org.ID = m.nextID("organizations")
+	org.CreatedAt, org.UpdatedAt = now, now
+	m.organizations[org.ID] = *org
+	m.members[org.ID] = make(map[int]string)
+	return nil
+}
+
+// checkOrganization enforces the unique name constraint of the organizations
+// table.
+func (m *MemoryStore) checkOrganization(org *Organization) error {
+	for _, o := range m.organizations {
+		if o.ID != org.ID && o.Name == org.Name {
+			return fmt.Errorf("name %q is already used by organization %d", org.Name, o.ID)
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) Setup(org *Organization, owner *Worker, passwordHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.organizations) > 0 {
+		return ErrAlreadySetUp
+	}
+	if err := m.checkWorker(owner); err != nil {
+		return err
+	}
+	now := time.Now()
+	org.ID = m.nextID("organizations")
+	org.CreatedAt, org.UpdatedAt = now, now
+	owner.ID = m.nextID("workers")
+	owner.CreatedAt, owner.UpdatedAt = now, now
+	m.organizations[org.ID] = *org
+	m.members[org.ID] = map[int]string{owner.ID: owner.AccessRole}
+	m.workers[owner.ID] = globalWorker(*owner)
+	m.passwordHashes[owner.ID] = passwordHash
+	return nil
+}
+
+func (m *MemoryStore) GetOrganizations() ([]Organization, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	organizations := []Organization{}
+	for _, o := range m.organizations {
+		organizations = append(organizations, o)
+	}
+	sort.Slice(organizations, func(i, j int) bool { return organizations[i].Name < organizations[j].Name })
+	return organizations, nil
+}
+
+func (m *MemoryStore) GetOrganizationByID(id int) (*Organization, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	o, ok := m.organizations[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &o, nil
+}
+
+func (m *MemoryStore) UpdateOrganization(org *Organization) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	old, ok := m.organizations[org.ID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if err := m.checkOrganization(org); err != nil {
+		return err
+	}
+	org.CreatedAt = old.CreatedAt
+	org.UpdatedAt = time.Now()
+	m.organizations[org.ID] = *org
+	return nil
+}
+
+func (m *MemoryStore) GetWorkerOrganizations(workerID int) ([]Membership, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	memberships := []Membership{}
+	for orgID, members := range m.members {
+		if accessRole, ok := members[workerID]; ok {
+			memberships = append(memberships, Membership{Organization: m.organizations[orgID], AccessRole: accessRole})
+		}
+	}
+	sort.Slice(memberships, func(i, j int) bool { return memberships[i].ID < memberships[j].ID })
+	return memberships, nil
+}
+
+func (m *MemoryStore) AddMember(workerID int, accessRole string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.workers[workerID]; !ok {
+		return sql.ErrNoRows
+	}
+	if _, ok := m.members[m.org][workerID]; ok {
+		return ErrMemberExists
+	}
+	if err := checkAccessRole(accessRole); err != nil {
+		return err
+	}
+	if _, ok := m.organizations[m.org]; !ok {
+		return fmt.Errorf("organization %d does not exist", m.org)
+	}
+	m.members[m.org][workerID] = accessRole
+	return nil
+}
+
+func (m *MemoryStore) InviteMember(workerID int, accessRole, invitedBy string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.members[m.org][workerID]; ok {
+		return ErrMemberExists
+	}
+	if _, ok := m.workers[workerID]; !ok {
+		return sql.ErrNoRows
+	}
+	if err := checkAccessRole(accessRole); err != nil {
+		return err
+	}
+	org, ok := m.organizations[m.org]
+	if !ok {
+		return fmt.Errorf("organization %d does not exist", m.org)
+	}
+	if m.invitations[m.org] == nil {
+		m.invitations[m.org] = make(map[int]Invitation)
+	}
+	m.invitations[m.org][workerID] = Invitation{
+		Membership: Membership{Organization: org, AccessRole: accessRole},
+		InvitedBy:  invitedBy,
+		InvitedAt:  time.Now(),
+	}
+	return nil
+}
+
+func (m *MemoryStore) GetWorkerInvitations(workerID int) ([]Invitation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	invitations := []Invitation{}
+	for orgID, invited := range m.invitations {
+		if i, ok := invited[workerID]; ok {
+			i.Organization = m.organizations[orgID]
+			invitations = append(invitations, i)
+		}
+	}
+	sort.Slice(invitations, func(i, j int) bool {
+		if a, b := invitations[i].InvitedAt, invitations[j].InvitedAt; !a.Equal(b) {
+			return a.After(b)
+		}
+		return invitations[i].ID < invitations[j].ID
+	})
+	return invitations, nil
+}
+
+func (m *MemoryStore) AcceptInvitation(workerID, orgID int) (*Membership, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i, ok := m.invitations[orgID][workerID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	delete(m.invitations[orgID], workerID)
+	if _, ok := m.members[orgID][workerID]; !ok {
+		m.members[orgID][workerID] = i.AccessRole
+	}
+	return &Membership{Organization: m.organizations[orgID], AccessRole: m.members[orgID][workerID]}, nil
+}
+
+func (m *MemoryStore) DeclineInvitation(workerID, orgID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.invitations[orgID][workerID]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(m.invitations[orgID], workerID)
+	return nil
+}
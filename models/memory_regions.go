@@ -0,0 +1,132 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// withRegion fills in the name of the field's region, which may have been
+// renamed since the field was stored. The caller holds the lock.
+func (m *MemoryStore) withRegion(f Field) Field {
+	f.Region = ""
+	if f.RegionID != nil {
+		f.Region = m.regions[*f.RegionID].Name
+	}
+	return f
+}
+
+// checkFieldRegion enforces the foreign key of fields.region_id.
+func (m *MemoryStore) checkFieldRegion(field *Field) error {
+	if field.RegionID == nil {
+		return nil
+	}
+	if _, ok := m.regions[*field.RegionID]; !ok {
+		return fmt.Errorf("region %d does not exist", *field.RegionID)
+	}
+	return nil
+}
+
+// checkRegion enforces the constraints of the regions table.
+func (m *MemoryStore) checkRegion(region *Region) error {
+	if region.ParentID != nil {
+		if _, ok := m.regions[*region.ParentID]; !ok {
+			return fmt.Errorf("region %d does not exist", *region.ParentID)
+		}
+	}
+	for _, r := range m.regions {
+		if r.ID != region.ID && r.Name == region.Name && sameParent(r.ParentID, region.ParentID) {
+			return fmt.Errorf("region %q already exists", region.Name)
+		}
+	}
+	return nil
+}
+
+func sameParent(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// Region methods
+func (m *MemoryStore) CreateRegion(region *Region) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkRegion(region); err != nil {
+		return err
+	}
+	now := time.Now()
+	region.ID = m.nextID("regions")
+	region.CreatedAt, region.UpdatedAt = now, now
+	m.regions[region.ID] = *region
+	return nil
+}
+
+func (m *MemoryStore) GetRegions() ([]Region, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	regions := []Region{}
+	for _, r := range m.regions {
+		regions = append(regions, r)
+	}
+	sort.Slice(regions, func(i, j int) bool {
+		if regions[i].Name != regions[j].Name {
+			return regions[i].Name < regions[j].Name
+		}
+		return regions[i].ID < regions[j].ID
+	})
+	return regions, nil
+}
+
+func (m *MemoryStore) GetRegionByID(id int) (*Region, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r, ok := m.regions[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &r, nil
+}
+
+func (m *MemoryStore) UpdateRegion(region *Region) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	old, ok := m.regions[region.ID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if err := m.checkRegion(region); err != nil {
+		return err
+	}
+	region.CreatedAt = old.CreatedAt
+	region.UpdatedAt = time.Now()
+	m.regions[region.ID] = *region
+	return nil
+}
+
+func (m *MemoryStore) DeleteRegion(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.regions[id]; !ok {
+		return sql.ErrNoRows
+	}
+	for _, f := range m.fields {
+		if f.RegionID != nil && *f.RegionID == id {
+			return ErrRegionInUse
+		}
+	}
+	for _, r := range m.regions {
+		if r.ParentID != nil && *r.ParentID == id {
+			return ErrRegionInUse
+		}
+	}
+	delete(m.regions, id)
+	return nil
+}
@@ -0,0 +1,343 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Report methods
+func (m *MemoryStore) GetDailyReport(date time.Time, filter ReportFilter) (*DailyReport, error) {
+	return dailyReport(m, date, filter)
+}
+
+func (m *MemoryStore) GetMonthlyReport(year int, month time.Month, filter ReportFilter) (*MonthlyReport, error) {
+	return monthlyReport(m, year, month, filter)
+}
+
+func (m *MemoryStore) GetYearlyReport(year int, filter ReportFilter) (*YearlyReport, error) {
+	return yearlyReport(m, year, filter)
+}
+
+func (m *MemoryStore) GetRangeReport(from, to time.Time, groupBy []string, filter ReportFilter) (*RangeReport, error) {
+	return rangeReport(m, from, to, groupBy, filter)
+}
+
+// reportOperations lists the operations started in [from, to) that pass the
+// filter in chronological order. The caller holds the lock.
+func (m *MemoryStore) reportOperations(from, to time.Time, filter ReportFilter) []Operation {
+	var operations []Operation
+	for _, o := range m.operations {
+		if o.StartTime != nil && !o.StartTime.Before(from) && o.StartTime.Before(to) && filter.Includes(m.fields[o.FieldID].RegionID) {
+			operations = append(operations, m.loadOperation(o))
+		}
+	}
+	sort.Slice(operations, func(i, j int) bool {
+		a, b := operations[i].StartTime, operations[j].StartTime
+		if !a.Equal(*b) {
+			return a.Before(*b)
+		}
+		return operations[i].ID < operations[j].ID
+	})
+	return operations
+}
+
+func (m *MemoryStore) getPeriodReport(from, to time.Time, filter ReportFilter) (*PeriodReport, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	report := &PeriodReport{
+		From:             from,
+		To:               to,
+		OperationsByType: make(map[string]int),
+	}
+
+	workers := make(map[int]bool)
+	workerStats := make(map[int]*WorkerDailyStats)
+	workerFields := make(map[int]map[int]bool)
+	fieldStats := make(map[int]*FieldDailyStats)
+	fieldWorkers := make(map[int]map[int]bool)
+	regionStats := make(map[int]*RegionStats)
+	regionFields := make(map[int]map[int]bool)
+	regionWorkers := make(map[int]map[int]bool)
+	for _, o := range m.reportOperations(from, to, filter) {
+		report.TotalOperations++
+		report.HoursWorked += o.HoursWorked
+		report.OperationsByType[o.Type]++
+		switch o.Status {
+		case StatusCompleted:
+			report.CompletedOps++
+		case StatusInProgress:
+			report.InProgressOps++
+		}
+		if o.WorkerID != 0 {
+			workers[o.WorkerID] = true
+		}
+
+		if o.Worker != nil {
+			ws, ok := workerStats[o.WorkerID]
+			if !ok {
+				ws = &WorkerDailyStats{WorkerID: o.WorkerID, WorkerName: o.Worker.Name}
+				workerStats[o.WorkerID] = ws
+				workerFields[o.WorkerID] = make(map[int]bool)
+			}
+			ws.Operations++
+			ws.HoursWorked += o.HoursWorked
+			workerFields[o.WorkerID][o.FieldID] = true
+		}
+		if o.Field != nil {
+			fs, ok := fieldStats[o.FieldID]
+			if !ok {
+				fs = &FieldDailyStats{FieldID: o.FieldID, FieldName: o.Field.Name}
+				fieldStats[o.FieldID] = fs
+				fieldWorkers[o.FieldID] = make(map[int]bool)
+			}
+			fs.Operations++
+			fs.HoursWorked += o.HoursWorked
+			fs.DecaresWorked += coveredArea(&o, m.operationFieldVersion(o).Area)
+			if o.WorkerID != 0 {
+				fieldWorkers[o.FieldID][o.WorkerID] = true
+			}
+
+			field := m.withRegion(m.fields[o.FieldID])
+			regionID := 0
+			if field.RegionID != nil {
+				regionID = *field.RegionID
+			}
+			rs, ok := regionStats[regionID]
+			if !ok {
+				rs = &RegionStats{RegionID: regionID, RegionName: field.Region}
+				regionStats[regionID] = rs
+				regionFields[regionID] = make(map[int]bool)
+				regionWorkers[regionID] = make(map[int]bool)
+			}
+			rs.Operations++
+			rs.HoursWorked += o.HoursWorked
+			rs.DecaresWorked += coveredArea(&o, m.operationFieldVersion(o).Area)
+			regionFields[regionID][o.FieldID] = true
+			if o.WorkerID != 0 {
+				regionWorkers[regionID][o.WorkerID] = true
+			}
+		}
+	}
+	report.TotalWorkers = len(workers)
+
+	for id, ws := range workerStats {
+		ws.FieldsWorked = len(workerFields[id])
+		report.WorkerStats = append(report.WorkerStats, *ws)
+	}
+	sort.Slice(report.WorkerStats, func(i, j int) bool {
+		a, b := report.WorkerStats[i], report.WorkerStats[j]
+		if a.WorkerName != b.WorkerName {
+			return a.WorkerName < b.WorkerName
+		}
+		return a.WorkerID < b.WorkerID
+	})
+
+	for id, fs := range fieldStats {
+		fs.WorkersCount = len(fieldWorkers[id])
+		report.FieldStats = append(report.FieldStats, *fs)
+	}
+	sort.Slice(report.FieldStats, func(i, j int) bool {
+		a, b := report.FieldStats[i], report.FieldStats[j]
+		if a.FieldName != b.FieldName {
+			return a.FieldName < b.FieldName
+		}
+		return a.FieldID < b.FieldID
+	})
+
+	for id, rs := range regionStats {
+		rs.FieldsWorked = len(regionFields[id])
+		rs.WorkersCount = len(regionWorkers[id])
+		report.RegionStats = append(report.RegionStats, *rs)
+	}
+	sort.Slice(report.RegionStats, func(i, j int) bool {
+		a, b := report.RegionStats[i], report.RegionStats[j]
+		if a.RegionName != b.RegionName {
+			return a.RegionName < b.RegionName
+		}
+		return a.RegionID < b.RegionID
+	})
+
+	return report, nil
+}
+
+func (m *MemoryStore) GetPeriodBreakdown(unit string, from, to time.Time, filter ReportFilter) ([]PeriodStats, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	found := make(map[string]PeriodStats)
+	workers := make(map[string]map[int]bool)
+	for _, o := range m.reportOperations(from, to, filter) {
+		key := truncatePeriod(unit, *o.StartTime).Format("2006-01-02")
+		ps := found[key]
+		ps.TotalOperations++
+		ps.HoursWorked += o.HoursWorked
+		switch o.Status {
+		case StatusCompleted:
+			ps.CompletedOps++
+		case StatusInProgress:
+			ps.InProgressOps++
+		}
+		if workers[key] == nil {
+			workers[key] = make(map[int]bool)
+		}
+		if o.WorkerID != 0 {
+			workers[key][o.WorkerID] = true
+		}
+		ps.TotalWorkers = len(workers[key])
+		found[key] = ps
+	}
+	return fillBreakdown(unit, from, to, found), nil
+}
+
+// reportDimensionValue returns the row key of an operation for a group_by
+// dimension, and the value it is grouped by when that differs (e.g. two
+// workers with the same name).
+func reportDimensionValue(name string, o Operation, f *Field) (value, group string) {
+	switch name {
+	case "worker":
+		if o.Worker != nil {
+			value = o.Worker.Name
+		}
+		return value, fmt.Sprintf("%d", o.WorkerID)
+	case "field":
+		if f != nil {
+			value = f.Name
+		}
+		return value, fmt.Sprintf("%d", o.FieldID)
+	case "type":
+		return o.Type, o.Type
+	case "region":
+		group = "0"
+		if f != nil && f.RegionID != nil {
+			value, group = f.Region, fmt.Sprintf("%d", *f.RegionID)
+		}
+		return value, group
+	case "crop_type":
+		if f != nil {
+			value = f.CropType
+		}
+		return value, value
+	case "day":
+		value = o.StartTime.Format("2006-01-02")
+		return value, value
+	}
+	return "", ""
+}
+
+func (m *MemoryStore) getRangeReportRows(from, to time.Time, groupBy []string, filter ReportFilter) ([]RangeReportRow, error) {
+	for _, name := range groupBy {
+		if _, ok := reportDimensions[name]; !ok {
+			return nil, fmt.Errorf("unknown group_by dimension %q", name)
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	type group struct {
+		row     RangeReportRow
+		values  []string
+		keys    []string
+		workers map[int]bool
+		fields  map[int]bool
+	}
+	groups := make(map[string]*group)
+	// Without dimensions there is a single row, even for an empty range
+	if len(groupBy) == 0 {
+		groups[""] = &group{row: RangeReportRow{Keys: map[string]string{}}, workers: map[int]bool{}, fields: map[int]bool{}}
+	}
+
+	for _, o := range m.reportOperations(from, to, filter) {
+		var field *Field
+		if f, ok := m.fields[o.FieldID]; ok {
+			f = m.withRegion(f)
+			field = &f
+		}
+
+		values := make([]string, len(groupBy))
+		keys := make([]string, len(groupBy))
+		for i, name := range groupBy {
+			values[i], keys[i] = reportDimensionValue(name, o, field)
+		}
+		key := strings.Join(keys, "\x00")
+
+		g, ok := groups[key]
+		if !ok {
+			g = &group{
+				row:     RangeReportRow{Keys: make(map[string]string)},
+				values:  values,
+				keys:    keys,
+				workers: make(map[int]bool),
+				fields:  make(map[int]bool),
+			}
+			for i, name := range groupBy {
+				g.row.Keys[name] = values[i]
+			}
+			groups[key] = g
+		}
+		g.row.Operations++
+		g.row.HoursWorked += o.HoursWorked
+		switch o.Status {
+		case StatusCompleted:
+			g.row.CompletedOps++
+		case StatusInProgress:
+			g.row.InProgressOps++
+		}
+		if o.WorkerID != 0 {
+			g.workers[o.WorkerID] = true
+		}
+		g.fields[o.FieldID] = true
+	}
+
+	sorted := make([]*group, 0, len(groups))
+	for _, g := range groups {
+		g.row.WorkersCount = len(g.workers)
+		g.row.FieldsCount = len(g.fields)
+		sorted = append(sorted, g)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		for k := range groupBy {
+			if sorted[i].values[k] != sorted[j].values[k] {
+				return sorted[i].values[k] < sorted[j].values[k]
+			}
+		}
+		return strings.Join(sorted[i].keys, "\x00") < strings.Join(sorted[j].keys, "\x00")
+	})
+
+	var result []RangeReportRow
+	for _, g := range sorted {
+		result = append(result, g.row)
+	}
+	return result, nil
+}
+
+func (m *MemoryStore) GetReportEntries(from, to time.Time, filter ReportFilter) ([]ReportEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var entries []ReportEntry
+	for _, o := range m.reportOperations(from, to, filter) {
+		e := ReportEntry{
+			OperationID: o.ID,
+			WorkerID:    o.WorkerID,
+			FieldID:     o.FieldID,
+			Type:        o.Type,
+			Status:      o.Status,
+			StartTime:   o.StartTime,
+			EndTime:     o.EndTime,
+			HoursWorked: o.HoursWorked,
+		}
+		if o.Worker != nil {
+			e.WorkerName = o.Worker.Name
+		}
+		if o.Field != nil {
+			e.FieldName = o.Field.Name
+			e.Boundary = m.operationFieldVersion(o).Coordinates
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
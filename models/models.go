@@ -3,19 +3,20 @@ package models
 import (
 	"database/sql"
 	"encoding/json"
+	"fmt"
+	"sort"
 	"time"
 )
 
-var db *sql.DB
-
 type Worker struct {
-	ID        int       `json:"id"`
-	Name      string    `json:"name"`
-	Email     string    `json:"email"`
-	Phone     string    `json:"phone"`
-	Role      string    `json:"role"` // "tractor_driver", "harvester_driver", etc.
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID         int       `json:"id"`
+	Name       string    `json:"name"`
+	Email      string    `json:"email"`
+	Phone      string    `json:"phone"`
+	Role       string    `json:"role"`        // "tractor_driver", "harvester_driver", etc.
+	AccessRole string    `json:"access_role"` // "owner", "agronomist", "foreman" or "operator"; see auth.Can
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
 }
 
 type Field struct {
@@ -26,9 +27,11 @@ type Field struct {
 	Area        float64         `json:"area"`        // in decares
 	CropType    string          `json:"crop_type"`
 	Period      string          `json:"period"` // the period of operation
-	Region      string          `json:"region"` // region name
+	RegionID    *int            `json:"region_id"`
+	Region      string          `json:"region"` // region name; see RegionID
 	CreatedAt   time.Time       `json:"created_at"`
 	UpdatedAt   time.Time       `json:"updated_at"`
+	RetiredAt   *time.Time      `json:"retired_at,omitempty"` // set once the field is split or merged into others
 }
 
 type Schedule struct {
@@ -41,130 +44,84 @@ type Schedule struct {
 }
 
 type Operation struct {
-	ID          int        `json:"id"`
-	ScheduleID  *int       `json:"schedule_id"`
-	WorkerID    int        `json:"worker_id"`
-	FieldID     int        `json:"field_id"`
-	Type        string     `json:"type"` // "plowing", "seeding", "harvesting", etc.
-	Description string     `json:"description"`
-	Status      string     `json:"status"` // "planned", "in_progress", "completed", "cancelled"
-	StartTime   *time.Time `json:"start_time"`
-	EndTime     *time.Time `json:"end_time"`
-	CompletedAt *time.Time `json:"completed_at"`
-	Notes       string     `json:"notes"`
-	CreatedAt   time.Time  `json:"created_at"`
-	UpdatedAt   time.Time  `json:"updated_at"`
-	Schedule    *Schedule  `json:"schedule,omitempty"`
-	Worker      *Worker    `json:"worker,omitempty"`
-	Field       *Field     `json:"field,omitempty"`
-}
-
-type DailyReport struct {
-	Date             time.Time          `json:"date"`
-	TotalWorkers     int                `json:"total_workers"`
-	TotalOperations  int                `json:"total_operations"`
-	CompletedOps     int                `json:"completed_operations"`
-	InProgressOps    int                `json:"in_progress_operations"`
-	OperationsByType map[string]int     `json:"operations_by_type"`
-	WorkerStats      []WorkerDailyStats `json:"worker_stats"`
-	FieldStats       []FieldDailyStats  `json:"field_stats"`
-}
-
-type WorkerDailyStats struct {
-	WorkerID     int     `json:"worker_id"`
-	WorkerName   string  `json:"worker_name"`
-	Operations   int     `json:"operations"`
-	HoursWorked  float64 `json:"hours_worked"`
-	FieldsWorked int     `json:"fields_worked"`
-}
-
-type FieldDailyStats struct {
-	FieldID      int     `json:"field_id"`
-	FieldName    string  `json:"field_name"`
-	Operations   int     `json:"operations"`
-	HoursWorked  float64 `json:"hours_worked"`
-	WorkersCount int     `json:"workers_count"`
-}
-
-func InitDB(database *sql.DB) {
-	db = database
-}
-
-func RunMigrations() error {
-	migrations := []string{
-		`CREATE TABLE IF NOT EXISTS workers (
-			id SERIAL PRIMARY KEY,
-			name VARCHAR(255) NOT NULL,
-			email VARCHAR(255) UNIQUE,
-			phone VARCHAR(50),
-			role VARCHAR(100) NOT NULL,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		)`,
-		`CREATE TABLE IF NOT EXISTS fields (
-			id SERIAL PRIMARY KEY,
-			name VARCHAR(255) NOT NULL,
-			description TEXT,
-			coordinates JSONB NOT NULL,
-			area DECIMAL(10,2) DEFAULT 0,
-			crop_type VARCHAR(100) NOT NULL,
-			period VARCHAR(100) NOT NULL,
-			region VARCHAR(255) NOT NULL,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		)`,
-		`CREATE TABLE IF NOT EXISTS schedules (
-			id SERIAL PRIMARY KEY,
-			worker_id INTEGER REFERENCES workers(id) ON DELETE CASCADE,
-			date DATE NOT NULL,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		)`,
-		`CREATE TABLE IF NOT EXISTS operations (
-			id SERIAL PRIMARY KEY,
-			schedule_id INTEGER REFERENCES schedules(id) ON DELETE CASCADE,
-			worker_id INTEGER REFERENCES workers(id) ON DELETE CASCADE,
-			field_id INTEGER REFERENCES fields(id) ON DELETE CASCADE,
-			type VARCHAR(100) NOT NULL,
-			description TEXT,
-			status VARCHAR(50) DEFAULT 'planned',
-			start_time TIMESTAMP,
-			end_time TIMESTAMP,
-			completed_at TIMESTAMP,
-			notes TEXT,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		)`,
-		`CREATE INDEX IF NOT EXISTS idx_schedules_worker_date ON schedules(worker_id, date)`,
-		`CREATE INDEX IF NOT EXISTS idx_operations_schedule ON operations(schedule_id) WHERE schedule_id IS NOT NULL`,
-		`CREATE INDEX IF NOT EXISTS idx_operations_worker ON operations(worker_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_operations_field ON operations(field_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_operations_worker_date ON operations(worker_id, DATE(start_time))`,
-		`CREATE INDEX IF NOT EXISTS idx_operations_field_date ON operations(field_id, DATE(start_time))`,
-		`CREATE INDEX IF NOT EXISTS idx_operations_status ON operations(status)`,
-	}
-
-	for _, migration := range migrations {
-		if _, err := db.Exec(migration); err != nil {
-			return err
-		}
+	ID              int        `json:"id"`
+	ScheduleID      *int       `json:"schedule_id"`
+	WorkerID        int        `json:"worker_id"` // 0 while the operation waits in the unassigned pool
+	FieldID         int        `json:"field_id"`
+	Type            string     `json:"type"` // "plowing", "seeding", "harvesting", etc.
+	Description     string     `json:"description"`
+	Status          string     `json:"status"` // see the Status* constants
+	StartTime       *time.Time `json:"start_time"`
+	EndTime         *time.Time `json:"end_time"`
+	CompletedAt     *time.Time `json:"completed_at"`
+	Notes           string     `json:"notes"`
+	StatusChangedAt *time.Time `json:"status_changed_at"`
+	StatusChangedBy string     `json:"status_changed_by"`
+	RejectedBy      *int       `json:"rejected_by,omitempty"`
+	RejectionReason string     `json:"rejection_reason,omitempty"`
+	HoursWorked     float64    `json:"hours_worked"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	Schedule        *Schedule  `json:"schedule,omitempty"`
+	Worker          *Worker    `json:"worker,omitempty"`
+	Field           *Field     `json:"field,omitempty"`
+
+	// CoveredArea is how many decares of the field the operation has covered so
+	// far, optionally drawn as the Coverage polygon. CoveragePercent is the share
+	// of the field done, counting a completed operation without a recorded
+	// coverage as the whole field.
+	CoveredArea     *float64        `json:"covered_area"`
+	Coverage        json.RawMessage `json:"coverage,omitempty"`
+	CoveragePercent float64         `json:"coverage_percent"`
+
+	Intervals []WorkInterval `json:"intervals,omitempty"`
+}
+
+// PostgresStore keeps the data in PostgreSQL. The schema is managed by the migrations package.
+type PostgresStore struct {
+	db *sql.DB
+
+	// org is the organization every query is limited to; see ForOrganization
+	org int
+}
+
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// Worker methods see the members of the store's organization, with their
+// access role there. Name, email, phone and role are shared by all the
+// organizations a worker belongs to.
+func (s *PostgresStore) CreateWorker(worker *Worker) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback()
 
-	return nil
-}
-
-// Worker methods
-func CreateWorker(worker *Worker) error {
 	query := `INSERT INTO workers (name, email, phone, role)
 			  VALUES ($1, $2, $3, $4)
 			  RETURNING id, created_at, updated_at`
-	return db.QueryRow(query, worker.Name, worker.Email, worker.Phone, worker.Role).
+	err = tx.QueryRow(query, worker.Name, worker.Email, worker.Phone, worker.Role).
 		Scan(&worker.ID, &worker.CreatedAt, &worker.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO memberships (organization_id, worker_id, access_role) VALUES ($1, $2, $3)`,
+		s.org, worker.ID, worker.AccessRole)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func GetWorkers() ([]Worker, error) {
-	query := `SELECT id, name, email, phone, role, created_at, updated_at FROM workers ORDER BY name`
-	rows, err := db.Query(query)
+const workerColumns = `w.id, w.name, w.email, w.phone, w.role, m.access_role, w.created_at, w.updated_at`
+
+func (s *PostgresStore) GetWorkers() ([]Worker, error) {
+	query := `SELECT ` + workerColumns + `
+			  FROM workers w JOIN memberships m ON m.worker_id = w.id AND m.organization_id = $1
+			  ORDER BY w.name`
+	rows, err := s.db.Query(query, s.org)
 	if err != nil {
 		return nil, err
 	}
@@ -173,7 +130,7 @@ func GetWorkers() ([]Worker, error) {
 	var workers []Worker
 	for rows.Next() {
 		var w Worker
-		err := rows.Scan(&w.ID, &w.Name, &w.Email, &w.Phone, &w.Role, &w.CreatedAt, &w.UpdatedAt)
+		err := rows.Scan(&w.ID, &w.Name, &w.Email, &w.Phone, &w.Role, &w.AccessRole, &w.CreatedAt, &w.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -182,41 +139,125 @@ func GetWorkers() ([]Worker, error) {
 	return workers, nil
 }
 
-func GetWorkerByID(id int) (*Worker, error) {
+func (s *PostgresStore) GetWorkerByID(id int) (*Worker, error) {
 	var w Worker
-	query := `SELECT id, name, email, phone, role, created_at, updated_at FROM workers WHERE id = $1`
-	err := db.QueryRow(query, id).Scan(&w.ID, &w.Name, &w.Email, &w.Phone, &w.Role, &w.CreatedAt, &w.UpdatedAt)
+	query := `SELECT ` + workerColumns + `
+			  FROM workers w JOIN memberships m ON m.worker_id = w.id AND m.organization_id = $1
+			  WHERE w.id = $2`
+	err := s.db.QueryRow(query, s.org, id).Scan(&w.ID, &w.Name, &w.Email, &w.Phone, &w.Role, &w.AccessRole, &w.CreatedAt, &w.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
 	return &w, nil
 }
 
-func UpdateWorker(worker *Worker) error {
+// UpdateWorker returns ErrSharedWorker when it would change the email or phone
+// of a worker who also belongs to other organizations.
+func (s *PostgresStore) UpdateWorker(worker *Worker) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE memberships SET access_role = $1 WHERE organization_id = $2 AND worker_id = $3`,
+		worker.AccessRole, s.org, worker.ID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	// Email and phone are the login of the worker in every organization
+	var shared bool
+	err = tx.QueryRow(`SELECT (COALESCE(email, '') <> $3 OR COALESCE(phone, '') <> $4)
+							  AND EXISTS (SELECT 1 FROM memberships WHERE worker_id = w.id AND organization_id <> $2)
+					   FROM workers w WHERE id = $1 FOR UPDATE`, worker.ID, s.org, worker.Email, worker.Phone).Scan(&shared)
+	if err != nil {
+		return err
+	}
+	if shared {
+		return ErrSharedWorker
+	}
+
 	query := `UPDATE workers SET name = $1, email = $2, phone = $3, role = $4, updated_at = CURRENT_TIMESTAMP
 			  WHERE id = $5 RETURNING updated_at`
-	return db.QueryRow(query, worker.Name, worker.Email, worker.Phone, worker.Role, worker.ID).
+	err = tx.QueryRow(query, worker.Name, worker.Email, worker.Phone, worker.Role, worker.ID).
 		Scan(&worker.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func DeleteWorker(id int) error {
-	query := `DELETE FROM workers WHERE id = $1`
-	_, err := db.Exec(query, id)
-	return err
+// DeleteWorker removes a worker from the organization together with their
+// schedules, operations and tracks there. A worker left in no organization
+// is deleted altogether.
+func (s *PostgresStore) DeleteWorker(id int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM memberships WHERE organization_id = $1 AND worker_id = $2`, s.org, id); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`DELETE FROM workers WHERE id = $1 AND NOT EXISTS (SELECT 1 FROM memberships WHERE worker_id = $1)`, id)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 // Field methods
-func CreateField(field *Field) error {
-	query := `INSERT INTO fields (name, description, coordinates, area, crop_type, period, region)
-			  VALUES ($1, $2, $3, $4, $5, $6, $7)
+func (s *PostgresStore) CreateField(field *Field) error {
+	return s.CreateFields([]*Field{field})
+}
+
+// CreateFields inserts a batch of fields in one transaction, so either all of
+// them are created or none.
+func (s *PostgresStore) CreateFields(fields []*Field) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, field := range fields {
+		if err := insertField(tx, s.org, field, nil); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// insertField inserts a field with the first version of its boundary, valid
+// from validFrom or, when nil, for all time before any later version.
+func insertField(tx *sql.Tx, org int, field *Field, validFrom *time.Time) error {
+	query := `INSERT INTO fields (organization_id, name, description, coordinates, area, crop_type, period, region_id)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 			  RETURNING id, created_at, updated_at`
-	return db.QueryRow(query, field.Name, field.Description, field.Coordinates, field.Area, field.CropType, field.Period, field.Region).
+	err := tx.QueryRow(query, org, field.Name, field.Description, field.Coordinates, field.Area, field.CropType, field.Period, field.RegionID).
 		Scan(&field.ID, &field.CreatedAt, &field.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	field.RetiredAt = nil
+	_, err = tx.Exec(`INSERT INTO field_boundaries (field_id, coordinates, area, valid_from) VALUES ($1, $2, $3, $4)`,
+		field.ID, field.Coordinates, field.Area, validFrom)
+	return err
 }
 
-func GetFields() ([]Field, error) {
-	query := `SELECT id, name, description, coordinates, area, crop_type, period, region, created_at, updated_at FROM fields ORDER BY name`
-	rows, err := db.Query(query)
+// GetFields lists the current fields, leaving out the ones retired by a split or merge.
+func (s *PostgresStore) GetFields() ([]Field, error) {
+	query := `SELECT id, name, description, coordinates, area, crop_type, period, region_id, COALESCE((SELECT r.name FROM regions r WHERE r.id = fields.region_id), ''),
+			  created_at, updated_at, retired_at
+			  FROM fields WHERE organization_id = $1 AND retired_at IS NULL ORDER BY name`
+	rows, err := s.db.Query(query, s.org)
 	if err != nil {
 		return nil, err
 	}
@@ -225,7 +266,7 @@ func GetFields() ([]Field, error) {
 	var fields []Field
 	for rows.Next() {
 		var f Field
-		err := rows.Scan(&f.ID, &f.Name, &f.Description, &f.Coordinates, &f.Area, &f.CropType, &f.Period, &f.Region, &f.CreatedAt, &f.UpdatedAt)
+		err := rows.Scan(&f.ID, &f.Name, &f.Description, &f.Coordinates, &f.Area, &f.CropType, &f.Period, &f.RegionID, &f.Region, &f.CreatedAt, &f.UpdatedAt, &f.RetiredAt)
 		if err != nil {
 			return nil, err
 		}
@@ -234,44 +275,100 @@ func GetFields() ([]Field, error) {
 	return fields, nil
 }
 
-func GetFieldByID(id int) (*Field, error) {
+func (s *PostgresStore) GetFieldByID(id int) (*Field, error) {
 	var f Field
-	query := `SELECT id, name, description, coordinates, area, crop_type, period, region, created_at, updated_at FROM fields WHERE id = $1`
-	err := db.QueryRow(query, id).Scan(&f.ID, &f.Name, &f.Description, &f.Coordinates, &f.Area, &f.CropType, &f.Period, &f.Region, &f.CreatedAt, &f.UpdatedAt)
+	query := `SELECT id, name, description, coordinates, area, crop_type, period, region_id, COALESCE((SELECT r.name FROM regions r WHERE r.id = fields.region_id), ''),
+			  created_at, updated_at, retired_at
+			  FROM fields WHERE id = $1 AND organization_id = $2`
+	err := s.db.QueryRow(query, id, s.org).Scan(&f.ID, &f.Name, &f.Description, &f.Coordinates, &f.Area, &f.CropType, &f.Period, &f.RegionID, &f.Region, &f.CreatedAt, &f.UpdatedAt, &f.RetiredAt)
 	if err != nil {
 		return nil, err
 	}
 	return &f, nil
 }
 
-func UpdateField(field *Field) error {
-	query := `UPDATE fields SET name = $1, description = $2, coordinates = $3, area = $4, crop_type = $5, period = $6, region = $7, updated_at = CURRENT_TIMESTAMP
+// UpdateField changes a field. A new boundary starts a new version, so the
+// operations done before keep the boundary they were done on. Retired fields
+// cannot be changed.
+func (s *PostgresStore) UpdateField(field *Field) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockCurrentField(tx, s.org, field.ID); err != nil {
+		return err
+	}
+
+	query := `UPDATE fields SET name = $1, description = $2, coordinates = $3, area = $4, crop_type = $5, period = $6, region_id = $7, updated_at = CURRENT_TIMESTAMP
 			  WHERE id = $8 RETURNING updated_at`
-	return db.QueryRow(query, field.Name, field.Description, field.Coordinates, field.Area, field.CropType, field.Period, field.Region, field.ID).
+	err = tx.QueryRow(query, field.Name, field.Description, field.Coordinates, field.Area, field.CropType, field.Period, field.RegionID, field.ID).
 		Scan(&field.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	// jsonb comparison ignores formatting, so resending the same boundary is no change
+	result, err := tx.Exec(`UPDATE field_boundaries SET valid_to = $1
+							WHERE field_id = $2 AND valid_to IS NULL AND coordinates <> $3::jsonb`,
+		field.UpdatedAt, field.ID, field.Coordinates)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n > 0 {
+		_, err = tx.Exec(`INSERT INTO field_boundaries (field_id, coordinates, area, valid_from) VALUES ($1, $2, $3, $4)`,
+			field.ID, field.Coordinates, field.Area, field.UpdatedAt)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
 }
 
-func DeleteField(id int) error {
-	query := `DELETE FROM fields WHERE id = $1`
-	_, err := db.Exec(query, id)
+func (s *PostgresStore) DeleteField(id int) error {
+	query := `DELETE FROM fields WHERE id = $1 AND organization_id = $2`
+	_, err := s.db.Exec(query, id, s.org)
 	return err
 }
 
 // Schedule methods
-func CreateSchedule(schedule *Schedule) error {
-	query := `INSERT INTO schedules (worker_id, date)
-			  VALUES ($1, $2)
+
+// CreateSchedule returns a *ConflictError when the worker already has a
+// schedule on the date.
+func (s *PostgresStore) CreateSchedule(schedule *Schedule) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertSchedule(tx, s.org, schedule); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func insertSchedule(tx *sql.Tx, org int, schedule *Schedule) error {
+	if err := checkScheduleConflicts(tx, org, schedule); err != nil {
+		return err
+	}
+	query := `INSERT INTO schedules (organization_id, worker_id, date)
+			  VALUES ($1, $2, $3)
 			  RETURNING id, created_at, updated_at`
-	return db.QueryRow(query, schedule.WorkerID, schedule.Date).
+	return tx.QueryRow(query, org, schedule.WorkerID, schedule.Date).
 		Scan(&schedule.ID, &schedule.CreatedAt, &schedule.UpdatedAt)
 }
 
-func GetSchedules() ([]Schedule, error) {
+func (s *PostgresStore) GetSchedules() ([]Schedule, error) {
 	query := `SELECT s.id, s.worker_id, s.date, s.created_at, s.updated_at, w.name
 			  FROM schedules s
 			  LEFT JOIN workers w ON s.worker_id = w.id
+			  WHERE s.organization_id = $1
 			  ORDER BY s.date DESC`
-	rows, err := db.Query(query)
+	rows, err := s.db.Query(query, s.org)
 	if err != nil {
 		return nil, err
 	}
@@ -279,44 +376,44 @@ func GetSchedules() ([]Schedule, error) {
 
 	var schedules []Schedule
 	for rows.Next() {
-		var s Schedule
+		var sc Schedule
 		var workerName sql.NullString
-		err := rows.Scan(&s.ID, &s.WorkerID, &s.Date, &s.CreatedAt, &s.UpdatedAt, &workerName)
+		err := rows.Scan(&sc.ID, &sc.WorkerID, &sc.Date, &sc.CreatedAt, &sc.UpdatedAt, &workerName)
 		if err != nil {
 			return nil, err
 		}
 		if workerName.Valid {
-			s.Worker = &Worker{ID: s.WorkerID, Name: workerName.String}
+			sc.Worker = &Worker{ID: sc.WorkerID, Name: workerName.String}
 		}
-		schedules = append(schedules, s)
+		schedules = append(schedules, sc)
 	}
 	return schedules, nil
 }
 
-func GetScheduleByID(id int) (*Schedule, error) {
-	var s Schedule
+func (s *PostgresStore) GetScheduleByID(id int) (*Schedule, error) {
+	var sc Schedule
 	query := `SELECT s.id, s.worker_id, s.date, s.created_at, s.updated_at, w.name
 			  FROM schedules s
 			  LEFT JOIN workers w ON s.worker_id = w.id
-			  WHERE s.id = $1`
+			  WHERE s.id = $1 AND s.organization_id = $2`
 	var workerName sql.NullString
-	err := db.QueryRow(query, id).Scan(&s.ID, &s.WorkerID, &s.Date, &s.CreatedAt, &s.UpdatedAt, &workerName)
+	err := s.db.QueryRow(query, id, s.org).Scan(&sc.ID, &sc.WorkerID, &sc.Date, &sc.CreatedAt, &sc.UpdatedAt, &workerName)
 	if err != nil {
 		return nil, err
 	}
 	if workerName.Valid {
-		s.Worker = &Worker{ID: s.WorkerID, Name: workerName.String}
+		sc.Worker = &Worker{ID: sc.WorkerID, Name: workerName.String}
 	}
-	return &s, nil
+	return &sc, nil
 }
 
-func GetWorkerSchedules(workerID int) ([]Schedule, error) {
+func (s *PostgresStore) GetWorkerSchedules(workerID int) ([]Schedule, error) {
 	query := `SELECT s.id, s.worker_id, s.date, s.created_at, s.updated_at, w.name
 			  FROM schedules s
 			  LEFT JOIN workers w ON s.worker_id = w.id
-			  WHERE s.worker_id = $1
+			  WHERE s.worker_id = $1 AND s.organization_id = $2
 			  ORDER BY s.date DESC`
-	rows, err := db.Query(query, workerID)
+	rows, err := s.db.Query(query, workerID, s.org)
 	if err != nil {
 		return nil, err
 	}
@@ -324,52 +421,164 @@ func GetWorkerSchedules(workerID int) ([]Schedule, error) {
 
 	var schedules []Schedule
 	for rows.Next() {
-		var s Schedule
+		var sc Schedule
 		var workerName sql.NullString
-		err := rows.Scan(&s.ID, &s.WorkerID, &s.Date, &s.CreatedAt, &s.UpdatedAt, &workerName)
+		err := rows.Scan(&sc.ID, &sc.WorkerID, &sc.Date, &sc.CreatedAt, &sc.UpdatedAt, &workerName)
 		if err != nil {
 			return nil, err
 		}
 		if workerName.Valid {
-			s.Worker = &Worker{ID: s.WorkerID, Name: workerName.String}
+			sc.Worker = &Worker{ID: sc.WorkerID, Name: workerName.String}
 		}
-		schedules = append(schedules, s)
+		schedules = append(schedules, sc)
 	}
 	return schedules, nil
 }
 
-func UpdateSchedule(schedule *Schedule) error {
+// UpdateSchedule returns a *ConflictError when the worker already has another
+// schedule on the date.
+func (s *PostgresStore) UpdateSchedule(schedule *Schedule) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRow(`SELECT id FROM schedules WHERE id = $1 AND organization_id = $2 FOR UPDATE`, schedule.ID, s.org).Scan(&id)
+	if err != nil {
+		return err
+	}
+	if err := checkScheduleConflicts(tx, s.org, schedule); err != nil {
+		return err
+	}
 	query := `UPDATE schedules SET worker_id = $1, date = $2, updated_at = CURRENT_TIMESTAMP
 			  WHERE id = $3 RETURNING updated_at`
-	return db.QueryRow(query, schedule.WorkerID, schedule.Date, schedule.ID).
-		Scan(&schedule.UpdatedAt)
+	if err := tx.QueryRow(query, schedule.WorkerID, schedule.Date, schedule.ID).Scan(&schedule.UpdatedAt); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func DeleteSchedule(id int) error {
-	query := `DELETE FROM schedules WHERE id = $1`
-	_, err := db.Exec(query, id)
+func (s *PostgresStore) DeleteSchedule(id int) error {
+	query := `DELETE FROM schedules WHERE id = $1 AND organization_id = $2`
+	_, err := s.db.Exec(query, id, s.org)
 	return err
 }
 
 // Operation methods
-func CreateOperation(operation *Operation) error {
-	query := `INSERT INTO operations (schedule_id, worker_id, field_id, type, description, status, start_time, end_time, notes)
-			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-			  RETURNING id, created_at, updated_at`
-	return db.QueryRow(query, operation.ScheduleID, operation.WorkerID, operation.FieldID, operation.Type,
-		operation.Description, operation.Status, operation.StartTime, operation.EndTime, operation.Notes).
-		Scan(&operation.ID, &operation.CreatedAt, &operation.UpdatedAt)
+func (s *PostgresStore) CreateOperation(operation *Operation, actor string) error {
+	if operation.Status == "" {
+		operation.Status = StatusPlanned
+	}
+	if operation.Status != StatusPlanned {
+		return ErrStatusChange
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertOperation(tx, s.org, operation, actor); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func insertOperation(tx *sql.Tx, org int, operation *Operation, actor string) error {
+	query := `INSERT INTO operations (organization_id, schedule_id, worker_id, field_id, type, description, status, start_time, end_time, notes,
+				  status_changed_at, status_changed_by)
+			  VALUES ($11, $1, $2, $3, $4, $5, $6, $7, $8, $9, CURRENT_TIMESTAMP, NULLIF($10, ''))
+			  RETURNING id, created_at, updated_at, status_changed_at`
+	operation.StatusChangedBy = actor
+	operation.CoveredArea, operation.Coverage = nil, nil
+	err := tx.QueryRow(query, operation.ScheduleID, operation.WorkerID, operation.FieldID, operation.Type,
+		operation.Description, operation.Status, operation.StartTime, operation.EndTime, operation.Notes, actor, org).
+		Scan(&operation.ID, &operation.CreatedAt, &operation.UpdatedAt, &operation.StatusChangedAt)
+	if err != nil {
+		return err
+	}
+
+	if err := checkOperationConflicts(tx, org, operation.ID); err != nil {
+		return err
+	}
+
+	event := &OperationEvent{
+		OperationID: operation.ID,
+		Event:       EventCreated,
+		NewStatus:   operation.Status,
+		Changes:     operationChanges(&Operation{}, operation),
+		Actor:       actor,
+	}
+	return recordOperationEvent(tx, org, event)
 }
 
-func GetOperations() ([]Operation, error) {
-	query := `SELECT o.id, o.schedule_id, o.worker_id, o.field_id, o.type, o.description, o.status,
+// StartNewOperation creates a planned operation and starts it in one
+// transaction, creating its schedule first when schedule.ID is 0.
+func (s *PostgresStore) StartNewOperation(schedule *Schedule, operation *Operation, actor string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if schedule.ID == 0 {
+		if err := insertSchedule(tx, s.org, schedule); err != nil {
+			return err
+		}
+	}
+	operation.ScheduleID = &schedule.ID
+	operation.Status = StatusPlanned
+	if err := insertOperation(tx, s.org, operation, actor); err != nil {
+		return err
+	}
+	if err := applyTransition(tx, s.org, operation.ID, []string{StatusPlanned}, StatusInProgress, actor, nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+const operationColumns = `o.id, o.schedule_id, COALESCE(o.worker_id, 0), o.field_id, o.type, o.description, o.status,
 					 o.start_time, o.end_time, o.completed_at, o.notes, o.created_at, o.updated_at,
-					 w.name, f.name
+					 o.status_changed_at, COALESCE(o.status_changed_by, ''), o.rejected_by, COALESCE(o.rejection_reason, ''),
+					 ` + operationHoursSQL + `, o.covered_area, o.coverage, w.name, f.name, COALESCE(fb.area, f.area)`
+
+func scanOperation(row interface{ Scan(...interface{}) error }) (*Operation, error) {
+	var o Operation
+	var coverage []byte
+	var workerName, fieldName sql.NullString
+	var fieldArea sql.NullFloat64
+	err := row.Scan(&o.ID, &o.ScheduleID, &o.WorkerID, &o.FieldID, &o.Type, &o.Description, &o.Status,
+		&o.StartTime, &o.EndTime, &o.CompletedAt, &o.Notes, &o.CreatedAt, &o.UpdatedAt,
+		&o.StatusChangedAt, &o.StatusChangedBy, &o.RejectedBy, &o.RejectionReason, &o.HoursWorked,
+		&o.CoveredArea, &coverage, &workerName, &fieldName, &fieldArea)
+	if err != nil {
+		return nil, err
+	}
+	if coverage != nil {
+		o.Coverage = json.RawMessage(coverage)
+	}
+	o.CoveragePercent = coveragePercent(&o, fieldArea.Float64)
+	if workerName.Valid {
+		o.Worker = &Worker{ID: o.WorkerID, Name: workerName.String}
+	}
+	if fieldName.Valid {
+		o.Field = &Field{ID: o.FieldID, Name: fieldName.String}
+	}
+	return &o, nil
+}
+
+func (s *PostgresStore) GetOperations() ([]Operation, error) {
+	query := `SELECT ` + operationColumns + `
 			  FROM operations o
 			  LEFT JOIN workers w ON o.worker_id = w.id
 			  LEFT JOIN fields f ON o.field_id = f.id
+			  ` + fieldVersionJoin + `
+			  WHERE o.organization_id = $1
 			  ORDER BY o.start_time DESC`
-	rows, err := db.Query(query)
+	rows, err := s.db.Query(query, s.org)
 	if err != nil {
 		return nil, err
 	}
@@ -377,167 +586,324 @@ func GetOperations() ([]Operation, error) {
 
 	var operations []Operation
 	for rows.Next() {
-		var o Operation
-		var workerName, fieldName sql.NullString
-		err := rows.Scan(&o.ID, &o.ScheduleID, &o.WorkerID, &o.FieldID, &o.Type, &o.Description, &o.Status,
-			&o.StartTime, &o.EndTime, &o.CompletedAt, &o.Notes, &o.CreatedAt, &o.UpdatedAt, &workerName, &fieldName)
+		o, err := scanOperation(rows)
 		if err != nil {
 			return nil, err
 		}
-		if workerName.Valid {
-			o.Worker = &Worker{ID: o.WorkerID, Name: workerName.String}
-		}
-		if fieldName.Valid {
-			o.Field = &Field{ID: o.FieldID, Name: fieldName.String}
-		}
-		operations = append(operations, o)
+		operations = append(operations, *o)
 	}
 	return operations, nil
 }
 
-func GetOperationByID(id int) (*Operation, error) {
-	var o Operation
-	query := `SELECT o.id, o.schedule_id, o.worker_id, o.field_id, o.type, o.description, o.status,
-					 o.start_time, o.end_time, o.completed_at, o.notes, o.created_at, o.updated_at,
-					 w.name, f.name
+func (s *PostgresStore) GetOperationByID(id int) (*Operation, error) {
+	query := `SELECT ` + operationColumns + `
 			  FROM operations o
 			  LEFT JOIN workers w ON o.worker_id = w.id
 			  LEFT JOIN fields f ON o.field_id = f.id
-			  WHERE o.id = $1`
-	var workerName, fieldName sql.NullString
-	err := db.QueryRow(query, id).Scan(&o.ID, &o.ScheduleID, &o.WorkerID, &o.FieldID, &o.Type, &o.Description, &o.Status,
-		&o.StartTime, &o.EndTime, &o.CompletedAt, &o.Notes, &o.CreatedAt, &o.UpdatedAt, &workerName, &fieldName)
+			  ` + fieldVersionJoin + `
+			  WHERE o.id = $1 AND o.organization_id = $2`
+	o, err := scanOperation(s.db.QueryRow(query, id, s.org))
 	if err != nil {
 		return nil, err
 	}
-	if workerName.Valid {
-		o.Worker = &Worker{ID: o.WorkerID, Name: workerName.String}
-	}
-	if fieldName.Valid {
-		o.Field = &Field{ID: o.FieldID, Name: fieldName.String}
+
+	o.Intervals, err = s.GetWorkIntervals(o.ID)
+	if err != nil {
+		return nil, err
 	}
-	return &o, nil
+	return o, nil
 }
 
-func UpdateOperation(operation *Operation) error {
-	query := `UPDATE operations SET schedule_id = $1, worker_id = $2, field_id = $3, type = $4, description = $5,
-			  status = $6, start_time = $7, end_time = $8, notes = $9, updated_at = CURRENT_TIMESTAMP
-			  WHERE id = $10 RETURNING updated_at`
-	return db.QueryRow(query, operation.ScheduleID, operation.WorkerID, operation.FieldID, operation.Type,
-		operation.Description, operation.Status, operation.StartTime, operation.EndTime, operation.Notes, operation.ID).
-		Scan(&operation.UpdatedAt)
-}
+// UpdateOperation saves the operation. An empty status or a WorkerID of 0
+// keeps the current one.
+func (s *PostgresStore) UpdateOperation(operation *Operation, actor string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
-func CompleteOperation(id int) error {
-	query := `UPDATE operations SET status = 'completed', completed_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
-			  WHERE id = $1`
-	_, err := db.Exec(query, id)
-	return err
-}
+	old, err := lockOperation(tx, s.org, operation.ID)
+	if err != nil {
+		return err
+	}
+	current := old.Status
+	if operation.Status == "" {
+		operation.Status = current
+	}
+	// Coverage is recorded through SetOperationCoverage
+	operation.CoveredArea, operation.Coverage = old.CoveredArea, old.Coverage
+	if operation.Status != current {
+		return ErrStatusChange
+	}
 
-func StartOperation(id int) error {
-	query := `UPDATE operations SET status = 'in_progress', start_time = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
-			  WHERE id = $1`
-	_, err := db.Exec(query, id)
-	return err
-}
+	query := `UPDATE operations SET schedule_id = $1, worker_id = COALESCE(NULLIF($2, 0), worker_id), field_id = $3,
+			  type = $4, description = $5, start_time = $6, end_time = $7, notes = $8, updated_at = CURRENT_TIMESTAMP
+			  WHERE id = $9 RETURNING COALESCE(worker_id, 0), updated_at, completed_at, rejected_by, COALESCE(rejection_reason, '')`
+	err = tx.QueryRow(query, operation.ScheduleID, operation.WorkerID, operation.FieldID, operation.Type,
+		operation.Description, operation.StartTime, operation.EndTime, operation.Notes, operation.ID).
+		Scan(&operation.WorkerID, &operation.UpdatedAt, &operation.CompletedAt, &operation.RejectedBy, &operation.RejectionReason)
+	if err != nil {
+		return err
+	}
 
-func RejectOperation(id int) error {
-	query := `UPDATE operations SET worker_id = NULL, schedule_id = NULL updated_at = CURRENT_TIMESTAMP
-			  WHERE id = $1`
-	_, err := db.Exec(query, id)
-	return err
+	if err := checkOperationConflicts(tx, s.org, operation.ID); err != nil {
+		return err
+	}
+
+	event := &OperationEvent{
+		OperationID: operation.ID,
+		Event:       EventUpdated,
+		OldStatus:   current,
+		NewStatus:   operation.Status,
+		Changes:     operationChanges(old, operation),
+		Actor:       actor,
+	}
+	if err := recordOperationEvent(tx, s.org, event); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func DeleteOperation(id int) error {
-	query := `DELETE FROM operations WHERE id = $1`
-	_, err := db.Exec(query, id)
-	return err
+// TransitionOperation moves an operation to the given status, recording who did it and when.
+// It returns a *TransitionError when the state machine does not allow the change.
+func (s *PostgresStore) TransitionOperation(id int, to, actor string) error {
+	return s.transitionOperation(id, nil, to, actor, nil)
 }
 
-// Report methods
-func GetDailyReport(date time.Time) (*DailyReport, error) {
-	report := &DailyReport{
-		Date:             date,
-		OperationsByType: make(map[string]int),
+// transitionOperation is TransitionOperation restricted to operations currently
+// in one of the given statuses, or in any status when from is empty. The set
+// columns are updated together with the status.
+func (s *PostgresStore) transitionOperation(id int, from []string, to, actor string, set map[string]interface{}) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := applyTransition(tx, s.org, id, from, to, actor, set); err != nil {
+		return err
 	}
+	return tx.Commit()
+}
 
-	// Get total workers active on this date
-	err := db.QueryRow(`SELECT COUNT(DISTINCT worker_id) FROM operations WHERE DATE(start_time) = $1`, date).
-		Scan(&report.TotalWorkers)
+// applyTransition makes the changes of transitionOperation within tx.
+func applyTransition(tx *sql.Tx, org, id int, from []string, to, actor string, set map[string]interface{}) error {
+	old, err := lockOperation(tx, org, id)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	current := old.Status
+	if !CanTransition(current, to) || (len(from) > 0 && !containsStatus(from, current)) {
+		return &TransitionError{From: current, To: to}
+	}
+
+	var extra string
+	switch {
+	case current == StatusPlanned && to == StatusInProgress:
+		extra = `, start_time = CURRENT_TIMESTAMP`
+	case to == StatusCompleted:
+		extra = `, completed_at = CURRENT_TIMESTAMP, end_time = CURRENT_TIMESTAMP`
+	case to == StatusRejected:
+		// The operation leaves its worker's schedule for the unassigned pool
+		extra = `, rejected_by = worker_id, worker_id = NULL, schedule_id = NULL`
+	}
+
+	args := []interface{}{to, actor, id}
+	columns := make([]string, 0, len(set))
+	for column := range set {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	for _, column := range columns {
+		args = append(args, set[column])
+		extra += fmt.Sprintf(", %s = $%d", column, len(args))
 	}
 
-	// Get operation statistics
-	err = db.QueryRow(`SELECT COUNT(*),
-						COUNT(CASE WHEN status = 'completed' THEN 1 END),
-						COUNT(CASE WHEN status = 'in_progress' THEN 1 END)
-					   FROM operations WHERE DATE(start_time) = $1`, date).
-		Scan(&report.TotalOperations, &report.CompletedOps, &report.InProgressOps)
+	updated := *old
+	updated.Status = to
+	query := `UPDATE operations SET status = $1, status_changed_at = CURRENT_TIMESTAMP, status_changed_by = NULLIF($2, ''),
+			  updated_at = CURRENT_TIMESTAMP` + extra + `
+			  WHERE id = $3
+			  RETURNING schedule_id, COALESCE(worker_id, 0), start_time, end_time, completed_at, COALESCE(rejection_reason, '')`
+	err = tx.QueryRow(query, args...).Scan(&updated.ScheduleID, &updated.WorkerID, &updated.StartTime, &updated.EndTime,
+		&updated.CompletedAt, &updated.RejectionReason)
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	if err := updateWorkIntervals(tx, id, current, to); err != nil {
+		return err
+	}
+	// Starting, resuming and assigning can put the worker on two operations at once
+	if to == StatusInProgress || to == StatusPlanned {
+		if err := checkOperationConflicts(tx, org, id); err != nil {
+			return err
+		}
 	}
 
-	// Get operations by type
-	rows, err := db.Query(`SELECT type, COUNT(*) FROM operations WHERE DATE(start_time) = $1 GROUP BY type`, date)
+	event := &OperationEvent{
+		OperationID: id,
+		Event:       eventForTransition(current, to),
+		OldStatus:   current,
+		NewStatus:   to,
+		Changes:     operationChanges(old, &updated),
+		Actor:       actor,
+	}
+	return recordOperationEvent(tx, org, event)
+}
+
+func containsStatus(statuses []string, status string) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *PostgresStore) CompleteOperation(id int, actor string) error {
+	return s.TransitionOperation(id, StatusCompleted, actor)
+}
+
+func (s *PostgresStore) StartOperation(id int, actor string) error {
+	return s.transitionOperation(id, []string{StatusPlanned}, StatusInProgress, actor, nil)
+}
+
+func (s *PostgresStore) PauseOperation(id int, actor string) error {
+	return s.TransitionOperation(id, StatusPaused, actor)
+}
+
+func (s *PostgresStore) ResumeOperation(id int, actor string) error {
+	return s.transitionOperation(id, []string{StatusPaused}, StatusInProgress, actor, nil)
+}
+
+func (s *PostgresStore) CancelOperation(id int, actor string) error {
+	return s.TransitionOperation(id, StatusCancelled, actor)
+}
+
+// RejectOperation returns a planned operation to the unassigned pool. The worker
+// it was assigned to is kept as rejected_by together with their reason.
+func (s *PostgresStore) RejectOperation(id int, reason, actor string) error {
+	return s.transitionOperation(id, nil, StatusRejected, actor, map[string]interface{}{
+		"rejection_reason": reason,
+	})
+}
+
+// AssignOperation takes an operation out of the unassigned pool and plans it for another worker.
+func (s *PostgresStore) AssignOperation(id, workerID int, scheduleID *int, actor string) error {
+	return s.transitionOperation(id, []string{StatusRejected}, StatusPlanned, actor, map[string]interface{}{
+		"rejected_by":      nil,
+		"rejection_reason": nil,
+		"schedule_id":      scheduleID,
+		"worker_id":        workerID,
+	})
+}
+
+// GetUnassignedOperations lists the rejected operations waiting to be assigned again.
+func (s *PostgresStore) GetUnassignedOperations() ([]Operation, error) {
+	query := `SELECT ` + operationColumns + `
+			  FROM operations o
+			  LEFT JOIN workers w ON o.worker_id = w.id
+			  LEFT JOIN fields f ON o.field_id = f.id
+			  ` + fieldVersionJoin + `
+			  WHERE o.status = $1 AND o.organization_id = $2
+			  ORDER BY o.status_changed_at`
+	rows, err := s.db.Query(query, StatusRejected, s.org)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	var operations []Operation
 	for rows.Next() {
-		var opType string
-		var count int
-		if err := rows.Scan(&opType, &count); err != nil {
+		o, err := scanOperation(rows)
+		if err != nil {
 			return nil, err
 		}
-		report.OperationsByType[opType] = count
+		operations = append(operations, *o)
 	}
+	return operations, nil
+}
 
-	// Get worker statistics
-	workerRows, err := db.Query(`
-		SELECT o.worker_id, w.name, COUNT(*),
-			   COALESCE(SUM(EXTRACT(EPOCH FROM (COALESCE(o.end_time, NOW()) - o.start_time))/3600), 0),
-			   COUNT(DISTINCT o.field_id)
-		FROM operations o
-		JOIN workers w ON o.worker_id = w.id
-		WHERE DATE(o.start_time) = $1
-		GROUP BY o.worker_id, w.name`, date)
+// GetWorkerOperations lists the operations of a worker that are on their
+// schedule for the date or still open: planned, in progress or paused.
+func (s *PostgresStore) GetWorkerOperations(workerID int, date time.Time) ([]Operation, error) {
+	query := `SELECT ` + operationColumns + `
+			  FROM operations o
+			  LEFT JOIN workers w ON o.worker_id = w.id
+			  LEFT JOIN fields f ON o.field_id = f.id
+			  ` + fieldVersionJoin + `
+			  WHERE o.organization_id = $1 AND o.worker_id = $2
+			    AND (o.status IN ($4, $5, $6)
+			         OR o.schedule_id IN (SELECT id FROM schedules WHERE organization_id = $1 AND worker_id = $2 AND date = $3))
+			  ORDER BY o.start_time DESC, o.id`
+	rows, err := s.db.Query(query, s.org, workerID, date.Format("2006-01-02"), StatusPlanned, StatusInProgress, StatusPaused)
 	if err != nil {
 		return nil, err
 	}
-	defer workerRows.Close()
+	defer rows.Close()
 
-	for workerRows.Next() {
-		var ws WorkerDailyStats
-		if err := workerRows.Scan(&ws.WorkerID, &ws.WorkerName, &ws.Operations, &ws.HoursWorked, &ws.FieldsWorked); err != nil {
+	var operations []Operation
+	for rows.Next() {
+		o, err := scanOperation(rows)
+		if err != nil {
 			return nil, err
 		}
-		report.WorkerStats = append(report.WorkerStats, ws)
+		operations = append(operations, *o)
 	}
+	return operations, nil
+}
 
-	// Get field statistics
-	fieldRows, err := db.Query(`
-		SELECT o.field_id, f.name, COUNT(*),
-			   COALESCE(SUM(EXTRACT(EPOCH FROM (COALESCE(o.end_time, NOW()) - o.start_time))/3600), 0),
-			   COUNT(DISTINCT o.worker_id)
-		FROM operations o
-		JOIN fields f ON o.field_id = f.id
-		WHERE DATE(o.start_time) = $1
-		GROUP BY o.field_id, f.name`, date)
+// GetLastCompletedOperations returns the most recently completed operation of
+// every field that has one, keyed by field ID.
+func (s *PostgresStore) GetLastCompletedOperations() (map[int]Operation, error) {
+	query := `SELECT DISTINCT ON (o.field_id) ` + operationColumns + `
+			  FROM operations o
+			  LEFT JOIN workers w ON o.worker_id = w.id
+			  LEFT JOIN fields f ON o.field_id = f.id
+			  ` + fieldVersionJoin + `
+			  WHERE o.status = $1 AND o.organization_id = $2
+			  ORDER BY o.field_id, o.completed_at DESC NULLS LAST, o.id DESC`
+	rows, err := s.db.Query(query, StatusCompleted, s.org)
 	if err != nil {
 		return nil, err
 	}
-	defer fieldRows.Close()
+	defer rows.Close()
 
-	for fieldRows.Next() {
-		var fs FieldDailyStats
-		if err := fieldRows.Scan(&fs.FieldID, &fs.FieldName, &fs.Operations, &fs.HoursWorked, &fs.WorkersCount); err != nil {
+	operations := make(map[int]Operation)
+	for rows.Next() {
+		o, err := scanOperation(rows)
+		if err != nil {
 			return nil, err
 		}
-		report.FieldStats = append(report.FieldStats, fs)
+		operations[o.FieldID] = *o
 	}
+	return operations, nil
+}
 
-	return report, nil
+func (s *PostgresStore) DeleteOperation(id int, actor string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	old, err := lockOperation(tx, s.org, id)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM operations WHERE id = $1`, id); err != nil {
+		return err
+	}
+
+	event := &OperationEvent{OperationID: id, Event: EventDeleted, OldStatus: old.Status, Actor: actor}
+	if err := recordOperationEvent(tx, s.org, event); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Operation statuses
+const (
+	StatusPlanned    = "planned"
+	StatusInProgress = "in_progress"
+	StatusPaused     = "paused"
+	StatusCompleted  = "completed"
+	StatusCancelled  = "cancelled"
+	StatusRejected   = "rejected"
+)
+
+// operationTransitions lists the statuses an operation may move to from each status.
+// Completed and cancelled operations are final. Rejected operations wait in the
+// unassigned pool until they are planned for another worker or cancelled.
+var operationTransitions = map[string][]string{
+	StatusPlanned:    {StatusInProgress, StatusCancelled, StatusRejected},
+	StatusInProgress: {StatusPaused, StatusCompleted, StatusCancelled},
+	StatusPaused:     {StatusInProgress, StatusCompleted, StatusCancelled},
+	StatusCompleted:  {},
+	StatusCancelled:  {},
+	StatusRejected:   {StatusPlanned, StatusCancelled},
+}
+
+// ErrStatusChange is returned when an operation would be created in another
+// status than planned, or its status changed by an update. Status changes go
+// through TransitionOperation and the methods built on it, which enforce the
+// state machine and record each transition.
+var ErrStatusChange = errors.New("status changes go through the transition methods")
+
+// TransitionError is returned when an operation cannot move between two statuses.
+type TransitionError struct {
+	From string
+	To   string
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("operation cannot move from %q to %q", e.From, e.To)
+}
+
+func ValidOperationStatus(status string) bool {
+	_, ok := operationTransitions[status]
+	return ok
+}
+
+func CanTransition(from, to string) bool {
+	for _, next := range operationTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,283 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrMemberExists is returned when adding a worker to an organization they
+// already belong to.
+var ErrMemberExists = errors.New("worker already belongs to the organization")
+
+// ErrAlreadySetUp is returned by Setup once there are organizations.
+var ErrAlreadySetUp = errors.New("already set up")
+
+// ErrSharedWorker is returned when an organization would change the email or
+// phone of a worker who also belongs to other organizations; they log in with
+// them everywhere.
+var ErrSharedWorker = errors.New("worker belongs to other organizations")
+
+// Organization is a farming company. Fields, regions, schedules, operations
+// and machine tracks belong to one; workers belong to any number as members.
+type Organization struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Membership is an organization a worker belongs to, with their access role there.
+type Membership struct {
+	Organization
+	AccessRole string `json:"access_role"`
+}
+
+// Invitation is an organization's offer to a worker to join it with an access
+// role. The worker only becomes a member by accepting it.
+type Invitation struct {
+	Membership
+	InvitedBy string    `json:"invited_by"`
+	InvitedAt time.Time `json:"invited_at"`
+}
+
+// ForOrganization returns a store limited to the data of one organization.
+// Stores from NewPostgresStore are limited to no organization at all and
+// only good for organizations and accounts.
+func (s *PostgresStore) ForOrganization(id int) Store {
+	return &PostgresStore{db: s.db, org: id}
+}
+
+func (s *PostgresStore) CreateOrganization(org *Organization) error {
+	return s.db.QueryRow(`INSERT INTO organizations (name) VALUES ($1) RETURNING id, created_at, updated_at`, org.Name).
+		Scan(&org.ID, &org.CreatedAt, &org.UpdatedAt)
+}
+
+// Setup creates the first organization and its owner, who logs in with a
+// password, all or nothing. The organizations table stays locked from the
+// check to the commit, so of replicas set up at once only one succeeds and
+// the others get ErrAlreadySetUp.
+func (s *PostgresStore) Setup(org *Organization, owner *Worker, passwordHash string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`LOCK TABLE organizations IN EXCLUSIVE MODE`); err != nil {
+		return err
+	}
+	var exists bool
+	if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM organizations)`).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return ErrAlreadySetUp
+	}
+
+	err = tx.QueryRow(`INSERT INTO organizations (name) VALUES ($1) RETURNING id, created_at, updated_at`, org.Name).
+		Scan(&org.ID, &org.CreatedAt, &org.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	err = tx.QueryRow(`INSERT INTO workers (name, email, phone, role, password_hash)
+					   VALUES ($1, $2, $3, $4, $5)
+					   RETURNING id, created_at, updated_at`,
+		owner.Name, owner.Email, owner.Phone, owner.Role, passwordHash).
+		Scan(&owner.ID, &owner.CreatedAt, &owner.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO memberships (organization_id, worker_id, access_role) VALUES ($1, $2, $3)`,
+		org.ID, owner.ID, owner.AccessRole)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *PostgresStore) GetOrganizations() ([]Organization, error) {
+	rows, err := s.db.Query(`SELECT id, name, created_at, updated_at FROM organizations ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	organizations := []Organization{}
+	for rows.Next() {
+		var o Organization
+		if err := rows.Scan(&o.ID, &o.Name, &o.CreatedAt, &o.UpdatedAt); err != nil {
+			return nil, err
+		}
+		organizations = append(organizations, o)
+	}
+	return organizations, rows.Err()
+}
+
+func (s *PostgresStore) GetOrganizationByID(id int) (*Organization, error) {
+	var o Organization
+	err := s.db.QueryRow(`SELECT id, name, created_at, updated_at FROM organizations WHERE id = $1`, id).
+		Scan(&o.ID, &o.Name, &o.CreatedAt, &o.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+func (s *PostgresStore) UpdateOrganization(org *Organization) error {
+	return s.db.QueryRow(`UPDATE organizations SET name = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2
+						  RETURNING created_at, updated_at`, org.Name, org.ID).
+		Scan(&org.CreatedAt, &org.UpdatedAt)
+}
+
+// GetWorkerOrganizations lists the organizations a worker belongs to, the
+// oldest first.
+func (s *PostgresStore) GetWorkerOrganizations(workerID int) ([]Membership, error) {
+	rows, err := s.db.Query(`SELECT o.id, o.name, o.created_at, o.updated_at, m.access_role
+							 FROM memberships m JOIN organizations o ON o.id = m.organization_id
+							 WHERE m.worker_id = $1 ORDER BY o.id`, workerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	memberships := []Membership{}
+	for rows.Next() {
+		var m Membership
+		if err := rows.Scan(&m.ID, &m.Name, &m.CreatedAt, &m.UpdatedAt, &m.AccessRole); err != nil {
+			return nil, err
+		}
+		memberships = append(memberships, m)
+	}
+	return memberships, rows.Err()
+}
+
+// AddMember makes an existing worker a member of the store's organization
+// without asking them, as its first owner. Everyone else joins by accepting
+// an invitation from InviteMember.
+func (s *PostgresStore) AddMember(workerID int, accessRole string) error {
+	result, err := s.db.Exec(`INSERT INTO memberships (organization_id, worker_id, access_role)
+							  SELECT $1, id, $3 FROM workers WHERE id = $2
+							  ON CONFLICT (organization_id, worker_id) DO NOTHING`, s.org, workerID, accessRole)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		var exists bool
+		if err := s.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM workers WHERE id = $1)`, workerID).Scan(&exists); err != nil {
+			return err
+		}
+		if exists {
+			return ErrMemberExists
+		}
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// InviteMember invites an existing worker, e.g. one working for another
+// organization, to join the store's organization; inviting them again
+// replaces the access role. It returns ErrMemberExists for members and
+// sql.ErrNoRows for unknown workers.
+func (s *PostgresStore) InviteMember(workerID int, accessRole, invitedBy string) error {
+	var member bool
+	err := s.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM memberships WHERE organization_id = $1 AND worker_id = $2)`,
+		s.org, workerID).Scan(&member)
+	if err != nil {
+		return err
+	}
+	if member {
+		return ErrMemberExists
+	}
+
+	result, err := s.db.Exec(`INSERT INTO invitations (organization_id, worker_id, access_role, invited_by)
+							  SELECT $1, id, $3, NULLIF($4, '') FROM workers WHERE id = $2
+							  ON CONFLICT (organization_id, worker_id) DO UPDATE
+							  SET access_role = EXCLUDED.access_role, invited_by = EXCLUDED.invited_by,
+								  created_at = CURRENT_TIMESTAMP`, s.org, workerID, accessRole, invitedBy)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetWorkerInvitations lists the invitations a worker has not answered yet,
+// the newest first.
+func (s *PostgresStore) GetWorkerInvitations(workerID int) ([]Invitation, error) {
+	rows, err := s.db.Query(`SELECT o.id, o.name, o.created_at, o.updated_at, i.access_role, COALESCE(i.invited_by, ''), i.created_at
+							 FROM invitations i JOIN organizations o ON o.id = i.organization_id
+							 WHERE i.worker_id = $1 ORDER BY i.created_at DESC, o.id`, workerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invitations := []Invitation{}
+	for rows.Next() {
+		var i Invitation
+		err := rows.Scan(&i.ID, &i.Name, &i.CreatedAt, &i.UpdatedAt, &i.AccessRole, &i.InvitedBy, &i.InvitedAt)
+		if err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, i)
+	}
+	return invitations, rows.Err()
+}
+
+// AcceptInvitation makes a worker a member of the organization that invited
+// them, with the access role of the invitation. It returns sql.ErrNoRows when
+// there is no such invitation.
+func (s *PostgresStore) AcceptInvitation(workerID, orgID int) (*Membership, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var accessRole string
+	err = tx.QueryRow(`DELETE FROM invitations WHERE organization_id = $1 AND worker_id = $2 RETURNING access_role`,
+		orgID, workerID).Scan(&accessRole)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(`INSERT INTO memberships (organization_id, worker_id, access_role) VALUES ($1, $2, $3)
+					  ON CONFLICT (organization_id, worker_id) DO NOTHING`, orgID, workerID, accessRole)
+	if err != nil {
+		return nil, err
+	}
+
+	var m Membership
+	err = tx.QueryRow(`SELECT o.id, o.name, o.created_at, o.updated_at, m.access_role
+					   FROM memberships m JOIN organizations o ON o.id = m.organization_id
+					   WHERE m.organization_id = $1 AND m.worker_id = $2`, orgID, workerID).
+		Scan(&m.ID, &m.Name, &m.CreatedAt, &m.UpdatedAt, &m.AccessRole)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// DeclineInvitation drops an invitation, or returns sql.ErrNoRows when there
+// is none.
+func (s *PostgresStore) DeclineInvitation(workerID, orgID int) error {
+	result, err := s.db.Exec(`DELETE FROM invitations WHERE organization_id = $1 AND worker_id = $2`, orgID, workerID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
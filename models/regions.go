@@ -0,0 +1,157 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ErrRegionInUse is returned when deleting a region that still has fields or
+// subregions.
+var ErrRegionInUse = errors.New("region has fields or subregions")
+
+// Region is an administrative or agronomic area fields belong to. Regions
+// nest: a region without a parent is a top level region.
+type Region struct {
+	ID        int             `json:"id"`
+	Name      string          `json:"name"`
+	ParentID  *int            `json:"parent_id"`
+	Boundary  json.RawMessage `json:"boundary,omitempty"` // optional GeoJSON polygon
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// RegionSubtree returns the ID of a region followed by the IDs of all the
+// regions below it, given every region.
+func RegionSubtree(regions []Region, id int) []int {
+	children := make(map[int][]int)
+	for _, r := range regions {
+		if r.ParentID != nil {
+			children[*r.ParentID] = append(children[*r.ParentID], r.ID)
+		}
+	}
+
+	subtree := []int{id}
+	for i := 0; i < len(subtree); i++ {
+		subtree = append(subtree, children[subtree[i]]...)
+	}
+	return subtree
+}
+
+// ReportFilter narrows a report to some of the operations. The zero value
+// keeps all of them.
+type ReportFilter struct {
+	// RegionIDs keeps the operations on fields in these regions; see RegionSubtree
+	RegionIDs []int
+}
+
+// Includes tells whether operations on a field in the region pass the filter.
+func (f ReportFilter) Includes(regionID *int) bool {
+	if f.RegionIDs == nil {
+		return true
+	}
+	return regionID != nil && containsInt(f.RegionIDs, *regionID)
+}
+
+// reportFilterSQL limits a report query on operations aliased "o" to the
+// organization in parameter $n and to the region IDs in the int array
+// parameter $n+1 (regionArg), unless that is NULL.
+func reportFilterSQL(n int) string {
+	return fmt.Sprintf(` AND o.organization_id = $%[1]d AND ($%[2]d::int[] IS NULL OR o.field_id IN (SELECT id FROM fields WHERE region_id = ANY($%[2]d::int[])))`, n, n+1)
+}
+
+// regionArg is the region parameter of reportFilterSQL.
+func (f ReportFilter) regionArg() interface{} {
+	if f.RegionIDs == nil {
+		return nil
+	}
+	return pq.Array(f.RegionIDs)
+}
+
+const regionColumns = `id, name, parent_id, boundary, created_at, updated_at`
+
+func scanRegion(row interface{ Scan(...interface{}) error }) (*Region, error) {
+	var r Region
+	var boundary []byte
+	if err := row.Scan(&r.ID, &r.Name, &r.ParentID, &boundary, &r.CreatedAt, &r.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if boundary != nil {
+		r.Boundary = boundary
+	}
+	return &r, nil
+}
+
+// nullableJSON stores an empty document as NULL.
+func nullableJSON(doc json.RawMessage) interface{} {
+	if len(doc) == 0 || string(doc) == "null" {
+		return nil
+	}
+	return []byte(doc)
+}
+
+// Region methods
+func (s *PostgresStore) CreateRegion(region *Region) error {
+	query := `INSERT INTO regions (organization_id, name, parent_id, boundary)
+			  VALUES ($1, $2, $3, $4)
+			  RETURNING id, created_at, updated_at`
+	return s.db.QueryRow(query, s.org, region.Name, region.ParentID, nullableJSON(region.Boundary)).
+		Scan(&region.ID, &region.CreatedAt, &region.UpdatedAt)
+}
+
+func (s *PostgresStore) GetRegions() ([]Region, error) {
+	rows, err := s.db.Query(`SELECT `+regionColumns+` FROM regions WHERE organization_id = $1 ORDER BY name, id`, s.org)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	regions := []Region{}
+	for rows.Next() {
+		r, err := scanRegion(rows)
+		if err != nil {
+			return nil, err
+		}
+		regions = append(regions, *r)
+	}
+	return regions, rows.Err()
+}
+
+func (s *PostgresStore) GetRegionByID(id int) (*Region, error) {
+	return scanRegion(s.db.QueryRow(`SELECT `+regionColumns+` FROM regions WHERE id = $1 AND organization_id = $2`, id, s.org))
+}
+
+func (s *PostgresStore) UpdateRegion(region *Region) error {
+	query := `UPDATE regions SET name = $1, parent_id = $2, boundary = $3, updated_at = CURRENT_TIMESTAMP
+			  WHERE id = $4 AND organization_id = $5 RETURNING created_at, updated_at`
+	return s.db.QueryRow(query, region.Name, region.ParentID, nullableJSON(region.Boundary), region.ID, s.org).
+		Scan(&region.CreatedAt, &region.UpdatedAt)
+}
+
+// DeleteRegion deletes a region that no field, not even a retired one, and no
+// subregion refers to.
+func (s *PostgresStore) DeleteRegion(id int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var inUse bool
+	err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM fields WHERE region_id = r.id)
+							OR EXISTS (SELECT 1 FROM regions c WHERE c.parent_id = r.id)
+					   FROM regions r WHERE r.id = $1 AND r.organization_id = $2 FOR UPDATE`, id, s.org).Scan(&inUse)
+	if err != nil {
+		return err
+	}
+	if inUse {
+		return ErrRegionInUse
+	}
+	if _, err := tx.Exec(`DELETE FROM regions WHERE id = $1`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
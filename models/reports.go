@@ -0,0 +1,539 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type DailyReport struct {
+	Date             time.Time          `json:"date"`
+	TotalWorkers     int                `json:"total_workers"`
+	TotalOperations  int                `json:"total_operations"`
+	CompletedOps     int                `json:"completed_operations"`
+	InProgressOps    int                `json:"in_progress_operations"`
+	OperationsByType map[string]int     `json:"operations_by_type"`
+	WorkerStats      []WorkerDailyStats `json:"worker_stats"`
+	FieldStats       []FieldDailyStats  `json:"field_stats"`
+	RegionStats      []RegionStats      `json:"region_stats"`
+}
+
+type WorkerDailyStats struct {
+	WorkerID     int     `json:"worker_id"`
+	WorkerName   string  `json:"worker_name"`
+	Operations   int     `json:"operations"`
+	HoursWorked  float64 `json:"hours_worked"`
+	FieldsWorked int     `json:"fields_worked"`
+}
+
+type FieldDailyStats struct {
+	FieldID       int     `json:"field_id"`
+	FieldName     string  `json:"field_name"`
+	Operations    int     `json:"operations"`
+	HoursWorked   float64 `json:"hours_worked"`
+	DecaresWorked float64 `json:"decares_worked"` // see coveredAreaSQL
+	WorkersCount  int     `json:"workers_count"`
+}
+
+// RegionStats sums up the operations on the fields of a region. Fields
+// without a region are counted under RegionID 0.
+type RegionStats struct {
+	RegionID      int     `json:"region_id"`
+	RegionName    string  `json:"region_name"`
+	Operations    int     `json:"operations"`
+	HoursWorked   float64 `json:"hours_worked"`
+	DecaresWorked float64 `json:"decares_worked"` // see coveredAreaSQL
+	FieldsWorked  int     `json:"fields_worked"`
+	WorkersCount  int     `json:"workers_count"`
+}
+
+// PeriodReport aggregates all operations started in the half-open interval [From, To).
+type PeriodReport struct {
+	From             time.Time          `json:"from"`
+	To               time.Time          `json:"to"`
+	TotalWorkers     int                `json:"total_workers"`
+	TotalOperations  int                `json:"total_operations"`
+	CompletedOps     int                `json:"completed_operations"`
+	InProgressOps    int                `json:"in_progress_operations"`
+	HoursWorked      float64            `json:"hours_worked"`
+	OperationsByType map[string]int     `json:"operations_by_type"`
+	WorkerStats      []WorkerDailyStats `json:"worker_stats"`
+	FieldStats       []FieldDailyStats  `json:"field_stats"`
+	RegionStats      []RegionStats      `json:"region_stats"`
+}
+
+// PeriodStats is a single day or month in a report breakdown.
+type PeriodStats struct {
+	Period          time.Time `json:"period"`
+	TotalWorkers    int       `json:"total_workers"`
+	TotalOperations int       `json:"total_operations"`
+	CompletedOps    int       `json:"completed_operations"`
+	InProgressOps   int       `json:"in_progress_operations"`
+	HoursWorked     float64   `json:"hours_worked"`
+}
+
+type MonthlyReport struct {
+	Year  int `json:"year"`
+	Month int `json:"month"`
+	PeriodReport
+	DailyBreakdown []PeriodStats `json:"daily_breakdown"`
+}
+
+type YearlyReport struct {
+	Year int `json:"year"`
+	PeriodReport
+	MonthlyBreakdown []PeriodStats `json:"monthly_breakdown"`
+}
+
+// RangeReport is a pivot of the operations started in [From, To), grouped by
+// the requested dimensions in GroupBy order.
+type RangeReport struct {
+	From    time.Time        `json:"from"`
+	To      time.Time        `json:"to"`
+	GroupBy []string         `json:"group_by"`
+	Totals  RangeReportRow   `json:"totals"`
+	Rows    []RangeReportRow `json:"rows"`
+}
+
+type RangeReportRow struct {
+	Keys          map[string]string `json:"keys"`
+	Operations    int               `json:"operations"`
+	CompletedOps  int               `json:"completed_operations"`
+	InProgressOps int               `json:"in_progress_operations"`
+	HoursWorked   float64           `json:"hours_worked"`
+	WorkersCount  int               `json:"workers_count"`
+	FieldsCount   int               `json:"fields_count"`
+}
+
+type reportDimension struct {
+	value string // expression selected as the row key
+	group string // expressions the rows are grouped by
+}
+
+// reportDimensions are the group_by keys accepted by GetRangeReport.
+var reportDimensions = map[string]reportDimension{
+	"worker":    {value: "w.name", group: "o.worker_id, w.name"},
+	"field":     {value: "f.name", group: "o.field_id, f.name"},
+	"type":      {value: "o.type", group: "o.type"},
+	"region":    {value: "r.name", group: "f.region_id, r.name"},
+	"crop_type": {value: "f.crop_type", group: "f.crop_type"},
+	"day":       {value: "TO_CHAR(DATE(o.start_time), 'YYYY-MM-DD')", group: "DATE(o.start_time)"},
+}
+
+// ParseReportDimensions parses a comma separated group_by list such as "worker,day".
+func ParseReportDimensions(groupBy string) ([]string, error) {
+	var dims []string
+	seen := make(map[string]bool)
+	for _, dim := range strings.Split(groupBy, ",") {
+		dim = strings.TrimSpace(dim)
+		if dim == "" {
+			continue
+		}
+		if _, ok := reportDimensions[dim]; !ok {
+			return nil, fmt.Errorf("unknown group_by dimension %q", dim)
+		}
+		if seen[dim] {
+			return nil, fmt.Errorf("duplicate group_by dimension %q", dim)
+		}
+		seen[dim] = true
+		dims = append(dims, dim)
+	}
+	return dims, nil
+}
+
+// operationHoursSQL is the number of hours an operation aliased as "o" has been worked:
+// the sum of its work intervals, or for completed operations entered after the fact
+// without any intervals, the span from start to end.
+const operationHoursSQL = `COALESCE(
+	(SELECT SUM(EXTRACT(EPOCH FROM (COALESCE(i.ended_at, NOW()) - i.started_at)))
+	 FROM operation_intervals i WHERE i.operation_id = o.id),
+	CASE WHEN o.status = 'completed' THEN EXTRACT(EPOCH FROM (COALESCE(o.end_time, o.completed_at) - o.start_time)) END,
+	0)/3600`
+
+// reportSource is the part of a report store that actually reads the data.
+// The reports built on top of it are shared by PostgresStore and MemoryStore.
+type reportSource interface {
+	getPeriodReport(from, to time.Time, filter ReportFilter) (*PeriodReport, error)
+	GetPeriodBreakdown(unit string, from, to time.Time, filter ReportFilter) ([]PeriodStats, error)
+	getRangeReportRows(from, to time.Time, groupBy []string, filter ReportFilter) ([]RangeReportRow, error)
+}
+
+// Report methods
+func (s *PostgresStore) GetDailyReport(date time.Time, filter ReportFilter) (*DailyReport, error) {
+	return dailyReport(s, date, filter)
+}
+
+func (s *PostgresStore) GetMonthlyReport(year int, month time.Month, filter ReportFilter) (*MonthlyReport, error) {
+	return monthlyReport(s, year, month, filter)
+}
+
+func (s *PostgresStore) GetYearlyReport(year int, filter ReportFilter) (*YearlyReport, error) {
+	return yearlyReport(s, year, filter)
+}
+
+func (s *PostgresStore) GetRangeReport(from, to time.Time, groupBy []string, filter ReportFilter) (*RangeReport, error) {
+	return rangeReport(s, from, to, groupBy, filter)
+}
+
+func dailyReport(src reportSource, date time.Time, filter ReportFilter) (*DailyReport, error) {
+	from := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+	period, err := src.getPeriodReport(from, from.AddDate(0, 0, 1), filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DailyReport{
+		Date:             date,
+		TotalWorkers:     period.TotalWorkers,
+		TotalOperations:  period.TotalOperations,
+		CompletedOps:     period.CompletedOps,
+		InProgressOps:    period.InProgressOps,
+		OperationsByType: period.OperationsByType,
+		WorkerStats:      period.WorkerStats,
+		FieldStats:       period.FieldStats,
+		RegionStats:      period.RegionStats,
+	}, nil
+}
+
+func monthlyReport(src reportSource, year int, month time.Month, filter ReportFilter) (*MonthlyReport, error) {
+	from := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+
+	period, err := src.getPeriodReport(from, to, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	daily, err := src.GetPeriodBreakdown("day", from, to, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MonthlyReport{
+		Year:           year,
+		Month:          int(month),
+		PeriodReport:   *period,
+		DailyBreakdown: daily,
+	}, nil
+}
+
+func yearlyReport(src reportSource, year int, filter ReportFilter) (*YearlyReport, error) {
+	from := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(1, 0, 0)
+
+	period, err := src.getPeriodReport(from, to, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	monthly, err := src.GetPeriodBreakdown("month", from, to, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return &YearlyReport{
+		Year:             year,
+		PeriodReport:     *period,
+		MonthlyBreakdown: monthly,
+	}, nil
+}
+
+// GetPeriodBreakdown returns one entry per day or month (unit) in [from, to),
+// including the periods in which nothing was done.
+func (s *PostgresStore) GetPeriodBreakdown(unit string, from, to time.Time, filter ReportFilter) ([]PeriodStats, error) {
+	rows, err := s.db.Query(`
+		SELECT DATE_TRUNC($3, o.start_time), COUNT(DISTINCT o.worker_id), COUNT(*),
+			   COUNT(CASE WHEN o.status = 'completed' THEN 1 END),
+			   COUNT(CASE WHEN o.status = 'in_progress' THEN 1 END),
+			   COALESCE(SUM(`+operationHoursSQL+`), 0)
+		FROM operations o
+		WHERE o.start_time >= $1 AND o.start_time < $2`+reportFilterSQL(4)+`
+		GROUP BY 1
+		ORDER BY 1`, from, to, unit, s.org, filter.regionArg())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	found := make(map[string]PeriodStats)
+	for rows.Next() {
+		var ps PeriodStats
+		if err := rows.Scan(&ps.Period, &ps.TotalWorkers, &ps.TotalOperations, &ps.CompletedOps, &ps.InProgressOps, &ps.HoursWorked); err != nil {
+			return nil, err
+		}
+		found[ps.Period.Format("2006-01-02")] = ps
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return fillBreakdown(unit, from, to, found), nil
+}
+
+// fillBreakdown lists the periods in [from, to) in order, taking the stats of
+// each from found (keyed by the period's date) or leaving them empty.
+func fillBreakdown(unit string, from, to time.Time, found map[string]PeriodStats) []PeriodStats {
+	var breakdown []PeriodStats
+	for p := truncatePeriod(unit, from); p.Before(to); p = nextPeriod(unit, p) {
+		ps := found[p.Format("2006-01-02")]
+		ps.Period = p
+		breakdown = append(breakdown, ps)
+	}
+	return breakdown
+}
+
+func truncatePeriod(unit string, t time.Time) time.Time {
+	if unit == "month" {
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func nextPeriod(unit string, t time.Time) time.Time {
+	if unit == "month" {
+		return t.AddDate(0, 1, 0)
+	}
+	return t.AddDate(0, 0, 1)
+}
+
+func (s *PostgresStore) getPeriodReport(from, to time.Time, filter ReportFilter) (*PeriodReport, error) {
+	report := &PeriodReport{
+		From:             from,
+		To:               to,
+		OperationsByType: make(map[string]int),
+	}
+
+	// Get operation statistics
+	err := s.db.QueryRow(`SELECT COUNT(DISTINCT o.worker_id), COUNT(*),
+						COUNT(CASE WHEN o.status = 'completed' THEN 1 END),
+						COUNT(CASE WHEN o.status = 'in_progress' THEN 1 END),
+						COALESCE(SUM(`+operationHoursSQL+`), 0)
+					   FROM operations o WHERE o.start_time >= $1 AND o.start_time < $2`+reportFilterSQL(3), from, to, s.org, filter.regionArg()).
+		Scan(&report.TotalWorkers, &report.TotalOperations, &report.CompletedOps, &report.InProgressOps, &report.HoursWorked)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get operations by type
+	rows, err := s.db.Query(`SELECT o.type, COUNT(*) FROM operations o
+						   WHERE o.start_time >= $1 AND o.start_time < $2`+reportFilterSQL(3)+` GROUP BY o.type`, from, to, s.org, filter.regionArg())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var opType string
+		var count int
+		if err := rows.Scan(&opType, &count); err != nil {
+			return nil, err
+		}
+		report.OperationsByType[opType] = count
+	}
+
+	// Get worker statistics
+	workerRows, err := s.db.Query(`
+		SELECT o.worker_id, w.name, COUNT(*),
+			   COALESCE(SUM(`+operationHoursSQL+`), 0),
+			   COUNT(DISTINCT o.field_id)
+		FROM operations o
+		JOIN workers w ON o.worker_id = w.id
+		WHERE o.start_time >= $1 AND o.start_time < $2`+reportFilterSQL(3)+`
+		GROUP BY o.worker_id, w.name
+		ORDER BY w.name`, from, to, s.org, filter.regionArg())
+	if err != nil {
+		return nil, err
+	}
+	defer workerRows.Close()
+
+	for workerRows.Next() {
+		var ws WorkerDailyStats
+		if err := workerRows.Scan(&ws.WorkerID, &ws.WorkerName, &ws.Operations, &ws.HoursWorked, &ws.FieldsWorked); err != nil {
+			return nil, err
+		}
+		report.WorkerStats = append(report.WorkerStats, ws)
+	}
+
+	// Get field statistics
+	fieldRows, err := s.db.Query(`
+		SELECT o.field_id, f.name, COUNT(*),
+			   COALESCE(SUM(`+operationHoursSQL+`), 0),
+			   COALESCE(SUM(`+coveredAreaSQL+`), 0),
+			   COUNT(DISTINCT o.worker_id)
+		FROM operations o
+		JOIN fields f ON o.field_id = f.id
+		`+fieldVersionJoin+`
+		WHERE o.start_time >= $1 AND o.start_time < $2`+reportFilterSQL(3)+`
+		GROUP BY o.field_id, f.name
+		ORDER BY f.name`, from, to, s.org, filter.regionArg())
+	if err != nil {
+		return nil, err
+	}
+	defer fieldRows.Close()
+
+	for fieldRows.Next() {
+		var fs FieldDailyStats
+		if err := fieldRows.Scan(&fs.FieldID, &fs.FieldName, &fs.Operations, &fs.HoursWorked, &fs.DecaresWorked, &fs.WorkersCount); err != nil {
+			return nil, err
+		}
+		report.FieldStats = append(report.FieldStats, fs)
+	}
+
+	// Get region statistics
+	regionRows, err := s.db.Query(`
+		SELECT COALESCE(f.region_id, 0), COALESCE(r.name, ''), COUNT(*),
+			   COALESCE(SUM(`+operationHoursSQL+`), 0),
+			   COALESCE(SUM(`+coveredAreaSQL+`), 0),
+			   COUNT(DISTINCT o.field_id),
+			   COUNT(DISTINCT o.worker_id)
+		FROM operations o
+		JOIN fields f ON o.field_id = f.id
+		LEFT JOIN regions r ON r.id = f.region_id
+		`+fieldVersionJoin+`
+		WHERE o.start_time >= $1 AND o.start_time < $2`+reportFilterSQL(3)+`
+		GROUP BY f.region_id, r.name
+		ORDER BY 2, 1`, from, to, s.org, filter.regionArg())
+	if err != nil {
+		return nil, err
+	}
+	defer regionRows.Close()
+
+	for regionRows.Next() {
+		var rs RegionStats
+		if err := regionRows.Scan(&rs.RegionID, &rs.RegionName, &rs.Operations, &rs.HoursWorked, &rs.DecaresWorked, &rs.FieldsWorked, &rs.WorkersCount); err != nil {
+			return nil, err
+		}
+		report.RegionStats = append(report.RegionStats, rs)
+	}
+
+	return report, nil
+}
+
+func rangeReport(src reportSource, from, to time.Time, groupBy []string, filter ReportFilter) (*RangeReport, error) {
+	totals, err := src.getRangeReportRows(from, to, nil, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &RangeReport{
+		From:    from,
+		To:      to,
+		GroupBy: groupBy,
+		Totals:  totals[0],
+		Rows:    []RangeReportRow{},
+	}
+	if len(groupBy) == 0 {
+		return report, nil
+	}
+
+	report.Rows, err = src.getRangeReportRows(from, to, groupBy, filter)
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func (s *PostgresStore) getRangeReportRows(from, to time.Time, groupBy []string, filter ReportFilter) ([]RangeReportRow, error) {
+	var values, groups, order []string
+	for i, name := range groupBy {
+		dim, ok := reportDimensions[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown group_by dimension %q", name)
+		}
+		values = append(values, "COALESCE("+dim.value+", '')")
+		groups = append(groups, dim.group)
+		order = append(order, fmt.Sprint(i+1))
+	}
+
+	query := `SELECT `
+	for _, v := range values {
+		query += v + `, `
+	}
+	query += `COUNT(*),
+			   COUNT(CASE WHEN o.status = 'completed' THEN 1 END),
+			   COUNT(CASE WHEN o.status = 'in_progress' THEN 1 END),
+			   COALESCE(SUM(` + operationHoursSQL + `), 0),
+			   COUNT(DISTINCT o.worker_id),
+			   COUNT(DISTINCT o.field_id)
+		FROM operations o
+		LEFT JOIN workers w ON o.worker_id = w.id
+		LEFT JOIN fields f ON o.field_id = f.id
+		LEFT JOIN regions r ON r.id = f.region_id
+		WHERE o.start_time >= $1 AND o.start_time < $2` + reportFilterSQL(3)
+	if len(groups) > 0 {
+		query += `
+		GROUP BY ` + strings.Join(groups, ", ") + `
+		ORDER BY ` + strings.Join(order, ", ")
+	}
+
+	rows, err := s.db.Query(query, from, to, s.org, filter.regionArg())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []RangeReportRow
+	for rows.Next() {
+		row := RangeReportRow{Keys: make(map[string]string)}
+		keys := make([]sql.NullString, len(groupBy))
+		dest := make([]interface{}, 0, len(groupBy)+6)
+		for i := range keys {
+			dest = append(dest, &keys[i])
+		}
+		dest = append(dest, &row.Operations, &row.CompletedOps, &row.InProgressOps, &row.HoursWorked, &row.WorkersCount, &row.FieldsCount)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		for i, name := range groupBy {
+			row.Keys[name] = keys[i].String
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// ReportEntry is a single operation as listed in the detail sheets of a report export.
+type ReportEntry struct {
+	OperationID int        `json:"operation_id"`
+	WorkerID    int        `json:"worker_id"`
+	WorkerName  string     `json:"worker_name"`
+	FieldID     int        `json:"field_id"`
+	FieldName   string     `json:"field_name"`
+	Type        string     `json:"type"`
+	Status      string     `json:"status"`
+	StartTime   *time.Time `json:"start_time"`
+	EndTime     *time.Time `json:"end_time"`
+	HoursWorked float64    `json:"hours_worked"`
+
+	// Boundary is the GeoJSON boundary of the field when the operation was done
+	Boundary json.RawMessage `json:"boundary,omitempty"`
+}
+
+// GetReportEntries lists the operations started in [from, to) in chronological order.
+func (s *PostgresStore) GetReportEntries(from, to time.Time, filter ReportFilter) ([]ReportEntry, error) {
+	rows, err := s.db.Query(`
+		SELECT o.id, COALESCE(o.worker_id, 0), COALESCE(w.name, ''), o.field_id, COALESCE(f.name, ''), o.type, o.status,
+			   o.start_time, o.end_time, COALESCE(`+operationHoursSQL+`, 0), COALESCE(fb.coordinates, f.coordinates)
+		FROM operations o
+		LEFT JOIN workers w ON o.worker_id = w.id
+		LEFT JOIN fields f ON o.field_id = f.id
+		`+fieldVersionJoin+`
+		WHERE o.start_time >= $1 AND o.start_time < $2`+reportFilterSQL(3)+`
+		ORDER BY o.start_time, o.id`, from, to, s.org, filter.regionArg())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []ReportEntry
+	for rows.Next() {
+		var e ReportEntry
+		if err := rows.Scan(&e.OperationID, &e.WorkerID, &e.WorkerName, &e.FieldID, &e.FieldName, &e.Type, &e.Status,
+			&e.StartTime, &e.EndTime, &e.HoursWorked, &e.Boundary); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
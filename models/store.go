@@ -0,0 +1,156 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// The stores below are implemented by PostgresStore and MemoryStore. Lookups
+// of a missing record return sql.ErrNoRows from both.
+
+type WorkerStore interface {
+	CreateWorker(worker *Worker) error
+	GetWorkers() ([]Worker, error)
+	GetWorkerByID(id int) (*Worker, error)
+	UpdateWorker(worker *Worker) error
+	DeleteWorker(id int) error
+	AddMember(workerID int, accessRole string) error
+	InviteMember(workerID int, accessRole, invitedBy string) error
+}
+
+// OrganizationStore methods work on any store. ForOrganization returns the
+// store for the data of one organization, which all the other stores are
+// limited to.
+type OrganizationStore interface {
+	// Setup creates the first organization with its owner on a fresh
+	// install, or returns ErrAlreadySetUp.
+	Setup(org *Organization, owner *Worker, passwordHash string) error
+	CreateOrganization(org *Organization) error
+	GetOrganizations() ([]Organization, error)
+	GetOrganizationByID(id int) (*Organization, error)
+	UpdateOrganization(org *Organization) error
+	GetWorkerOrganizations(workerID int) ([]Membership, error)
+	GetWorkerInvitations(workerID int) ([]Invitation, error)
+	AcceptInvitation(workerID, orgID int) (*Membership, error)
+	DeclineInvitation(workerID, orgID int) error
+	ForOrganization(id int) Store
+}
+
+// AccountStore keeps the credentials workers log in with.
+type AccountStore interface {
+	GetWorkerAccount(login string) (*WorkerAccount, error)
+	GetWorkerAccountByID(id int) (*WorkerAccount, error)
+	SetWorkerPassword(workerID int, passwordHash string) error
+	CreateLoginCode(code *LoginCode, resendAfter time.Duration) error
+	UseLoginCode(workerID int, codeHash string, now time.Time) error
+	CreateRefreshToken(token *RefreshToken) error
+	RotateRefreshToken(id string, next *RefreshToken, now time.Time) error
+	RevokeRefreshTokens(workerID int) error
+}
+
+type FieldStore interface {
+	CreateField(field *Field) error
+	CreateFields(fields []*Field) error
+	GetFields() ([]Field, error)
+	GetFieldByID(id int) (*Field, error)
+	UpdateField(field *Field) error
+	DeleteField(id int) error
+	GetFieldCoverage(fieldID int, from, to *time.Time) ([]FieldCoverage, error)
+	SplitField(id int, parts []*Field) error
+	MergeFields(ids []int, merged *Field) error
+	GetFieldVersions(id int) ([]FieldVersion, error)
+	GetFieldLineage(id int) (*FieldLineage, error)
+}
+
+type RegionStore interface {
+	CreateRegion(region *Region) error
+	GetRegions() ([]Region, error)
+	GetRegionByID(id int) (*Region, error)
+	UpdateRegion(region *Region) error
+	DeleteRegion(id int) error
+}
+
+// ScheduleStore allows one schedule per worker and date; CreateSchedule and
+// UpdateSchedule return a *ConflictError for another.
+type ScheduleStore interface {
+	CreateSchedule(schedule *Schedule) error
+	GetSchedules() ([]Schedule, error)
+	GetScheduleByID(id int) (*Schedule, error)
+	GetWorkerSchedules(workerID int) ([]Schedule, error)
+	UpdateSchedule(schedule *Schedule) error
+	DeleteSchedule(id int) error
+}
+
+// OperationStore methods that change an operation record who made the change
+// (actor) in the operation history. Those that could put a worker on two
+// operations at the same time return a *ConflictError instead. Operations are
+// created planned and CreateOperation and UpdateOperation return
+// ErrStatusChange for any other status; the transition methods move them on.
+// UpdateOperation keeps the current worker when WorkerID is 0, so operations
+// only leave their worker through RejectOperation. StartNewOperation creates
+// an operation on the schedule, and the schedule itself when its ID is 0, and
+// starts it; either all of that is stored or none of it.
+type OperationStore interface {
+	CreateOperation(operation *Operation, actor string) error
+	StartNewOperation(schedule *Schedule, operation *Operation, actor string) error
+	GetOperations() ([]Operation, error)
+	GetOperationByID(id int) (*Operation, error)
+	GetWorkerOperations(workerID int, date time.Time) ([]Operation, error)
+	UpdateOperation(operation *Operation, actor string) error
+	DeleteOperation(id int, actor string) error
+	StartOperation(id int, actor string) error
+	PauseOperation(id int, actor string) error
+	ResumeOperation(id int, actor string) error
+	CompleteOperation(id int, actor string) error
+	CancelOperation(id int, actor string) error
+	RejectOperation(id int, reason, actor string) error
+	AssignOperation(id, workerID int, scheduleID *int, actor string) error
+	GetUnassignedOperations() ([]Operation, error)
+	GetLastCompletedOperations() (map[int]Operation, error)
+	SetOperationCoverage(id int, coveredArea *float64, coverage json.RawMessage, actor string) error
+	AddOperationTrack(track *Track, summary TrackSummary, actor string) error
+	GetOperationTracks(operationID int) ([]Track, error)
+	GetOperationEvents(operationID int) ([]OperationEvent, error)
+}
+
+// ReportStore methods only count the operations that pass the filter.
+type ReportStore interface {
+	GetDailyReport(date time.Time, filter ReportFilter) (*DailyReport, error)
+	GetMonthlyReport(year int, month time.Month, filter ReportFilter) (*MonthlyReport, error)
+	GetYearlyReport(year int, filter ReportFilter) (*YearlyReport, error)
+	GetPeriodBreakdown(unit string, from, to time.Time, filter ReportFilter) ([]PeriodStats, error)
+	GetRangeReport(from, to time.Time, groupBy []string, filter ReportFilter) (*RangeReport, error)
+	GetReportEntries(from, to time.Time, filter ReportFilter) ([]ReportEntry, error)
+}
+
+// DetectionStore keeps the machine tracks uploaded for the track analyzer and
+// the operations it proposes from them.
+type DetectionStore interface {
+	CreateMachineTrack(track *MachineTrack) error
+	GetMachineTracks() ([]MachineTrack, error)
+	GetMachineTrackByID(id int) (*MachineTrack, error)
+	GetPendingMachineTracks(limit int) ([]MachineTrack, error)
+	SaveTrackAnalysis(trackID int, proposals []OperationProposal) error
+	GetOperationProposals(status string) ([]OperationProposal, error)
+	GetOperationProposalByID(id int) (*OperationProposal, error)
+	ConfirmOperationProposal(id int, operationType, actor string) (int, error)
+	DismissOperationProposal(id int, actor string) error
+}
+
+// Store is everything the API needs from the storage layer.
+type Store interface {
+	OrganizationStore
+	WorkerStore
+	AccountStore
+	FieldStore
+	RegionStore
+	ScheduleStore
+	OperationStore
+	ReportStore
+	DetectionStore
+}
+
+var (
+	_ Store = (*PostgresStore)(nil)
+	_ Store = (*MemoryStore)(nil)
+)
@@ -0,0 +1,128 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Track is a GPS track recorded while working an operation, e.g. uploaded
+// from a tractor's logger. An operation worked over several days has one
+// track per upload.
+type Track struct {
+	ID             int          `json:"id"`
+	OperationID    int          `json:"operation_id"`
+	Points         []TrackPoint `json:"points,omitempty"`
+	StartedAt      time.Time    `json:"started_at"`
+	EndedAt        time.Time    `json:"ended_at"`
+	DistanceMeters float64      `json:"distance_m"`
+	WidthMeters    *float64     `json:"width_m"`      // working width of the implement, if known
+	CoveredArea    *float64     `json:"covered_area"` // decares of the field this track covered, needs WidthMeters
+	CreatedAt      time.Time    `json:"created_at"`
+}
+
+type TrackPoint struct {
+	Lon  float64   `json:"lon"`
+	Lat  float64   `json:"lat"`
+	Time time.Time `json:"time"`
+}
+
+// TrackSummary is what the tracks of an operation show about it: when work
+// actually started and ended and, if any track has a working width, how much
+// of the field was covered.
+type TrackSummary struct {
+	StartTime   time.Time
+	EndTime     time.Time
+	CoveredArea *float64
+}
+
+// AddOperationTrack stores a track and updates the operation with the summary
+// of all its tracks, recording the change in the operation history.
+func (s *PostgresStore) AddOperationTrack(track *Track, summary TrackSummary, actor string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	old, err := lockOperation(tx, s.org, track.OperationID)
+	if err != nil {
+		return err
+	}
+
+	points, err := json.Marshal(track.Points)
+	if err != nil {
+		return err
+	}
+	err = tx.QueryRow(`INSERT INTO operation_tracks (operation_id, points, started_at, ended_at, distance_m, width_m, covered_area)
+					   VALUES ($1, $2, $3, $4, $5, $6, $7)
+					   RETURNING id, created_at`,
+		track.OperationID, points, track.StartedAt, track.EndedAt, track.DistanceMeters, track.WidthMeters, track.CoveredArea).
+		Scan(&track.ID, &track.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	updated := summarizeTracks(old, summary)
+	_, err = tx.Exec(`UPDATE operations SET start_time = $1, end_time = $2, covered_area = $3,
+					  coverage = CASE WHEN $4 THEN NULL ELSE coverage END, updated_at = CURRENT_TIMESTAMP
+					  WHERE id = $5`,
+		updated.StartTime, updated.EndTime, updated.CoveredArea, summary.CoveredArea != nil, track.OperationID)
+	if err != nil {
+		return err
+	}
+	if err := checkOperationConflicts(tx, s.org, track.OperationID); err != nil {
+		return err
+	}
+
+	event := &OperationEvent{
+		OperationID: track.OperationID,
+		Event:       EventTrackAdded,
+		OldStatus:   old.Status,
+		NewStatus:   old.Status,
+		Changes:     operationChanges(old, updated),
+		Actor:       actor,
+	}
+	if err := recordOperationEvent(tx, s.org, event); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// summarizeTracks returns the operation as updated by a track summary. A
+// covered area measured from tracks replaces any recorded coverage polygon.
+func summarizeTracks(o *Operation, summary TrackSummary) *Operation {
+	updated := *o
+	start, end := summary.StartTime, summary.EndTime
+	updated.StartTime, updated.EndTime = &start, &end
+	if summary.CoveredArea != nil {
+		updated.CoveredArea, updated.Coverage = summary.CoveredArea, nil
+	}
+	return &updated
+}
+
+// GetOperationTracks lists the tracks of an operation in the order they were driven.
+func (s *PostgresStore) GetOperationTracks(operationID int) ([]Track, error) {
+	rows, err := s.db.Query(`SELECT t.id, t.operation_id, t.points, t.started_at, t.ended_at, t.distance_m, t.width_m, t.covered_area, t.created_at
+							 FROM operation_tracks t JOIN operations o ON o.id = t.operation_id AND o.organization_id = $2
+							 WHERE t.operation_id = $1 ORDER BY t.started_at, t.id`, operationID, s.org)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tracks := []Track{}
+	for rows.Next() {
+		var t Track
+		var points []byte
+		err := rows.Scan(&t.ID, &t.OperationID, &points, &t.StartedAt, &t.EndedAt, &t.DistanceMeters,
+			&t.WidthMeters, &t.CoveredArea, &t.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(points, &t.Points); err != nil {
+			return nil, err
+		}
+		tracks = append(tracks, t)
+	}
+	return tracks, nil
+}
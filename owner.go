@@ -0,0 +1,68 @@
+package main
+
+import (
+	"agroport/auth"
+	"agroport/models"
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+)
+
+// runOwner handles the owner subcommand, which makes a worker an owner of an
+// organization, e.g. the first one after upgrading from a version without
+// access roles:
+//
+//	main owner <login> [organization_id]
+//
+// The organization may be left out for workers who belong to only one.
+func runOwner(db *sql.DB, args []string) error {
+	if len(args) == 0 || len(args) > 2 {
+		return fmt.Errorf("usage: owner <login> [organization_id]")
+	}
+	store := models.NewPostgresStore(db)
+
+	account, err := store.GetWorkerAccount(args[0])
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("no worker logs in as %q", args[0])
+	}
+	if err != nil {
+		return err
+	}
+	memberships, err := store.GetWorkerOrganizations(account.ID)
+	if err != nil {
+		return err
+	}
+
+	var org *models.Organization
+	if len(args) == 2 {
+		id, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid organization ID %q", args[1])
+		}
+		for i := range memberships {
+			if memberships[i].Organization.ID == id {
+				org = &memberships[i].Organization
+			}
+		}
+		if org == nil {
+			return fmt.Errorf("%s does not belong to organization %d", account.Name, id)
+		}
+	} else if len(memberships) == 1 {
+		org = &memberships[0].Organization
+	} else {
+		return fmt.Errorf("%s belongs to %d organizations; give the organization ID", account.Name, len(memberships))
+	}
+
+	scoped := store.ForOrganization(org.ID)
+	worker, err := scoped.GetWorkerByID(account.ID)
+	if err != nil {
+		return err
+	}
+	worker.AccessRole = auth.RoleOwner
+	if err := scoped.UpdateWorker(worker); err != nil {
+		return err
+	}
+	log.Printf("%s is an owner of %s", worker.Name, org.Name)
+	return nil
+}
@@ -0,0 +1,69 @@
+package render
+
+import (
+	"encoding/csv"
+	"io"
+)
+
+// CSV renders the summary and every table as consecutive blocks separated by
+// an empty line. Each table block starts with its title followed by a header
+// row; export a single table to get a plain file that other systems can import.
+type CSV struct{}
+
+func (CSV) ContentType() string {
+	return "text/csv; charset=utf-8"
+}
+
+func (CSV) Extension() string {
+	return "csv"
+}
+
+func (CSV) Render(w io.Writer, doc *Document) error {
+	cw := csv.NewWriter(w)
+	blocks := 0
+
+	if len(doc.Summary) > 0 {
+		cw.Write([]string{"metric", "value"})
+		for _, m := range doc.Summary {
+			cw.Write([]string{m.Key, formatValue(m.Kind, m.Value)})
+		}
+		blocks++
+	}
+
+	var tables []Table
+	for _, t := range doc.Tables {
+		if !t.Breakdown {
+			tables = append(tables, t)
+		}
+	}
+
+	single := blocks == 0 && len(tables) == 1
+	for _, t := range tables {
+		if blocks > 0 {
+			cw.Write(nil)
+		}
+		if !single {
+			cw.Write([]string{t.Title})
+		}
+
+		header := make([]string, len(t.Columns))
+		for i, c := range t.Columns {
+			header[i] = c.Key
+		}
+		cw.Write(header)
+
+		for _, values := range t.Rows {
+			record := make([]string, len(t.Columns))
+			for i, c := range t.Columns {
+				if i < len(values) {
+					record[i] = formatValue(c.Kind, values[i])
+				}
+			}
+			cw.Write(record)
+		}
+		blocks++
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
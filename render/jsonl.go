@@ -0,0 +1,52 @@
+package render
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// JSONLines renders one JSON object per line: a single "summary" object
+// followed by one object per table row, tagged with the table name.
+type JSONLines struct{}
+
+func (JSONLines) ContentType() string {
+	return "application/x-ndjson"
+}
+
+func (JSONLines) Extension() string {
+	return "jsonl"
+}
+
+func (JSONLines) Render(w io.Writer, doc *Document) error {
+	enc := json.NewEncoder(w)
+
+	if len(doc.Summary) > 0 {
+		line := map[string]interface{}{"table": "summary"}
+		for _, m := range doc.Summary {
+			line[m.Key] = plainValue(m.Kind, m.Value)
+		}
+		if err := enc.Encode(line); err != nil {
+			return err
+		}
+	}
+
+	for _, t := range doc.Tables {
+		if t.Breakdown {
+			continue
+		}
+		for _, values := range t.Rows {
+			line := map[string]interface{}{"table": t.Name}
+			for i, c := range t.Columns {
+				var value interface{}
+				if i < len(values) {
+					value = plainValue(c.Kind, values[i])
+				}
+				line[c.Key] = value
+			}
+			if err := enc.Encode(line); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package render
+
+import (
+	_ "embed"
+	"io"
+	"math"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+//go:embed fonts/DejaVuSansCondensed.ttf
+var regularFont []byte
+
+//go:embed fonts/DejaVuSansCondensed-Bold.ttf
+var boldFont []byte
+
+const (
+	pdfFont      = "DejaVu"
+	pdfMargin    = 15.0
+	pdfMapHeight = 90.0
+	pdfRowHeight = 6.0
+)
+
+// PDF renders a printable report laid out like the report screen of the
+// mobile app: a map of the fields involved, the summary figures as a list
+// and then every table.
+type PDF struct{}
+
+func (PDF) ContentType() string {
+	return "application/pdf"
+}
+
+func (PDF) Extension() string {
+	return "pdf"
+}
+
+func (PDF) Render(w io.Writer, doc *Document) error {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddUTF8FontFromBytes(pdfFont, "", regularFont)
+	pdf.AddUTF8FontFromBytes(pdfFont, "B", boldFont)
+	pdf.SetMargins(pdfMargin, pdfMargin, pdfMargin)
+	pdf.SetAutoPageBreak(true, pdfMargin)
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-10)
+		pdf.SetFont(pdfFont, "", 8)
+		pdf.SetTextColor(128, 128, 128)
+		width := contentWidth(pdf)
+		pdf.CellFormat(width/2, 5, doc.Title, "", 0, "L", false, 0, "")
+		pdf.CellFormat(width/2, 5, formatValue(Int, pdf.PageNo()), "", 0, "R", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+	})
+	pdf.AddPage()
+
+	pdf.SetFont(pdfFont, "B", 16)
+	pdf.CellFormat(0, 8, doc.Title, "", 1, "L", false, 0, "")
+	if doc.Subtitle != "" {
+		pdf.SetFont(pdfFont, "", 10)
+		pdf.CellFormat(0, 6, doc.Subtitle, "", 1, "L", false, 0, "")
+	}
+	pdf.Ln(4)
+
+	if len(doc.Shapes) > 0 {
+		drawMap(pdf, doc.Shapes)
+		pdf.Ln(6)
+	}
+
+	if len(doc.Summary) > 0 {
+		width := contentWidth(pdf)
+		pdf.SetFont(pdfFont, "", 11)
+		pdf.SetDrawColor(0, 0, 0)
+		for _, m := range doc.Summary {
+			pdf.CellFormat(width*0.6, 9, m.Label, "B", 0, "L", false, 0, "")
+			pdf.CellFormat(width*0.4, 9, formatValue(m.Kind, m.Value), "B", 1, "R", false, 0, "")
+		}
+		pdf.Ln(6)
+	}
+
+	for _, t := range doc.Tables {
+		if t.Breakdown {
+			continue
+		}
+		drawTable(pdf, t)
+		pdf.Ln(6)
+	}
+
+	return pdf.Output(w)
+}
+
+func contentWidth(pdf *gofpdf.Fpdf) float64 {
+	pageWidth, _ := pdf.GetPageSize()
+	left, _, right, _ := pdf.GetMargins()
+	return pageWidth - left - right
+}
+
+// drawMap draws the shapes scaled to fit a frame spanning the page width,
+// using an equirectangular projection around the centre of the shapes.
+func drawMap(pdf *gofpdf.Fpdf, shapes []Shape) {
+	left, _, _, _ := pdf.GetMargins()
+	top := pdf.GetY()
+	width := contentWidth(pdf)
+
+	pdf.SetDrawColor(0, 0, 0)
+	pdf.SetLineWidth(0.4)
+	pdf.Rect(left, top, width, pdfMapHeight, "D")
+
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, s := range shapes {
+		for _, polygon := range s.Polygons {
+			for _, ring := range polygon {
+				for _, p := range ring {
+					minX, maxX = math.Min(minX, p[0]), math.Max(maxX, p[0])
+					minY, maxY = math.Min(minY, p[1]), math.Max(maxY, p[1])
+				}
+			}
+		}
+	}
+	if math.IsInf(minX, 0) {
+		pdf.SetY(top + pdfMapHeight)
+		return
+	}
+
+	cosLat := math.Cos((minY + maxY) / 2 * math.Pi / 180)
+	spanX := math.Max((maxX-minX)*cosLat, 1e-9)
+	spanY := math.Max(maxY-minY, 1e-9)
+	padding := 6.0
+	scale := math.Min((width-2*padding)/spanX, (pdfMapHeight-2*padding)/spanY)
+	offsetX := left + (width-spanX*scale)/2
+	offsetY := top + (pdfMapHeight-spanY*scale)/2
+	project := func(p [2]float64) gofpdf.PointType {
+		return gofpdf.PointType{
+			X: offsetX + (p[0]-minX)*cosLat*scale,
+			Y: offsetY + (maxY-p[1])*scale,
+		}
+	}
+
+	pdf.SetLineWidth(0.3)
+	pdf.SetFont(pdfFont, "B", 8)
+	for _, s := range shapes {
+		x0, y0, x1, y1 := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+		for _, polygon := range s.Polygons {
+			for i, ring := range polygon {
+				points := make([]gofpdf.PointType, len(ring))
+				for j, p := range ring {
+					points[j] = project(p)
+					if i == 0 {
+						x0, x1 = math.Min(x0, points[j].X), math.Max(x1, points[j].X)
+						y0, y1 = math.Min(y0, points[j].Y), math.Max(y1, points[j].Y)
+					}
+				}
+				if i == 0 {
+					pdf.SetFillColor(214, 234, 200)
+				} else {
+					pdf.SetFillColor(255, 255, 255)
+				}
+				pdf.Polygon(points, "DF")
+			}
+		}
+		if s.Label != "" && !math.IsInf(x0, 0) {
+			label := fitText(pdf, s.Label, math.Max(x1-x0, 12))
+			pdf.Text((x0+x1)/2-pdf.GetStringWidth(label)/2, (y0+y1)/2+1.5, label)
+		}
+	}
+
+	pdf.SetLineWidth(0.2)
+	pdf.SetY(top + pdfMapHeight)
+}
+
+func drawTable(pdf *gofpdf.Fpdf, t Table) {
+	width := contentWidth(pdf)
+	_, pageHeight := pdf.GetPageSize()
+
+	// Text columns get twice the width of numeric ones
+	var weights float64
+	for _, c := range t.Columns {
+		weights += columnWeight(c)
+	}
+	widths := make([]float64, len(t.Columns))
+	for i, c := range t.Columns {
+		widths[i] = width * columnWeight(c) / weights
+	}
+
+	header := func() {
+		pdf.SetFont(pdfFont, "B", 9)
+		pdf.SetFillColor(230, 230, 230)
+		for i, c := range t.Columns {
+			pdf.CellFormat(widths[i], pdfRowHeight, fitText(pdf, c.Title, widths[i]), "1", 0, "C", true, 0, "")
+		}
+		pdf.Ln(-1)
+		pdf.SetFont(pdfFont, "", 9)
+	}
+
+	if pdf.GetY()+3*pdfRowHeight > pageHeight-pdfMargin {
+		pdf.AddPage()
+	}
+	pdf.SetFont(pdfFont, "B", 12)
+	pdf.CellFormat(0, 8, t.Title, "", 1, "L", false, 0, "")
+	header()
+
+	for _, values := range t.Rows {
+		if pdf.GetY()+pdfRowHeight > pageHeight-pdfMargin {
+			pdf.AddPage()
+			header()
+		}
+		for i, c := range t.Columns {
+			var value interface{}
+			if i < len(values) {
+				value = values[i]
+			}
+			align := "R"
+			if c.Kind == Text {
+				align = "L"
+			}
+			pdf.CellFormat(widths[i], pdfRowHeight, fitText(pdf, formatValue(c.Kind, value), widths[i]), "1", 0, align, false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+}
+
+func columnWeight(c Column) float64 {
+	if c.Kind == Text || c.Kind == DateTime {
+		return 2
+	}
+	return 1
+}
+
+// fitText shortens s with an ellipsis until it fits in a cell of the given width.
+func fitText(pdf *gofpdf.Fpdf, s string, width float64) string {
+	const padding = 2.0
+	if pdf.GetStringWidth(s) <= width-padding {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && pdf.GetStringWidth(string(runes)+"…") > width-padding {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "…"
+}
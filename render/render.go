@@ -0,0 +1,171 @@
+// Package render turns report documents into downloadable files.
+//
+// Handlers describe an export once as a Document made of summary metrics and
+// tables, and a Renderer for the requested format (xlsx, csv, jsonl, pdf)
+// writes it out. New formats are added with Register.
+package render
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Kind tells renderers how a value should be typed and formatted.
+type Kind int
+
+const (
+	Text Kind = iota
+	Int
+	Decimal
+	Date
+	DateTime
+	Month
+)
+
+type Column struct {
+	Key   string
+	Title string
+	Kind  Kind
+}
+
+// Table is a named block of rows. Each row holds one value per column;
+// nil values are rendered as empty cells.
+//
+// Breakdown tables repeat rows of another table split per worker, field etc.
+// They only make sense as separate worksheets, so the other formats skip them.
+type Table struct {
+	Name      string
+	Title     string
+	Sheet     string // tables with the same sheet share a worksheet in xlsx exports
+	Breakdown bool
+	Columns   []Column
+	Rows      [][]interface{}
+}
+
+type Metric struct {
+	Key   string
+	Label string
+	Kind  Kind
+	Value interface{}
+}
+
+// Shape is a field outline drawn on the map of a printed report. Each polygon
+// is an outer ring followed by its holes, as [longitude, latitude] pairs.
+type Shape struct {
+	Label    string
+	Polygons [][][][2]float64
+}
+
+type Document struct {
+	Title    string
+	Subtitle string
+	Sheet    string // worksheet holding the title and summary
+	Summary  []Metric
+	Tables   []Table
+	Shapes   []Shape
+}
+
+// Only returns a copy of the document containing just the named table,
+// or just the summary when name is "summary".
+func (d *Document) Only(name string) (*Document, bool) {
+	only := &Document{Title: d.Title, Subtitle: d.Subtitle, Sheet: d.Sheet}
+	if name == "summary" {
+		only.Summary = d.Summary
+		return only, true
+	}
+	for _, t := range d.Tables {
+		if t.Name == name && !t.Breakdown {
+			only.Tables = append(only.Tables, t)
+		}
+	}
+	return only, len(only.Tables) > 0
+}
+
+type Renderer interface {
+	ContentType() string
+	Extension() string
+	Render(w io.Writer, doc *Document) error
+}
+
+var renderers = map[string]Renderer{}
+
+// Register makes a renderer available under the given format name.
+func Register(format string, r Renderer) {
+	renderers[format] = r
+}
+
+func ForFormat(format string) (Renderer, bool) {
+	r, ok := renderers[format]
+	return r, ok
+}
+
+// Formats lists the registered format names.
+func Formats() []string {
+	formats := make([]string, 0, len(renderers))
+	for format := range renderers {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+func init() {
+	Register("xlsx", XLSX{})
+	Register("csv", CSV{})
+	Register("jsonl", JSONLines{})
+	Register("pdf", PDF{})
+}
+
+// formatValue renders a value as text, as used by the csv and pdf renderers.
+func formatValue(kind Kind, value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case *time.Time:
+		if v == nil {
+			return ""
+		}
+		return formatValue(kind, *v)
+	case time.Time:
+		switch kind {
+		case DateTime:
+			return v.Format("2006-01-02 15:04")
+		case Month:
+			return v.Format("2006-01")
+		default:
+			return v.Format("2006-01-02")
+		}
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', 2, 64)
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// plainValue converts a value to what is written to JSON.
+func plainValue(kind Kind, value interface{}) interface{} {
+	switch v := value.(type) {
+	case *time.Time:
+		if v == nil {
+			return nil
+		}
+		return plainValue(kind, *v)
+	case time.Time:
+		if kind == DateTime {
+			return v.Format(time.RFC3339)
+		}
+		return formatValue(kind, v)
+	case float64:
+		return math.Round(v*100) / 100
+	default:
+		return v
+	}
+}
@@ -0,0 +1,159 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testDay = time.Date(2026, 5, 4, 8, 30, 0, 0, time.UTC)
+
+func testDocument() *Document {
+	return &Document{
+		Title: "Report",
+		Summary: []Metric{
+			{Key: "hours", Label: "Hours", Kind: Decimal, Value: 12.345},
+			{Key: "operations", Label: "Operations", Kind: Int, Value: 3},
+		},
+		Tables: []Table{
+			{
+				Name:  "workers",
+				Title: "Workers",
+				Columns: []Column{
+					{Key: "name", Title: "Name", Kind: Text},
+					{Key: "hours", Title: "Hours", Kind: Decimal},
+					{Key: "last", Title: "Last", Kind: DateTime},
+				},
+				Rows: [][]interface{}{
+					{"Ivan", 8.5, testDay},
+					{"Petar", 3.845, nil},
+				},
+			},
+			{
+				Name:      "by_field",
+				Title:     "By field",
+				Breakdown: true,
+				Columns:   []Column{{Key: "field", Title: "Field", Kind: Text}},
+				Rows:      [][]interface{}{{"North"}},
+			},
+		},
+	}
+}
+
+func TestFormatValue(t *testing.T) {
+	var nilTime *time.Time
+	tests := []struct {
+		kind  Kind
+		value interface{}
+		want  string
+	}{
+		{Text, nil, ""},
+		{Text, "plowing", "plowing"},
+		{Int, 7, "7"},
+		{Decimal, 2.0 / 3, "0.67"},
+		{Date, testDay, "2026-05-04"},
+		{DateTime, testDay, "2026-05-04 08:30"},
+		{Month, testDay, "2026-05"},
+		{DateTime, &testDay, "2026-05-04 08:30"},
+		{DateTime, nilTime, ""},
+		{Text, true, "true"},
+	}
+	for _, tt := range tests {
+		if got := formatValue(tt.kind, tt.value); got != tt.want {
+			t.Errorf("formatValue(%v, %#v) = %q, want %q", tt.kind, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestCSV(t *testing.T) {
+	single, _ := testDocument().Only("workers")
+	tests := []struct {
+		name string
+		doc  *Document
+		want string
+	}{
+		{
+			name: "document",
+			doc:  testDocument(),
+			want: "metric,value\nhours,12.35\noperations,3\n\nWorkers\nname,hours,last\nIvan,8.50,2026-05-04 08:30\nPetar,3.85,\n",
+		},
+		{
+			name: "single table",
+			doc:  single,
+			want: "name,hours,last\nIvan,8.50,2026-05-04 08:30\nPetar,3.85,\n",
+		},
+		{
+			name: "short row",
+			doc: &Document{Tables: []Table{{
+				Columns: []Column{{Key: "a"}, {Key: "b"}},
+				Rows:    [][]interface{}{{"x"}},
+			}}},
+			want: "a,b\nx,\n",
+		},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := (CSV{}).Render(&buf, tt.doc); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if buf.String() != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, buf.String(), tt.want)
+		}
+	}
+}
+
+func TestJSONLines(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (JSONLines{}).Render(&buf, testDocument()); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	want := []map[string]interface{}{
+		{"table": "summary", "hours": 12.35, "operations": float64(3)},
+		{"table": "workers", "name": "Ivan", "hours": 8.5, "last": "2026-05-04T08:30:00Z"},
+		{"table": "workers", "name": "Petar", "hours": 3.85, "last": nil},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("got %d lines, want %d:\n%s", len(lines), len(want), buf.String())
+	}
+	for i, line := range lines {
+		var got map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &got); err != nil {
+			t.Fatalf("line %d: %v", i+1, err)
+		}
+		if len(got) != len(want[i]) {
+			t.Errorf("line %d is %v, want %v", i+1, got, want[i])
+			continue
+		}
+		for k, v := range want[i] {
+			if got[k] != v {
+				t.Errorf("line %d: %s is %v, want %v", i+1, k, got[k], v)
+			}
+		}
+	}
+}
+
+func TestOnly(t *testing.T) {
+	doc := testDocument()
+	tests := []struct {
+		name    string
+		ok      bool
+		summary int
+		tables  int
+	}{
+		{"summary", true, 2, 0},
+		{"workers", true, 0, 1},
+		{"by_field", false, 0, 0},
+		{"missing", false, 0, 0},
+	}
+	for _, tt := range tests {
+		only, ok := doc.Only(tt.name)
+		if ok != tt.ok || len(only.Summary) != tt.summary || len(only.Tables) != tt.tables {
+			t.Errorf("Only(%q) has %d metrics and %d tables (%v), want %d and %d (%v)",
+				tt.name, len(only.Summary), len(only.Tables), ok, tt.summary, tt.tables, tt.ok)
+		}
+	}
+}
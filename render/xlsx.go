@@ -0,0 +1,187 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/tealeg/xlsx/v3"
+)
+
+// XLSX renders a document as an Excel workbook. The title and summary go on
+// the document sheet, every other table on the sheet it names. Numbers and
+// dates are written as typed cells so they can be summed and filtered in Excel.
+type XLSX struct{}
+
+func (XLSX) ContentType() string {
+	return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+}
+
+func (XLSX) Extension() string {
+	return "xlsx"
+}
+
+func (XLSX) Render(w io.Writer, doc *Document) error {
+	file := xlsx.NewFile()
+	names := make(map[string]bool)
+
+	summarySheet := doc.Sheet
+	if summarySheet == "" {
+		summarySheet = "Summary"
+	}
+
+	// Group the tables by sheet, keeping the order in which sheets first appear
+	var order []string
+	bySheet := make(map[string][]Table)
+	for _, t := range doc.Tables {
+		sheet := t.Sheet
+		if sheet == "" {
+			sheet = t.Title
+		}
+		if _, ok := bySheet[sheet]; !ok && sheet != summarySheet {
+			order = append(order, sheet)
+		}
+		bySheet[sheet] = append(bySheet[sheet], t)
+	}
+
+	if len(doc.Summary) > 0 || len(bySheet[summarySheet]) > 0 || len(order) == 0 {
+		sheet, err := file.AddSheet(uniqueSheetName(names, summarySheet))
+		if err != nil {
+			return err
+		}
+		writeSummary(sheet, doc)
+		for _, t := range bySheet[summarySheet] {
+			writeTable(sheet, t, true)
+			sheet.AddRow() // Empty row
+		}
+		sheet.SetColWidth(1, 1, 28)
+		sheet.SetColWidth(2, 8, 16)
+	}
+
+	for _, name := range order {
+		sheet, err := file.AddSheet(uniqueSheetName(names, name))
+		if err != nil {
+			return err
+		}
+		tables := bySheet[name]
+		for i, t := range tables {
+			if i > 0 {
+				sheet.AddRow() // Empty row
+			}
+			writeTable(sheet, t, len(tables) > 1)
+		}
+		sheet.SetColWidth(1, 8, 16)
+	}
+
+	return file.Write(w)
+}
+
+func writeSummary(sheet *xlsx.Sheet, doc *Document) {
+	row := sheet.AddRow()
+	row.AddCell().Value = doc.Title
+	if doc.Subtitle != "" {
+		row.AddCell().Value = doc.Subtitle
+	}
+
+	if len(doc.Summary) == 0 {
+		sheet.AddRow() // Empty row
+		return
+	}
+
+	sheet.AddRow() // Empty row
+	setBold(sheet.AddRow().AddCell(), "Summary")
+	for _, m := range doc.Summary {
+		row = sheet.AddRow()
+		row.AddCell().Value = m.Label
+		setCell(row.AddCell(), m.Kind, m.Value)
+	}
+	sheet.AddRow() // Empty row
+}
+
+func writeTable(sheet *xlsx.Sheet, t Table, withTitle bool) {
+	if withTitle && t.Title != "" {
+		setBold(sheet.AddRow().AddCell(), t.Title)
+	}
+
+	header := sheet.AddRow()
+	for _, c := range t.Columns {
+		setBold(header.AddCell(), c.Title)
+	}
+
+	for _, values := range t.Rows {
+		row := sheet.AddRow()
+		for i, c := range t.Columns {
+			var value interface{}
+			if i < len(values) {
+				value = values[i]
+			}
+			setCell(row.AddCell(), c.Kind, value)
+		}
+	}
+}
+
+func setBold(cell *xlsx.Cell, value string) {
+	style := xlsx.NewStyle()
+	style.Font.Bold = true
+	style.ApplyFont = true
+	cell.Value = value
+	cell.SetStyle(style)
+}
+
+func setCell(cell *xlsx.Cell, kind Kind, value interface{}) {
+	switch v := value.(type) {
+	case nil:
+	case *time.Time:
+		if v != nil {
+			setCell(cell, kind, *v)
+		}
+	case time.Time:
+		switch kind {
+		case DateTime:
+			cell.SetDateTime(v)
+		case Month:
+			cell.SetDateWithOptions(v, xlsx.DateTimeOptions{Location: time.UTC, ExcelTimeFormat: "yyyy-mm"})
+		default:
+			cell.SetDate(v)
+		}
+	case int:
+		cell.SetInt(v)
+	case float64:
+		cell.SetFloatWithFormat(v, "0.00")
+	case string:
+		cell.Value = v
+	default:
+		cell.Value = fmt.Sprint(v)
+	}
+}
+
+// uniqueSheetName strips the characters Excel forbids in sheet names and
+// shortens the name to the 31 character limit, appending a counter on clashes.
+func uniqueSheetName(names map[string]bool, name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`:\/?*[]`, r) {
+			return '-'
+		}
+		return r
+	}, name)
+	if strings.TrimSpace(name) == "" {
+		name = "Sheet"
+	}
+
+	candidate := truncateRunes(name, 31)
+	for i := 2; names[strings.ToLower(candidate)]; i++ {
+		suffix := fmt.Sprintf(" (%d)", i)
+		candidate = truncateRunes(name, 31-len(suffix)) + suffix
+	}
+	names[strings.ToLower(candidate)] = true
+	return candidate
+}
+
+func truncateRunes(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) > max {
+		return string(runes[:max])
+	}
+	return s
+}
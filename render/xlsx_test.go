@@ -0,0 +1,95 @@
+package render
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/tealeg/xlsx/v3"
+)
+
+func TestUniqueSheetName(t *testing.T) {
+	long := strings.Repeat("a", 40)
+	tests := []struct {
+		taken []string
+		name  string
+		want  string
+	}{
+		{nil, "Workers", "Workers"},
+		{nil, "2026/05: fields?", "2026-05- fields-"},
+		{nil, "  ", "Sheet"},
+		{nil, long, long[:31]},
+		{[]string{"Workers"}, "Workers", "Workers (2)"},
+		{[]string{"workers", "Workers (2)"}, "Workers", "Workers (3)"},
+		{[]string{long[:31]}, long, long[:27] + " (2)"},
+		{nil, strings.Repeat("ж", 35), strings.Repeat("ж", 31)},
+	}
+	for _, tt := range tests {
+		names := make(map[string]bool)
+		for _, n := range tt.taken {
+			names[strings.ToLower(n)] = true
+		}
+		got := uniqueSheetName(names, tt.name)
+		if got != tt.want {
+			t.Errorf("uniqueSheetName(%v, %q) = %q, want %q", tt.taken, tt.name, got, tt.want)
+		}
+		if !names[strings.ToLower(got)] {
+			t.Errorf("uniqueSheetName(%v, %q) did not take %q", tt.taken, tt.name, got)
+		}
+	}
+}
+
+func TestXLSX(t *testing.T) {
+	doc := testDocument()
+	doc.Sheet = "Report"
+	doc.Tables[0].Sheet = "Workers"
+	doc.Tables[1].Sheet = "Workers"
+
+	var buf bytes.Buffer
+	if err := (XLSX{}).Render(&buf, doc); err != nil {
+		t.Fatal(err)
+	}
+	file, err := xlsx.OpenBinary(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sheets []string
+	for _, s := range file.Sheets {
+		sheets = append(sheets, s.Name)
+	}
+	if strings.Join(sheets, ",") != "Report,Workers" {
+		t.Fatalf("sheets are %v, want Report and Workers", sheets)
+	}
+
+	cells := []struct {
+		sheet     int
+		row, col  int
+		want      string
+		wantFloat float64
+	}{
+		{0, 0, 0, "Report", 0},
+		{0, 3, 0, "Hours", 0},
+		{0, 3, 1, "", 12.345},
+		{1, 0, 0, "Workers", 0},
+		{1, 1, 0, "Name", 0},
+		{1, 2, 0, "Ivan", 0},
+		{1, 2, 1, "", 8.5},
+		{1, 5, 0, "By field", 0},
+		{1, 7, 0, "North", 0},
+	}
+	for _, c := range cells {
+		cell, err := file.Sheets[c.sheet].Cell(c.row, c.col)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.want != "" && cell.Value != c.want {
+			t.Errorf("sheet %d cell %d,%d is %q, want %q", c.sheet, c.row, c.col, cell.Value, c.want)
+		}
+		if c.wantFloat != 0 {
+			if f, err := cell.Float(); err != nil || f != c.wantFloat {
+				t.Errorf("sheet %d cell %d,%d is %v (%v), want %v", c.sheet, c.row, c.col, f, err, c.wantFloat)
+			}
+		}
+	}
+}
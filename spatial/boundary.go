@@ -0,0 +1,220 @@
+// Package spatial validates field boundaries and computes their geometric
+// properties. Boundaries are GeoJSON Polygons or MultiPolygons in WGS84
+// longitude/latitude.
+package spatial
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geo"
+)
+
+// SquareMetersPerDecare converts areas to decares, the unit fields are measured in.
+const SquareMetersPerDecare = 1000
+
+// BoundaryError describes why a boundary is invalid. Location points at the
+// offending part, e.g. "polygon 2, ring 1", and is empty for the whole geometry.
+type BoundaryError struct {
+	Location string
+	Message  string
+}
+
+func (e *BoundaryError) Error() string {
+	if e.Location == "" {
+		return e.Message
+	}
+	return e.Location + ": " + e.Message
+}
+
+// ParseBoundary parses and validates a GeoJSON Polygon or MultiPolygon geometry.
+// Every ring must have at least four positions, be closed and must not cross itself.
+func ParseBoundary(raw json.RawMessage) (orb.MultiPolygon, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, &BoundaryError{Message: "coordinates are required"}
+	}
+
+	var geometry struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+	}
+	if err := json.Unmarshal(raw, &geometry); err != nil {
+		return nil, &BoundaryError{Message: "coordinates must be a GeoJSON geometry object"}
+	}
+
+	var polygons [][][][]float64
+	switch geometry.Type {
+	case "Polygon":
+		var polygon [][][]float64
+		if err := json.Unmarshal(geometry.Coordinates, &polygon); err != nil {
+			return nil, &BoundaryError{Message: "Polygon coordinates must be an array of rings of [longitude, latitude] positions"}
+		}
+		polygons = [][][][]float64{polygon}
+	case "MultiPolygon":
+		if err := json.Unmarshal(geometry.Coordinates, &polygons); err != nil {
+			return nil, &BoundaryError{Message: "MultiPolygon coordinates must be an array of polygons"}
+		}
+	default:
+		return nil, &BoundaryError{Message: fmt.Sprintf("geometry type must be Polygon or MultiPolygon, got %q", geometry.Type)}
+	}
+
+	if len(polygons) == 0 {
+		return nil, &BoundaryError{Message: "geometry has no polygons"}
+	}
+
+	boundary := make(orb.MultiPolygon, 0, len(polygons))
+	for i, rings := range polygons {
+		location := "polygon"
+		if geometry.Type == "MultiPolygon" {
+			location = fmt.Sprintf("polygon %d", i+1)
+		}
+		if len(rings) == 0 {
+			return nil, &BoundaryError{Location: location, Message: "polygon has no rings"}
+		}
+
+		polygon := make(orb.Polygon, 0, len(rings))
+		for j, positions := range rings {
+			ringLocation := fmt.Sprintf("%s, ring %d", location, j+1)
+			if j == 0 {
+				ringLocation = location + ", outer ring"
+			}
+			ring, err := parseRing(positions)
+			if err != nil {
+				return nil, &BoundaryError{Location: ringLocation, Message: err.Error()}
+			}
+			polygon = append(polygon, ring)
+		}
+		boundary = append(boundary, polygon)
+	}
+	return boundary, nil
+}
+
+func parseRing(positions [][]float64) (orb.Ring, error) {
+	if len(positions) < 4 {
+		return nil, fmt.Errorf("ring has %d positions, at least 4 are required", len(positions))
+	}
+
+	ring := make(orb.Ring, len(positions))
+	for i, p := range positions {
+		if len(p) < 2 {
+			return nil, fmt.Errorf("position %d must have a longitude and a latitude", i+1)
+		}
+		if math.IsNaN(p[0]) || p[0] < -180 || p[0] > 180 {
+			return nil, fmt.Errorf("position %d has longitude %g outside [-180, 180]", i+1, p[0])
+		}
+		if math.IsNaN(p[1]) || p[1] < -90 || p[1] > 90 {
+			return nil, fmt.Errorf("position %d has latitude %g outside [-90, 90]", i+1, p[1])
+		}
+		ring[i] = orb.Point{p[0], p[1]}
+	}
+
+	if first, last := ring[0], ring[len(ring)-1]; first != last {
+		return nil, fmt.Errorf("ring is not closed: first position %s differs from last position %s",
+			formatPoint(first), formatPoint(last))
+	}
+
+	if err := checkSelfIntersection(ring); err != nil {
+		return nil, err
+	}
+	if geo.Area(ring) == 0 {
+		return nil, fmt.Errorf("ring has no area")
+	}
+	return ring, nil
+}
+
+// checkSelfIntersection reports the first pair of ring edges that touch or
+// cross other than where consecutive edges meet.
+func checkSelfIntersection(ring orb.Ring) error {
+	// Repeated consecutive positions would form zero-length edges
+	points := orb.Ring{ring[0]}
+	indexes := []int{0}
+	for i := 1; i < len(ring); i++ {
+		if ring[i] != points[len(points)-1] {
+			points = append(points, ring[i])
+			indexes = append(indexes, i)
+		}
+	}
+
+	edges := len(points) - 1
+	for i := 0; i < edges; i++ {
+		for j := i + 1; j < edges; j++ {
+			// Consecutive edges share an endpoint, including the last and the first
+			if j == i+1 || (i == 0 && j == edges-1) {
+				if overlapping(points[i], points[i+1], points[j], points[j+1]) {
+					return edgeError(points, indexes, i, j, "doubles back over")
+				}
+				continue
+			}
+			if segmentsIntersect(points[i], points[i+1], points[j], points[j+1]) {
+				return edgeError(points, indexes, i, j, "crosses")
+			}
+		}
+	}
+	return nil
+}
+
+func edgeError(points orb.Ring, indexes []int, i, j int, verb string) error {
+	return fmt.Errorf("ring intersects itself: edge %s–%s (positions %d–%d) %s edge %s–%s (positions %d–%d)",
+		formatPoint(points[i]), formatPoint(points[i+1]), indexes[i]+1, indexes[i+1]+1, verb,
+		formatPoint(points[j]), formatPoint(points[j+1]), indexes[j]+1, indexes[j+1]+1)
+}
+
+func formatPoint(p orb.Point) string {
+	return fmt.Sprintf("[%g, %g]", p[0], p[1])
+}
+
+// orientation is positive when c lies left of the line a→b, negative when it
+// lies to the right and zero when the three points are collinear.
+func orientation(a, b, c orb.Point) float64 {
+	return (b[0]-a[0])*(c[1]-a[1]) - (b[1]-a[1])*(c[0]-a[0])
+}
+
+// onSegment tells whether c, collinear with a and b, lies within their bounding box.
+func onSegment(a, b, c orb.Point) bool {
+	return math.Min(a[0], b[0]) <= c[0] && c[0] <= math.Max(a[0], b[0]) &&
+		math.Min(a[1], b[1]) <= c[1] && c[1] <= math.Max(a[1], b[1])
+}
+
+func segmentsIntersect(a, b, c, d orb.Point) bool {
+	o1, o2 := orientation(a, b, c), orientation(a, b, d)
+	o3, o4 := orientation(c, d, a), orientation(c, d, b)
+	if ((o1 > 0 && o2 < 0) || (o1 < 0 && o2 > 0)) && ((o3 > 0 && o4 < 0) || (o3 < 0 && o4 > 0)) {
+		return true
+	}
+	return (o1 == 0 && onSegment(a, b, c)) || (o2 == 0 && onSegment(a, b, d)) ||
+		(o3 == 0 && onSegment(c, d, a)) || (o4 == 0 && onSegment(c, d, b))
+}
+
+// overlapping tells whether two edges sharing an endpoint run back along each
+// other, i.e. the ring makes a 180° turn.
+func overlapping(a, b, c, d orb.Point) bool {
+	if orientation(a, b, c) != 0 || orientation(a, b, d) != 0 {
+		return false
+	}
+	// Collinear: the edges overlap when either has an interior point on the other
+	shared, otherA, otherC := sharedEndpoint(a, b, c, d)
+	dx1, dy1 := otherA[0]-shared[0], otherA[1]-shared[1]
+	dx2, dy2 := otherC[0]-shared[0], otherC[1]-shared[1]
+	return dx1*dx2+dy1*dy2 > 0
+}
+
+func sharedEndpoint(a, b, c, d orb.Point) (shared, other1, other2 orb.Point) {
+	switch {
+	case a == c:
+		return a, b, d
+	case a == d:
+		return a, b, c
+	case b == c:
+		return b, a, d
+	default:
+		return b, a, c
+	}
+}
+
+// AreaDecares returns the geodesic area of a boundary in decares, with holes
+// subtracted.
+func AreaDecares(boundary orb.MultiPolygon) float64 {
+	return geo.Area(boundary) / SquareMetersPerDecare
+}
@@ -0,0 +1,138 @@
+package spatial
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestParseBoundary(t *testing.T) {
+	tests := []struct {
+		name     string
+		geometry string
+		polygons int
+		err      string
+	}{
+		{
+			name:     "polygon",
+			geometry: `{"type":"Polygon","coordinates":[[[25,42],[25.01,42],[25.01,42.01],[25,42.01],[25,42]]]}`,
+			polygons: 1,
+		},
+		{
+			name: "multipolygon with a hole",
+			geometry: `{"type":"MultiPolygon","coordinates":[
+				[[[25,42],[25.01,42],[25.01,42.01],[25,42.01],[25,42]],
+				 [[25.002,42.002],[25.004,42.002],[25.004,42.004],[25.002,42.002]]],
+				[[[26,42],[26.01,42],[26.01,42.01],[26,42]]]]}`,
+			polygons: 2,
+		},
+		{
+			name:     "repeated position",
+			geometry: `{"type":"Polygon","coordinates":[[[25,42],[25.01,42],[25.01,42],[25.01,42.01],[25,42]]]}`,
+			polygons: 1,
+		},
+		{name: "missing", geometry: ``, err: "coordinates are required"},
+		{name: "null", geometry: `null`, err: "coordinates are required"},
+		{name: "not an object", geometry: `[1, 2]`, err: "must be a GeoJSON geometry object"},
+		{name: "point", geometry: `{"type":"Point","coordinates":[25,42]}`, err: `got "Point"`},
+		{name: "bad polygon", geometry: `{"type":"Polygon","coordinates":[25,42]}`, err: "Polygon coordinates must be"},
+		{name: "no polygons", geometry: `{"type":"MultiPolygon","coordinates":[]}`, err: "geometry has no polygons"},
+		{name: "no rings", geometry: `{"type":"Polygon","coordinates":[]}`, err: "polygon: polygon has no rings"},
+		{
+			name:     "too few positions",
+			geometry: `{"type":"Polygon","coordinates":[[[25,42],[25.01,42],[25,42]]]}`,
+			err:      "polygon, outer ring: ring has 3 positions",
+		},
+		{
+			name:     "no latitude",
+			geometry: `{"type":"Polygon","coordinates":[[[25,42],[25.01],[25.01,42.01],[25,42]]]}`,
+			err:      "position 2 must have a longitude and a latitude",
+		},
+		{
+			name:     "longitude out of range",
+			geometry: `{"type":"Polygon","coordinates":[[[25,42],[181,42],[25.01,42.01],[25,42]]]}`,
+			err:      "position 2 has longitude 181",
+		},
+		{
+			name:     "latitude out of range",
+			geometry: `{"type":"Polygon","coordinates":[[[25,42],[25.01,-91],[25.01,42.01],[25,42]]]}`,
+			err:      "position 2 has latitude -91",
+		},
+		{
+			name:     "not closed",
+			geometry: `{"type":"Polygon","coordinates":[[[25,42],[25.01,42],[25.01,42.01],[25,42.01]]]}`,
+			err:      "ring is not closed: first position [25, 42] differs from last position [25, 42.01]",
+		},
+		{
+			name:     "bow tie",
+			geometry: `{"type":"Polygon","coordinates":[[[25,42],[25.01,42.01],[25.01,42],[25,42.01],[25,42]]]}`,
+			err:      "ring intersects itself: edge [25, 42]–[25.01, 42.01] (positions 1–2) crosses edge [25.01, 42]–[25, 42.01] (positions 3–4)",
+		},
+		{
+			name:     "doubles back",
+			geometry: `{"type":"Polygon","coordinates":[[[25,42],[25.02,42],[25.01,42],[25.01,42.01],[25,42]]]}`,
+			err:      "doubles back over",
+		},
+		{
+			name:     "touches itself",
+			geometry: `{"type":"Polygon","coordinates":[[[25,42],[25.02,42],[25.02,42.02],[25.01,42],[25,42.02],[25,42]]]}`,
+			err:      "ring intersects itself",
+		},
+		{
+			name:     "flat",
+			geometry: `{"type":"Polygon","coordinates":[[[25,42],[25,42],[25,42],[25,42]]]}`,
+			err:      "ring has no area",
+		},
+		{
+			name: "bad hole",
+			geometry: `{"type":"MultiPolygon","coordinates":[[[[25,42],[25.01,42],[25.01,42.01],[25,42]],
+				[[25.002,42.002],[25.004,42.002],[25.004,42.004]]]]}`,
+			err: "polygon 1, ring 2: ring has 3 positions",
+		},
+	}
+	for _, tt := range tests {
+		boundary, err := ParseBoundary(json.RawMessage(tt.geometry))
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: got error %v, want %q", tt.name, err, tt.err)
+			}
+			if _, ok := err.(*BoundaryError); err != nil && !ok {
+				t.Errorf("%s: error is %T, want *BoundaryError", tt.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if len(boundary) != tt.polygons {
+			t.Errorf("%s: got %d polygons, want %d", tt.name, len(boundary), tt.polygons)
+		}
+	}
+}
+
+func TestAreaDecares(t *testing.T) {
+	// Squares of about 100 m near the equator, 0.0009° on each side
+	square := `[[0,0],[0.0009,0],[0.0009,0.0009],[0,0.0009],[0,0]]`
+	other := `[[1,0],[1.0009,0],[1.0009,0.0009],[1,0.0009],[1,0]]`
+	hole := `[[0.0003,0.0003],[0.0006,0.0003],[0.0006,0.0006],[0.0003,0.0006],[0.0003,0.0003]]`
+	tests := []struct {
+		name     string
+		geometry string
+		want     float64
+	}{
+		{"square", `{"type":"Polygon","coordinates":[` + square + `]}`, 10.02},
+		{"with a hole", `{"type":"Polygon","coordinates":[` + square + `,` + hole + `]}`, 8.91},
+		{"two squares", `{"type":"MultiPolygon","coordinates":[[` + square + `],[` + other + `]]}`, 20.04},
+	}
+	for _, tt := range tests {
+		boundary, err := ParseBoundary(json.RawMessage(tt.geometry))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := AreaDecares(boundary); math.Abs(got-tt.want) > 0.05 {
+			t.Errorf("%s: area is %.2f decares, want %.2f", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package spatial
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/planar"
+)
+
+// Contains tells whether the point lies inside the boundary, outside its holes.
+// Points on an edge count as inside.
+func Contains(boundary orb.MultiPolygon, p orb.Point) bool {
+	return planar.MultiPolygonContains(boundary, p)
+}
+
+// Within tells whether every position of inner lies inside outer. It does not
+// catch edges that cut across a concave corner of outer, which is good enough
+// to reject coverage drawn on the wrong field.
+func Within(inner, outer orb.MultiPolygon) bool {
+	for _, polygon := range inner {
+		for _, ring := range polygon {
+			for _, p := range ring {
+				if !planar.MultiPolygonContains(outer, p) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// Intersects tells whether the bounding box of the boundary overlaps the given box.
+func Intersects(boundary orb.MultiPolygon, bbox orb.Bound) bool {
+	return boundary.Bound().Intersects(bbox)
+}
+
+// DistanceMeters returns how far the point is from the boundary, 0 when it is
+// inside. Distances are measured on a local flat projection around the point,
+// which is accurate to well under a percent for the tens of kilometres that
+// separate the fields of a farm.
+func DistanceMeters(boundary orb.MultiPolygon, p orb.Point) float64 {
+	if planar.MultiPolygonContains(boundary, p) {
+		return 0
+	}
+
+	metersPerDegLat := orb.EarthRadius * math.Pi / 180
+	metersPerDegLon := metersPerDegLat * math.Cos(p[1]*math.Pi/180)
+	project := func(q orb.Point) orb.Point {
+		return orb.Point{(q[0] - p[0]) * metersPerDegLon, (q[1] - p[1]) * metersPerDegLat}
+	}
+
+	best := math.Inf(1)
+	for _, polygon := range boundary {
+		for _, ring := range polygon {
+			for i := 0; i+1 < len(ring); i++ {
+				d := distanceToOrigin(project(ring[i]), project(ring[i+1]))
+				best = math.Min(best, d)
+			}
+		}
+	}
+	return best
+}
+
+// distanceToOrigin returns the distance from (0, 0) to the segment a–b.
+func distanceToOrigin(a, b orb.Point) float64 {
+	dx, dy := b[0]-a[0], b[1]-a[1]
+	t := 0.0
+	if length := dx*dx + dy*dy; length > 0 {
+		t = math.Max(0, math.Min(1, -(a[0]*dx+a[1]*dy)/length))
+	}
+	return math.Hypot(a[0]+t*dx, a[1]+t*dy)
+}
+
+// ParseBBox parses a "minLon,minLat,maxLon,maxLat" bounding box as used by the bbox query parameter.
+func ParseBBox(s string) (orb.Bound, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return orb.Bound{}, fmt.Errorf("bbox must be minLon,minLat,maxLon,maxLat")
+	}
+	var v [4]float64
+	for i, part := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return orb.Bound{}, fmt.Errorf("bbox value %q is not a number", part)
+		}
+		v[i] = f
+	}
+	if v[0] > v[2] || v[1] > v[3] {
+		return orb.Bound{}, fmt.Errorf("bbox minimum must not exceed its maximum")
+	}
+	return orb.Bound{Min: orb.Point{v[0], v[1]}, Max: orb.Point{v[2], v[3]}}, nil
+}
+
+// ParsePoint parses the lat and lon query parameters.
+func ParsePoint(lat, lon string) (orb.Point, error) {
+	y, err := strconv.ParseFloat(lat, 64)
+	if err != nil || y < -90 || y > 90 {
+		return orb.Point{}, fmt.Errorf("lat must be a number between -90 and 90")
+	}
+	x, err := strconv.ParseFloat(lon, 64)
+	if err != nil || x < -180 || x > 180 {
+		return orb.Point{}, fmt.Errorf("lon must be a number between -180 and 180")
+	}
+	return orb.Point{x, y}, nil
+}
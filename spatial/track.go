@@ -0,0 +1,109 @@
+package spatial
+
+import (
+	"math"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geo"
+	"github.com/paulmach/orb/planar"
+)
+
+const (
+	// maxCoverageCells bounds the grid CoveredAreaDecares rasterizes a field
+	// on, a few megabytes however large the field.
+	maxCoverageCells = 4_000_000
+
+	// maxPassGapMeters is the longest step between two fixes that is taken as
+	// worked ground. Longer jumps are GPS dropouts and are not swept.
+	maxPassGapMeters = 100
+)
+
+// Pass is a track driven with an implement of the given working width.
+type Pass struct {
+	Line        orb.LineString
+	WidthMeters float64
+}
+
+// LengthMeters returns the geodesic length of a track.
+func LengthMeters(line orb.LineString) float64 {
+	return geo.LengthHaversine(line)
+}
+
+// CoveredAreaDecares estimates how much of the boundary the passes swept,
+// counting ground covered by overlapping passes once. The boundary is laid on
+// a grid of cells a quarter of the narrowest working width across (coarser
+// for very large fields) and a cell counts as covered when its centre is
+// within half a working width of a pass.
+func CoveredAreaDecares(boundary orb.MultiPolygon, passes []Pass) float64 {
+	bound := boundary.Bound()
+	center := bound.Center()
+	metersPerDegLat := orb.EarthRadius * math.Pi / 180
+	metersPerDegLon := metersPerDegLat * math.Cos(center[1]*math.Pi/180)
+	project := func(p orb.Point) orb.Point {
+		return orb.Point{(p[0] - center[0]) * metersPerDegLon, (p[1] - center[1]) * metersPerDegLat}
+	}
+
+	projected := make(orb.MultiPolygon, len(boundary))
+	for i, polygon := range boundary {
+		projected[i] = make(orb.Polygon, len(polygon))
+		for j, ring := range polygon {
+			projected[i][j] = make(orb.Ring, len(ring))
+			for k, p := range ring {
+				projected[i][j][k] = project(p)
+			}
+		}
+	}
+	min, max := project(bound.Min), project(bound.Max)
+
+	narrowest := math.Inf(1)
+	for _, pass := range passes {
+		if pass.WidthMeters > 0 {
+			narrowest = math.Min(narrowest, pass.WidthMeters)
+		}
+	}
+	if math.IsInf(narrowest, 1) {
+		return 0
+	}
+	cell := math.Max(narrowest/4, math.Sqrt((max[0]-min[0])*(max[1]-min[1])/maxCoverageCells))
+	cols := int(math.Ceil((max[0]-min[0])/cell)) + 1
+	rows := int(math.Ceil((max[1]-min[1])/cell)) + 1
+
+	// 0: not swept yet, 1: swept inside the boundary, 2: swept outside it
+	cells := make([]uint8, cols*rows)
+	covered := 0
+	for _, pass := range passes {
+		half := pass.WidthMeters / 2
+		if half <= 0 {
+			continue
+		}
+		for i := 0; i+1 < len(pass.Line); i++ {
+			a, b := project(pass.Line[i]), project(pass.Line[i+1])
+			if math.Hypot(b[0]-a[0], b[1]-a[1]) > maxPassGapMeters {
+				continue
+			}
+
+			c0 := int(math.Max(0, math.Floor((math.Min(a[0], b[0])-half-min[0])/cell)))
+			c1 := int(math.Min(float64(cols-1), math.Ceil((math.Max(a[0], b[0])+half-min[0])/cell)))
+			r0 := int(math.Max(0, math.Floor((math.Min(a[1], b[1])-half-min[1])/cell)))
+			r1 := int(math.Min(float64(rows-1), math.Ceil((math.Max(a[1], b[1])+half-min[1])/cell)))
+			for r := r0; r <= r1; r++ {
+				for c := c0; c <= c1; c++ {
+					if cells[r*cols+c] != 0 {
+						continue
+					}
+					p := orb.Point{min[0] + (float64(c)+0.5)*cell, min[1] + (float64(r)+0.5)*cell}
+					if distanceToOrigin(orb.Point{a[0] - p[0], a[1] - p[1]}, orb.Point{b[0] - p[0], b[1] - p[1]}) > half {
+						continue
+					}
+					if planar.MultiPolygonContains(projected, p) {
+						cells[r*cols+c] = 1
+						covered++
+					} else {
+						cells[r*cols+c] = 2
+					}
+				}
+			}
+		}
+	}
+	return float64(covered) * cell * cell / SquareMetersPerDecare
+}
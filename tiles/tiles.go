@@ -0,0 +1,129 @@
+// Package tiles renders field boundaries as Mapbox Vector Tiles and caches the
+// rendered tiles in process memory.
+package tiles
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/encoding/mvt"
+	"github.com/paulmach/orb/geojson"
+	"github.com/paulmach/orb/maptile"
+	"github.com/paulmach/orb/simplify"
+)
+
+// ContentType is the media type of rendered tiles.
+const ContentType = "application/vnd.mapbox-vector-tile"
+
+// Layer is the name of the tile layer holding the fields.
+const Layer = "fields"
+
+// MaxZoom is the deepest zoom level served. Beyond it tiles are smaller than a
+// few metres, finer than any field boundary is measured.
+const MaxZoom = 22
+
+// buffer is how far, in tile extent units, geometry is kept beyond the tile
+// edges so polygons do not show seams where neighbouring tiles meet.
+const buffer = 64
+
+// Feature is a field boundary with the properties written to its tile feature.
+type Feature struct {
+	ID         int
+	Boundary   orb.MultiPolygon
+	Properties map[string]interface{}
+}
+
+// Render draws the features overlapping a tile. Boundaries are clipped to the
+// tile and simplified to the tile resolution, dropping those that shrink to
+// nothing at this zoom.
+func Render(tile maptile.Tile, features []Feature) ([]byte, error) {
+	bound := tile.Bound(buffer / float64(mvt.DefaultExtent))
+	collection := geojson.NewFeatureCollection()
+	for _, f := range features {
+		if !f.Boundary.Bound().Intersects(bound) {
+			continue
+		}
+		feature := geojson.NewFeature(f.Boundary.Clone())
+		feature.ID = f.ID
+		for key, value := range f.Properties {
+			feature.Properties[key] = value
+		}
+		collection.Append(feature)
+	}
+
+	layers := mvt.NewLayers(map[string]*geojson.FeatureCollection{Layer: collection})
+	layers.ProjectToTile(tile)
+	layers.Clip(orb.Bound{
+		Min: orb.Point{-buffer, -buffer},
+		Max: orb.Point{mvt.DefaultExtent + buffer, mvt.DefaultExtent + buffer},
+	})
+	layers.Simplify(simplify.DouglasPeucker(1.0))
+	layers.RemoveEmpty(1.0, 1.0)
+	return mvt.Marshal(layers)
+}
+
+// Cache keeps the most recently used tiles. Invalidate drops them all; a tile
+// rendered from data read before an invalidation is not stored.
+type Cache struct {
+	mu         sync.Mutex
+	size       int
+	tiles      map[maptile.Tile]*list.Element
+	order      *list.List // front is the most recently used
+	generation uint64
+}
+
+type cacheEntry struct {
+	tile maptile.Tile
+	data []byte
+}
+
+// NewCache creates a cache holding up to size tiles.
+func NewCache(size int) *Cache {
+	return &Cache{size: size, tiles: make(map[maptile.Tile]*list.Element), order: list.New()}
+}
+
+// Get returns the cached tile, if any, and the cache generation to pass to Put
+// when the tile has to be rendered.
+func (c *Cache) Get(tile maptile.Tile) ([]byte, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.tiles[tile]; ok {
+		c.order.MoveToFront(e)
+		return e.Value.(*cacheEntry).data, c.generation, true
+	}
+	return nil, c.generation, false
+}
+
+// Put stores a tile rendered during the given generation, unless the cache
+// has been invalidated since.
+func (c *Cache) Put(tile maptile.Tile, data []byte, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+	if e, ok := c.tiles[tile]; ok {
+		e.Value.(*cacheEntry).data = data
+		c.order.MoveToFront(e)
+		return
+	}
+	c.tiles[tile] = c.order.PushFront(&cacheEntry{tile: tile, data: data})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.tiles, oldest.Value.(*cacheEntry).tile)
+	}
+}
+
+// Invalidate drops every cached tile, e.g. after a field boundary changed.
+func (c *Cache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.tiles = make(map[maptile.Tile]*list.Element)
+	c.order.Init()
+}
@@ -0,0 +1,124 @@
+package tiles
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/encoding/mvt"
+	"github.com/paulmach/orb/maptile"
+)
+
+func TestCache(t *testing.T) {
+	a := maptile.New(1, 1, 1)
+	b := maptile.New(2, 1, 2)
+	c := maptile.New(3, 2, 3)
+
+	type step struct {
+		op   string // get, put, putStale or invalidate
+		tile maptile.Tile
+		hit  bool
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"miss then hit", []step{
+			{op: "get", tile: a}, {op: "put", tile: a}, {op: "get", tile: a, hit: true},
+		}},
+		{"oldest dropped", []step{
+			{op: "put", tile: a}, {op: "put", tile: b}, {op: "put", tile: c},
+			{op: "get", tile: a}, {op: "get", tile: b, hit: true}, {op: "get", tile: c, hit: true},
+		}},
+		{"use keeps a tile", []step{
+			{op: "put", tile: a}, {op: "put", tile: b}, {op: "get", tile: a, hit: true}, {op: "put", tile: c},
+			{op: "get", tile: a, hit: true}, {op: "get", tile: b},
+		}},
+		{"invalidate drops all", []step{
+			{op: "put", tile: a}, {op: "put", tile: b}, {op: "invalidate"},
+			{op: "get", tile: a}, {op: "get", tile: b}, {op: "put", tile: a}, {op: "get", tile: a, hit: true},
+		}},
+		{"stale tile not stored", []step{
+			{op: "putStale", tile: a}, {op: "get", tile: a},
+		}},
+	}
+	for _, tt := range tests {
+		cache := NewCache(2)
+		for i, s := range tt.steps {
+			switch s.op {
+			case "get":
+				data, _, ok := cache.Get(s.tile)
+				if ok != s.hit {
+					t.Errorf("%s: step %d: Get(%v) hit %v, want %v", tt.name, i+1, s.tile, ok, s.hit)
+				}
+				if ok && string(data) != fmt.Sprint(s.tile) {
+					t.Errorf("%s: step %d: Get(%v) = %q", tt.name, i+1, s.tile, data)
+				}
+			case "put":
+				_, generation, _ := cache.Get(s.tile)
+				cache.Put(s.tile, []byte(fmt.Sprint(s.tile)), generation)
+			case "putStale":
+				// Rendered from data read before a field changed
+				_, generation, _ := cache.Get(s.tile)
+				cache.Invalidate()
+				cache.Put(s.tile, []byte(fmt.Sprint(s.tile)), generation)
+			case "invalidate":
+				cache.Invalidate()
+			}
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	square := func(lon, lat, size float64) orb.MultiPolygon {
+		return orb.MultiPolygon{{{{lon, lat}, {lon + size, lat}, {lon + size, lat + size}, {lon, lat + size}, {lon, lat}}}}
+	}
+	tile := maptile.At(orb.Point{25.005, 42.005}, 14)
+	features := []Feature{
+		{ID: 1, Boundary: square(25, 42, 0.01), Properties: map[string]interface{}{"name": "North"}},
+		{ID: 2, Boundary: square(26, 43, 0.01)},         // elsewhere
+		{ID: 3, Boundary: square(25.004, 42.004, 1e-9)}, // smaller than a tile pixel
+	}
+
+	tests := []struct {
+		name string
+		tile maptile.Tile
+		ids  []int
+	}{
+		{"field in tile", tile, []int{1}},
+		{"zoomed out", maptile.At(orb.Point{25.005, 42.005}, 6), []int{1, 2}},
+		{"empty tile", maptile.At(orb.Point{0, 0}, 14), nil},
+	}
+	for _, tt := range tests {
+		data, err := Render(tt.tile, features)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		layers, err := mvt.Unmarshal(data)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		var ids []int
+		for _, l := range layers {
+			if l.Name != Layer {
+				t.Errorf("%s: layer is %q, want %q", tt.name, l.Name, Layer)
+			}
+			for _, f := range l.Features {
+				ids = append(ids, int(f.ID.(float64)))
+				if f.ID.(float64) == 1 && f.Properties["name"] != "North" {
+					t.Errorf("%s: feature 1 has properties %v", tt.name, f.Properties)
+				}
+			}
+		}
+		if len(ids) != len(tt.ids) {
+			t.Errorf("%s: got features %v, want %v", tt.name, ids, tt.ids)
+			continue
+		}
+		for i := range ids {
+			if ids[i] != tt.ids[i] {
+				t.Errorf("%s: got features %v, want %v", tt.name, ids, tt.ids)
+				break
+			}
+		}
+	}
+}